@@ -3,9 +3,14 @@ package main
 import (
 	"card-service/internal/api"
 	"card-service/internal/handlers"
+	"card-service/internal/models"
 	"card-service/internal/services"
+	"card-service/internal/simulator"
 	"card-service/internal/store"
 	"card-service/pkg/config"
+	"context"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -31,23 +36,174 @@ func main() {
 	defer db.Close()
 
 	// Initialize services
-	// Update the arguments to match the actual NewClient signature in your api package
-	apiClient := api.NewClient(cfg.CardAPIBaseURL, cfg.SecureAPIBaseURL, cfg.CardAPIKey)
-	customerService := services.NewCustomerService(db, apiClient, logger)
-	cardService := services.NewCardService(db, apiClient, logger)
-	webhookService := services.NewWebhookService(db, apiClient, logger)
+	// In sandbox mode the in-memory simulator stands in for the issuer
+	var apiClient api.Issuer
+	var issuerSimulator *simulator.Simulator
+	if cfg.SandboxMode {
+		issuerSimulator = simulator.New(simulator.Config{
+			WebhookURL:     cfg.SimulatorWebhookURL,
+			SigningKey:     cfg.WebhookSigningKey,
+			Latency:        time.Duration(cfg.SimulatorLatencyMs) * time.Millisecond,
+			FailurePercent: cfg.SimulatorFailurePercent,
+			OpeningBalance: cfg.SimulatorOpeningBalance,
+		}, logger)
+		apiClient = issuerSimulator
+	} else {
+		// Update the arguments to match the actual NewClient signature in your api package
+		apiClient = api.NewClient(cfg.CardAPIBaseURL, cfg.SecureAPIBaseURL, cfg.CardAPIKey, time.Duration(cfg.IssuerTimeoutMs)*time.Millisecond)
+	}
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		logger.Fatal("Failed to load timezone", zap.String("timezone", cfg.Timezone), zap.Error(err))
+	}
+	limitEvaluator := services.NewLimitEvaluator(db, location, logger)
+	ledgerService := services.NewLedgerService(db, logger)
+	holdLedger := services.NewHoldLedger(db, ledgerService, time.Duration(cfg.HoldExpiryDays)*24*time.Hour, logger)
+	depositService := services.NewDepositService(db, ledgerService, logger)
+	balanceBreaker := api.NewCircuitBreaker(cfg.BreakerFailures, time.Duration(cfg.BreakerCooldownSecs)*time.Second)
+	balanceProvider := services.NewBalanceProvider(apiClient, balanceBreaker, db, logger)
+	standInPolicy := services.NewStandInPolicy(cfg.StandInCaps, cfg.StandInDefaultCap, time.Duration(cfg.StandInMaxAgeMinutes)*time.Minute)
+	screeningService := services.NewScreeningService(db, services.ScreeningConfig{
+		ReviewScore: float64(cfg.ScreeningReviewScore) / 100,
+		BlockScore:  float64(cfg.ScreeningBlockScore) / 100,
+	}, logger)
+	if err := screeningService.Reload(context.Background()); err != nil {
+		logger.Error("Failed to load watchlists", zap.Error(err))
+	}
+	kycLimits := make(map[string]services.TierLimits)
+	for _, tier := range models.KYCTiers {
+		kycLimits[tier] = services.TierLimits{
+			MaxBalance: cfg.KYCMaxBalance[tier],
+			DailySpend: cfg.KYCDailySpend[tier],
+			MaxCards:   cfg.KYCMaxCards[tier],
+		}
+	}
+	kycService := services.NewKYCService(db, kycLimits, logger)
+	outboxService := services.NewOutboxService(db, services.OutboxConfig{
+		MaxAttempts: cfg.OutboxMaxAttempts,
+		Backoff:     time.Duration(cfg.OutboxBackoffSecs) * time.Second,
+		Secret:      cfg.OutboxSecret,
+	}, logger)
+	customerService := services.NewCustomerService(db, apiClient, ledgerService, screeningService, kycService, outboxService, logger)
+	cardService := services.NewCardService(db, apiClient, limitEvaluator, kycService, outboxService, logger)
+	travelNoticeService := services.NewTravelNoticeService(db, location, logger)
+	ruleService := services.NewRuleService(db, logger)
+	if err := ruleService.SeedDefaults(context.Background()); err != nil {
+		logger.Fatal("Failed to load authorization rules", zap.Error(err))
+	}
+	velocityChecker := services.NewVelocityChecker(db, services.VelocityConfig{
+		Window:        time.Duration(cfg.VelocityWindowMinutes) * time.Minute,
+		MaxCount:      cfg.VelocityMaxCount,
+		MaxDeclines:   cfg.VelocityMaxDeclines,
+		SmallAmount:   cfg.VelocitySmallAmount,
+		MaxMerchants:  cfg.VelocityMaxMerchants,
+		GeoJumpWindow: time.Duration(cfg.GeoJumpMinutes) * time.Minute,
+		Weights:       cfg.RiskWeights,
+		FlagScore:     cfg.RiskFlagScore,
+		DeclineScore:  cfg.RiskDeclineScore,
+	}, logger)
+	caseService := services.NewCaseService(db, logger)
+	monitoringService := services.NewMonitoringService(db, caseService, services.MonitoringConfig{
+		StructuringThreshold: cfg.StructuringThreshold,
+		StructuringMargin:    cfg.StructuringMarginPercent,
+		StructuringCount:     cfg.StructuringCount,
+		StructuringWindow:    time.Duration(cfg.StructuringDays) * 24 * time.Hour,
+		RapidWindow:          time.Duration(cfg.RapidMovementHours) * time.Hour,
+		RapidMinAmount:       cfg.RapidMovementMinAmount,
+		RapidOutflowPercent:  cfg.RapidOutflowPercent,
+		VolumeWindow:         24 * time.Hour,
+		VolumeBaselineDays:   cfg.VolumeBaselineDays,
+		VolumeMultiplier:     cfg.VolumeMultiplier,
+		VolumeMinAmount:      cfg.VolumeMinAmount,
+	}, logger)
+	webhookService := services.NewWebhookService(db, balanceProvider, standInPolicy, limitEvaluator, holdLedger, ledgerService, ruleService, velocityChecker, kycService, depositService, services.DecisionBudget{
+		Timeout:  time.Duration(cfg.DecisionBudgetMs) * time.Millisecond,
+		Fallback: cfg.DecisionFallback,
+	}, logger)
+
+	// Start background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go holdLedger.RunExpiry(ctx, time.Hour)
+	go ruleService.RunReload(ctx, time.Duration(cfg.RulesReloadSecs)*time.Second)
+	go monitoringService.RunMonitoring(ctx, time.Duration(cfg.MonitoringMinutes)*time.Minute)
+	go screeningService.RunRescreening(ctx, time.Duration(cfg.RescreenMinutes)*time.Minute)
+	go outboxService.RunDispatcher(ctx, time.Duration(cfg.OutboxIntervalSecs)*time.Second)
+	go cardService.RunControlsExpiry(ctx, time.Duration(cfg.ControlsExpirySecs)*time.Second)
 
 	// Initialize handlers
 	customerHandler := handlers.NewCustomerHandler(customerService, cfg.SettlementAccount, logger)
 	cardHandler := handlers.NewCardHandler(cardService, logger)
+	accountHandler := handlers.NewAccountHandler(ledgerService, depositService, logger)
 	webhookHandler := handlers.NewWebhookHandler(webhookService, logger, cfg.WebhookSigningKey)
+	ruleHandler := handlers.NewRuleHandler(ruleService, webhookService, logger)
+	caseHandler := handlers.NewCaseHandler(caseService, logger)
+	screeningHandler := handlers.NewScreeningHandler(screeningService, customerService, logger)
+	kycHandler := handlers.NewKYCHandler(kycService, logger)
+	outboxHandler := handlers.NewOutboxHandler(outboxService, logger)
+	travelNoticeHandler := handlers.NewTravelNoticeHandler(travelNoticeService, logger)
 
 	// Set up Gin router
 	r := gin.Default()
 	r.POST("/api/customers", customerHandler.CreateCustomer)
+	r.POST("/api/customers/business", customerHandler.CreateBusinessCustomer)
+	r.GET("/api/onboarding/:ref", customerHandler.GetOnboarding)
+	r.POST("/api/customers/:id/employee-cards", cardHandler.LinkEmployeeCard)
+	r.POST("/api/customers/:id/travel-notices", travelNoticeHandler.CreateNotice)
+	r.GET("/api/customers/:id/travel-notices", travelNoticeHandler.ListNotices)
+	r.DELETE("/api/customers/:id/travel-notices/:noticeId", travelNoticeHandler.CancelNotice)
+	r.GET("/api/customers/:id/kyc", kycHandler.GetKYC)
+	r.POST("/api/customers/:id/kyc/upgrades", kycHandler.RequestUpgrade)
+	r.POST("/api/customers/:id/kyc/upgrades/:verificationId/review", kycHandler.ReviewUpgrade)
 	r.POST("/api/cards", cardHandler.LinkCard)
 	r.POST("api/cards/:id/activate", cardHandler.ActivateCard)
+	r.POST("/api/cards/:id/freeze", cardHandler.FreezeCard)
+	r.POST("/api/cards/:id/unfreeze", cardHandler.UnfreezeCard)
+	r.POST("/api/cards/:id/terminate", cardHandler.TerminateCard)
+	r.POST("/api/cards/:id/replace", cardHandler.ReplaceCard)
+	r.PATCH("/api/cards/:id/controls", cardHandler.UpdateCardControls)
+	r.GET("/api/cards/:id/controls", cardHandler.GetCardControls)
+	r.GET("/api/cards/:id/controls/versions", cardHandler.ListCardControlsVersions)
+	r.POST("/api/cards/:id/temporary-block", cardHandler.BlockTemporarily)
+	r.DELETE("/api/cards/:id/temporary-block", cardHandler.LiftTemporaryBlock)
+	r.POST("/api/cards/:id/unlocks", cardHandler.UnlockChannel)
+	r.GET("/api/cards/:id/limits", cardHandler.GetSpendingLimits)
+	r.GET("/api/cards/:id/decisions", cardHandler.ListDecisions)
+	r.GET("/api/accounts/:id/ledger", accountHandler.GetLedger)
+	r.GET("/api/accounts/:id/deposits", accountHandler.ListDeposits)
+	r.GET("/api/admin/deposits/quarantine", accountHandler.ListQuarantinedDeposits)
+	r.POST("/api/admin/deposits/:id/review", accountHandler.ReviewDeposit)
 	r.POST("/webhooks", webhookHandler.HandleWebhook)
+	r.GET("/api/admin/webhooks", webhookHandler.ListWebhookEvents)
+	r.GET("/api/admin/webhooks/:id", webhookHandler.GetWebhookEvent)
+	r.POST("/api/admin/webhooks/:id/replay", webhookHandler.ReplayWebhookEvent)
+	r.GET("/api/admin/outbox", outboxHandler.ListOperations)
+	r.GET("/api/admin/outbox/:id", outboxHandler.GetOperation)
+	r.GET("/api/rules", ruleHandler.ListRules)
+	r.POST("/api/rules", ruleHandler.CreateRule)
+	r.POST("/api/rules/evaluate", ruleHandler.EvaluateRules)
+	r.PUT("/api/rules/:id", ruleHandler.UpdateRule)
+	r.GET("/api/rules/:id/versions", ruleHandler.GetRuleVersions)
+	r.POST("/api/rules/:id/promote", ruleHandler.PromoteShadow)
+	r.DELETE("/api/rules/:id/shadow", ruleHandler.DiscardShadow)
+	r.GET("/api/rules/shadow/report", ruleHandler.GetShadowReport)
+	r.GET("/api/rules/shadow/disagreements", ruleHandler.ListShadowDisagreements)
+	r.GET("/api/cases", caseHandler.ListCases)
+	r.GET("/api/cases/:id", caseHandler.GetCase)
+	r.POST("/api/cases/:id/status", caseHandler.UpdateStatus)
+	r.POST("/api/cases/:id/notes", caseHandler.AddNote)
+	r.GET("/api/screening/lists", screeningHandler.ListWatchlists)
+	r.POST("/api/screening/lists/:source", screeningHandler.ImportWatchlist)
+	r.GET("/api/screening/screenings", screeningHandler.ListScreenings)
+	r.GET("/api/screening/screenings/:id", screeningHandler.GetScreening)
+	r.POST("/api/screening/screenings/:id/review", screeningHandler.ReviewScreening)
+	if issuerSimulator != nil {
+		sandboxHandler := handlers.NewSandboxHandler(issuerSimulator, logger)
+		r.POST("/api/sandbox/accounts/:id/deposits", sandboxHandler.Deposit)
+		r.POST("/api/sandbox/cards/:id/authorizations", sandboxHandler.Authorize)
+		r.POST("/api/sandbox/faults", sandboxHandler.InjectFault)
+		r.DELETE("/api/sandbox/faults", sandboxHandler.ClearFaults)
+	}
 	// Start server
 	logger.Info("Starting server", zap.String("port", cfg.Port))
 	if err := r.Run(":" + cfg.Port); err != nil {
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerIgnoresCallerContext(t *testing.T) {
+	errIssuer := errors.New("issuer unavailable")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		err   error
+		state string // State after two failing calls with a threshold of two
+	}{
+		{name: "issuer error", ctx: context.Background(), err: errIssuer, state: CircuitOpen},
+		{name: "issuer timeout", ctx: context.Background(), err: fmt.Errorf("failed to send request: %w", context.DeadlineExceeded), state: CircuitOpen},
+		{name: "caller cancelled", ctx: cancelled, err: fmt.Errorf("failed to send request: %w", context.Canceled), state: CircuitClosed},
+		{name: "caller deadline", ctx: expired, err: fmt.Errorf("failed to send request: %w", context.DeadlineExceeded), state: CircuitClosed},
+		{name: "issuer error after caller deadline", ctx: expired, err: errIssuer, state: CircuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := NewCircuitBreaker(2, time.Minute)
+			for i := 0; i < 2; i++ {
+				if err := breaker.Execute(tt.ctx, func() error { return tt.err }); !errors.Is(err, tt.err) {
+					t.Fatalf("Execute error = %v; want %v", err, tt.err)
+				}
+			}
+			if state := breaker.State(); state != tt.state {
+				t.Errorf("state = %s; want %s", state, tt.state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerTrialCutShortByCaller(t *testing.T) {
+	breaker := NewCircuitBreaker(1, 0)
+	breaker.Execute(context.Background(), func() error { return errors.New("issuer unavailable") })
+
+	// The trial call is abandoned by its caller, so the next call is the trial
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.Execute(ctx, func() error { return ctx.Err() })
+	if state := breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("state after abandoned trial = %s; want %s", state, CircuitHalfOpen)
+	}
+	if err := breaker.Execute(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("trial call rejected: %v", err)
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Errorf("state after successful trial = %s; want %s", state, CircuitClosed)
+	}
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -18,15 +20,16 @@ type Client struct {
 	logger        *zap.Logger
 }
 
-// create a new client API
-func NewClient(baseURL, secureBaseURL, apiKey string) *Client {
+// create a new client API. Requests are bounded by timeout as well as by the
+// context passed to each call.
+func NewClient(baseURL, secureBaseURL, apiKey string, timeout time.Duration) *Client {
 	logger := zap.NewExample()
 	logger.Info("Initializing API client", zap.String("baseURL", baseURL), zap.String("secureBaseURL", secureBaseURL), zap.String("apiKeyPrefix", apiKey[:4]))
 	return &Client{
 		baseURL:       baseURL,
 		secureBaseURL: secureBaseURL,
 		apiKey:        apiKey,
-		client:        &http.Client{},
+		client:        &http.Client{Timeout: timeout},
 		logger:        logger,
 	}
 }
@@ -47,11 +50,39 @@ type IndividualIdentity struct {
 	ID             string `json:"id"`
 	IssuingCountry string `json:"issuingCountry"`
 }
-type CustomerClaims struct {
+
+// BusinessInformation is the company registration of a business customer.
+type BusinessInformation struct {
+	Name               string `json:"name"` // Registered name
+	TradingName        string `json:"tradingName,omitempty"`
+	Email              string `json:"email"`
+	PhoneNumber        string `json:"phoneNumber"`
+	RegistrationType   string `json:"registrationType"` // e.g. rc, bn, it
+	RegistrationNumber string `json:"registrationNumber"`
+	IncorporationDate  string `json:"incorporationDate"`
+	Country            string `json:"country"`
+	Industry           string `json:"industry,omitempty"`
+	Address            string `json:"address"`
+}
+
+// BusinessOfficer is a director or beneficial owner of a business customer,
+// identified like an individual customer.
+type BusinessOfficer struct {
+	Role                  string                `json:"role"` // director or beneficial-owner
+	OwnershipPercent      int                   `json:"ownershipPercent,omitempty"`
 	IndividualInformation IndividualInformation `json:"individualInformation"`
 	IndividualIdentity    IndividualIdentity    `json:"individualIdentity"`
 }
 
+// CustomerClaims holds the individual claims of an individual customer or the
+// business claims of a business customer.
+type CustomerClaims struct {
+	IndividualInformation *IndividualInformation `json:"individualInformation,omitempty"`
+	IndividualIdentity    *IndividualIdentity    `json:"individualIdentity,omitempty"`
+	BusinessInformation   *BusinessInformation   `json:"businessInformation,omitempty"`
+	BusinessOfficers      []BusinessOfficer      `json:"businessOfficers,omitempty"`
+}
+
 //create customer request struct
 
 type CreateCustomerRequest struct {
@@ -83,7 +114,7 @@ type CreateCustomerResponse struct {
 }
 
 // create customer sends a request to create a customer
-func (c *Client) CreateCustomer(req CreateCustomerRequest) (string, error) {
+func (c *Client) CreateCustomer(ctx context.Context, req CreateCustomerRequest) (string, error) {
 	// marshal the request payload to json
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -91,13 +122,14 @@ func (c *Client) CreateCustomer(req CreateCustomerRequest) (string, error) {
 		return "", err
 	}
 	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/customers", bytes.NewBuffer(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/customers", bytes.NewBuffer(body))
 	if err != nil {
 		c.logger.Error("Failed to create CreateCustomer HTTP request", zap.Error(err))
 		return "", err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 	httpReq.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(httpReq)
 
 	// Log request details
 	c.logger.Info("Sending CreateCustomer request",
@@ -179,19 +211,20 @@ type CreateSubAccountResponse struct {
 }
 
 // create sub account sends a request to create a sub account
-func (c *Client) CreateSubAccount(req CreateSubAccountRequest) (string, []DepositChannel, error) {
+func (c *Client) CreateSubAccount(ctx context.Context, req CreateSubAccountRequest) (string, []DepositChannel, error) {
 	//marshal the payload to json
 	body, err := json.Marshal(req)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/accounts", bytes.NewBuffer(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/accounts", bytes.NewBuffer(body))
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 	httpReq.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(httpReq)
 
 	//send request
 	resp, err := c.client.Do(httpReq)
@@ -294,7 +327,7 @@ type ActivateCardResponse struct {
 	Message string `json:"message"` // Response message
 }
 
-func (c *Client) LinkCard(req LinkCardRequest) (LinkCardResponse, error) {
+func (c *Client) LinkCard(ctx context.Context, req LinkCardRequest) (LinkCardResponse, error) {
 	var response LinkCardResponse
 
 	if req.Pan == "" || req.Customer == "" {
@@ -307,7 +340,7 @@ func (c *Client) LinkCard(req LinkCardRequest) (LinkCardResponse, error) {
 		return response, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	//create request
-	httpReq, err := http.NewRequest("POST", c.secureBaseURL+"/cards/link", bytes.NewBuffer(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.secureBaseURL+"/cards/link", bytes.NewBuffer(body))
 	if err != nil {
 		c.logger.Error("failed to create LinkCard HTTP request", zap.Error(err))
 		return response, fmt.Errorf("failed to create Linkcard request: %w", err)
@@ -315,6 +348,7 @@ func (c *Client) LinkCard(req LinkCardRequest) (LinkCardResponse, error) {
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 	httpReq.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(httpReq)
 
 	c.logger.Info("Sending LinkCard request",
 		zap.String("url", httpReq.URL.String()),
@@ -377,7 +411,7 @@ func (c *Client) LinkCard(req LinkCardRequest) (LinkCardResponse, error) {
 	return response, nil
 }
 
-func (c *Client) ActivateCard(CardID string, req ActivateCardRequest) (ActivateCardResponse, error) {
+func (c *Client) ActivateCard(ctx context.Context, CardID string, req ActivateCardRequest) (ActivateCardResponse, error) {
 	var response ActivateCardResponse
 	if CardID == "" || req.Cvv == "" || req.Pin == "" {
 		c.logger.Error("Invalid ActivateCard request",
@@ -393,13 +427,14 @@ func (c *Client) ActivateCard(CardID string, req ActivateCardRequest) (ActivateC
 		return response, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	//create request
-	httpReq, err := http.NewRequest("POST", c.secureBaseURL+"/cards/"+CardID+"/activate", bytes.NewBuffer(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.secureBaseURL+"/cards/"+CardID+"/activate", bytes.NewBuffer(body))
 	if err != nil {
 		c.logger.Error("fialed to create activate card HTTP request", zap.Error(err))
 		return response, fmt.Errorf("failed to create ActivateCard request: %w", err)
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 	httpReq.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(httpReq)
 	c.logger.Info("Sending Activate Card request",
 		zap.String("url", httpReq.URL.String()),
 		zap.String("body", string(body)),
@@ -468,14 +503,14 @@ func (c *Client) ActivateCard(CardID string, req ActivateCardRequest) (ActivateC
 	return response, nil
 }
 
-func (c *Client) GetAccountBalance(accountID string) (GetAccountBalanceResponse, error) {
+func (c *Client) GetAccountBalance(ctx context.Context, accountID string) (GetAccountBalanceResponse, error) {
 	var response GetAccountBalanceResponse
 	if accountID == "" {
 		c.logger.Error("Invalid GetAccountBalance request", zap.String("accountID", accountID))
 		return response, fmt.Errorf("accountID is required")
 	}
 
-	httpReq, err := http.NewRequest("GET", c.baseURL+"/accounts/"+accountID+"/balance", nil)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/accounts/"+accountID+"/balance", nil)
 	if err != nil {
 		return response, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -534,3 +569,182 @@ func (c *Client) GetAccountBalance(accountID string) (GetAccountBalanceResponse,
 
 	return response, nil
 }
+
+type UpdateCardStatusRequest struct {
+	Status string `json:"status"`           // Status to move the card to: active, frozen or terminated
+	Reason string `json:"reason,omitempty"` // Why the status changed, e.g. lost or stolen
+}
+
+type UpdateCardStatusResponse struct {
+	Code string `json:"code"`
+	Data struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	} `json:"data"`
+}
+
+// UpdateCardStatus freezes, unfreezes or terminates a card.
+func (c *Client) UpdateCardStatus(ctx context.Context, cardID string, req UpdateCardStatusRequest) (UpdateCardStatusResponse, error) {
+	var response UpdateCardStatusResponse
+	if cardID == "" || req.Status == "" {
+		c.logger.Error("Invalid UpdateCardStatus request", zap.String("cardID", cardID), zap.String("status", req.Status))
+		return response, fmt.Errorf("cardID and status are required")
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return response, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "PATCH", c.baseURL+"/cards/"+cardID, bytes.NewBuffer(body))
+	if err != nil {
+		return response, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(httpReq)
+
+	c.logger.Info("Sending UpdateCardStatus request",
+		zap.String("url", httpReq.URL.String()),
+		zap.String("status", req.Status),
+		zap.String("authHeader", "Bearer "+c.apiKey[:4]+"..."),
+	)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		c.logger.Error("Failed to send request", zap.Error(err))
+		return response, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.logger.Error("Failed to read response body", zap.Error(err))
+		return response, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var allaweeErr AllaweeError
+		if err := json.Unmarshal(respBody, &allaweeErr); err == nil && allaweeErr.Code != "" {
+			c.logger.Error("UpdateCardStatus request failed",
+				zap.Int("status", resp.StatusCode),
+				zap.String("code", allaweeErr.Code),
+				zap.String("message", allaweeErr.Message),
+			)
+			return response, fmt.Errorf("allawee error: %s - %s", allaweeErr.Code, allaweeErr.Message)
+		}
+		c.logger.Error("UpdateCardStatus request failed",
+			zap.Int("status", resp.StatusCode),
+			zap.String("response", string(respBody)),
+		)
+		return response, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		c.logger.Error("Failed to unmarshal response", zap.Error(err))
+		return response, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.Code != "success" {
+		c.logger.Error("Invalid response", zap.String("response", string(respBody)))
+		return response, fmt.Errorf("request failed: %s", string(respBody))
+	}
+	return response, nil
+}
+
+type UpdateCardControlsRequest struct {
+	Controls CardControls `json:"controls"` // Controls replacing the current ones of the card
+}
+
+type UpdateCardControlsResponse struct {
+	Code string `json:"code"`
+	Data struct {
+		ID       string       `json:"id"`
+		Controls CardControls `json:"controls"`
+	} `json:"data"`
+}
+
+// UpdateCardControls replaces the controls of a linked card.
+func (c *Client) UpdateCardControls(ctx context.Context, cardID string, req UpdateCardControlsRequest) (UpdateCardControlsResponse, error) {
+	var response UpdateCardControlsResponse
+	if cardID == "" {
+		c.logger.Error("Invalid UpdateCardControls request", zap.String("cardID", cardID))
+		return response, fmt.Errorf("cardID is required")
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return response, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "PATCH", c.baseURL+"/cards/"+cardID+"/controls", bytes.NewBuffer(body))
+	if err != nil {
+		return response, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	setIdempotencyKey(httpReq)
+
+	c.logger.Info("Sending UpdateCardControls request",
+		zap.String("url", httpReq.URL.String()),
+		zap.String("controls", fmt.Sprintf("%+v", req.Controls)),
+		zap.String("authHeader", "Bearer "+c.apiKey[:4]+"..."),
+	)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		c.logger.Error("Failed to send request", zap.Error(err))
+		return response, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.logger.Error("Failed to read response body", zap.Error(err))
+		return response, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var allaweeErr AllaweeError
+		if err := json.Unmarshal(respBody, &allaweeErr); err == nil && allaweeErr.Code != "" {
+			c.logger.Error("UpdateCardControls request failed",
+				zap.Int("status", resp.StatusCode),
+				zap.String("code", allaweeErr.Code),
+				zap.String("message", allaweeErr.Message),
+			)
+			return response, fmt.Errorf("allawee error: %s - %s", allaweeErr.Code, allaweeErr.Message)
+		}
+		c.logger.Error("UpdateCardControls request failed",
+			zap.Int("status", resp.StatusCode),
+			zap.String("response", string(respBody)),
+		)
+		return response, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		c.logger.Error("Failed to unmarshal response", zap.Error(err))
+		return response, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.Code != "success" {
+		c.logger.Error("Invalid response", zap.String("response", string(respBody)))
+		return response, fmt.Errorf("request failed: %s", string(respBody))
+	}
+	return response, nil
+}
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey returns a context whose issuer requests carry key in the
+// Idempotency-Key header, so the issuer applies a retried request once.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// IdempotencyKey returns the idempotency key set on ctx, if any.
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContext{}).(string)
+	return key
+}
+
+func setIdempotencyKey(req *http.Request) {
+	if key := IdempotencyKey(req.Context()); key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+}
@@ -0,0 +1,18 @@
+package api
+
+import "context"
+
+// Issuer is the card issuer the service creates customers, accounts and cards
+// with. Client calls the Allawee API; the simulator package stands in for it
+// in sandbox mode.
+type Issuer interface {
+	CreateCustomer(ctx context.Context, req CreateCustomerRequest) (string, error)
+	CreateSubAccount(ctx context.Context, req CreateSubAccountRequest) (string, []DepositChannel, error)
+	LinkCard(ctx context.Context, req LinkCardRequest) (LinkCardResponse, error)
+	ActivateCard(ctx context.Context, cardID string, req ActivateCardRequest) (ActivateCardResponse, error)
+	UpdateCardStatus(ctx context.Context, cardID string, req UpdateCardStatusRequest) (UpdateCardStatusResponse, error)
+	UpdateCardControls(ctx context.Context, cardID string, req UpdateCardControlsRequest) (UpdateCardControlsResponse, error)
+	GetAccountBalance(ctx context.Context, accountID string) (GetAccountBalanceResponse, error)
+}
+
+var _ Issuer = (*Client)(nil)
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CardAcceptor holds the parts of an ISO 8583 card acceptor name/location field.
+type CardAcceptor struct {
+	Name    string
+	City    string
+	State   string
+	Country string // ISO 3166 alpha-2 country code
+}
+
+const (
+	acceptorNameEnd  = 23
+	acceptorCityEnd  = 36
+	acceptorStateEnd = 38
+	acceptorFieldLen = 40
+)
+
+var (
+	fieldSeparator = regexp.MustCompile(`\s{2,}`)
+	countryCode    = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// ParseCardAcceptorNameLocation splits a card acceptor name/location field.
+// Fields of 40 characters follow the fixed-width ISO 8583 layout: name (23),
+// city (13), state (2) and country (2). Shorter fields are split on runs of
+// spaces, taking a trailing two-letter code as the country. The country is
+// empty unless it is a two-letter code.
+func ParseCardAcceptorNameLocation(value string) CardAcceptor {
+	if len(value) >= acceptorFieldLen {
+		acceptor := CardAcceptor{
+			Name:  strings.TrimSpace(value[:acceptorNameEnd]),
+			City:  strings.TrimSpace(value[acceptorNameEnd:acceptorCityEnd]),
+			State: strings.TrimSpace(value[acceptorCityEnd:acceptorStateEnd]),
+		}
+		if country := strings.ToUpper(value[acceptorStateEnd:acceptorFieldLen]); countryCode.MatchString(country) {
+			acceptor.Country = country
+		}
+		return acceptor
+	}
+
+	parts := fieldSeparator.Split(strings.TrimSpace(value), -1)
+	var acceptor CardAcceptor
+	if len(parts) > 1 && countryCode.MatchString(strings.ToUpper(parts[len(parts)-1])) {
+		acceptor.Country = strings.ToUpper(parts[len(parts)-1])
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) > 0 {
+		acceptor.Name = parts[0]
+	}
+	if len(parts) > 1 {
+		acceptor.City = parts[1]
+	}
+	return acceptor
+}
+
+// TransactionCountry returns the country a transaction was made in: the
+// explicit merchant country when the network sends one, else the country of
+// the card acceptor name/location field. It is empty when neither has one.
+func TransactionCountry(data NetworkData) string {
+	if country := strings.ToUpper(strings.TrimSpace(data.MerchantCountry)); countryCode.MatchString(country) {
+		return country
+	}
+	return ParseCardAcceptorNameLocation(data.CardAcceptorNameLocation).Country
+}
@@ -0,0 +1,27 @@
+package api
+
+import "testing"
+
+func TestParseCardAcceptorNameLocation(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  CardAcceptor
+	}{
+		{name: "fixed width", value: "SHOPRITE LEKKI         LAGOS        LANG", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS", State: "LA", Country: "NG"}},
+		{name: "fixed width lower case country", value: "SHOPRITE LEKKI         LAGOS        LAng", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS", State: "LA", Country: "NG"}},
+		{name: "fixed width numeric country", value: "SHOPRITE LEKKI         LAGOS        LA56", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS", State: "LA"}},
+		{name: "fixed width half country", value: "SHOPRITE LEKKI         LAGOS        LAN ", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS", State: "LA"}},
+		{name: "fixed width blank country", value: "SHOPRITE LEKKI         LAGOS        LA  ", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS", State: "LA"}},
+		{name: "split", value: "SHOPRITE LEKKI  LAGOS  NG", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS", Country: "NG"}},
+		{name: "split without country", value: "SHOPRITE LEKKI  LAGOS", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS"}},
+		{name: "split numeric country", value: "SHOPRITE LEKKI  LAGOS  566", want: CardAcceptor{Name: "SHOPRITE LEKKI", City: "LAGOS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseCardAcceptorNameLocation(tt.value); got != tt.want {
+				t.Errorf("ParseCardAcceptorNameLocation(%q) = %+v; want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+)
+
+// WebhookSignature returns the signature Allawee sends with a webhook in the
+// Allawee-Signature header: the hex HMAC-SHA512 of the body under the
+// signing key.
+func WebhookSignature(signingKey string, body []byte) string {
+	hash := hmac.New(sha512.New, []byte(signingKey))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature is the signature of body
+// under the signing key. The comparison takes constant time.
+func VerifyWebhookSignature(signingKey string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(WebhookSignature(signingKey, body)))
+}
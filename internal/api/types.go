@@ -1,13 +1,50 @@
 package api
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type CardControls struct {
-	AllowedChannels   []string        `json:"allowedChannels" bson:"allowedChannels"`
-	BlockedChannels   []string        `json:"blockedChannels" bson:"blockedChannels"`
-	AllowedMerchants  []string        `json:"allowedMerchants" bson:"allowedMerchants"`
-	BlockedMerchants  []string        `json:"blockedMerchants" bson:"blockedMerchants"`
-	AllowedCategories []string        `json:"allowedCategories" bson:"allowedCategories"`
-	BlockedCategories []string        `json:"blockedCategories" bson:"blockedCategories"`
-	SpendingLimits    []SpendingLimit `json:"spendingLimits" bson:"spendingLimits"`
+	AllowedChannels   []string         `json:"allowedChannels" bson:"allowedChannels"`
+	BlockedChannels   []string         `json:"blockedChannels" bson:"blockedChannels"`
+	AllowedMerchants  []string         `json:"allowedMerchants" bson:"allowedMerchants"`
+	BlockedMerchants  []string         `json:"blockedMerchants" bson:"blockedMerchants"`
+	AllowedCategories []string         `json:"allowedCategories" bson:"allowedCategories"`
+	BlockedCategories []string         `json:"blockedCategories" bson:"blockedCategories"`
+	SpendingLimits    []SpendingLimit  `json:"spendingLimits" bson:"spendingLimits"`
+	AllowedCountries  []string         `json:"allowedCountries,omitempty" bson:"allowedCountries,omitempty"` // ISO 3166 alpha-2 codes
+	BlockedCountries  []string         `json:"blockedCountries,omitempty" bson:"blockedCountries,omitempty"`
+	Schedule          *ControlSchedule `json:"schedule,omitempty" bson:"schedule,omitempty"`
+	TemporaryBlock    *TemporaryBlock  `json:"temporaryBlock,omitempty" bson:"temporaryBlock,omitempty"`
+	Unlocks           []ChannelUnlock  `json:"unlocks,omitempty" bson:"unlocks,omitempty"`
+}
+
+// ControlSchedule limits spending to days of the week and hours of the day
+// in the cardholder's timezone.
+type ControlSchedule struct {
+	Timezone string      `json:"timezone,omitempty" bson:"timezone,omitempty"` // IANA timezone; the service timezone when empty
+	Days     []string    `json:"days,omitempty" bson:"days,omitempty"`         // e.g. "mon", "sat"; every day when empty
+	Hours    []HourRange `json:"hours,omitempty" bson:"hours,omitempty"`       // Every hour when empty
+}
+
+// HourRange is a range of the day, e.g. 09:00 to 17:30. A range whose end
+// is before its start runs past midnight.
+type HourRange struct {
+	From string `json:"from" bson:"from"` // Inclusive, HH:MM
+	To   string `json:"to" bson:"to"`     // Exclusive, HH:MM
+}
+
+// TemporaryBlock declines every authorization until it ends.
+type TemporaryBlock struct {
+	Until  time.Time `json:"until" bson:"until"`
+	Reason string    `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// ChannelUnlock allows a channel the controls otherwise deny until it ends.
+type ChannelUnlock struct {
+	Channel string    `json:"channel" bson:"channel"`
+	Until   time.Time `json:"until" bson:"until"`
 }
 
 type SpendingLimit struct {
@@ -24,9 +61,12 @@ type CardDetails struct {
 	Expiry         string `json:"expiry" bson:"expiry"`
 	CardHolderName string `json:"cardHolderName" bson:"cardHolderName"`
 }
+
+// WebhookEvent is the envelope of every webhook sent by the issuer. Data is kept
+// raw and decoded into the typed payload registered for Event.
 type WebhookEvent struct {
-	Event    string      `json:"event"`
-	Data     interface{} `json:"data"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data"`
 	Metadata struct {
 		SentAt string `json:"sentAt"`
 		Event  string `json:"event"`
@@ -35,10 +75,13 @@ type WebhookEvent struct {
 type NetworkData struct {
 	CardAcceptorNameLocation string `json:"cardAcceptorNameLocation" bson:"cardAcceptorNameLocation"`
 	TerminalID               string `json:"terminalId" bson:"terminalId"`
-	Network                  string `json:"network" bson:"network"`     // Network used for the transaction (e.g., "Visa", "Mastercard")
-	Reference                string `json:"reference" bson:"reference"` // Reference number for the transaction
-	RRN                      string `json:"rrn" bson:"rrn"`             // Retrieval Reference Number
-	STAN                     string `json:"stan" bson:"stan"`           // System Trace Audit Number
+	Network                  string `json:"network" bson:"network"`                                     // Network used for the transaction (e.g., "Visa", "Mastercard")
+	Reference                string `json:"reference" bson:"reference"`                                 // Reference number for the transaction
+	RRN                      string `json:"rrn" bson:"rrn"`                                             // Retrieval Reference Number
+	STAN                     string `json:"stan" bson:"stan"`                                           // System Trace Audit Number
+	MCC                      string `json:"mcc" bson:"mcc"`                                             // Merchant Category Code
+	MerchantID               string `json:"merchantId" bson:"merchantId"`                               // Card acceptor ID of the merchant
+	MerchantCountry          string `json:"merchantCountry,omitempty" bson:"merchantCountry,omitempty"` // ISO 3166 alpha-2 country of the merchant, when the network sends it
 }
 type TransactionEvent struct {
 	ID            string      `json:"id"`
@@ -55,9 +98,9 @@ type TransactionEvent struct {
 }
 type AuthorizationRequestEvent struct {
 	ID            string      `json:"id" bson:"id"`
-	CardID        string      `json:"cardId" bson:"cardId"`
-	Authorization string      `json:"authorizationId" bson:"authorizationId"`
-	CustomerID    string      `json:"customerId" bson:"customerId"`
+	CardID        string      `json:"card" bson:"cardId"`
+	Authorization string      `json:"authorization,omitempty" bson:"authorizationId"`
+	CustomerID    string      `json:"customer" bson:"customerId"`
 	Amount        int64       `json:"amount" bson:"amount"`
 	Currency      string      `json:"currency" bson:"currency"`
 	Type          string      `json:"type" bson:"type"` // Transaction type (e.g.,	"Authorization", "Deposit")
@@ -96,11 +139,11 @@ type AuthorizationClosedEvent struct {
 }
 
 type AuthorizationResponse struct {
-	Action         string                 `json:"action"` // "approve" or "decline"
-	Code           string                 `json:"code,omitempty"`
-	CardBalance    int64                  `json:"cardBalance,omitempty"`
-	CardHolderName string                 `json:"cardHolderName,omitempty"`
-	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Action         string                 `json:"action" bson:"action"` // "approve" or "decline"
+	Code           string                 `json:"code,omitempty" bson:"code,omitempty"`
+	CardBalance    int64                  `json:"cardBalance,omitempty" bson:"cardBalance,omitempty"`
+	CardHolderName string                 `json:"cardHolderName,omitempty" bson:"cardHolderName,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
 }
 type GetAccountBalanceResponse struct {
 	Code string `json:"code"`
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AccountHandler handles sub-account HTTP requests.
+type AccountHandler struct {
+	ledgerService  *services.LedgerService
+	depositService *services.DepositService
+	logger         *zap.Logger
+}
+
+// NewAccountHandler creates a new account handler.
+func NewAccountHandler(ledgerService *services.LedgerService, depositService *services.DepositService, logger *zap.Logger) *AccountHandler {
+	return &AccountHandler{
+		ledgerService:  ledgerService,
+		depositService: depositService,
+		logger:         logger,
+	}
+}
+
+type ReviewDepositRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=released rejected"`
+	Reviewer string `json:"reviewer" binding:"required"`
+	Note     string `json:"note"`
+}
+
+// GetLedger handles GET /api/accounts/:id/ledger and pages through the postings of a sub-account.
+func (h *AccountHandler) GetLedger(c *gin.Context) {
+	accountID := c.Param("id")
+	page, limit := parsePagination(c)
+
+	balances, _, err := h.ledgerService.Balances(c.Request.Context(), accountID)
+	if err != nil {
+		h.logger.Error("Failed to fetch ledger balances", zap.String("accountID", accountID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	postings, err := h.ledgerService.ListPostings(c.Request.Context(), accountID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"accountId": accountID,
+		"balances":  balances,
+		"page":      page,
+		"limit":     limit,
+		"postings":  postings,
+	})
+}
+
+// ListDeposits handles GET /api/accounts/:id/deposits and pages through the
+// deposits into a sub-account, optionally filtered by status.
+func (h *AccountHandler) ListDeposits(c *gin.Context) {
+	accountID := c.Param("id")
+	page, limit := parsePagination(c)
+	deposits, err := h.depositService.ListAccountDeposits(c.Request.Context(), accountID, c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accountId": accountID, "page": page, "limit": limit, "deposits": deposits})
+}
+
+// ListQuarantinedDeposits handles GET /api/admin/deposits/quarantine and lists
+// the deposits held for review, by quarantine status.
+func (h *AccountHandler) ListQuarantinedDeposits(c *gin.Context) {
+	page, limit := parsePagination(c)
+	deposits, err := h.depositService.ListQuarantined(c.Request.Context(), c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "limit": limit, "deposits": deposits})
+}
+
+// ReviewDeposit handles POST /api/admin/deposits/:id/review and releases or
+// rejects a quarantined deposit.
+func (h *AccountHandler) ReviewDeposit(c *gin.Context) {
+	var req ReviewDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deposit, err := h.depositService.Review(c.Request.Context(), c.Param("id"), req.Decision, req.Reviewer, req.Note)
+	switch {
+	case errors.Is(err, services.ErrDepositNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDepositNotQuarantined):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDepositReview):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to review deposit", zap.String("id", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, deposit)
+	}
+}
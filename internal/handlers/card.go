@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"card-service/internal/api"
+	"card-service/internal/models"
 	"card-service/internal/services"
-
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -37,9 +41,36 @@ type CardControlsRequest struct {
 	AllowedCategories []string               `json:"allowedCategories"`
 	BlockedCategories []string               `json:"blockedCategories"`
 	SpendingLimits    []SpendingLimitRequest `json:"spendingLimits"`
+	AllowedCountries  []string               `json:"allowedCountries"` // ISO 3166 alpha-2 codes
+	BlockedCountries  []string               `json:"blockedCountries"`
+	Schedule          *ScheduleRequest       `json:"schedule"`
 	// Metadata         string                 `bson:"Metadata"`
 }
 
+// ScheduleRequest limits spending to days of the week and hours of the day.
+type ScheduleRequest struct {
+	Timezone string             `json:"timezone"` // IANA timezone of the cardholder
+	Days     []string           `json:"days"`     // sun to sat
+	Hours    []HourRangeRequest `json:"hours"`
+}
+
+type HourRangeRequest struct {
+	From string `json:"from"` // HH:MM
+	To   string `json:"to"`   // HH:MM
+}
+
+// TemporaryBlockRequest blocks a card for a number of minutes.
+type TemporaryBlockRequest struct {
+	Minutes int    `json:"minutes" binding:"required,min=1"`
+	Reason  string `json:"reason"`
+}
+
+// UnlockChannelRequest allows a channel for a number of minutes, 30 by default.
+type UnlockChannelRequest struct {
+	Channel string `json:"channel" binding:"required"`
+	Minutes int    `json:"minutes" binding:"omitempty,min=1,max=1440"`
+}
+
 type CardMetadataRequest struct {
 	Name string `json:"name"`
 }
@@ -52,6 +83,19 @@ type LinkCardRequest struct {
 	Controls      *CardControlsRequest `json:"controls"`
 	Metadata      *CardMetadataRequest `json:"metadata"`
 }
+type EmployeeRequest struct {
+	ID    string `json:"id" binding:"required"`
+	Name  string `json:"name" binding:"required"`
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+type LinkEmployeeCardRequest struct {
+	Pan       string               `json:"pan" binding:"required"`
+	Reference string               `json:"reference"`
+	Employee  EmployeeRequest      `json:"employee" binding:"required"`
+	Controls  *CardControlsRequest `json:"controls"`
+}
+
 type CardDetails struct {
 	Last4          string `json:"last4" bson:"last4"`
 	Expiry         string `json:"exp" bson:"exp"`
@@ -70,6 +114,55 @@ type LinkCardRequestResponse struct {
 	Metadata      api.CardMetadata `json:"metadata"`
 }
 
+type LinkEmployeeCardResponse struct {
+	CardID        string              `json:"cardId"`
+	CustomerID    string              `json:"customerId"`
+	FundingSource string              `json:"fundingSource"`
+	Program       string              `json:"program"`
+	Type          string              `json:"type"`
+	Status        string              `json:"status"`
+	Details       CardDetails         `json:"details"`
+	Controls      api.CardControls    `json:"controls"`
+	Employee      models.CardEmployee `json:"employee"`
+}
+
+// CardResponse is a stored card with its lifecycle.
+type CardResponse struct {
+	CardID          string                    `json:"cardId"`
+	CustomerID      string                    `json:"customerId"`
+	FundingSource   string                    `json:"fundingSource"`
+	Program         string                    `json:"program"`
+	Type            string                    `json:"type"`
+	Status          string                    `json:"status"`
+	Details         CardDetails               `json:"details"`
+	Controls        api.CardControls          `json:"controls"`
+	ControlsVersion int                       `json:"controlsVersion"`
+	Metadata        api.CardMetadata          `json:"metadata"`
+	Employee        *models.CardEmployee      `json:"employee,omitempty"`
+	Replaces        string                    `json:"replaces,omitempty"`
+	ReplacedBy      string                    `json:"replacedBy,omitempty"`
+	History         []models.CardStatusChange `json:"history"`
+}
+
+// UpdateCardControlsRequest changes the controls of a card. Omitted fields
+// keep their current value.
+type UpdateCardControlsRequest struct {
+	CardControlsRequest
+	Schedule json.RawMessage `json:"schedule"`                   // A ScheduleRequest; null or {} removes the schedule
+	Version  *int            `json:"version" binding:"required"` // Controls version the change is based on
+}
+
+// CardStatusRequest carries the optional reason of a freeze or termination.
+type CardStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReplaceCardRequest links a new PAN in place of a lost or stolen card.
+type ReplaceCardRequest struct {
+	Pan    string `json:"pan" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
 type ActivateCardRequest struct {
 	Cvv string `json:"cvv" binding:"required"`
 	Pin string `json:"pin" binding:"required"`
@@ -91,6 +184,39 @@ func convertSpendingLimits(limits []SpendingLimitRequest) []api.SpendingLimit {
 	return result
 }
 
+func convertSchedule(schedule *ScheduleRequest) *api.ControlSchedule {
+	if schedule == nil {
+		return nil
+	}
+	hours := make([]api.HourRange, len(schedule.Hours))
+	for i, h := range schedule.Hours {
+		hours[i] = api.HourRange{From: h.From, To: h.To}
+	}
+	return &api.ControlSchedule{
+		Timezone: schedule.Timezone,
+		Days:     schedule.Days,
+		Hours:    hours,
+	}
+}
+
+func convertControls(controls *CardControlsRequest) *api.CardControls {
+	if controls == nil {
+		return nil
+	}
+	return &api.CardControls{
+		AllowedChannels:   controls.AllowedChannels,
+		BlockedChannels:   controls.BlockedChannels,
+		AllowedMerchants:  controls.AllowedMerchants,
+		BlockedMerchants:  controls.BlockedMerchants,
+		AllowedCategories: controls.AllowedCategories,
+		BlockedCategories: controls.BlockedCategories,
+		SpendingLimits:    convertSpendingLimits(controls.SpendingLimits),
+		AllowedCountries:  controls.AllowedCountries,
+		BlockedCountries:  controls.BlockedCountries,
+		Schedule:          convertSchedule(controls.Schedule),
+	}
+}
+
 func (h *CardHandler) LinkCard(c *gin.Context) {
 	var req LinkCardRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -99,18 +225,7 @@ func (h *CardHandler) LinkCard(c *gin.Context) {
 		return
 	}
 
-	var controls *api.CardControls
-	if req.Controls != nil {
-		controls = &api.CardControls{
-			AllowedChannels:   req.Controls.AllowedChannels,
-			BlockedChannels:   req.Controls.BlockedChannels,
-			AllowedMerchants:  req.Controls.AllowedMerchants,
-			BlockedMerchants:  req.Controls.BlockedMerchants,
-			AllowedCategories: req.Controls.AllowedCategories,
-			BlockedCategories: req.Controls.BlockedCategories,
-			SpendingLimits:    convertSpendingLimits(req.Controls.SpendingLimits),
-		}
-	}
+	controls := convertControls(req.Controls)
 
 	var metadata *api.CardMetadata
 	if req.Metadata != nil {
@@ -128,6 +243,21 @@ func (h *CardHandler) LinkCard(c *gin.Context) {
 		controls,
 		metadata,
 	)
+	if errors.Is(err, services.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrCardLimitReached) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidControls) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if operationPending(c, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to link card", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -154,6 +284,48 @@ func (h *CardHandler) LinkCard(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// LinkEmployeeCard handles POST /api/customers/:id/employee-cards and links a
+// card for an employee of a business customer, funded from the business sub
+// account with the employee's own controls.
+func (h *CardHandler) LinkEmployeeCard(c *gin.Context) {
+	var req LinkEmployeeCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("failed to bind request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	employee := models.CardEmployee{ID: req.Employee.ID, Name: req.Employee.Name, Email: req.Employee.Email}
+	card, err := h.cardService.LinkEmployeeCard(c.Request.Context(), c.Param("id"), req.Pan, req.Reference, employee, convertControls(req.Controls))
+	switch {
+	case errors.Is(err, services.ErrCustomerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotBusinessCustomer), errors.Is(err, services.ErrInvalidControls):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCardLimitReached):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case operationPending(c, err):
+	case err != nil:
+		h.logger.Error("Failed to link employee card", zap.String("customerID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, LinkEmployeeCardResponse{
+			CardID:        card.CardID,
+			CustomerID:    card.CustomerID,
+			FundingSource: card.FundingSource,
+			Program:       card.Program,
+			Type:          card.Type,
+			Status:        card.Status,
+			Details: CardDetails{
+				Last4:          card.Last4,
+				Expiry:         card.Expiry,
+				CardHolderName: card.CardHolderName,
+			},
+			Controls: card.Controls,
+			Employee: *card.Employee,
+		})
+	}
+}
+
 func (h *CardHandler) ActivateCard(c *gin.Context) {
 	var req ActivateCardRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -164,6 +336,17 @@ func (h *CardHandler) ActivateCard(c *gin.Context) {
 
 	cardID := c.Param("id") // Assumes cardId is passed as a URL parameter, e.g., /cards/id/activate
 	code, err := h.cardService.ActivateCard(c.Request.Context(), req.Cvv, req.Pin, cardID)
+	if errors.Is(err, services.ErrCardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCardTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if operationPending(c, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to activate card", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -176,3 +359,247 @@ func (h *CardHandler) ActivateCard(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+// FreezeCard handles POST /api/cards/:id/freeze and temporarily blocks an active card.
+func (h *CardHandler) FreezeCard(c *gin.Context) {
+	var req CardStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, err := h.cardService.FreezeCard(c.Request.Context(), c.Param("id"), req.Reason)
+	h.respondCard(c, "freeze", card, err)
+}
+
+// UnfreezeCard handles POST /api/cards/:id/unfreeze and lifts the block of a frozen card.
+func (h *CardHandler) UnfreezeCard(c *gin.Context) {
+	card, err := h.cardService.UnfreezeCard(c.Request.Context(), c.Param("id"))
+	h.respondCard(c, "unfreeze", card, err)
+}
+
+// TerminateCard handles POST /api/cards/:id/terminate and permanently blocks a card.
+func (h *CardHandler) TerminateCard(c *gin.Context) {
+	var req CardStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, err := h.cardService.TerminateCard(c.Request.Context(), c.Param("id"), req.Reason)
+	h.respondCard(c, "terminate", card, err)
+}
+
+// ReplaceCard handles POST /api/cards/:id/replace: the lost or stolen card is
+// terminated and the new PAN is linked with its controls and metadata.
+func (h *CardHandler) ReplaceCard(c *gin.Context) {
+	var req ReplaceCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("failed to bind request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, err := h.cardService.ReplaceCard(c.Request.Context(), c.Param("id"), req.Pan, req.Reason)
+	switch {
+	case errors.Is(err, services.ErrInvalidReplacement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCardReplaced):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCardLimitReached):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err != nil:
+		h.respondCard(c, "replace", nil, err)
+	default:
+		c.JSON(http.StatusCreated, newCardResponse(card))
+	}
+}
+
+// respondCard writes the card a lifecycle action left, or the error it failed with.
+func (h *CardHandler) respondCard(c *gin.Context, action string, card *models.Card, err error) {
+	switch {
+	case errors.Is(err, services.ErrCardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCardTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case operationPending(c, err):
+	case err != nil:
+		h.logger.Error("Failed to change card", zap.String("cardID", c.Param("id")), zap.String("action", action), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, newCardResponse(card))
+	}
+}
+
+func newCardResponse(card *models.Card) CardResponse {
+	return CardResponse{
+		CardID:        card.CardID,
+		CustomerID:    card.CustomerID,
+		FundingSource: card.FundingSource,
+		Program:       card.Program,
+		Type:          card.Type,
+		Status:        card.Status,
+		Details: CardDetails{
+			Last4:          card.Last4,
+			Expiry:         card.Expiry,
+			CardHolderName: card.CardHolderName,
+		},
+		Controls:        card.Controls,
+		ControlsVersion: card.ControlsVersion,
+		Metadata:        card.Metadata,
+		Employee:        card.Employee,
+		Replaces:        card.Replaces,
+		ReplacedBy:      card.ReplacedBy,
+		History:         card.History,
+	}
+}
+
+// UpdateCardControls handles PATCH /api/cards/:id/controls and changes the
+// controls of a card, provided version is still its controls version.
+func (h *CardHandler) UpdateCardControls(c *gin.Context) {
+	var req UpdateCardControlsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("failed to bind request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	update := *convertControls(&req.CardControlsRequest)
+	if req.SpendingLimits == nil {
+		update.SpendingLimits = nil
+	}
+	if len(req.Schedule) > 0 {
+		var schedule *ScheduleRequest
+		if err := json.Unmarshal(req.Schedule, &schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// An empty schedule tells the service to remove it
+		update.Schedule = &api.ControlSchedule{}
+		if schedule != nil {
+			update.Schedule = convertSchedule(schedule)
+		}
+	}
+	card, err := h.cardService.UpdateControls(c.Request.Context(), c.Param("id"), *req.Version, update)
+	h.respondControls(c, "update controls", card, err)
+}
+
+// BlockTemporarily handles POST /api/cards/:id/temporary-block and declines
+// every authorization of a card for the given minutes.
+func (h *CardHandler) BlockTemporarily(c *gin.Context) {
+	var req TemporaryBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	card, err := h.cardService.BlockTemporarily(c.Request.Context(), c.Param("id"), time.Duration(req.Minutes)*time.Minute, req.Reason)
+	h.respondControls(c, "block temporarily", card, err)
+}
+
+// LiftTemporaryBlock handles DELETE /api/cards/:id/temporary-block and ends
+// the temporary block of a card early.
+func (h *CardHandler) LiftTemporaryBlock(c *gin.Context) {
+	card, err := h.cardService.LiftTemporaryBlock(c.Request.Context(), c.Param("id"))
+	h.respondControls(c, "lift temporary block", card, err)
+}
+
+// UnlockChannel handles POST /api/cards/:id/unlocks and allows a channel,
+// e.g. online spending, for the given minutes.
+func (h *CardHandler) UnlockChannel(c *gin.Context) {
+	var req UnlockChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Minutes == 0 {
+		req.Minutes = 30
+	}
+	card, err := h.cardService.UnlockChannel(c.Request.Context(), c.Param("id"), req.Channel, time.Duration(req.Minutes)*time.Minute)
+	h.respondControls(c, "unlock channel", card, err)
+}
+
+// respondControls writes the card a controls change left, or the error it failed with.
+func (h *CardHandler) respondControls(c *gin.Context, action string, card *models.Card, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidControls):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrControlsConflict), errors.Is(err, services.ErrCardTerminated):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.respondCard(c, action, card, err)
+	}
+}
+
+// GetCardControls handles GET /api/cards/:id/controls and returns the
+// controls version in force at the optional at time, RFC 3339, or now.
+func (h *CardHandler) GetCardControls(c *gin.Context) {
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC 3339 time"})
+			return
+		}
+		at = parsed
+	}
+	version, err := h.cardService.ControlsAt(c.Request.Context(), c.Param("id"), at)
+	switch {
+	case errors.Is(err, services.ErrCardNotFound), errors.Is(err, services.ErrNoControlsInForce):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to get card controls", zap.String("cardID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, version)
+	}
+}
+
+// ListCardControlsVersions handles GET /api/cards/:id/controls/versions and
+// returns every controls version of a card, newest first.
+func (h *CardHandler) ListCardControlsVersions(c *gin.Context) {
+	versions, err := h.cardService.ControlsVersions(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrCardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list card controls versions", zap.String("cardID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cardId": c.Param("id"), "versions": versions})
+}
+
+// GetSpendingLimits handles GET /api/cards/:id/limits and returns the remaining amount per interval.
+func (h *CardHandler) GetSpendingLimits(c *gin.Context) {
+	cardID := c.Param("id")
+	usages, err := h.cardService.GetSpendingLimits(c.Request.Context(), cardID)
+	if errors.Is(err, services.ErrCardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get spending limits", zap.String("cardID", cardID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cardId": cardID, "limits": usages})
+}
+
+// ListDecisions handles GET /api/cards/:id/decisions and pages through the authorization decisions of a card.
+func (h *CardHandler) ListDecisions(c *gin.Context) {
+	cardID := c.Param("id")
+	page, limit := parsePagination(c)
+
+	decisions, err := h.cardService.ListDecisions(c.Request.Context(), cardID, page, limit)
+	if errors.Is(err, services.ErrCardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"cardId":    cardID,
+		"page":      page,
+		"limit":     limit,
+		"decisions": decisions,
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CaseHandler handles compliance case HTTP requests.
+type CaseHandler struct {
+	caseService *services.CaseService
+	logger      *zap.Logger
+}
+
+// NewCaseHandler creates a new case handler.
+func NewCaseHandler(caseService *services.CaseService, logger *zap.Logger) *CaseHandler {
+	return &CaseHandler{
+		caseService: caseService,
+		logger:      logger,
+	}
+}
+
+type CaseStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Actor  string `json:"actor" binding:"required"`
+	Reason string `json:"reason"` // Required when closing a case
+}
+
+type CaseNoteRequest struct {
+	Author string `json:"author" binding:"required"`
+	Text   string `json:"text" binding:"required"`
+}
+
+// ListCases handles GET /api/cases and pages through the compliance cases,
+// optionally filtered by status, type and accountId.
+func (h *CaseHandler) ListCases(c *gin.Context) {
+	page, limit := parsePagination(c)
+	cases, err := h.caseService.ListCases(c.Request.Context(), c.Query("status"), c.Query("type"), c.Query("accountId"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"page":  page,
+		"limit": limit,
+		"cases": cases,
+	})
+}
+
+// GetCase handles GET /api/cases/:id and returns a case with its notes and history.
+func (h *CaseHandler) GetCase(c *gin.Context) {
+	found, err := h.caseService.GetCase(c.Request.Context(), c.Param("id"))
+	h.respondCase(c, found, err)
+}
+
+// UpdateStatus handles POST /api/cases/:id/status and moves a case through the status workflow.
+func (h *CaseHandler) UpdateStatus(c *gin.Context) {
+	var req CaseStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updated, err := h.caseService.UpdateStatus(c.Request.Context(), c.Param("id"), req.Status, req.Actor, req.Reason)
+	h.respondCase(c, updated, err)
+}
+
+// AddNote handles POST /api/cases/:id/notes and appends a note to a case.
+func (h *CaseHandler) AddNote(c *gin.Context) {
+	var req CaseNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updated, err := h.caseService.AddNote(c.Request.Context(), c.Param("id"), req.Author, req.Text)
+	h.respondCase(c, updated, err)
+}
+
+func (h *CaseHandler) respondCase(c *gin.Context, found *models.Case, err error) {
+	switch {
+	case errors.Is(err, services.ErrCaseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCaseUpdate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCaseTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, found)
+	}
+}
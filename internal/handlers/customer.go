@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"card-service/internal/api"
+	"card-service/internal/models"
 	"card-service/internal/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -44,6 +46,37 @@ type CreateCustomerRequest struct {
 	// SettlementAccount string `json:"settlementAccount"`
 }
 
+type BusinessOfficerRequest struct {
+	Role             string `json:"role" binding:"required,oneof=director beneficial-owner"`
+	OwnershipPercent int    `json:"ownershipPercent" binding:"min=0,max=100"`
+	FirstName        string `json:"firstName" binding:"required"`
+	LastName         string `json:"lastName" binding:"required"`
+	MiddleName       string `json:"middleName"`
+	Email            string `json:"email" binding:"required,email"`
+	PhoneNumber      string `json:"phoneNumber" binding:"required"`
+	DateOfBirth      string `json:"dateOfBirth" binding:"required"`
+	NationalityCode  string `json:"nationalityCode" binding:"required,len=2"`
+	IDType           string `json:"idType" binding:"required,oneof=bvn nin"`
+	IDNumber         string `json:"idNumber" binding:"required"`
+	IssuingCountry   string `json:"issuingCountry" binding:"required,len=2"`
+}
+
+type CreateBusinessCustomerRequest struct {
+	Name               string                   `json:"name" binding:"required"`
+	TradingName        string                   `json:"tradingName"`
+	Email              string                   `json:"email" binding:"required,email"`
+	PhoneNumber        string                   `json:"phoneNumber" binding:"required"`
+	RegistrationType   string                   `json:"registrationType" binding:"required,oneof=rc bn it"`
+	RegistrationNumber string                   `json:"registrationNumber" binding:"required"`
+	IncorporationDate  string                   `json:"incorporationDate" binding:"required,datetime=2006-01-02"`
+	Country            string                   `json:"country" binding:"required,len=2"`
+	Industry           string                   `json:"industry"`
+	Address            string                   `json:"address" binding:"required"`
+	Officers           []BusinessOfficerRequest `json:"officers" binding:"required,min=1,dive"`
+	UserID             int                      `json:"userId" binding:"required"`
+	Ref                string                   `json:"ref" binding:"required"`
+}
+
 type CreateCustomerResponse struct {
 	CustomerID      string               `json:"customerId"`
 	Name            string               `json:"name"`
@@ -76,6 +109,7 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 
 	// create customer and sub account using the service
 	customerID, accountID, depositChannels, err := h.customerService.CreateCustomer(
+		c.Request.Context(),
 		req.Name,
 		req.FirstName,
 		req.LastName,
@@ -97,6 +131,19 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	// 	return
 	// }
+	var hold *services.ScreeningHoldError
+	if errors.As(err, &hold) {
+		status := http.StatusAccepted
+		if errors.Is(err, services.ErrOnboardingBlocked) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "screeningId": hold.ScreeningID, "ref": req.Ref})
+		return
+	}
+	if errors.Is(err, services.ErrOnboardingInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "ref": req.Ref})
+		return
+	}
 	if err != nil {
 		h.Logger.Error("Failed to create customer", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -114,3 +161,102 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 	}
 	c.JSON(http.StatusCreated, response)
 }
+
+// CreateBusinessCustomer handles POST /api/customers/business to create a
+// business customer with its directors and beneficial owners, and a business
+// sub account.
+func (h *CustomerHandler) CreateBusinessCustomer(c *gin.Context) {
+	var req CreateBusinessCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.Logger.Error("Failed to bind request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.Logger.Info("Received CreateBusinessCustomer request",
+		zap.String("email", req.Email),
+		zap.String("registrationNumber", req.RegistrationNumber),
+		zap.String("name", req.Name),
+	)
+
+	officers := make([]models.BusinessOfficer, 0, len(req.Officers))
+	for _, officer := range req.Officers {
+		officers = append(officers, models.BusinessOfficer{
+			Role:             officer.Role,
+			OwnershipPercent: officer.OwnershipPercent,
+			FirstName:        officer.FirstName,
+			LastName:         officer.LastName,
+			MiddleName:       officer.MiddleName,
+			Email:            officer.Email,
+			PhoneNumber:      officer.PhoneNumber,
+			DateOfBirth:      officer.DateOfBirth,
+			NationalityCode:  officer.NationalityCode,
+			IDType:           officer.IDType,
+			IDNumber:         officer.IDNumber,
+			IssuingCountry:   officer.IssuingCountry,
+		})
+	}
+	application := models.BusinessApplication{
+		Name:  req.Name,
+		Email: req.Email,
+		BusinessProfile: models.BusinessProfile{
+			TradingName:        req.TradingName,
+			PhoneNumber:        req.PhoneNumber,
+			RegistrationType:   req.RegistrationType,
+			RegistrationNumber: req.RegistrationNumber,
+			IncorporationDate:  req.IncorporationDate,
+			Country:            req.Country,
+			Industry:           req.Industry,
+			Address:            req.Address,
+			Officers:           officers,
+		},
+		UserID: req.UserID,
+		Ref:    req.Ref,
+	}
+	customerID, accountID, depositChannels, err := h.customerService.CreateBusinessCustomer(c.Request.Context(), application)
+	var hold *services.ScreeningHoldError
+	if errors.As(err, &hold) {
+		status := http.StatusAccepted
+		if errors.Is(err, services.ErrOnboardingBlocked) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "screeningId": hold.ScreeningID, "ref": req.Ref})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidBusiness) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrOnboardingInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "ref": req.Ref})
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to create business customer", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateCustomerResponse{
+		CustomerID:      customerID,
+		Name:            req.Name,
+		Email:           req.Email,
+		AccountID:       accountID,
+		DepositChannels: depositChannels,
+	})
+}
+
+// GetOnboarding handles GET /api/onboarding/:ref and returns the progress of
+// the onboarding of a reference, so clients can poll an onboarding that is
+// held or was interrupted.
+func (h *CustomerHandler) GetOnboarding(c *gin.Context) {
+	onboarding, err := h.customerService.GetOnboarding(c.Request.Context(), c.Param("ref"))
+	if errors.Is(err, services.ErrOnboardingNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, onboarding)
+}
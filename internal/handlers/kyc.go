@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// KYCHandler handles KYC tier HTTP requests.
+type KYCHandler struct {
+	kycService *services.KYCService
+	logger     *zap.Logger
+}
+
+// NewKYCHandler creates a new KYC handler.
+func NewKYCHandler(kycService *services.KYCService, logger *zap.Logger) *KYCHandler {
+	return &KYCHandler{
+		kycService: kycService,
+		logger:     logger,
+	}
+}
+
+type KYCDocumentRequest struct {
+	Type           string `json:"type" binding:"required"`
+	Number         string `json:"number"`
+	IssuingCountry string `json:"issuingCountry" binding:"omitempty,len=2"`
+	ExpiresAt      string `json:"expiresAt" binding:"omitempty,datetime=2006-01-02"`
+	Reference      string `json:"reference" binding:"required"`
+}
+
+type KYCUpgradeRequest struct {
+	Tier        string               `json:"tier" binding:"required"`
+	RequestedBy string               `json:"requestedBy"`
+	Documents   []KYCDocumentRequest `json:"documents" binding:"required,min=1,dive"`
+}
+
+type KYCReviewRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=verified rejected"`
+	Reviewer string `json:"reviewer" binding:"required"`
+	Reason   string `json:"reason"` // Required when rejecting an upgrade
+}
+
+// GetKYC handles GET /api/customers/:id/kyc and returns the tier of a
+// customer, its limits and its verifications.
+func (h *KYCHandler) GetKYC(c *gin.Context) {
+	status, err := h.kycService.GetKYC(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// RequestUpgrade handles POST /api/customers/:id/kyc/upgrades. The upgrade
+// stays pending until it is reviewed.
+func (h *KYCHandler) RequestUpgrade(c *gin.Context) {
+	var req KYCUpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RequestedBy == "" {
+		req.RequestedBy = "customer"
+	}
+	documents := make([]models.KYCDocument, 0, len(req.Documents))
+	for _, doc := range req.Documents {
+		documents = append(documents, models.KYCDocument{
+			Type:           doc.Type,
+			Number:         doc.Number,
+			IssuingCountry: doc.IssuingCountry,
+			ExpiresAt:      doc.ExpiresAt,
+			Reference:      doc.Reference,
+		})
+	}
+	verification, err := h.kycService.RequestUpgrade(c.Request.Context(), c.Param("id"), req.Tier, req.RequestedBy, documents)
+	switch {
+	case errors.Is(err, services.ErrCustomerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidUpgrade):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUpgradePending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to request tier upgrade", zap.String("customerID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, verification)
+	}
+}
+
+// ReviewUpgrade handles POST /api/customers/:id/kyc/upgrades/:verificationId/review.
+// A verified upgrade raises the tier of the customer.
+func (h *KYCHandler) ReviewUpgrade(c *gin.Context) {
+	var req KYCReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status, err := h.kycService.ReviewUpgrade(c.Request.Context(), c.Param("id"), c.Param("verificationId"), req.Decision, req.Reviewer, req.Reason)
+	switch {
+	case errors.Is(err, services.ErrCustomerNotFound), errors.Is(err, services.ErrVerificationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidUpgrade):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to review tier upgrade", zap.String("customerID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, status)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OutboxHandler handles HTTP requests inspecting the issuer call outbox.
+type OutboxHandler struct {
+	outboxService *services.OutboxService
+	logger        *zap.Logger
+}
+
+// NewOutboxHandler creates a new outbox handler.
+func NewOutboxHandler(outboxService *services.OutboxService, logger *zap.Logger) *OutboxHandler {
+	return &OutboxHandler{
+		outboxService: outboxService,
+		logger:        logger,
+	}
+}
+
+// ListOperations handles GET /api/admin/outbox and lists outbox operations,
+// filtered by type, status and subject.
+func (h *OutboxHandler) ListOperations(c *gin.Context) {
+	page, limit := parsePagination(c)
+	filter := services.OutboxFilter{
+		Type:    c.Query("type"),
+		Status:  c.Query("status"),
+		Subject: c.Query("subject"),
+	}
+	ops, err := h.outboxService.ListOperations(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "limit": limit, "operations": ops})
+}
+
+// GetOperation handles GET /api/admin/outbox/:id to inspect an outbox operation.
+func (h *OutboxHandler) GetOperation(c *gin.Context) {
+	op, err := h.outboxService.GetOperation(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrOperationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, op)
+}
+
+// operationPending answers 202 Accepted with the ID of the outbox operation
+// when the issuer call of a request is still pending, and reports whether it did.
+func operationPending(c *gin.Context, err error) bool {
+	var pending *services.OperationPendingError
+	if !errors.As(err, &pending) {
+		return false
+	}
+	c.JSON(http.StatusAccepted, gin.H{"error": err.Error(), "operationId": pending.OperationID})
+	return true
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RuleHandler handles authorization rule HTTP requests.
+type RuleHandler struct {
+	ruleService    *services.RuleService
+	webhookService *services.WebhookService
+	logger         *zap.Logger
+}
+
+// NewRuleHandler creates a new rule handler.
+func NewRuleHandler(ruleService *services.RuleService, webhookService *services.WebhookService, logger *zap.Logger) *RuleHandler {
+	return &RuleHandler{
+		ruleService:    ruleService,
+		webhookService: webhookService,
+		logger:         logger,
+	}
+}
+
+type RuleRequest struct {
+	RuleID      string `json:"ruleId"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Scope       string `json:"scope"` // global, program or card; defaults to global
+	ScopeID     string `json:"scopeId"`
+	Priority    int    `json:"priority"`
+	Expression  string `json:"expression" binding:"required"`
+	Outcome     string `json:"outcome" binding:"required"`
+	Code        string `json:"code"`
+	Enabled     *bool  `json:"enabled"` // Defaults to true
+	Shadow      bool   `json:"shadow"`  // Store as a shadow version, evaluated without affecting decisions
+	Version     int    `json:"version"` // Version an update is based on
+}
+
+type EvaluateRulesRequest struct {
+	Event   api.AuthorizationRequestEvent `json:"event" binding:"required"`
+	Balance *int64                        `json:"balance"` // Issuer balance to assume; defaults to the last known balance
+	Rules   []RuleRequest                 `json:"rules"`   // Candidate rules evaluated in place of the current ones
+}
+
+func (r RuleRequest) toModel() models.Rule {
+	rule := models.Rule{
+		RuleID:      r.RuleID,
+		Name:        r.Name,
+		Description: r.Description,
+		Scope:       r.Scope,
+		ScopeID:     r.ScopeID,
+		Priority:    r.Priority,
+		Expression:  r.Expression,
+		Outcome:     r.Outcome,
+		Code:        r.Code,
+		Enabled:     r.Enabled == nil || *r.Enabled,
+		Version:     r.Version,
+		Shadow:      r.Shadow,
+	}
+	if rule.Scope == "" {
+		rule.Scope = models.RuleScopeGlobal
+	}
+	return rule
+}
+
+// ListRules handles GET /api/rules and returns the current version of every rule.
+func (h *RuleHandler) ListRules(c *gin.Context) {
+	rules, err := h.ruleService.ListRules(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list rules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// GetRuleVersions handles GET /api/rules/:id/versions and returns every version of a rule.
+func (h *RuleHandler) GetRuleVersions(c *gin.Context) {
+	versions, err := h.ruleService.RuleVersions(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ruleId": c.Param("id"), "versions": versions})
+}
+
+// CreateRule handles POST /api/rules and stores the first version of a rule.
+func (h *RuleHandler) CreateRule(c *gin.Context) {
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule, err := h.ruleService.CreateRule(c.Request.Context(), req.toModel())
+	h.respondRule(c, http.StatusCreated, rule, err)
+}
+
+// UpdateRule handles PUT /api/rules/:id and stores a new version of a rule.
+// The request must carry the version it is based on.
+func (h *RuleHandler) UpdateRule(c *gin.Context) {
+	var req RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule, err := h.ruleService.UpdateRule(c.Request.Context(), c.Param("id"), req.Version, req.toModel())
+	h.respondRule(c, http.StatusOK, rule, err)
+}
+
+func (h *RuleHandler) respondRule(c *gin.Context, status int, rule models.Rule, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRuleConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(status, rule)
+	}
+}
+
+// EvaluateRules handles POST /api/rules/evaluate, a dry run of the rules
+// against a sample authorization request. Nothing is recorded.
+func (h *RuleHandler) EvaluateRules(c *gin.Context) {
+	var req EvaluateRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	candidates := make([]models.Rule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		candidates = append(candidates, rule.toModel())
+	}
+
+	result, err := h.webhookService.EvaluateRules(c.Request.Context(), req.Event, req.Balance, candidates)
+	switch {
+	case errors.Is(err, services.ErrCardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to evaluate rules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// PromoteShadow handles POST /api/rules/:id/promote and makes the shadow version of a rule live.
+func (h *RuleHandler) PromoteShadow(c *gin.Context) {
+	rule, err := h.ruleService.PromoteShadow(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrNoShadowVersion) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondRule(c, http.StatusOK, rule, err)
+}
+
+// DiscardShadow handles DELETE /api/rules/:id/shadow and drops the shadow version of a rule.
+func (h *RuleHandler) DiscardShadow(c *gin.Context) {
+	err := h.ruleService.DiscardShadow(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrRuleNotFound), errors.Is(err, services.ErrNoShadowVersion):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetShadowReport handles GET /api/rules/shadow/report and summarizes, per
+// rule, the decisions the shadow rules would flip between from and to.
+func (h *RuleHandler) GetShadowReport(c *gin.Context) {
+	from, to, err := parseTimeWindow(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := h.ruleService.ShadowReport(c.Request.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to build shadow report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListShadowDisagreements handles GET /api/rules/shadow/disagreements and
+// pages through the decisions the shadow rules disagreed with.
+func (h *RuleHandler) ListShadowDisagreements(c *gin.Context) {
+	from, to, err := parseTimeWindow(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, limit := parsePagination(c)
+	decisions, err := h.ruleService.ListShadowDisagreements(c.Request.Context(), from, to, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":      from,
+		"to":        to,
+		"page":      page,
+		"limit":     limit,
+		"decisions": decisions,
+	})
+}
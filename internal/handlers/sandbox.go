@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"card-service/internal/simulator"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SandboxHandler drives the issuer simulator in sandbox mode.
+type SandboxHandler struct {
+	simulator *simulator.Simulator
+	logger    *zap.Logger
+}
+
+// NewSandboxHandler creates a new sandbox handler.
+func NewSandboxHandler(simulator *simulator.Simulator, logger *zap.Logger) *SandboxHandler {
+	return &SandboxHandler{
+		simulator: simulator,
+		logger:    logger,
+	}
+}
+
+type SandboxDepositRequest struct {
+	Amount int64 `json:"amount" binding:"required,gt=0"`
+}
+
+type SandboxAuthorizationRequest struct {
+	Amount   int64  `json:"amount" binding:"required,gt=0"`
+	Channel  string `json:"channel" binding:"required"`
+	MCC      string `json:"mcc"`
+	Merchant string `json:"merchant"`                          // Card acceptor name and location
+	Country  string `json:"country" binding:"omitempty,len=2"` // Merchant country sent apart from the name and location
+}
+
+type SandboxFaultRequest struct {
+	Operation string `json:"operation" binding:"required,oneof=create-customer create-sub-account link-card activate-card update-card-status update-card-controls get-account-balance"`
+	Count     int    `json:"count" binding:"omitempty,gte=1"`
+	DelayMs   int    `json:"delayMs" binding:"omitempty,gte=0"`
+}
+
+// Deposit handles POST /api/sandbox/accounts/:id/deposits and pays into a
+// simulated sub account, emitting its payment webhook.
+func (h *SandboxHandler) Deposit(c *gin.Context) {
+	var req SandboxDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payment, err := h.simulator.Deposit(c.Request.Context(), c.Param("id"), req.Amount)
+	if err != nil {
+		h.sandboxError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// Authorize handles POST /api/sandbox/cards/:id/authorizations and runs a
+// simulated card payment through the authorization webhooks.
+func (h *SandboxHandler) Authorize(c *gin.Context) {
+	var req SandboxAuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response, err := h.simulator.Authorize(c.Request.Context(), simulator.Authorization{
+		CardID:   c.Param("id"),
+		Amount:   req.Amount,
+		Channel:  req.Channel,
+		MCC:      req.MCC,
+		Merchant: req.Merchant,
+		Country:  req.Country,
+	})
+	if err != nil {
+		h.sandboxError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// InjectFault handles POST /api/sandbox/faults and fails the next calls of a
+// simulated issuer operation.
+func (h *SandboxHandler) InjectFault(c *gin.Context) {
+	var req SandboxFaultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.simulator.InjectFault(simulator.Fault{
+		Operation: req.Operation,
+		Count:     req.Count,
+		Delay:     time.Duration(req.DelayMs) * time.Millisecond,
+	})
+	c.JSON(http.StatusCreated, gin.H{"operation": req.Operation})
+}
+
+// ClearFaults handles DELETE /api/sandbox/faults.
+func (h *SandboxHandler) ClearFaults(c *gin.Context) {
+	h.simulator.ClearFaults()
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SandboxHandler) sandboxError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, simulator.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, simulator.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("Sandbox request failed", zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ScreeningHandler handles watchlist and sanctions screening HTTP requests.
+type ScreeningHandler struct {
+	screeningService *services.ScreeningService
+	customerService  *services.CustomerService
+	logger           *zap.Logger
+}
+
+// NewScreeningHandler creates a new screening handler.
+func NewScreeningHandler(screeningService *services.ScreeningService, customerService *services.CustomerService, logger *zap.Logger) *ScreeningHandler {
+	return &ScreeningHandler{
+		screeningService: screeningService,
+		customerService:  customerService,
+		logger:           logger,
+	}
+}
+
+type ScreeningReviewRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=cleared confirmed"`
+	Reviewer string `json:"reviewer" binding:"required"`
+	Note     string `json:"note"`
+}
+
+// ListWatchlists handles GET /api/screening/lists and returns the latest import of every list.
+func (h *ScreeningHandler) ListWatchlists(c *gin.Context) {
+	lists, err := h.screeningService.ListWatchlists(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lists": lists})
+}
+
+// ImportWatchlist handles POST /api/screening/lists/:source. The body is the
+// list file; its format comes from the format query parameter or the content
+// type.
+func (h *ScreeningHandler) ImportWatchlist(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		switch contentType := c.ContentType(); {
+		case strings.Contains(contentType, "csv"):
+			format = "csv"
+		case strings.Contains(contentType, "xml"):
+			format = "xml"
+		}
+	}
+	list, err := h.screeningService.Import(c.Request.Context(), c.Param("source"), format, c.Request.Body)
+	if errors.Is(err, services.ErrInvalidWatchlist) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to import watchlist", zap.String("source", c.Param("source")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, list)
+}
+
+// ListScreenings handles GET /api/screening/screenings and pages through the
+// screenings, optionally filtered by reviewStatus and result. The review queue
+// is reviewStatus=pending.
+func (h *ScreeningHandler) ListScreenings(c *gin.Context) {
+	page, limit := parsePagination(c)
+	screenings, err := h.screeningService.ListScreenings(c.Request.Context(), c.Query("reviewStatus"), c.Query("result"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"page":       page,
+		"limit":      limit,
+		"screenings": screenings,
+	})
+}
+
+// GetScreening handles GET /api/screening/screenings/:id and returns a screening with its matches.
+func (h *ScreeningHandler) GetScreening(c *gin.Context) {
+	screening, err := h.screeningService.GetScreening(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrScreeningNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, screening)
+}
+
+// ReviewScreening handles POST /api/screening/screenings/:id/review. Clearing
+// a held onboarding creates the customer.
+func (h *ScreeningHandler) ReviewScreening(c *gin.Context) {
+	var req ScreeningReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	screening, err := h.customerService.ReviewScreening(c.Request.Context(), c.Param("id"), req.Decision, req.Reviewer, req.Note)
+	switch {
+	case errors.Is(err, services.ErrScreeningNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReview):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrScreeningReviewed), errors.Is(err, services.ErrOnboardingInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to review screening", zap.String("screeningID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, screening)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TravelNoticeHandler handles HTTP requests for the travel notices of customers.
+type TravelNoticeHandler struct {
+	travelService *services.TravelNoticeService
+	logger        *zap.Logger
+}
+
+// NewTravelNoticeHandler creates a new travel notice handler.
+func NewTravelNoticeHandler(travelService *services.TravelNoticeService, logger *zap.Logger) *TravelNoticeHandler {
+	return &TravelNoticeHandler{
+		travelService: travelService,
+		logger:        logger,
+	}
+}
+
+// TravelNoticeRequest pre-authorizes countries for a date range.
+type TravelNoticeRequest struct {
+	Countries []string `json:"countries" binding:"required,min=1"` // ISO 3166 alpha-2 codes
+	From      string   `json:"from" binding:"required"`            // First day of travel, YYYY-MM-DD
+	To        string   `json:"to" binding:"required"`              // Last day of travel, YYYY-MM-DD
+	Note      string   `json:"note"`
+}
+
+// CreateNotice handles POST /api/customers/:id/travel-notices and allows the
+// customer's cards in the given countries for the travel dates.
+func (h *TravelNoticeHandler) CreateNotice(c *gin.Context) {
+	var req TravelNoticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("failed to bind request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	notice, err := h.travelService.CreateNotice(c.Request.Context(), c.Param("id"), req.Countries, req.From, req.To, req.Note)
+	h.respondNotice(c, http.StatusCreated, notice, err)
+}
+
+// ListNotices handles GET /api/customers/:id/travel-notices and returns the
+// travel notices of a customer, latest travel first.
+func (h *TravelNoticeHandler) ListNotices(c *gin.Context) {
+	notices, err := h.travelService.ListNotices(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to list travel notices", zap.String("customerID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"customerId": c.Param("id"), "notices": notices})
+}
+
+// CancelNotice handles DELETE /api/customers/:id/travel-notices/:noticeId
+// and withdraws a travel notice.
+func (h *TravelNoticeHandler) CancelNotice(c *gin.Context) {
+	notice, err := h.travelService.CancelNotice(c.Request.Context(), c.Param("id"), c.Param("noticeId"))
+	h.respondNotice(c, http.StatusOK, notice, err)
+}
+
+func (h *TravelNoticeHandler) respondNotice(c *gin.Context, status int, notice *models.TravelNotice, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTravelNotice):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCustomerNotFound), errors.Is(err, services.ErrTravelNoticeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		h.logger.Error("Failed to change travel notice", zap.String("customerID", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(status, notice)
+	}
+}
@@ -3,11 +3,8 @@ package handlers
 import (
 	"card-service/internal/api"
 	"card-service/internal/services"
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -37,68 +34,29 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 		return
 	}
 	signature := c.GetHeader("Allawee-Signature")
-	hash := hmac.New(sha512.New, []byte(h.signingKey))
-	hash.Write(body)
-	expected := hex.EncodeToString(hash.Sum(nil))
-	if signature != expected {
-		h.logger.Error("invalid signature",
-			zap.String("received", signature),
-			zap.String("expected", expected),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid-signature"})
-		return
+	signatureValid := api.VerifyWebhookSignature(h.signingKey, body, signature)
+	if !signatureValid {
+		h.logger.Error("invalid signature", zap.String("received", signature))
 	}
 
-	var event api.WebhookEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		h.logger.Error("Failed to bind webhook event", zap.Error(err))
+	response, err := h.webhookService.ReceiveWebhook(c.Request.Context(), c.Request.Header, body, signatureValid)
+	switch {
+	case errors.Is(err, services.ErrInvalidSignature):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid-signature"})
+		return
+	case errors.Is(err, services.ErrInvalidEventPayload):
+		h.logger.Error("failed to decode webhook event", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
-	}
-
-	switch event.Event {
-	case "card.transaction.created":
-		var transaction api.TransactionEvent
-		if err := json.Unmarshal(body, &event); err != nil {
-			h.logger.Error("failed to bind transaction event", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		event.Data = transaction
-
-	case "card.authorization.request":
-		var authRequest api.AuthorizationRequestEvent
-		if err := json.Unmarshal(body, &err); err != nil {
-			h.logger.Error("failed to bind authorization event", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		event.Data = authRequest
-
-	case "card.authorization.closed":
-		var authClosed api.AuthorizationClosedEvent
-		if err := json.Unmarshal(body, &err); err != nil {
-			h.logger.Error("failed to bind authorization closed", zap.Error(err))
-			return
-		}
-		event.Data = authClosed
-
-	default:
-		h.logger.Error("unknown event type", zap.String("event", event.Event))
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown event type: %s", event.Event)})
+	case errors.Is(err, services.ErrEventInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
-	}
-	h.logger.Info("Received webhook event", zap.String("event", event.Event))
-
-	response, err := h.webhookService.HandleWebhook(c.Request.Context(), event)
-	if err != nil {
-		h.logger.Error("failed to handle event webhook", zap.String("event", event.Event), zap.Error(err))
+	case err != nil && response.Action == "":
+		h.logger.Error("failed to handle event webhook", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if response.Action != "" {
-		c.JSON(http.StatusOK, response)
-	} else {
+	if response.Action == "" {
 		c.JSON(http.StatusOK, gin.H{"code": "success"})
 		return
 	}
@@ -106,9 +64,56 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 	// Log the response sent to Allawee
 	responseBytes, _ := json.Marshal(response)
 	h.logger.Info("Webhook response sent",
-		zap.String("event", event.Event),
 		zap.String("response", string(responseBytes)),
+		zap.NamedError("reason", err),
 	)
 
-	c.JSON(200, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// ListWebhookEvents handles GET /api/admin/webhooks to page through the webhook inbox.
+func (h *WebhookHandler) ListWebhookEvents(c *gin.Context) {
+	page, limit := parsePagination(c)
+	filter := services.WebhookEventFilter{
+		Event:  c.Query("event"),
+		Status: c.Query("status"),
+	}
+	records, err := h.webhookService.ListWebhookEvents(c.Request.Context(), filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "limit": limit, "events": records})
+}
+
+// GetWebhookEvent handles GET /api/admin/webhooks/:id to inspect a stored event.
+func (h *WebhookHandler) GetWebhookEvent(c *gin.Context) {
+	record, err := h.webhookService.GetWebhookEvent(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrWebhookEventNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// ReplayWebhookEvent handles POST /api/admin/webhooks/:id/replay to run a stored event through the pipeline again.
+func (h *WebhookHandler) ReplayWebhookEvent(c *gin.Context) {
+	response, err := h.webhookService.ReplayWebhookEvent(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrWebhookEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrReplayNotAllowed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	result := gin.H{"response": response}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, result)
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// BalanceSnapshot is the last balance the issuer reported for a sub-account.
+type BalanceSnapshot struct {
+	AccountID string    `bson:"accountId"`
+	Available int64     `bson:"available"`
+	Currency  string    `bson:"currency"`
+	FetchedAt time.Time `bson:"fetchedAt"`
+}
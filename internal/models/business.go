@@ -0,0 +1,59 @@
+package models
+
+// Customer types.
+const (
+	CustomerIndividual = "individual"
+	CustomerBusiness   = "business"
+)
+
+// Business officer roles.
+const (
+	OfficerDirector        = "director"
+	OfficerBeneficialOwner = "beneficial-owner"
+)
+
+// BusinessProfile is the company registration of a business customer and the
+// individuals linked to it.
+type BusinessProfile struct {
+	TradingName        string            `bson:"tradingName,omitempty"`
+	PhoneNumber        string            `bson:"phoneNumber"`
+	RegistrationType   string            `bson:"registrationType"` // e.g. rc, bn, it
+	RegistrationNumber string            `bson:"registrationNumber"`
+	IncorporationDate  string            `bson:"incorporationDate"` // YYYY-MM-DD
+	Country            string            `bson:"country"`
+	Industry           string            `bson:"industry,omitempty"`
+	Address            string            `bson:"address"`
+	Officers           []BusinessOfficer `bson:"officers"`
+}
+
+// BusinessOfficer is a director or beneficial owner of a business.
+type BusinessOfficer struct {
+	Role             string `bson:"role"`
+	OwnershipPercent int    `bson:"ownershipPercent,omitempty"`
+	FirstName        string `bson:"firstName"`
+	LastName         string `bson:"lastName"`
+	MiddleName       string `bson:"middleName,omitempty"`
+	Email            string `bson:"email"`
+	PhoneNumber      string `bson:"phoneNumber"`
+	DateOfBirth      string `bson:"dateOfBirth"`
+	NationalityCode  string `bson:"nationalityCode"`
+	IDType           string `bson:"idType"`
+	IDNumber         string `bson:"idNumber"`
+	IssuingCountry   string `bson:"issuingCountry"`
+}
+
+// BusinessApplication is an onboarding request for a business customer.
+type BusinessApplication struct {
+	Name            string `bson:"name"` // Registered name
+	Email           string `bson:"email"`
+	BusinessProfile `bson:",inline"`
+	UserID          int    `bson:"userId"`
+	Ref             string `bson:"ref"`
+}
+
+// CardEmployee is the employee a business card is issued to.
+type CardEmployee struct {
+	ID    string `bson:"id" json:"id"` // Reference of the employee at the business
+	Name  string `bson:"name" json:"name"`
+	Email string `bson:"email,omitempty" json:"email,omitempty"`
+}
@@ -25,20 +25,65 @@ type Controls struct {
 type CardMetadata struct {
 	Name string `bson:"name"`
 }
+
+// Card statuses. Linking and link-failed cards are managed by the outbox; the
+// others follow the card state machine.
+const (
+	CardLinking    = "linking"     // Waiting for the issuer to link it
+	CardLinkFailed = "link-failed" // The issuer never linked it
+	CardInactive   = "inactive"    // Linked, not activated yet
+	CardActive     = "active"
+	CardFrozen     = "frozen"     // Temporarily blocked by the customer
+	CardTerminated = "terminated" // Permanently blocked
+)
+
+// Reasons a card is terminated and replaced.
+const (
+	CardLost   = "lost"
+	CardStolen = "stolen"
+)
+
+// CardStatusChange records a status a card moved to.
+type CardStatusChange struct {
+	Status string    `bson:"status" json:"status"`
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	At     time.Time `bson:"at" json:"at"`
+}
+
 type Card struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty"`
-	CardID         string             `bson:"cardId"`
-	Reference      string             `bson:"reference"`
-	CustomerID     string             `bson:"customerId"`
-	FundingSource  string             `bson:"fundingSource"`
-	Last4          string             `bson:"last4"`
-	Expiry         string             `bson:"expiry"`
-	CardHolderName string             `bson:"cardHolderName"`
-	Type           string             `bson:"type"`
-	Status         string             `bson:"status"`
-	Program        string             `bson:"program"`
-	Controls       api.CardControls   `bson:"controls"`
-	Metadata       api.CardMetadata   `bson:"metadata"`
-	CreatedAt      time.Time          `bson:"createdAt"`
-	UpdatedAt      time.Time          `bson:"updatedAt"`
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	CardID          string             `bson:"cardId"`
+	Reference       string             `bson:"reference"`
+	CustomerID      string             `bson:"customerId"`
+	FundingSource   string             `bson:"fundingSource"`
+	Last4           string             `bson:"last4"`
+	Expiry          string             `bson:"expiry"`
+	CardHolderName  string             `bson:"cardHolderName"`
+	Type            string             `bson:"type"`
+	Status          string             `bson:"status"`
+	Program         string             `bson:"program"`
+	Controls        api.CardControls   `bson:"controls"`
+	ControlsVersion int                `bson:"controlsVersion"` // Version of the controls; 0 until they are first changed
+	Metadata        api.CardMetadata   `bson:"metadata"`
+	Employee        *CardEmployee      `bson:"employee,omitempty"`    // Employee of a business customer the card is issued to
+	History         []CardStatusChange `bson:"history,omitempty"`     // Status changes after linking, oldest first
+	Replaces        string             `bson:"replaces,omitempty"`    // Card this card replaced
+	Replacement     string             `bson:"replacement,omitempty"` // Local ID of the card claimed to replace this one while it is linked
+	ReplacedBy      string             `bson:"replacedBy,omitempty"`
+	CreatedAt       time.Time          `bson:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt"`
+}
+
+// CardControlsVersion is one version of the controls of a card. Version 0
+// holds the controls the card was linked with and is recorded when they are
+// first changed. A version is in force from EffectiveFrom until the next
+// version's.
+type CardControlsVersion struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CardID        string             `bson:"cardId" json:"cardId"`
+	Version       int                `bson:"version" json:"version"`
+	Controls      api.CardControls   `bson:"controls" json:"controls"`
+	EffectiveFrom time.Time          `bson:"effectiveFrom" json:"effectiveFrom"`
+	PushedAt      *time.Time         `bson:"pushedAt,omitempty" json:"pushedAt,omitempty"` // When the issuer applied them
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
 }
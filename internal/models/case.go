@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Case statuses.
+const (
+	CaseOpen          = "open"
+	CaseInvestigating = "investigating"
+	CaseEscalated     = "escalated" // Referred for a suspicious activity report
+	CaseClosed        = "closed"
+)
+
+// Monitoring alert types that open cases.
+const (
+	AlertStructuring   = "structuring"    // Many deposits just under the reporting threshold
+	AlertRapidMovement = "rapid-movement" // Deposits spent again shortly after they arrived
+	AlertUnusualVolume = "unusual-volume" // Volume far above the customer's history
+)
+
+// Case is a compliance case opened by the transaction monitoring job. Key
+// identifies the alert that opened it, so later runs of the job do not open
+// the same case again.
+type Case struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key        string             `bson:"key" json:"key"`
+	Type       string             `bson:"type" json:"type"`
+	AccountID  string             `bson:"accountId" json:"accountId"`
+	CustomerID string             `bson:"customerId,omitempty" json:"customerId,omitempty"`
+	Status     string             `bson:"status" json:"status"`
+	Summary    string             `bson:"summary" json:"summary"`
+	Evidence   CaseEvidence       `bson:"evidence" json:"evidence"`
+	Notes      []CaseNote         `bson:"notes" json:"notes"`
+	History    []CaseStatusChange `bson:"history" json:"history"` // Status changes, oldest first
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CaseEvidence is the activity that raised the alert.
+type CaseEvidence struct {
+	From       time.Time `bson:"from" json:"from"`
+	To         time.Time `bson:"to" json:"to"`
+	Deposits   int64     `bson:"deposits" json:"deposits"`
+	Inflow     int64     `bson:"inflow" json:"inflow"`
+	Outflow    int64     `bson:"outflow" json:"outflow"`
+	Baseline   int64     `bson:"baseline,omitempty" json:"baseline,omitempty"` // Volume expected in the window from the customer's history
+	References []string  `bson:"references" json:"references"`                 // Journal entry references and authorization IDs
+}
+
+// CaseNote is a note left on a case by an analyst.
+type CaseNote struct {
+	Author string    `bson:"author" json:"author"`
+	Text   string    `bson:"text" json:"text"`
+	At     time.Time `bson:"at" json:"at"`
+}
+
+// CaseStatusChange records a move of a case through the status workflow.
+type CaseStatusChange struct {
+	From   string    `bson:"from,omitempty" json:"from,omitempty"`
+	To     string    `bson:"to" json:"to"`
+	Actor  string    `bson:"actor" json:"actor"`
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	At     time.Time `bson:"at" json:"at"`
+}
+
+// AccountActivity totals the deposits or card spend of a sub-account in a window.
+type AccountActivity struct {
+	AccountID  string   `bson:"accountId"`
+	Count      int64    `bson:"count"`
+	Amount     int64    `bson:"amount"`
+	References []string `bson:"references"`
+}
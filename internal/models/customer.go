@@ -9,8 +9,18 @@ import (
 type Customer struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty"`
 	CustomerID string             `bson:"customerId"`
+	Type       string             `bson:"type,omitempty"` // individual or business; customers from before business onboarding are individuals
 	Name       string             `bson:"name"`
 	Email      string             `bson:"email"`
 	AccountID  string             `bson:"accountId"`
 	CreatedAt  time.Time          `bson:"createdAt"`
+
+	DateOfBirth     string    `bson:"dateOfBirth,omitempty"`
+	NationalityCode string    `bson:"nationalityCode,omitempty"`
+	ScreenedAt      time.Time `bson:"screenedAt,omitempty"` // Import time of the newest watchlist the customer was screened against
+
+	Tier          string            `bson:"tier,omitempty"`          // Verified KYC tier; customers onboarded before tiering are tier-2
+	Verifications []KYCVerification `bson:"verifications,omitempty"` // KYC verifications, oldest first
+
+	Business *BusinessProfile `bson:"business,omitempty"` // Registration and officers of a business customer
 }
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"card-service/internal/api"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Decision check results.
+const (
+	CheckPass = "pass"
+	CheckFail = "fail"
+	CheckSkip = "skip" // Check does not apply to the authorization
+	CheckFlag = "flag" // Check queued the authorization for review
+)
+
+// Shadow decision flips.
+const (
+	FlipApproveToDecline = "approve-to-decline"
+	FlipDeclineToApprove = "decline-to-approve"
+)
+
+// AuthorizationDecision is the audit record of one authorization decision. It
+// is written once and never updated.
+type AuthorizationDecision struct {
+	ID              primitive.ObjectID            `bson:"_id,omitempty" json:"id"`
+	AuthorizationID string                        `bson:"authorizationId" json:"authorizationId"`
+	CardID          string                        `bson:"cardId" json:"cardId"`
+	Event           api.AuthorizationRequestEvent `bson:"event" json:"event"`
+	Card            *Card                         `bson:"card,omitempty" json:"card,omitempty"`         // Card as it was when the decision was made
+	Customer        *Customer                     `bson:"customer,omitempty" json:"customer,omitempty"` // Cardholder as they were when the decision was made
+	Balance         *DecisionBalance              `bson:"balance,omitempty" json:"balance,omitempty"`
+	Checks          []DecisionCheck               `bson:"checks" json:"checks"`
+	Shadow          *ShadowDecision               `bson:"shadow,omitempty" json:"shadow,omitempty"` // Decision of the candidate policy
+	Response        api.AuthorizationResponse     `bson:"response" json:"response"`
+	Error           string                        `bson:"error,omitempty" json:"error,omitempty"`
+	Timing          *DecisionTiming               `bson:"timing,omitempty" json:"timing,omitempty"`
+	DecidedAt       time.Time                     `bson:"decidedAt" json:"decidedAt"`
+}
+
+// DecisionBalance is the balance seen while deciding an authorization.
+type DecisionBalance struct {
+	Issuer    int64  `bson:"issuer" json:"issuer"`                     // Balance reported by the issuer, or the cached one when standing in
+	Holds     int64  `bson:"holds" json:"holds"`                       // Open holds deducted from the issuer balance
+	Ledger    *int64 `bson:"ledger,omitempty" json:"ledger,omitempty"` // Ledger balance, for ledger-backed accounts
+	Available int64  `bson:"available" json:"available"`               // Balance the authorization was checked against
+	StandIn   bool   `bson:"standIn" json:"standIn"`
+}
+
+// DecisionCheck is the outcome of one check of an authorization decision.
+type DecisionCheck struct {
+	Name   string `bson:"name" json:"name"`
+	Result string `bson:"result" json:"result"`
+	Detail string `bson:"detail,omitempty" json:"detail,omitempty"`
+}
+
+// ShadowDecision is the decision the candidate rules would have made for an
+// authorization, compared with the decision of the live rules.
+type ShadowDecision struct {
+	LiveOutcome string   `bson:"liveOutcome" json:"liveOutcome"`
+	LiveCode    string   `bson:"liveCode,omitempty" json:"liveCode,omitempty"`
+	Outcome     string   `bson:"outcome" json:"outcome"`
+	Code        string   `bson:"code,omitempty" json:"code,omitempty"`
+	RuleID      string   `bson:"ruleId,omitempty" json:"ruleId,omitempty"` // Candidate rule that decided
+	Flags       []string `bson:"flags,omitempty" json:"flags,omitempty"`
+	Disagrees   bool     `bson:"disagrees" json:"disagrees"`                       // Outcome or decline code differs
+	Flip        string   `bson:"flip,omitempty" json:"flip,omitempty"`             // Set when the outcome differs
+	FlipRuleID  string   `bson:"flipRuleId,omitempty" json:"flipRuleId,omitempty"` // Rule the flip is attributed to
+	Error       string   `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// ShadowFlipCount is the number of decisions a rule would flip one way.
+type ShadowFlipCount struct {
+	RuleID string `bson:"ruleId" json:"ruleId"`
+	Flip   string `bson:"flip" json:"flip"`
+	Count  int64  `bson:"count" json:"count"`
+}
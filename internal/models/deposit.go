@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Deposit statuses, following the status of the payment.
+const (
+	DepositPending   = "pending"
+	DepositCompleted = "completed" // Funds received; credited unless quarantined
+	DepositFailed    = "failed"
+)
+
+// Quarantine statuses of a deposit.
+const (
+	QuarantineOpen     = "open"     // Waiting for a review
+	QuarantineReleased = "released" // Credited to its account after the review
+	QuarantineRejected = "rejected" // Not credited; to be returned to the payer
+)
+
+// Reasons a deposit is quarantined.
+const (
+	QuarantineUnknownAccount  = "unknown-account"
+	QuarantineInactiveAccount = "inactive-account"
+)
+
+// Deposit is a payment into the virtual account of a sub account, keyed by
+// the ID of the payment so redelivered events never credit it twice.
+type Deposit struct {
+	ID         primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
+	PaymentID  string                `bson:"paymentId" json:"paymentId"`
+	AccountID  string                `bson:"accountId" json:"accountId"` // Virtual account paid into
+	CustomerID string                `bson:"customerId,omitempty" json:"customerId,omitempty"`
+	Amount     int64                 `bson:"amount" json:"amount"`
+	Currency   string                `bson:"currency" json:"currency"`
+	Status     string                `bson:"status" json:"status"`
+	History    []DepositStatusChange `bson:"history" json:"history"` // Status changes, oldest first
+	Quarantine *DepositQuarantine    `bson:"quarantine,omitempty" json:"quarantine,omitempty"`
+	CreditedAt *time.Time            `bson:"creditedAt,omitempty" json:"creditedAt,omitempty"`
+	PaidAt     time.Time             `bson:"paidAt" json:"paidAt"`
+	CreatedAt  time.Time             `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time             `bson:"updatedAt" json:"updatedAt"`
+}
+
+// DepositStatusChange records a status a deposit moved to.
+type DepositStatusChange struct {
+	Status string    `bson:"status" json:"status"`
+	At     time.Time `bson:"at" json:"at"`
+}
+
+// DepositQuarantine holds a deposit back from its account until a review.
+type DepositQuarantine struct {
+	Status        string     `bson:"status" json:"status"`
+	Reason        string     `bson:"reason" json:"reason"`
+	AccountStatus string     `bson:"accountStatus,omitempty" json:"accountStatus,omitempty"` // Status of the account when the deposit arrived
+	At            time.Time  `bson:"at" json:"at"`
+	Reviewer      string     `bson:"reviewer,omitempty" json:"reviewer,omitempty"`
+	Note          string     `bson:"note,omitempty" json:"note,omitempty"`
+	ReviewedAt    *time.Time `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Hold statuses.
+const (
+	HoldOpen     = "open"     // Funds are reserved for an unsettled authorization
+	HoldReleased = "released" // Authorization was declined or reversed
+	HoldCaptured = "captured" // Authorization closed approved and settled by the issuer
+	HoldExpired  = "expired"  // Authorization was never closed within the expiry period
+)
+
+// Hold reserves funds of a sub-account for an approved authorization until the
+// issuer settles or releases it.
+type Hold struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AuthorizationID string             `bson:"authorizationId" json:"authorizationId"`
+	CardID          string             `bson:"cardId" json:"cardId"`
+	AccountID       string             `bson:"accountId" json:"accountId"` // Funding sub-account of the card
+	Amount          int64              `bson:"amount" json:"amount"`
+	Fees            int64              `bson:"fees" json:"fees"`
+	Status          string             `bson:"status" json:"status"`
+	ReleaseReason   string             `bson:"releaseReason,omitempty" json:"releaseReason,omitempty"`
+	ExpiresAt       time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
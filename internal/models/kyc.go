@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// KYC tiers, lowest first.
+const (
+	KYCTier1 = "tier-1" // Onboarded on a NIN
+	KYCTier2 = "tier-2" // Onboarded on a BVN
+	KYCTier3 = "tier-3" // Identity document and address verified
+)
+
+// KYCTiers lists the tiers from lowest to highest.
+var KYCTiers = []string{KYCTier1, KYCTier2, KYCTier3}
+
+// Verification statuses.
+const (
+	VerificationPending  = "pending"
+	VerificationVerified = "verified"
+	VerificationRejected = "rejected"
+)
+
+// KYCVerification is the verification of a customer at a tier: the initial
+// tier granted at onboarding, or a requested upgrade.
+type KYCVerification struct {
+	ID          string                     `bson:"id" json:"id"`
+	Tier        string                     `bson:"tier" json:"tier"`
+	Status      string                     `bson:"status" json:"status"`
+	IDType      string                     `bson:"idType,omitempty" json:"idType,omitempty"` // Identity the initial tier was granted on
+	Documents   []KYCDocument              `bson:"documents,omitempty" json:"documents,omitempty"`
+	RequestedAt time.Time                  `bson:"requestedAt" json:"requestedAt"`
+	History     []VerificationStatusChange `bson:"history" json:"history"` // Status changes, oldest first
+}
+
+// KYCDocument is the metadata of a document supporting a tier upgrade. The
+// document itself is kept by the document store under Reference.
+type KYCDocument struct {
+	Type           string `bson:"type" json:"type"` // e.g. passport, drivers-license, utility-bill
+	Number         string `bson:"number,omitempty" json:"number,omitempty"`
+	IssuingCountry string `bson:"issuingCountry,omitempty" json:"issuingCountry,omitempty"`
+	ExpiresAt      string `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // YYYY-MM-DD
+	Reference      string `bson:"reference" json:"reference"`
+}
+
+// VerificationStatusChange records a change of the status of a verification.
+type VerificationStatusChange struct {
+	From   string    `bson:"from,omitempty" json:"from,omitempty"`
+	To     string    `bson:"to" json:"to"`
+	Actor  string    `bson:"actor" json:"actor"`
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	At     time.Time `bson:"at" json:"at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ledger account types. Assets and expenses are debit-normal; liabilities and
+// revenue are credit-normal.
+const (
+	LedgerAsset     = "asset"
+	LedgerLiability = "liability"
+	LedgerRevenue   = "revenue"
+	LedgerExpense   = "expense"
+)
+
+// Posting directions.
+const (
+	Debit  = "debit"
+	Credit = "credit"
+)
+
+// Journal entry types.
+const (
+	EntryDeposit       = "deposit"
+	EntryAuthorization = "authorization"
+	EntryAdjustment    = "adjustment"
+	EntryReversal      = "reversal"
+	EntryRelease       = "release"
+	EntryExpiry        = "expiry" // Release of the held funds of an authorization never closed
+	EntryCapture       = "capture"
+	EntryFee           = "fee"
+)
+
+// LedgerAccount is an account of the internal double-entry ledger. Customer
+// sub-accounts are backed by an available and a held ledger account.
+type LedgerAccount struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code       string             `bson:"code" json:"code"` // Unique code, e.g. customer:<accountId>:available
+	Name       string             `bson:"name" json:"name"`
+	Type       string             `bson:"type" json:"type"`
+	AccountID  string             `bson:"accountId,omitempty" json:"accountId,omitempty"` // Sub-account backed by this ledger account
+	CustomerID string             `bson:"customerId,omitempty" json:"customerId,omitempty"`
+	Currency   string             `bson:"currency" json:"currency"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// JournalEntry is an immutable, balanced set of postings. Its reference makes
+// posting the same business event twice a no-op.
+type JournalEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Reference   string             `bson:"reference" json:"reference"`
+	Type        string             `bson:"type" json:"type"`
+	Description string             `bson:"description" json:"description"`
+	Postings    []Posting          `bson:"postings" json:"postings"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Posting is one debit or credit of a journal entry.
+type Posting struct {
+	AccountCode string `bson:"accountCode" json:"accountCode"`
+	AccountID   string `bson:"accountId,omitempty" json:"accountId,omitempty"` // Sub-account the ledger account backs, if any
+	Direction   string `bson:"direction" json:"direction"`
+	Amount      int64  `bson:"amount" json:"amount"`
+	Currency    string `bson:"currency" json:"currency"`
+}
+
+// PostingLine is a posting together with the entry it belongs to, as listed for an account.
+type PostingLine struct {
+	EntryID     primitive.ObjectID `bson:"entryId" json:"entryId"`
+	Reference   string             `bson:"reference" json:"reference"`
+	Type        string             `bson:"type" json:"type"`
+	Description string             `bson:"description" json:"description"`
+	Posting     Posting            `bson:"posting" json:"posting"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Onboarding statuses.
+const (
+	OnboardingInProgress = "in-progress"
+	OnboardingInReview   = "in-review" // Held for a sanctions screening review
+	OnboardingFailed     = "failed"    // A step failed; retrying resumes after the last completed step
+	OnboardingCompleted  = "completed"
+	OnboardingRejected   = "rejected" // Blocked or confirmed by sanctions screening
+)
+
+// Onboarding steps, in the order they complete.
+const (
+	StepScreened       = "screened"        // Screening recorded and cleared
+	StepIssuerCustomer = "issuer-customer" // Customer created with the issuer
+	StepSubAccount     = "sub-account"     // Sub account created with the issuer
+	StepStored         = "stored"          // Customer, account and ledger stored in one transaction
+)
+
+// Onboarding is the saga that onboards a customer, keyed by the reference of
+// the onboarding request. Issuer calls are recorded as steps so a retry never
+// repeats one that completed.
+type Onboarding struct {
+	ID              primitive.ObjectID   `bson:"_id,omitempty" json:"-"`
+	Ref             string               `bson:"ref" json:"ref"`
+	Type            string               `bson:"type" json:"type"` // individual or business
+	Status          string               `bson:"status" json:"status"`
+	Steps           []OnboardingStep     `bson:"steps" json:"steps"`
+	Attempts        int                  `bson:"attempts" json:"attempts"`
+	LastError       string               `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	ScreeningID     string               `bson:"screeningId,omitempty" json:"screeningId,omitempty"`
+	ScreenedAt      time.Time            `bson:"screenedAt,omitempty" json:"-"` // Import time of the newest list screened against
+	CustomerID      string               `bson:"customerId,omitempty" json:"customerId,omitempty"`
+	AccountID       string               `bson:"accountId,omitempty" json:"accountId,omitempty"`
+	DepositChannels []DepositChannel     `bson:"depositChannels,omitempty" json:"depositChannels,omitempty"`
+	Application     *CustomerApplication `bson:"application,omitempty" json:"-"`
+	Business        *BusinessApplication `bson:"business,omitempty" json:"-"`
+	LockedUntil     *time.Time           `bson:"lockedUntil,omitempty" json:"-"` // Lease of the attempt running the saga
+	CreatedAt       time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time            `bson:"updatedAt" json:"updatedAt"`
+}
+
+// OnboardingStep is a completed step of an onboarding.
+type OnboardingStep struct {
+	Name string    `bson:"name" json:"name"`
+	At   time.Time `bson:"at" json:"at"`
+}
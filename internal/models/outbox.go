@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outbox operation statuses.
+const (
+	OutboxPending    = "pending"    // Waiting for its first or next attempt
+	OutboxProcessing = "processing" // Claimed by an attempt until LockedUntil
+	OutboxCompleted  = "completed"
+	OutboxFailed     = "failed" // Gave up after the last attempt
+)
+
+// Outbox operation types.
+const (
+	OpCreateCustomer     = "create-customer"
+	OpCreateSubAccount   = "create-sub-account"
+	OpLinkCard           = "link-card"
+	OpActivateCard       = "activate-card"
+	OpUpdateCardStatus   = "update-card-status"
+	OpUpdateCardControls = "update-card-controls"
+)
+
+// OutboxOperation is an issuer call recorded in the same transaction as the
+// local change that needs it, and executed by the outbox dispatcher.
+type OutboxOperation struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type          string             `bson:"type" json:"type"`
+	Key           string             `bson:"key" json:"key"`         // Idempotency key sent to the issuer
+	Subject       string             `bson:"subject" json:"subject"` // Local record the result is written back to
+	Status        string             `bson:"status" json:"status"`
+	Payload       []byte             `bson:"payload,omitempty" json:"-"` // Encrypted issuer request; removed once the operation settles
+	Result        bson.Raw           `bson:"result,omitempty" json:"-"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastError     string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	LockedUntil   *time.Time         `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updatedAt" json:"updatedAt"`
+	CompletedAt   *time.Time         `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+}
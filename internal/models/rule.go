@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Rule outcomes.
+const (
+	RuleApprove = "approve" // Approve without evaluating lower priority rules
+	RuleDecline = "decline" // Decline without evaluating lower priority rules
+	RuleFlag    = "flag"    // Queue the authorization for review and keep evaluating
+)
+
+// Rule scopes.
+const (
+	RuleScopeGlobal  = "global"
+	RuleScopeProgram = "program" // Applies to cards of the program in ScopeID
+	RuleScopeCard    = "card"    // Applies to the card in ScopeID
+)
+
+// Rule is one version of an authorization rule. Changing a rule stores a new
+// version; only the current version of each rule is evaluated. A rule can have
+// a current live version and a current shadow version: shadow versions form
+// the candidate policy, which is evaluated alongside the live one without
+// affecting decisions.
+type Rule struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RuleID      string             `bson:"ruleId" json:"ruleId"`
+	Version     int                `bson:"version" json:"version"`
+	Current     bool               `bson:"current" json:"current"`
+	Shadow      bool               `bson:"shadow" json:"shadow"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Scope       string             `bson:"scope" json:"scope"`
+	ScopeID     string             `bson:"scopeId,omitempty" json:"scopeId,omitempty"`
+	Priority    int                `bson:"priority" json:"priority"` // Lower priorities are evaluated first
+	Expression  string             `bson:"expression" json:"expression"`
+	Outcome     string             `bson:"outcome" json:"outcome"`
+	Code        string             `bson:"code,omitempty" json:"code,omitempty"` // Decline code returned to the issuer
+	Enabled     bool               `bson:"enabled" json:"enabled"`
+	CreatedBy   string             `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
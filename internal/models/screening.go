@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Screening results.
+const (
+	ScreeningClear   = "clear"   // No entry scored at the review threshold
+	ScreeningReview  = "review"  // The best match needs a manual review
+	ScreeningBlocked = "blocked" // The best match scored at the block threshold
+)
+
+// Screening triggers.
+const (
+	ScreeningOnboarding = "onboarding"
+	ScreeningRescreen   = "rescreen" // Existing customer screened against an updated list
+)
+
+// Screening review statuses.
+const (
+	ReviewPending   = "pending"
+	ReviewCleared   = "cleared"   // False positive; onboarding resumes
+	ReviewConfirmed = "confirmed" // True match; onboarding is rejected or the account frozen
+)
+
+// Watchlist is the latest import of a sanctions or watchlist file.
+type Watchlist struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Source     string             `bson:"source" json:"source"` // Name of the list, e.g. un-consolidated
+	Format     string             `bson:"format" json:"format"` // csv or xml
+	Entries    int                `bson:"entries" json:"entries"`
+	Checksum   string             `bson:"checksum" json:"checksum"` // SHA-256 of the imported file
+	ImportedAt time.Time          `bson:"importedAt" json:"importedAt"`
+}
+
+// WatchlistEntry is a listed person. Entries of the latest import of a list
+// share its ImportedAt.
+type WatchlistEntry struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Source        string             `bson:"source" json:"source"`
+	EntryID       string             `bson:"entryId" json:"entryId"`
+	Name          string             `bson:"name" json:"name"`
+	Aliases       []string           `bson:"aliases,omitempty" json:"aliases,omitempty"`
+	DatesOfBirth  []string           `bson:"datesOfBirth,omitempty" json:"datesOfBirth,omitempty"`
+	Nationalities []string           `bson:"nationalities,omitempty" json:"nationalities,omitempty"`
+	ImportedAt    time.Time          `bson:"importedAt" json:"importedAt"`
+}
+
+// Screening is the result of screening a person against the watchlists.
+// Screenings that need a review wait in the review queue.
+type Screening struct {
+	ID              primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Trigger         string               `bson:"trigger" json:"trigger"`
+	Ref             string               `bson:"ref,omitempty" json:"ref,omitempty"` // Reference of the onboarding request
+	CustomerID      string               `bson:"customerId,omitempty" json:"customerId,omitempty"`
+	AccountID       string               `bson:"accountId,omitempty" json:"accountId,omitempty"`
+	Subject         ScreeningSubject     `bson:"subject" json:"subject"`
+	Result          string               `bson:"result" json:"result"`
+	Matches         []ScreeningMatch     `bson:"matches" json:"matches"`
+	ListsImportedAt time.Time            `bson:"listsImportedAt" json:"listsImportedAt"` // Import time of the newest list screened against
+	ReviewStatus    string               `bson:"reviewStatus,omitempty" json:"reviewStatus,omitempty"`
+	ReviewedBy      string               `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewNote      string               `bson:"reviewNote,omitempty" json:"reviewNote,omitempty"`
+	ReviewedAt      *time.Time           `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+	Application     *CustomerApplication `bson:"application,omitempty" json:"-"` // Onboarding request held until the review
+	Business        *BusinessApplication `bson:"business,omitempty" json:"-"`    // Business onboarding request held until the review
+	CreatedAt       time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// ScreeningSubject is the person screened.
+type ScreeningSubject struct {
+	Name        string `bson:"name" json:"name"`
+	DateOfBirth string `bson:"dateOfBirth,omitempty" json:"dateOfBirth,omitempty"`
+	Nationality string `bson:"nationality,omitempty" json:"nationality,omitempty"`
+}
+
+// ScreeningMatch is a watchlist entry that resembles the subject.
+type ScreeningMatch struct {
+	Party       string  `bson:"party,omitempty" json:"party,omitempty"` // Person or business matched when a screening covers several
+	Source      string  `bson:"source" json:"source"`
+	EntryID     string  `bson:"entryId" json:"entryId"`
+	Name        string  `bson:"name" json:"name"`
+	MatchedName string  `bson:"matchedName" json:"matchedName"` // Name or alias that matched best
+	NameScore   float64 `bson:"nameScore" json:"nameScore"`
+	Score       float64 `bson:"score" json:"score"`
+	DateOfBirth string  `bson:"dateOfBirth,omitempty" json:"dateOfBirth,omitempty"` // match or mismatch
+	Nationality string  `bson:"nationality,omitempty" json:"nationality,omitempty"` // match or mismatch
+}
+
+// CustomerApplication is an onboarding request for an individual customer.
+type CustomerApplication struct {
+	Name            string `bson:"name"`
+	FirstName       string `bson:"firstName"`
+	LastName        string `bson:"lastName"`
+	MiddleName      string `bson:"middleName,omitempty"`
+	Email           string `bson:"email"`
+	PhoneNumber     string `bson:"phoneNumber"`
+	Title           string `bson:"title"`
+	Gender          string `bson:"gender"`
+	DateOfBirth     string `bson:"dateOfBirth"`
+	NationalityCode string `bson:"nationalityCode"`
+	IDType          string `bson:"idType"`
+	IDNumber        string `bson:"idNumber"`
+	IssuingCountry  string `bson:"issuingCountry"`
+	UserID          int    `bson:"userId"`
+	Ref             string `bson:"ref"`
+}
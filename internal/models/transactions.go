@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type NetworkData struct {
 	CardAcceptorNameLocation string `bson:"cardAcceptorNameLocation"`
 	TerminalID               string `bson:"terminalId"`
@@ -7,12 +9,18 @@ type NetworkData struct {
 	Reference                string `bson:"reference"` // Reference number for the transaction
 	RRN                      string `bson:"rrn"`       // Retrieval Reference Number
 	STAN                     string `bson:"stan"`      // System Trace Audit Number
+	MCC                      string `bson:"mcc"`       // Merchant Category Code
+	MerchantID               string `bson:"merchantId"`
+	MerchantName             string `bson:"merchantName"` // Parsed from CardAcceptorNameLocation
+	MerchantCity             string `bson:"merchantCity"`
+	MerchantCountry          string `bson:"merchantCountry"`
 }
 type Transaction struct {
 	ID            string      `bson:"id"`
 	Authorization string      `bson:"authorizationId"`
 	CardID        string      `bson:"cardId"`
 	CustomerID    string      `bson:"customerId"`
+	AccountID     string      `bson:"accountId,omitempty"` // Funding sub-account of the card
 	Amount        int64       `bson:"amount"`
 	Currency      string      `bson:"currency"`
 	Type          string      `bson:"type"` // Transaction type(e.g Authorization, deposits)
@@ -20,11 +28,63 @@ type Transaction struct {
 	Channel       string      `bson:"channel"`     // Channel through which the transaction was made (e.g POS, ATM, Online)
 	NetworkData   NetworkData `bson:"networkData"` // Network data related to the transaction
 	Status        string      `bson:"status"`
+	Reversal      string      `bson:"reversal,omitempty"`     // "partial" or "full" once the authorization has been reversed
+	StandIn       bool        `bson:"standIn,omitempty"`      // Approved on a cached balance while the issuer was unavailable
+	Fallback      bool        `bson:"fallback,omitempty"`     // Approved by the fallback decision after the latency budget ran out
+	Flags         []string    `bson:"flags,omitempty"`        // Flag rules that matched the authorization
+	ReviewStatus  string      `bson:"reviewStatus,omitempty"` // "pending-review" for stand-in approvals until reviewed
+
+	Timing         *DecisionTiming `bson:"timing,omitempty"`         // Time spent deciding the authorization
+	History        []AmountChange  `bson:"history,omitempty"`        // Amount changes of the authorization, oldest first
+	Reconciliation *Reconciliation `bson:"reconciliation,omitempty"` // Result of reconciling the closed authorization
 
 	CreatedAt string `bson:"createdAt"`
 	UpdatedAt string `bson:"updatedAt,omitempty"` // Optional field for the last update time
 }
 
+// DecisionTiming records how long an authorization decision took, step by step.
+type DecisionTiming struct {
+	BudgetMs  int64        `bson:"budgetMs" json:"budgetMs"`
+	ElapsedMs float64      `bson:"elapsedMs" json:"elapsedMs"`
+	Exceeded  bool         `bson:"exceeded" json:"exceeded"` // The budget ran out and the fallback decision was used
+	Steps     []StepTiming `bson:"steps" json:"steps"`
+}
+
+// StepTiming is the duration of one step of an authorization decision.
+type StepTiming struct {
+	Step       string  `bson:"step" json:"step"`
+	DurationMs float64 `bson:"durationMs" json:"durationMs"`
+}
+
+// Amount change types recorded in a transaction history.
+const (
+	AmountChangeAuthorization   = "authorization"
+	AmountChangeIncremental     = "incremental"
+	AmountChangePartialReversal = "partial-reversal"
+	AmountChangeReversal        = "reversal"
+	AmountChangeClosed          = "closed"
+)
+
+// AmountChange is one change to the amount held by an authorization.
+type AmountChange struct {
+	Type           string    `bson:"type"`
+	EventStatus    string    `bson:"eventStatus,omitempty"` // Status carried by the issuer event
+	PreviousAmount int64     `bson:"previousAmount"`
+	Amount         int64     `bson:"amount"` // Authorized amount after the change
+	Delta          int64     `bson:"delta"`
+	Fees           int64     `bson:"fees"`
+	At             time.Time `bson:"at"`
+}
+
+// Reconciliation compares the amount of a closed authorization with its history.
+type Reconciliation struct {
+	ExpectedAmount int64     `bson:"expectedAmount"` // Amount after the last update before closure
+	ClosedAmount   int64     `bson:"closedAmount"`
+	Difference     int64     `bson:"difference"`
+	Matched        bool      `bson:"matched"`
+	At             time.Time `bson:"at"`
+}
+
 type AuthorizationRequestEvent struct {
 	CardID        string      `bson:"cardId"`
 	Authorization string      `bson:"authorizationId"`
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TravelNotice pre-authorizes the cards of a customer in countries outside
+// their allowed countries for a date range.
+type TravelNotice struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CustomerID  string             `bson:"customerId" json:"customerId"`
+	Countries   []string           `bson:"countries" json:"countries"` // ISO 3166 alpha-2 codes
+	From        string             `bson:"from" json:"from"`           // First day of travel, YYYY-MM-DD
+	To          string             `bson:"to" json:"to"`               // Last day of travel, YYYY-MM-DD
+	StartsAt    time.Time          `bson:"startsAt" json:"startsAt"`   // Start of From in the service timezone
+	EndsAt      time.Time          `bson:"endsAt" json:"endsAt"`       // End of To in the service timezone
+	Note        string             `bson:"note,omitempty" json:"note,omitempty"`
+	CancelledAt *time.Time         `bson:"cancelledAt,omitempty" json:"cancelledAt,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// CardVelocity holds the most recent authorizations of a card, bounded in
+// size, so velocity checks never scan the transactions collection.
+type CardVelocity struct {
+	CardID    string          `bson:"cardId"`
+	Events    []VelocityEvent `bson:"events"` // Oldest first
+	UpdatedAt time.Time       `bson:"updatedAt"`
+}
+
+// VelocityEvent is one authorization decision of a card.
+type VelocityEvent struct {
+	AuthorizationID string    `bson:"authorizationId"`
+	At              time.Time `bson:"at"`
+	Amount          int64     `bson:"amount"`
+	Approved        bool      `bson:"approved"`
+	Merchant        string    `bson:"merchant,omitempty"` // Merchant ID, or the acceptor name without one
+	City            string    `bson:"city,omitempty"`
+	Country         string    `bson:"country,omitempty"`
+}
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"card-service/internal/api"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // AllaweeEventBody represents the webhook payload from the card issuing service.
 type AllaweeEventBody struct {
@@ -51,3 +56,39 @@ type PaymentData struct {
 	Status           string    `json:"status"`           // Status of the payment (e.g., pending, completed, failed)
 	CreatedAt        time.Time `json:"createdAt"`        // Timestamp when the payment was created
 }
+
+// Webhook event statuses in the inbox.
+const (
+	WebhookEventReceived  = "received"  // Stored, not processed yet
+	WebhookEventProcessed = "processed" // Handler ran and a decision was recorded
+	WebhookEventFailed    = "failed"    // Handler failed; a redelivery or replay will retry it
+	WebhookEventUnhandled = "unhandled" // No handler is registered for the event
+	WebhookEventRejected  = "rejected"  // Signature or envelope was invalid
+)
+
+// WebhookEventRecord is a raw inbound webhook as stored in the inbox before processing.
+type WebhookEventRecord struct {
+	ID              primitive.ObjectID         `bson:"_id,omitempty" json:"id"`
+	Key             string                     `bson:"key,omitempty" json:"key,omitempty"` // Dedupe key (event name and data ID)
+	Event           string                     `bson:"event" json:"event"`
+	Headers         map[string]string          `bson:"headers" json:"headers"`
+	Body            string                     `bson:"body" json:"body"` // Raw request body
+	SignatureValid  bool                       `bson:"signatureValid" json:"signatureValid"`
+	Status          string                     `bson:"status" json:"status"`
+	Response        *api.AuthorizationResponse `bson:"response,omitempty" json:"response,omitempty"` // Decision returned on first successful processing
+	Error           string                     `bson:"error,omitempty" json:"error,omitempty"`
+	Deliveries      int                        `bson:"deliveries" json:"deliveries"` // Number of times the issuer sent the event
+	Attempts        []WebhookEventAttempt      `bson:"attempts" json:"attempts"`
+	ReceivedAt      time.Time                  `bson:"receivedAt" json:"receivedAt"`
+	ProcessedAt     *time.Time                 `bson:"processedAt,omitempty" json:"processedAt,omitempty"`
+	LastDeliveredAt time.Time                  `bson:"lastDeliveredAt" json:"lastDeliveredAt"`
+}
+
+// WebhookEventAttempt records one run of an inbox event through the pipeline.
+type WebhookEventAttempt struct {
+	Trigger  string                     `bson:"trigger" json:"trigger"` // delivery, redelivery or replay
+	Status   string                     `bson:"status" json:"status"`
+	Response *api.AuthorizationResponse `bson:"response,omitempty" json:"response,omitempty"`
+	Error    string                     `bson:"error,omitempty" json:"error,omitempty"`
+	At       time.Time                  `bson:"at" json:"at"`
+}
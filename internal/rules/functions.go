@@ -0,0 +1,57 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+type function struct {
+	arity int // Number of arguments, or -1 for any
+	call  func(args []interface{}) (interface{}, error)
+}
+
+// functions are the functions available to expressions.
+var functions = map[string]function{
+	"len": {arity: 1, call: func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("len of %s", typeName(args[0]))
+	}},
+	"lower": {arity: 1, call: stringFunc(strings.ToLower)},
+	"upper": {arity: 1, call: stringFunc(strings.ToUpper)},
+	"contains": {arity: 2, call: func(args []interface{}) (interface{}, error) {
+		return contains(args[0], args[1])
+	}},
+	"startsWith": {arity: 2, call: stringPredicate(strings.HasPrefix)},
+	"endsWith":   {arity: 2, call: stringPredicate(strings.HasSuffix)},
+}
+
+func stringFunc(fn func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", typeName(args[0]))
+		}
+		return fn(s), nil
+	}
+}
+
+func stringPredicate(fn func(string, string) bool) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return false, nil
+		}
+		prefix, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", typeName(args[1]))
+		}
+		return fn(s, prefix), nil
+	}
+}
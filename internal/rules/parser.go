@@ -0,0 +1,286 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Expression is a compiled rule expression.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression. The grammar, loosest binding first:
+//
+//	a || b, a or b
+//	a && b, a and b
+//	a == b, a != b, a < b, a <= b, a > b, a >= b, a in b, a not in b
+//	a + b, a - b
+//	a * b, a / b
+//	!a, not a, -a
+//	literals (12, 1.5, "text", true, false, null, [a, b]), dotted paths
+//	(event.amount) and function calls (len(card.controls.allowedChannels))
+func Compile(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token when it is one of the given operators or
+// keywords and returns its canonical operator.
+func (p *parser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOperator && tok.kind != tokIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.next()
+			return canonical(op), true
+		}
+	}
+	return "", false
+}
+
+func canonical(op string) string {
+	switch op {
+	case "or":
+		return "||"
+	case "and":
+		return "&&"
+	case "not":
+		return "!"
+	}
+	return op
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	tok := p.next()
+	if tok.kind != kind {
+		return fmt.Errorf("expected %q at %d, got %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("||", "or")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("&&", "and")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">", "in")
+	if !ok {
+		tok := p.peek()
+		if tok.kind != tokIdent || tok.text != "not" || p.tokens[p.pos+1].text != "in" {
+			return left, nil
+		}
+		p.next()
+		p.next()
+		op = "not in"
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "not", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", tok.text, tok.pos)
+		}
+		return &literalNode{value: value}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(tokRParen, ")")
+	case tokLBracket:
+		items, err := p.parseList(tokRBracket, "]")
+		if err != nil {
+			return nil, err
+		}
+		return &listNode{items: items}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.peek().kind == tokLParen {
+			p.next()
+			fn, ok := functions[tok.text]
+			if !ok {
+				return nil, fmt.Errorf("unknown function %q at %d", tok.text, tok.pos)
+			}
+			args, err := p.parseList(tokRParen, ")")
+			if err != nil {
+				return nil, err
+			}
+			if fn.arity >= 0 && len(args) != fn.arity {
+				return nil, fmt.Errorf("%s expects %d arguments, got %d at %d", tok.text, fn.arity, len(args), tok.pos)
+			}
+			return &callNode{fn: fn, args: args}, nil
+		}
+		path := []string{tok.text}
+		for p.peek().kind == tokDot {
+			p.next()
+			field := p.next()
+			if field.kind != tokIdent {
+				return nil, fmt.Errorf("expected field name at %d", field.pos)
+			}
+			path = append(path, field.text)
+		}
+		return &pathNode{path: path}, nil
+	}
+	if tok.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+// parseList parses comma separated expressions up to the closing token.
+func (p *parser) parseList(closing tokenKind, text string) ([]node, error) {
+	var items []node
+	if p.peek().kind == closing {
+		p.next()
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.peek().kind == tokComma {
+			p.next()
+			continue
+		}
+		return items, p.expect(closing, text)
+	}
+}
@@ -0,0 +1,255 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testEnv() Env {
+	return Env{
+		"event": map[string]interface{}{
+			"amount":   int64(5000),
+			"currency": "NGN",
+			"mcc":      "5411",
+			"channels": []string{"pos", "online"},
+		},
+		"card": Env{
+			"status": "active",
+			"controls": map[string]interface{}{
+				"blockedMccs": []interface{}{"7995"},
+			},
+		},
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want interface{}
+	}{
+		// Precedence
+		{name: "multiplication binds tighter than addition", expr: "1 + 2 * 3", want: float64(7)},
+		{name: "parentheses", expr: "(1 + 2) * 3", want: float64(9)},
+		{name: "subtraction is left associative", expr: "10 - 4 - 3", want: float64(3)},
+		{name: "division is left associative", expr: "12 / 3 / 2", want: float64(2)},
+		{name: "unary minus", expr: "-2 * 3", want: float64(-6)},
+		{name: "arithmetic before comparison", expr: "1 + 1 == 2", want: true},
+		{name: "and binds tighter than or", expr: "true || false && false", want: true},
+		{name: "and binds tighter than or, keywords", expr: "false and true or true", want: true},
+		{name: "not binds tighter than and", expr: "!false && false", want: false},
+		{name: "not keyword", expr: "not true or true", want: true},
+		{name: "comparison before and", expr: "event.amount > 100 && event.currency == \"NGN\"", want: true},
+		{name: "string concatenation", expr: "\"a\" + \"b\" == \"ab\"", want: true},
+		{name: "string comparison", expr: "\"abc\" < \"abd\"", want: true},
+
+		// in / not in
+		{name: "in list", expr: "event.mcc in [\"5411\", \"5812\"]", want: true},
+		{name: "not in list", expr: "event.mcc in [\"5812\"]", want: false},
+		{name: "not in operator", expr: "event.mcc not in card.controls.blockedMccs", want: true},
+		{name: "not in operator, found", expr: "\"7995\" not in card.controls.blockedMccs", want: false},
+		{name: "in normalized slice", expr: "\"online\" in event.channels", want: true},
+		{name: "in string", expr: "\"GN\" in event.currency", want: true},
+		{name: "in null", expr: "1 in null", want: false},
+		{name: "not in null", expr: "1 not in null", want: true},
+		{name: "in arithmetic", expr: "1 + 1 in [2]", want: true},
+
+		// Null and missing paths
+		{name: "missing fact", expr: "missing", want: nil},
+		{name: "missing fact equals null", expr: "missing == null", want: true},
+		{name: "missing nested field", expr: "event.missing.deep == null", want: true},
+		{name: "path through a scalar", expr: "event.amount.value == null", want: true},
+		{name: "missing fact is not a value", expr: "card.status != null", want: true},
+		{name: "missing fact short-circuits and", expr: "missing && 1", want: false},
+		{name: "not missing", expr: "!missing", want: true},
+		{name: "len of missing", expr: "len(missing)", want: float64(0)},
+		{name: "missing startsWith", expr: "startsWith(missing, \"5\")", want: false},
+
+		// Normalization and functions
+		{name: "integer fact", expr: "event.amount", want: float64(5000)},
+		{name: "len of list", expr: "len(event.channels)", want: float64(2)},
+		{name: "lower", expr: "lower(event.currency)", want: "ngn"},
+		{name: "contains", expr: "contains(event.channels, \"pos\")", want: true},
+		{name: "list literal", expr: "[1, \"a\", null]", want: []interface{}{float64(1), "a", nil}},
+		{name: "underscore separators", expr: "1_000 + 1", want: float64(1001)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.expr, err)
+			}
+			got, err := expr.Eval(testEnv())
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval(%q) = %#v; want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalTypeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		err  string
+	}{
+		{name: "number plus string", expr: "1 + \"a\"", err: "cannot apply + to number and string"},
+		{name: "multiply booleans", expr: "true * 2", err: "cannot apply * to boolean and number"},
+		{name: "compare string with number", expr: "event.currency < 1", err: "cannot compare string with number"},
+		{name: "compare null", expr: "missing > 1", err: "cannot compare null"},
+		{name: "number as condition", expr: "1 && true", err: "expected a boolean, got number"},
+		{name: "string as condition", expr: "!event.currency", err: "expected a boolean, got string"},
+		{name: "right operand of or", expr: "false || 1", err: "expected a boolean, got number"},
+		{name: "negate string", expr: "-event.currency", err: "cannot negate string"},
+		{name: "in number", expr: "1 in 5", err: "cannot look for a value in number"},
+		{name: "number in string", expr: "1 in \"abc\"", err: "cannot look for number in a string"},
+		{name: "division by zero", expr: "1 / 0", err: "division by zero"},
+		{name: "upper of number", expr: "upper(event.amount)", err: "expected a string, got number"},
+		{name: "len of number", expr: "len(event.amount)", err: "len of number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.expr, err)
+			}
+			_, err = expr.Eval(testEnv())
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Eval(%q) error = %v; want %q", tt.expr, err, tt.err)
+			}
+			if IsFactError(err) {
+				t.Errorf("Eval(%q) error is a FactError", tt.expr)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: "card.status == \"active\"", want: true},
+		{expr: "card.status == \"frozen\""},
+		{expr: "missing"},
+		{expr: "event.amount", wantErr: true},
+	}
+	for _, tt := range tests {
+		expr, err := Compile(tt.expr)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", tt.expr, err)
+		}
+		got, err := expr.Match(testEnv())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Match(%q) error = %v; want error %v", tt.expr, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q) = %v; want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestLazyFacts(t *testing.T) {
+	errUnavailable := errors.New("velocity store unavailable")
+
+	tests := []struct {
+		name    string
+		expr    string
+		fail    bool
+		loads   int
+		want    interface{}
+		errPath string
+	}{
+		{name: "loaded once across reads", expr: "velocity.count > 1 && velocity.count < 10", loads: 1, want: true},
+		{name: "nested lazy field", expr: "velocity.window.hours == 24", loads: 1, want: true},
+		{name: "not loaded when short-circuited", expr: "false && velocity.count > 1", loads: 0, want: false},
+		{name: "not loaded when unread", expr: "card.status == \"active\"", loads: 0, want: true},
+		{name: "load failure", expr: "velocity.count > 1", fail: true, loads: 1, errPath: "velocity"},
+		{name: "load failure inside a call", expr: "len(velocity.merchants) > 1", fail: true, loads: 1, errPath: "velocity"},
+		{name: "load failure skipped by short-circuit", expr: "true || velocity.count > 1", fail: true, loads: 0, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads := 0
+			env := testEnv()
+			env["velocity"] = Lazy(func() (interface{}, error) {
+				loads++
+				if tt.fail {
+					return nil, errUnavailable
+				}
+				return map[string]interface{}{
+					"count":     3,
+					"merchants": []string{"m1"},
+					"window":    Lazy(func() (interface{}, error) { return Env{"hours": 24}, nil }),
+				}, nil
+			})
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.expr, err)
+			}
+			got, err := expr.Eval(env)
+			if loads != tt.loads {
+				t.Errorf("Eval(%q) loaded the fact %d times; want %d", tt.expr, loads, tt.loads)
+			}
+			if tt.errPath != "" {
+				var factErr *FactError
+				if !errors.As(err, &factErr) {
+					t.Fatalf("Eval(%q) error = %v; want a FactError", tt.expr, err)
+				}
+				if factErr.Path != tt.errPath {
+					t.Errorf("FactError.Path = %q; want %q", factErr.Path, tt.errPath)
+				}
+				if !IsFactError(err) || !errors.Is(err, errUnavailable) {
+					t.Errorf("Eval(%q) error = %v; want it to wrap %v", tt.expr, err, errUnavailable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", tt.expr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval(%q) = %#v; want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "", err: "unexpected end of expression"},
+		{expr: "1 +", err: "unexpected end of expression"},
+		{expr: "(1 + 2", err: "expected \")\""},
+		{expr: "[1, 2", err: "expected \"]\""},
+		{expr: "1 2", err: "unexpected \"2\" at 2"},
+		{expr: "event.", err: "expected field name"},
+		{expr: "event.1", err: "expected field name"},
+		{expr: "\"open", err: "unterminated string"},
+		{expr: "1 # 2", err: "unexpected character '#'"},
+		{expr: "1.2.3", err: "invalid number"},
+		{expr: "a == == b", err: "unexpected \"==\""},
+		{expr: "1 < 2 < 3", err: "unexpected \"<\""},
+		{expr: "not", err: "unexpected end of expression"},
+		{expr: "unknown(1)", err: "unknown function \"unknown\""},
+		{expr: "len()", err: "len expects 1 arguments, got 0"},
+		{expr: "len(event.channels, 1)", err: "len expects 1 arguments, got 2"},
+		{expr: "contains(event.channels)", err: "contains expects 2 arguments, got 1"},
+		{expr: "false && startsWith(event.mcc)", err: "startsWith expects 2 arguments, got 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Compile(%q) error = %v; want %q", tt.expr, err, tt.err)
+			}
+		})
+	}
+}
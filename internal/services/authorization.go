@@ -1 +1,248 @@
 package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// newAuthorizationTransaction builds the pending transaction for an approved
+// authorization with the initial entry of its amount history.
+func newAuthorizationTransaction(event api.AuthorizationRequestEvent, card models.Card) models.Transaction {
+	now := time.Now().UTC()
+	return models.Transaction{
+		ID:            event.ID,
+		Authorization: event.ID,
+		CardID:        event.CardID,
+		CustomerID:    card.CustomerID,
+		AccountID:     card.FundingSource,
+		Amount:        event.Amount,
+		Currency:      event.Currency,
+		Type:          event.Type,
+		Fees:          event.Fees,
+		Channel:       event.Channel,
+		NetworkData:   toNetworkDataModel(event.NetworkData),
+		Status:        "pending",
+		History: []models.AmountChange{{
+			Type:        models.AmountChangeAuthorization,
+			EventStatus: event.Status,
+			Amount:      event.Amount,
+			Delta:       event.Amount,
+			Fees:        event.Fees,
+			At:          now,
+		}},
+		CreatedAt: now.Format(time.RFC3339),
+	}
+}
+
+// approveAuthorization stores an approved authorization, reserves its hold and
+// posts it to the ledger in one transaction, so an authorization is never
+// recorded without the funds it holds.
+func (s *WebhookService) approveAuthorization(ctx context.Context, transaction models.Transaction) error {
+	return s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := s.store.InsertTransaction(sc, transaction); err != nil {
+			return err
+		}
+		if err := s.holds.Reserve(sc, transaction.ID, transaction.CardID, transaction.AccountID, transaction.Amount, transaction.Fees); err != nil {
+			return err
+		}
+		return s.ledger.RecordAuthorization(sc, transaction.AccountID, transaction.ID, transaction.Amount+transaction.Fees, transaction.Currency)
+	})
+}
+
+// fallbackDecision answers an authorization whose decision budget ran out with
+// the configured fallback action. The fallback only approves cards read before
+// the budget ran out that were active and not temporarily blocked. Fallback
+// approvals are recorded in the background and queued for review.
+func (s *WebhookService) fallbackDecision(event api.AuthorizationRequestEvent, audit *decisionAudit, timing *models.DecisionTiming, cause error) (api.AuthorizationResponse, error) {
+	s.logger.Warn("Authorization decision budget exceeded",
+		zap.String("authorizationID", event.ID),
+		zap.String("fallback", s.budget.Fallback),
+		zap.Float64("elapsedMs", timing.ElapsedMs),
+		zap.Any("steps", timing.Steps),
+		zap.Error(cause),
+	)
+	if s.budget.Fallback != "approve" {
+		return api.AuthorizationResponse{Action: "decline", Code: "decision-timeout"}, fmt.Errorf("decision budget exceeded: %w", cause)
+	}
+	card := audit.record.Card
+	if card == nil {
+		audit.check("fallback", false, "card not read before the budget ran out")
+		return api.AuthorizationResponse{Action: "decline", Code: "decision-timeout"}, fmt.Errorf("decision budget exceeded before the card was read: %w", cause)
+	}
+	if card.Status != models.CardActive {
+		audit.check("fallback", false, "card "+card.Status)
+		return api.AuthorizationResponse{Action: "decline", Code: "account-inactive"}, fmt.Errorf("decision budget exceeded on a %s card: %w", card.Status, cause)
+	}
+	if isTemporarilyBlocked(card.Controls, authorizationTime(event, time.Now())) {
+		audit.check("fallback", false, "card temporarily blocked")
+		return api.AuthorizationResponse{Action: "decline", Code: "card-blocked"}, fmt.Errorf("decision budget exceeded on a blocked card: %w", cause)
+	}
+	audit.check("fallback", true, "")
+	go s.recordFallbackApproval(event, *card, timing)
+	return api.AuthorizationResponse{
+		Action:   "approve",
+		Metadata: map[string]interface{}{"fallback": true},
+	}, nil
+}
+
+// recordFallbackApproval records an authorization approved by the fallback so
+// later updates and closure can be applied to it.
+func (s *WebhookService) recordFallbackApproval(event api.AuthorizationRequestEvent, card models.Card, timing *models.DecisionTiming) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if existing, err := s.store.GetTransaction(ctx, event.ID); err == nil && existing != nil {
+		return
+	}
+	transaction := newAuthorizationTransaction(event, card)
+	transaction.Fallback = true
+	transaction.ReviewStatus = "pending-review"
+	transaction.Timing = timing
+	if err := s.approveAuthorization(ctx, transaction); err != nil {
+		s.logger.Error("Failed to record fallback approval",
+			zap.String("authorizationID", event.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// HandleAuthorizationUpdated applies an authorization update to the stored
+// transaction. The event amount is the authorized amount after the update: a
+// higher amount is an incremental authorization, a lower one a partial reversal,
+// and a "reversed" status releases the whole authorization.
+func (s *WebhookService) HandleAuthorizationUpdated(ctx context.Context, event api.AuthorizationUpdateEvent) (api.AuthorizationResponse, error) {
+	original, err := s.store.GetTransaction(ctx, event.ID)
+	if err != nil || original == nil {
+		s.logger.Error("Failed to fetch original transaction",
+			zap.String("authorizationID", event.ID),
+			zap.Error(err),
+		)
+		return api.AuthorizationResponse{}, fmt.Errorf("failed to fetch original transaction: %w", err)
+	}
+
+	change, ok := classifyAmountChange(*original, event)
+	if !ok {
+		s.logger.Info("Authorization update does not change the amount",
+			zap.String("authorizationID", event.ID),
+			zap.String("status", event.Status),
+		)
+		return api.AuthorizationResponse{}, nil
+	}
+	change.At = time.Now().UTC()
+
+	set := bson.M{
+		"amount":    change.Amount,
+		"fees":      change.Fees,
+		"updatedAt": change.At.Format(time.RFC3339),
+	}
+	switch change.Type {
+	case models.AmountChangeReversal:
+		set["status"] = "reversed"
+		set["reversal"] = "full"
+	case models.AmountChangePartialReversal:
+		set["reversal"] = "partial"
+	}
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"history": change},
+	}
+
+	// The transaction, its hold and its ledger entries change together, so a
+	// failure part way leaves none of them changed and the redelivered event
+	// applies the update again.
+	err = s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		// Match on the amount we read so concurrent updates cannot be lost; the
+		// losing event is retried on redelivery.
+		result, err := s.store.Transactions.UpdateOne(sc, bson.M{
+			"authorizationId": event.ID,
+			"amount":          original.Amount,
+		}, update)
+		if err != nil {
+			s.logger.Error("Failed to update transaction", zap.String("authorizationID", event.ID), zap.Error(err))
+			return fmt.Errorf("failed to update transaction: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			s.logger.Warn("Transaction changed while applying update", zap.String("authorizationID", event.ID))
+			return fmt.Errorf("transaction %s changed concurrently", event.ID)
+		}
+
+		//keep the funds hold in line with the authorized amount
+		if change.Type == models.AmountChangeReversal {
+			err = s.holds.Release(sc, event.ID, models.HoldReleased, "reversed")
+		} else {
+			err = s.holds.Adjust(sc, event.ID, change.Amount, change.Fees)
+		}
+		if err != nil || original.AccountID == "" {
+			return err
+		}
+		previousTotal := original.Amount + original.Fees
+		if change.Type == models.AmountChangeReversal {
+			return s.ledger.RecordRelease(sc, original.AccountID, event.ID, models.EntryReversal, previousTotal, original.Currency)
+		}
+		delta := change.Amount + change.Fees - previousTotal
+		return s.ledger.RecordAdjustment(sc, original.AccountID, event.ID, len(original.History), delta, original.Currency)
+	})
+	if err != nil {
+		return api.AuthorizationResponse{}, err
+	}
+
+	s.logger.Info("Applied authorization update",
+		zap.String("authorizationID", event.ID),
+		zap.String("cardID", event.CardID),
+		zap.String("change", change.Type),
+		zap.Int64("previousAmount", change.PreviousAmount),
+		zap.Int64("amount", change.Amount),
+	)
+	return api.AuthorizationResponse{}, nil
+}
+
+// classifyAmountChange determines how an update event changes the stored
+// authorization. It reports false when the update leaves the amount as is.
+func classifyAmountChange(original models.Transaction, event api.AuthorizationUpdateEvent) (models.AmountChange, bool) {
+	change := models.AmountChange{
+		EventStatus:    event.Status,
+		PreviousAmount: original.Amount,
+		Amount:         event.Amount,
+		Fees:           event.Fees,
+	}
+	switch {
+	case event.Status == "reversed" || event.Amount == 0:
+		change.Type = models.AmountChangeReversal
+		change.Amount = 0
+		change.Fees = 0
+	case event.Amount > original.Amount:
+		change.Type = models.AmountChangeIncremental
+	case event.Amount < original.Amount:
+		change.Type = models.AmountChangePartialReversal
+	default:
+		return change, false
+	}
+	if original.Status == "reversed" {
+		return change, false
+	}
+	change.Delta = change.Amount - original.Amount
+	return change, true
+}
+
+// reconcileAuthorization compares the amount of a closed authorization with the
+// amount left by the last entry of its history.
+func reconcileAuthorization(original models.Transaction, event api.AuthorizationClosedEvent) models.Reconciliation {
+	expected := original.Amount
+	if n := len(original.History); n > 0 {
+		expected = original.History[n-1].Amount
+	}
+	return models.Reconciliation{
+		ExpectedAmount: expected,
+		ClosedAmount:   event.Amount,
+		Difference:     event.Amount - expected,
+		Matched:        event.Amount == expected,
+		At:             time.Now().UTC(),
+	}
+}
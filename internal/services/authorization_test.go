@@ -0,0 +1,122 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/simulator"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFallbackDecisionChecksCard(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		block  *api.TemporaryBlock
+		action string
+		code   string
+	}{
+		{name: "active card", status: models.CardActive, action: "approve"},
+		{name: "frozen card", status: models.CardFrozen, action: "decline", code: "account-inactive"},
+		{name: "temporarily blocked card", status: models.CardActive, block: &api.TemporaryBlock{Until: time.Now().Add(time.Hour)}, action: "decline", code: "card-blocked"},
+		{name: "block that ended", status: models.CardActive, block: &api.TemporaryBlock{Until: time.Now().Add(-time.Hour)}, action: "approve"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testStore(t)
+			sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 100_000})
+			service := testWebhookService(t, db, sim)
+			service.budget = DecisionBudget{Timeout: 200 * time.Millisecond, Fallback: "approve"}
+			card := models.Card{
+				CardID:        fmt.Sprintf("card_%d", i),
+				CustomerID:    customerID,
+				FundingSource: accountID,
+				Status:        tt.status,
+				Controls:      api.CardControls{TemporaryBlock: tt.block},
+			}
+			if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+				t.Fatal(err)
+			}
+			// The issuer answers after the budget ran out
+			sim.InjectFault(simulator.Fault{Operation: simulator.OpGetAccountBalance, Delay: time.Second})
+
+			response, _ := service.HandleAuthorizationRequest(ctx, testAuthorization(card.CardID, 10_000))
+			if response.Action != tt.action || response.Code != tt.code {
+				t.Errorf("response = %s %q; want %s %q", response.Action, response.Code, tt.action, tt.code)
+			}
+		})
+	}
+
+	t.Run("card not read", func(t *testing.T) {
+		service := NewWebhookService(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, DecisionBudget{Fallback: "approve"}, zap.NewNop())
+		event := testAuthorization("card_1", 10_000)
+		response, err := service.fallbackDecision(event, newDecisionAudit(context.Background(), event), &models.DecisionTiming{}, context.DeadlineExceeded)
+		if response.Action != "decline" || err == nil {
+			t.Errorf("fallbackDecision = %s, %v; want a decline", response.Action, err)
+		}
+	})
+}
+
+func TestAuthorizationUpdatesMoveHoldAndLedgerTogether(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 100_000})
+	service := testWebhookService(t, db, sim)
+	if err := service.ledger.OpenAccount(ctx, accountID, customerID, "NGN"); err != nil {
+		t.Fatalf("OpenAccount: %v", err)
+	}
+	if err := service.ledger.RecordDeposit(ctx, accountID, models.PaymentData{ID: "pay_1", Amount: 100_000, Currency: "NGN"}); err != nil {
+		t.Fatalf("RecordDeposit: %v", err)
+	}
+	card := models.Card{CardID: "card_1", CustomerID: customerID, FundingSource: accountID, Status: models.CardActive}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	request := testAuthorization(card.CardID, 40_000)
+	if response, err := service.HandleAuthorizationRequest(ctx, request); response.Action != "approve" {
+		t.Fatalf("authorization = %s %q, %v; want approve", response.Action, response.Code, err)
+	}
+
+	tests := []struct {
+		name   string
+		amount int64
+		status string
+		held   int64 // Amount held after the update, in the transaction, the hold and the ledger
+	}{
+		{name: "partial reversal", amount: 25_000, status: "pending", held: 25_000},
+		{name: "redelivered partial reversal", amount: 25_000, status: "pending", held: 25_000},
+		{name: "incremental authorization", amount: 30_000, status: "pending", held: 30_000},
+		{name: "full reversal", amount: 0, status: "reversed", held: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := api.AuthorizationUpdateEvent{ID: request.ID, CardID: card.CardID, Amount: tt.amount, Currency: "NGN", Status: tt.status}
+			if _, err := service.HandleAuthorizationUpdated(ctx, update); err != nil {
+				t.Fatalf("HandleAuthorizationUpdated: %v", err)
+			}
+			transaction, err := db.GetTransaction(ctx, request.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != "reversed" && transaction.Amount != tt.held {
+				t.Errorf("transaction amount = %d; want %d", transaction.Amount, tt.held)
+			}
+			holds, err := db.SumOpenHolds(ctx, accountID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			balances, _, err := service.ledger.Balances(ctx, accountID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if holds != tt.held || balances.Held != tt.held || balances.Available != 100_000-tt.held {
+				t.Errorf("holds = %d, ledger held %d and available %d; want %d held", holds, balances.Held, balances.Available, tt.held)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// BalanceProvider fetches issuer balances through a circuit breaker and keeps
+// the last balance seen per account for stand-in authorization.
+type BalanceProvider struct {
+	client  api.Issuer
+	breaker *api.CircuitBreaker
+	store   *store.Store
+	logger  *zap.Logger
+}
+
+// NewBalanceProvider creates a balance provider calling the issuer through the given breaker.
+func NewBalanceProvider(client api.Issuer, breaker *api.CircuitBreaker, store *store.Store, logger *zap.Logger) *BalanceProvider {
+	return &BalanceProvider{client: client, breaker: breaker, store: store, logger: logger}
+}
+
+// Fetch returns the issuer balance of an account and caches it as the last known balance.
+func (p *BalanceProvider) Fetch(ctx context.Context, accountID string) (api.GetAccountBalanceResponse, error) {
+	var balance api.GetAccountBalanceResponse
+	err := p.breaker.Execute(ctx, func() error {
+		var err error
+		balance, err = p.client.GetAccountBalance(ctx, accountID)
+		return err
+	})
+	if err != nil {
+		return balance, err
+	}
+
+	snapshot := models.BalanceSnapshot{
+		AccountID: accountID,
+		Available: balance.Data.Available,
+		Currency:  balance.Data.Currency,
+		FetchedAt: time.Now().UTC(),
+	}
+	_, err = p.store.BalanceSnapshots.ReplaceOne(ctx, bson.M{"accountId": accountID}, snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		p.logger.Warn("Failed to cache balance", zap.String("accountID", accountID), zap.Error(err))
+	}
+	return balance, nil
+}
+
+// LastKnown returns the last cached issuer balance of an account, or nil when none was seen.
+func (p *BalanceProvider) LastKnown(ctx context.Context, accountID string) (*models.BalanceSnapshot, error) {
+	var snapshot models.BalanceSnapshot
+	err := p.store.BalanceSnapshots.FindOne(ctx, bson.M{"accountId": accountID}).Decode(&snapshot)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch cached balance: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// StandInPolicy decides whether an authorization may be approved on a cached
+// balance while the issuer balance API is unavailable.
+type StandInPolicy struct {
+	caps       map[string]int64 // Highest stand-in amount per channel
+	defaultCap int64
+	maxAge     time.Duration
+}
+
+// NewStandInPolicy creates a stand-in policy. Channels without a cap use the
+// default cap; a cap of zero disables stand-in for the channel.
+func NewStandInPolicy(caps map[string]int64, defaultCap int64, maxAge time.Duration) *StandInPolicy {
+	return &StandInPolicy{caps: caps, defaultCap: defaultCap, maxAge: maxAge}
+}
+
+// Allows reports whether an amount on a channel may be approved in stand-in
+// using the given snapshot, and why not when it may not.
+func (p *StandInPolicy) Allows(snapshot *models.BalanceSnapshot, channel string, amount int64, now time.Time) (bool, string) {
+	if snapshot == nil {
+		return false, "no cached balance"
+	}
+	if now.Sub(snapshot.FetchedAt) > p.maxAge {
+		return false, "cached balance is too old"
+	}
+	limit, ok := p.caps[channel]
+	if !ok {
+		limit = p.defaultCap
+	}
+	if amount > limit {
+		return false, fmt.Sprintf("amount exceeds stand-in cap of %d", limit)
+	}
+	return true, ""
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"sync"
+	"time"
+)
+
+// DecisionBudget bounds the time spent deciding an authorization request.
+type DecisionBudget struct {
+	Timeout  time.Duration // Time allowed for the whole decision
+	Fallback string        // Action returned when the timeout is exceeded: approve or decline
+}
+
+// decisionTimer records how long each step of a decision takes.
+type decisionTimer struct {
+	mu    sync.Mutex
+	start time.Time
+	steps []models.StepTiming
+}
+
+func newDecisionTimer() *decisionTimer {
+	return &decisionTimer{start: time.Now()}
+}
+
+// step starts timing a step and returns the function that ends it.
+func (t *decisionTimer) step(name string) func() {
+	begin := time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.steps = append(t.steps, models.StepTiming{Step: name, DurationMs: milliseconds(time.Since(begin))})
+	}
+}
+
+// timing returns the steps recorded so far together with the budget.
+func (t *decisionTimer) timing(budget time.Duration, exceeded bool) *models.DecisionTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	steps := make([]models.StepTiming, len(t.steps))
+	copy(steps, t.steps)
+	return &models.DecisionTiming{
+		BudgetMs:  budget.Milliseconds(),
+		ElapsedMs: milliseconds(time.Since(t.start)),
+		Exceeded:  exceeded,
+		Steps:     steps,
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
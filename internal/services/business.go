@@ -0,0 +1,147 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidBusiness is returned when a business onboarding request is incomplete.
+var ErrInvalidBusiness = errors.New("invalid business application")
+
+// businessIDType is the identity type business customers are tiered on.
+const businessIDType = "cac"
+
+// CreateBusinessCustomer creates a business customer, with its directors and
+// beneficial owners as linked individuals, and its business sub account once
+// the business and its officers have passed sanctions screening. A screening
+// match returns a *ScreeningHoldError like CreateCustomer.
+func (s *CustomerService) CreateBusinessCustomer(ctx context.Context, application models.BusinessApplication) (string, string, []api.DepositChannel, error) {
+	s.logger.Info("Starting CreateBusinessCustomer",
+		zap.String("registrationNumber", application.RegistrationNumber),
+		zap.String("email", application.Email),
+		zap.Int("officers", len(application.Officers)),
+	)
+	if err := validateBusiness(application); err != nil {
+		return "", "", nil, err
+	}
+
+	onboarding := models.Onboarding{Ref: application.Ref, Type: models.CustomerBusiness, Business: &application}
+	return s.startOnboarding(ctx, onboarding, func() models.Screening {
+		officers := make([]models.ScreeningSubject, 0, len(application.Officers))
+		for _, officer := range application.Officers {
+			officers = append(officers, officerSubject(officer))
+		}
+		business := models.ScreeningSubject{Name: application.Name, Nationality: application.Country}
+		return s.screening.ScreenParties(models.ScreeningOnboarding, business, officers)
+	})
+}
+
+// validateBusiness checks what request binding cannot: a business needs a
+// director, and its owners cannot hold more than all of it.
+func validateBusiness(application models.BusinessApplication) error {
+	var directors, owned int
+	for _, officer := range application.Officers {
+		switch officer.Role {
+		case models.OfficerDirector:
+			directors++
+		case models.OfficerBeneficialOwner:
+			owned += officer.OwnershipPercent
+		default:
+			return fmt.Errorf("%w: unknown officer role %q", ErrInvalidBusiness, officer.Role)
+		}
+	}
+	if directors == 0 {
+		return fmt.Errorf("%w: at least one director is required", ErrInvalidBusiness)
+	}
+	if owned > 100 {
+		return fmt.Errorf("%w: beneficial owners hold %d%%", ErrInvalidBusiness, owned)
+	}
+	return nil
+}
+
+// officerSubject is the person screened for an officer of a business.
+func officerSubject(officer models.BusinessOfficer) models.ScreeningSubject {
+	var names []string
+	for _, name := range []string{officer.FirstName, officer.MiddleName, officer.LastName} {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return models.ScreeningSubject{
+		Name:        strings.Join(names, " "),
+		DateOfBirth: officer.DateOfBirth,
+		Nationality: officer.NationalityCode,
+	}
+}
+
+// businessCustomer is the issuer request and the stored customer of a
+// business onboarding.
+func (s *CustomerService) businessCustomer(application models.BusinessApplication) (api.CreateCustomerRequest, models.Customer) {
+	tier := s.kyc.InitialTier(businessIDType)
+
+	officers := make([]api.BusinessOfficer, 0, len(application.Officers))
+	for _, officer := range application.Officers {
+		officers = append(officers, api.BusinessOfficer{
+			Role:             officer.Role,
+			OwnershipPercent: officer.OwnershipPercent,
+			IndividualInformation: api.IndividualInformation{
+				FirstName:       officer.FirstName,
+				LastName:        officer.LastName,
+				MiddleName:      officer.MiddleName,
+				Email:           officer.Email,
+				PhoneNumber:     officer.PhoneNumber,
+				DateOfBirth:     officer.DateOfBirth,
+				NationalityCode: officer.NationalityCode,
+			},
+			IndividualIdentity: api.IndividualIdentity{
+				Type:           officer.IDType,
+				ID:             officer.IDNumber,
+				IssuingCountry: officer.IssuingCountry,
+			},
+		})
+	}
+	req := api.CreateCustomerRequest{
+		Name: application.Name,
+		Type: models.CustomerBusiness,
+		Claims: api.CustomerClaims{
+			BusinessInformation: &api.BusinessInformation{
+				Name:               application.Name,
+				TradingName:        application.TradingName,
+				Email:              application.Email,
+				PhoneNumber:        application.PhoneNumber,
+				RegistrationType:   application.RegistrationType,
+				RegistrationNumber: application.RegistrationNumber,
+				IncorporationDate:  application.IncorporationDate,
+				Country:            application.Country,
+				Industry:           application.Industry,
+				Address:            application.Address,
+			},
+			BusinessOfficers: officers,
+		},
+		Verifications: []api.CustomerVerification{
+			{Type: tier, Status: models.VerificationVerified},
+		},
+		Metadata: api.CustomerMetadata{
+			UserID: application.UserID,
+			Ref:    application.Ref,
+		},
+	}
+	profile := application.BusinessProfile
+	customer := models.Customer{
+		Type:            models.CustomerBusiness,
+		Name:            application.Name,
+		Email:           application.Email,
+		NationalityCode: application.Country,
+		Tier:            tier,
+		Verifications:   []models.KYCVerification{s.kyc.InitialVerification(tier, businessIDType, time.Now().UTC())},
+		Business:        &profile,
+	}
+	return req, customer
+}
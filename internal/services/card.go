@@ -5,25 +5,41 @@ import (
 	"card-service/internal/models"
 	"card-service/internal/store"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
 //card service handles card operations
 
+// ErrCardNotFound is returned when no card exists with the given ID.
+var ErrCardNotFound = errors.New("card not found")
+
+// ErrNotBusinessCustomer is returned when employee cards are linked for an individual customer.
+var ErrNotBusinessCustomer = errors.New("customer is not a business customer")
+
 type CardService struct {
 	store  *store.Store
-	client *api.Client
+	client api.Issuer
+	limits *LimitEvaluator
+	kyc    *KYCService
+	outbox *OutboxService
 	logger *zap.Logger
 }
 
 // New card service intialize a new card service instances with provided client, store
-func NewCardService(store *store.Store, client *api.Client, logger *zap.Logger) *CardService {
-	return &CardService{store: store, client: client, logger: logger}
+func NewCardService(store *store.Store, client api.Issuer, limits *LimitEvaluator, kyc *KYCService, outbox *OutboxService, logger *zap.Logger) *CardService {
+	s := &CardService{store: store, client: client, limits: limits, kyc: kyc, outbox: outbox, logger: logger}
+	outbox.Register(models.OpLinkCard, OutboxHandler{Execute: s.executeLinkCard, Complete: s.completeLinkCard, Fail: s.failLinkCard})
+	outbox.Register(models.OpActivateCard, OutboxHandler{Execute: s.executeActivateCard, Complete: s.completeActivateCard})
+	outbox.Register(models.OpUpdateCardStatus, OutboxHandler{Execute: s.executeCardStatus, Complete: s.completeCardStatus})
+	outbox.Register(models.OpUpdateCardControls, OutboxHandler{Execute: s.executeCardControls, Complete: s.completeCardControls})
+	return s
 }
 
 // LinkCard links a card to a customer and stores them in the mongoDB
@@ -46,12 +62,12 @@ func (s *CardService) LinkCard(
 		zap.String("fundingSource", fundingSource),
 		zap.String("controls", fmt.Sprintf("%+v", controls)),
 	)
-	session, err := s.store.Client.StartSession()
-	if err != nil {
-		s.logger.Error("Failed to start MongoDB session", zap.Error(err))
-		return "", "", "", "", "", "", "", "", err
+	if controls != nil {
+		if err := validateControls(*controls); err != nil {
+			s.logger.Warn("Card not linked", zap.String("customer", customer), zap.Error(err))
+			return "", "", "", "", "", "", "", "", err
+		}
 	}
-	defer session.EndSession(context.Background())
 	// ctx := context.Background()
 	//Determine funding source
 	// selectedFundingSource := fundingSource
@@ -77,37 +93,10 @@ func (s *CardService) LinkCard(
 		Controls:      controls,
 		Metadata:      metadata,
 	}
-
-	// Call the API to link the card
-	resp, err := s.client.LinkCard(req)
+	resp, err := s.link(ctx, req, models.Card{})
 	if err != nil {
-		s.logger.Error("Failed to link card via API", zap.Error(err))
 		return "", "", "", "", "", "", "", "", err
 	}
-
-	card := models.Card{
-		CardID:         resp.Data.ID,
-		CustomerID:     resp.Data.Customer,
-		FundingSource:  resp.Data.FundingSource,
-		Last4:          resp.Data.Details.Last4,
-		Expiry:         resp.Data.Details.Expiry,
-		CardHolderName: resp.Data.Details.CardHolderName,
-		Controls:       resp.Data.Controls,
-		Type:           resp.Data.Type,
-		Status:         resp.Data.Status,
-		Program:        resp.Data.Program,
-		Reference:      resp.Data.Reference,
-		Metadata:       resp.Data.Metadata,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-	}
-
-	_, err = s.store.Cards.InsertOne(ctx, card)
-	if err != nil {
-		s.logger.Error("Failed to store card in MongoDB", zap.Error(err))
-		return "", "", "", "", "", "", "", "", err
-	}
-	s.logger.Info("Stored card in MongoDB", zap.String("cardID", resp.Data.ID))
 	return resp.Data.ID,
 		resp.Data.Details.Last4,
 		resp.Data.Details.Expiry,
@@ -119,52 +108,206 @@ func (s *CardService) LinkCard(
 		nil
 }
 
-func (s *CardService) ActivateCard(ctx context.Context, cvv, pin string, cardID string) (string, error) {
-	s.logger.Info("Activating card",
-		zap.String("cvv", cvv),
-		zap.String("pin", pin),
+// LinkEmployeeCard links a card issued to an employee of a business customer.
+// The card is funded from the business sub account and carries its own
+// controls.
+func (s *CardService) LinkEmployeeCard(ctx context.Context, businessID, pan, reference string, employee models.CardEmployee, controls *api.CardControls) (*models.Card, error) {
+	var business models.Customer
+	err := s.store.Customers.FindOne(ctx, bson.M{"customerId": businessID}).Decode(&business)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch customer: %w", err)
+	}
+	if business.Type != models.CustomerBusiness {
+		return nil, ErrNotBusinessCustomer
+	}
+	if controls != nil {
+		if err := validateControls(*controls); err != nil {
+			s.logger.Warn("Employee card not linked", zap.String("customer", businessID), zap.String("employee", employee.ID), zap.Error(err))
+			return nil, err
+		}
+	}
+	s.logger.Info("Starting linking employee card",
+		zap.String("customer", businessID),
+		zap.String("employee", employee.ID),
+		zap.String("controls", fmt.Sprintf("%+v", controls)),
 	)
+	req := api.LinkCardRequest{
+		Pan:           pan,
+		Customer:      businessID,
+		FundingSource: business.AccountID,
+		Reference:     reference,
+		Controls:      controls,
+		Metadata:      &api.CardMetadata{Name: employee.Name},
+	}
+	resp, err := s.link(ctx, req, models.Card{Employee: &employee})
+	if err != nil {
+		return nil, err
+	}
+	return s.getCard(ctx, resp.Data.ID)
+}
 
-	// Check if card exists and is inactive
-	var card models.Card
-	err := s.store.Cards.FindOne(ctx, bson.M{"cardId": cardID}).Decode(&card)
-	if err == mongo.ErrNoDocuments {
-		s.logger.Error("Card not found", zap.String("cardID", cardID))
-		return "", fmt.Errorf("card not found")
+// link links a card with the issuer within the card count of the customer's
+// KYC tier. The card, with the ID, employee and replaced card of the given
+// one, is stored as linking together with the outbox operation that links it, and
+// completed with the issuer's card when the operation does; an
+// *OperationPendingError is returned when that did not happen yet.
+func (s *CardService) link(ctx context.Context, req api.LinkCardRequest, card models.Card) (api.LinkCardResponse, error) {
+	// The KYC tier of the customer caps how many cards it may link
+	if err := s.kyc.CheckCardCount(ctx, req.Customer); err != nil {
+		s.logger.Warn("Card not linked", zap.String("customer", req.Customer), zap.Error(err))
+		return api.LinkCardResponse{}, err
+	}
+
+	now := time.Now()
+	if card.ID.IsZero() {
+		card.ID = primitive.NewObjectID()
 	}
+	card.CustomerID = req.Customer
+	card.FundingSource = req.FundingSource
+	card.Reference = req.Reference
+	card.Status = models.CardLinking
+	card.CreatedAt, card.UpdatedAt = now, now
+	op, err := s.outbox.Submit(ctx, models.OpLinkCard, "link-card:"+card.ID.Hex(), card.ID.Hex(), req, func(sc mongo.SessionContext) error {
+		if _, err := s.store.Cards.InsertOne(sc, card); err != nil {
+			return fmt.Errorf("failed to store card: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		s.logger.Error("Failed to fetch card", zap.String("cardID", cardID), zap.Error(err))
-		return "", fmt.Errorf("failed to fetch card: %w", err)
+		s.logger.Error("Failed to store card in MongoDB", zap.Error(err))
+		return api.LinkCardResponse{}, err
 	}
 
-	if card.Status == "active" {
-		s.logger.Warn("Card already activated", zap.String("cardID", cardID))
-		return "", fmt.Errorf("card already activated")
+	op, err = s.outbox.Dispatch(ctx, op.ID)
+	if err != nil {
+		s.logger.Error("Failed to link card via API", zap.String("card", card.ID.Hex()), zap.Error(err))
+		return api.LinkCardResponse{}, err
 	}
+	var resp api.LinkCardResponse
+	if err := bson.Unmarshal(op.Result, &resp); err != nil {
+		return api.LinkCardResponse{}, fmt.Errorf("failed to decode linked card: %w", err)
+	}
+	s.logger.Info("Stored card in MongoDB", zap.String("cardID", resp.Data.ID))
+	return resp, nil
+}
 
-	req := api.ActivateCardRequest{
-		Cvv: cvv,
-		Pin: pin,
+// executeLinkCard links the card of a link-card operation with the issuer.
+func (s *CardService) executeLinkCard(ctx context.Context, op models.OutboxOperation) (interface{}, error) {
+	var req api.LinkCardRequest
+	if err := bson.Unmarshal(op.Payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode link card request: %w", err)
 	}
+	return s.client.LinkCard(ctx, req)
+}
 
-	resp, err := s.client.ActivateCard(cardID, req)
+// completeLinkCard fills the linking card in with the card the issuer linked.
+func (s *CardService) completeLinkCard(ctx context.Context, op models.OutboxOperation, result bson.Raw) error {
+	var resp api.LinkCardResponse
+	if err := bson.Unmarshal(result, &resp); err != nil {
+		return fmt.Errorf("failed to decode linked card: %w", err)
+	}
+	id, err := primitive.ObjectIDFromHex(op.Subject)
+	if err != nil {
+		return fmt.Errorf("invalid card %q: %w", op.Subject, err)
+	}
+	var card models.Card
+	err = s.store.Cards.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"cardId":         resp.Data.ID,
+		"customerId":     resp.Data.Customer,
+		"fundingSource":  resp.Data.FundingSource,
+		"last4":          resp.Data.Details.Last4,
+		"expiry":         resp.Data.Details.Expiry,
+		"cardHolderName": resp.Data.Details.CardHolderName,
+		"controls":       resp.Data.Controls,
+		"type":           resp.Data.Type,
+		"status":         resp.Data.Status,
+		"program":        resp.Data.Program,
+		"reference":      resp.Data.Reference,
+		"metadata":       resp.Data.Metadata,
+		"updatedAt":      time.Now(),
+	}}).Decode(&card)
+	if err != nil {
+		return fmt.Errorf("failed to store card: %w", err)
+	}
+	if card.Replaces != "" {
+		_, err := s.store.Cards.UpdateOne(ctx, bson.M{"cardId": card.Replaces}, bson.M{
+			"$set":   bson.M{"replacedBy": resp.Data.ID},
+			"$unset": bson.M{"replacement": ""},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to link replaced card: %w", err)
+		}
+	}
+	return nil
+}
+
+// failLinkCard marks a card the issuer never linked, so it no longer counts
+// against the customer's cards, and lets the card it was to replace be
+// replaced again.
+func (s *CardService) failLinkCard(ctx context.Context, op models.OutboxOperation, cause string) error {
+	id, err := primitive.ObjectIDFromHex(op.Subject)
+	if err != nil {
+		return fmt.Errorf("invalid card %q: %w", op.Subject, err)
+	}
+	var card models.Card
+	err = s.store.Cards.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"status": models.CardLinkFailed, "updatedAt": time.Now()}}).Decode(&card)
+	if err != nil {
+		return err
+	}
+	if card.Replaces != "" {
+		_, err := s.store.Cards.UpdateOne(ctx, bson.M{"cardId": card.Replaces, "replacement": op.Subject}, bson.M{"$unset": bson.M{"replacement": ""}})
+		return err
+	}
+	return nil
+}
+
+// activateCardPayload is the payload of an activate-card operation.
+type activateCardPayload struct {
+	CardID  string                  `bson:"cardId"`
+	Request api.ActivateCardRequest `bson:"request"`
+}
+
+func (s *CardService) ActivateCard(ctx context.Context, cvv, pin string, cardID string) (string, error) {
+	s.logger.Info("Activating card",
+		zap.String("cvv", cvv),
+		zap.String("pin", pin),
+	)
+
+	// Check if card exists and is inactive
+	card, err := s.getCard(ctx, cardID)
 	if err != nil {
-		s.logger.Error("Failed to activate card via API", zap.Error(err))
+		return "", err
+	}
+	if _, err := cardTransition(card, cardActivate); err != nil {
+		s.logger.Warn("Card not activated", zap.String("cardID", cardID), zap.String("status", card.Status))
 		return "", err
 	}
 
-	//update card status in MongoDB
-	update := bson.M{
-		"$set": bson.M{
-			"status":    "active",
-			"updatedAt": time.Now(),
+	payload := activateCardPayload{
+		CardID: cardID,
+		Request: api.ActivateCardRequest{
+			Cvv: cvv,
+			Pin: pin,
 		},
 	}
-	_, err = s.store.Cards.UpdateOne(ctx, bson.M{"cardId": cardID}, update)
+
+	// A retry against the same card state is the same operation
+	key := fmt.Sprintf("activate-card:%s:%d", cardID, card.UpdatedAt.UnixNano())
+	op, err := s.outbox.Submit(ctx, models.OpActivateCard, key, cardID, payload, nil)
+	if err != nil {
+		return "", err
+	}
+	op, err = s.outbox.Dispatch(ctx, op.ID)
 	if err != nil {
-		s.logger.Error("Failed to update card status in MongoDB",
-			zap.String("cardID", cardID), zap.Error(err))
-		return "", fmt.Errorf("failed to update card status: %w", err)
+		s.logger.Error("Failed to activate card via API", zap.Error(err))
+		return "", err
+	}
+	var resp api.ActivateCardResponse
+	if err := bson.Unmarshal(op.Result, &resp); err != nil {
+		return "", fmt.Errorf("failed to decode activation: %w", err)
 	}
 
 	s.logger.Info("Card activated successfully",
@@ -174,3 +317,51 @@ func (s *CardService) ActivateCard(ctx context.Context, cvv, pin string, cardID
 
 	return resp.Code, nil
 }
+
+// executeActivateCard activates the card of an activate-card operation with the issuer.
+func (s *CardService) executeActivateCard(ctx context.Context, op models.OutboxOperation) (interface{}, error) {
+	var payload activateCardPayload
+	if err := bson.Unmarshal(op.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode activate card request: %w", err)
+	}
+	return s.client.ActivateCard(ctx, payload.CardID, payload.Request)
+}
+
+// completeActivateCard marks the card of an activate-card operation active.
+func (s *CardService) completeActivateCard(ctx context.Context, op models.OutboxOperation, _ bson.Raw) error {
+	//update card status in MongoDB
+	return s.setCardStatus(ctx, op.Subject, cardStates[cardActivate].from, models.CardActive, "")
+}
+
+// GetSpendingLimits returns the spent and remaining amount of each spending limit of a card.
+func (s *CardService) GetSpendingLimits(ctx context.Context, cardID string) ([]LimitUsage, error) {
+	card, err := s.getCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := s.limits.Usage(ctx, cardID, card.Controls.SpendingLimits, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to compute spending limits", zap.String("cardID", cardID), zap.Error(err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// ListDecisions returns the authorization decisions made for a card, newest first.
+func (s *CardService) ListDecisions(ctx context.Context, cardID string, page, limit int64) ([]models.AuthorizationDecision, error) {
+	count, err := s.store.Cards.CountDocuments(ctx, bson.M{"cardId": cardID})
+	if err != nil {
+		s.logger.Error("Failed to fetch card", zap.String("cardID", cardID), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch card: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrCardNotFound
+	}
+	decisions, err := s.store.ListDecisions(ctx, cardID, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list decisions", zap.String("cardID", cardID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list decisions: %w", err)
+	}
+	return decisions, nil
+}
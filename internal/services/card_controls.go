@@ -0,0 +1,409 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidControls is returned when card controls fail validation.
+	ErrInvalidControls = errors.New("invalid card controls")
+	// ErrControlsConflict is returned when the controls of a card were changed by someone else.
+	ErrControlsConflict = errors.New("card controls were changed concurrently")
+	// ErrCardTerminated is returned when a terminated card is changed.
+	ErrCardTerminated = errors.New("card is terminated")
+	// ErrNoControlsInForce is returned for a time before a card was linked.
+	ErrNoControlsInForce = errors.New("no card controls in force at that time")
+)
+
+// cardControlsPayload is the payload of an update-card-controls operation.
+type cardControlsPayload struct {
+	CardID   string           `bson:"cardId"`
+	Version  int              `bson:"version"`
+	Controls api.CardControls `bson:"controls"`
+}
+
+// UpdateControls changes the controls of a card. Fields of update left nil
+// keep their current value, and an empty schedule removes the schedule.
+// expectedVersion must be the controls version of the card when the change
+// was made. The new version is stored, and enforced on authorizations,
+// together with the outbox operation that pushes it to the issuer; an
+// *OperationPendingError is returned when the push did not complete yet.
+func (s *CardService) UpdateControls(ctx context.Context, cardID string, expectedVersion int, update api.CardControls) (*models.Card, error) {
+	card, err := s.getModifiableCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.ControlsVersion != expectedVersion {
+		return nil, ErrControlsConflict
+	}
+	return s.changeControls(ctx, card, mergeControls(card.Controls, update))
+}
+
+// BlockTemporarily declines every authorization of a card for the given
+// duration. The block is lifted by the controls expiry once it ends.
+func (s *CardService) BlockTemporarily(ctx context.Context, cardID string, duration time.Duration, reason string) (*models.Card, error) {
+	card, err := s.getModifiableCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	controls := card.Controls
+	controls.TemporaryBlock = &api.TemporaryBlock{Until: time.Now().UTC().Add(duration), Reason: reason}
+	return s.changeControls(ctx, card, controls)
+}
+
+// LiftTemporaryBlock ends the temporary block of a card before its end time.
+func (s *CardService) LiftTemporaryBlock(ctx context.Context, cardID string) (*models.Card, error) {
+	card, err := s.getModifiableCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Controls.TemporaryBlock == nil {
+		return card, nil
+	}
+	controls := card.Controls
+	controls.TemporaryBlock = nil
+	return s.changeControls(ctx, card, controls)
+}
+
+// UnlockChannel allows a channel on a card for the given duration, whatever
+// its channel controls, e.g. online spending for 30 minutes. Unlocking a
+// channel again replaces its unlock.
+func (s *CardService) UnlockChannel(ctx context.Context, cardID, channel string, duration time.Duration) (*models.Card, error) {
+	card, err := s.getModifiableCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	controls := card.Controls
+	controls.Unlocks = []api.ChannelUnlock{{Channel: channel, Until: time.Now().UTC().Add(duration)}}
+	for _, unlock := range card.Controls.Unlocks {
+		if unlock.Channel != channel {
+			controls.Unlocks = append(controls.Unlocks, unlock)
+		}
+	}
+	return s.changeControls(ctx, card, controls)
+}
+
+// RunControlsExpiry periodically removes the temporary blocks and channel
+// unlocks that ended, until ctx is cancelled.
+func (s *CardService) RunControlsExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := s.ExpireOverrides(ctx, now.UTC())
+			if err != nil {
+				s.logger.Error("Failed to expire card control overrides", zap.Error(err))
+				continue
+			}
+			if expired > 0 {
+				s.logger.Info("Expired card control overrides", zap.Int("cards", expired))
+			}
+		}
+	}
+}
+
+// ExpireOverrides stores a new controls version without the overrides that
+// ended by now for every card that has one. Authorizations already ignore
+// ended overrides; this keeps the stored controls and the issuer in line. A
+// card changed concurrently is left for the next run.
+func (s *CardService) ExpireOverrides(ctx context.Context, now time.Time) (int, error) {
+	cursor, err := s.store.Cards.Find(ctx, bson.M{
+		"status": bson.M{"$ne": models.CardTerminated},
+		"$or": bson.A{
+			bson.M{"controls.temporaryBlock.until": bson.M{"$lte": now}},
+			bson.M{"controls.unlocks.until": bson.M{"$lte": now}},
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find cards with ended overrides: %w", err)
+	}
+	var cards []models.Card
+	if err := cursor.All(ctx, &cards); err != nil {
+		return 0, fmt.Errorf("failed to decode cards with ended overrides: %w", err)
+	}
+
+	expired := 0
+	for i := range cards {
+		controls, ok := withoutExpiredOverrides(cards[i].Controls, now)
+		if !ok {
+			continue
+		}
+		// Only the ended overrides are removed, so controls stored before a
+		// validation rule was added do not keep them from expiring
+		update := bson.M{}
+		if cards[i].Controls.TemporaryBlock != nil && controls.TemporaryBlock == nil {
+			update["$unset"] = bson.M{"controls.temporaryBlock": ""}
+		}
+		if len(controls.Unlocks) < len(cards[i].Controls.Unlocks) {
+			update["$pull"] = bson.M{"controls.unlocks": bson.M{"until": bson.M{"$lte": now}}}
+		}
+		_, err := s.submitControls(ctx, &cards[i], controls, update)
+		var pending *OperationPendingError
+		if err != nil && !errors.As(err, &pending) {
+			s.logger.Warn("Card control overrides not expired", zap.String("cardID", cards[i].CardID), zap.Error(err))
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// getModifiableCard fetches a card whose controls may be changed.
+func (s *CardService) getModifiableCard(ctx context.Context, cardID string) (*models.Card, error) {
+	card, err := s.getCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == models.CardTerminated {
+		return nil, ErrCardTerminated
+	}
+	return card, nil
+}
+
+// changeControls validates controls and stores them as the next version of
+// the controls of card, together with the outbox operation that pushes them
+// to the issuer, then dispatches the operation.
+func (s *CardService) changeControls(ctx context.Context, card *models.Card, controls api.CardControls) (*models.Card, error) {
+	if err := validateControls(controls); err != nil {
+		s.logger.Warn("Card controls not changed", zap.String("cardID", card.CardID), zap.Error(err))
+		return nil, err
+	}
+	return s.submitControls(ctx, card, controls, bson.M{"$set": bson.M{"controls": controls}})
+}
+
+// submitControls stores controls as the next version of the controls of
+// card, applying update to the card, together with the outbox operation that
+// pushes them to the issuer, then dispatches the operation. update must leave
+// the card with controls.
+func (s *CardService) submitControls(ctx context.Context, card *models.Card, controls api.CardControls, update bson.M) (*models.Card, error) {
+	cardID := card.CardID
+	now := time.Now().UTC()
+	version := models.CardControlsVersion{
+		ID:            primitive.NewObjectID(),
+		CardID:        cardID,
+		Version:       card.ControlsVersion + 1,
+		Controls:      controls,
+		EffectiveFrom: now,
+		CreatedAt:     now,
+	}
+	payload := cardControlsPayload{CardID: cardID, Version: version.Version, Controls: controls}
+	op, err := s.outbox.Submit(ctx, models.OpUpdateCardControls, "card-controls:"+version.ID.Hex(), cardID, payload, func(sc mongo.SessionContext) error {
+		return s.storeControls(sc, card, version, update)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Card controls changed",
+		zap.String("cardID", cardID),
+		zap.Int("version", version.Version),
+		zap.String("controls", fmt.Sprintf("%+v", controls)),
+	)
+
+	if _, err := s.outbox.Dispatch(ctx, op.ID); err != nil {
+		s.logger.Error("Failed to push card controls via API", zap.String("cardID", cardID), zap.Int("version", version.Version), zap.Error(err))
+		return nil, err
+	}
+	return s.getCard(ctx, cardID)
+}
+
+// storeControls applies update to the controls of a card and makes version
+// its controls version, provided the card is still at the version before it.
+// The first change also records the controls the card was linked with as
+// version 0.
+func (s *CardService) storeControls(sc mongo.SessionContext, card *models.Card, version models.CardControlsVersion, update bson.M) error {
+	filter := bson.M{"cardId": card.CardID, "controlsVersion": card.ControlsVersion}
+	if card.ControlsVersion == 0 {
+		// Cards linked before controls were versioned have no controlsVersion
+		filter["controlsVersion"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	set := bson.M{"controlsVersion": version.Version, "updatedAt": version.CreatedAt}
+	if controls, ok := update["$set"].(bson.M); ok {
+		maps.Copy(set, controls)
+	}
+	changes := maps.Clone(update)
+	changes["$set"] = set
+	result, err := s.store.Cards.UpdateOne(sc, filter, changes)
+	if err != nil {
+		return fmt.Errorf("failed to update card controls: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrControlsConflict
+	}
+
+	versions := []interface{}{version}
+	if card.ControlsVersion == 0 {
+		linked := linkedControls(card)
+		linked.ID, linked.CreatedAt = primitive.NewObjectID(), version.CreatedAt
+		versions = []interface{}{linked, version}
+	}
+	_, err = s.store.ControlsVersions.InsertMany(sc, versions)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrControlsConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store card controls version: %w", err)
+	}
+	return nil
+}
+
+// executeCardControls pushes the controls of an update-card-controls
+// operation to the issuer. An operation superseded by a later version is not
+// pushed, since the later version pushes its own controls.
+func (s *CardService) executeCardControls(ctx context.Context, op models.OutboxOperation) (interface{}, error) {
+	var payload cardControlsPayload
+	if err := bson.Unmarshal(op.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode card controls: %w", err)
+	}
+	card, err := s.getCard(ctx, payload.CardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.ControlsVersion > payload.Version {
+		s.logger.Info("Skipping superseded card controls", zap.String("cardID", payload.CardID), zap.Int("version", payload.Version))
+		return api.UpdateCardControlsResponse{}, nil
+	}
+	return s.client.UpdateCardControls(ctx, payload.CardID, api.UpdateCardControlsRequest{Controls: payload.Controls})
+}
+
+// completeCardControls records when the issuer applied a controls version.
+func (s *CardService) completeCardControls(ctx context.Context, op models.OutboxOperation, result bson.Raw) error {
+	var resp api.UpdateCardControlsResponse
+	if err := bson.Unmarshal(result, &resp); err != nil {
+		return fmt.Errorf("failed to decode card controls result: %w", err)
+	}
+	if resp.Data.ID == "" {
+		return nil
+	}
+	var payload cardControlsPayload
+	if err := bson.Unmarshal(op.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode card controls: %w", err)
+	}
+	_, err := s.store.ControlsVersions.UpdateOne(ctx,
+		bson.M{"cardId": payload.CardID, "version": payload.Version},
+		bson.M{"$set": bson.M{"pushedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update card controls version: %w", err)
+	}
+	return nil
+}
+
+// ControlsVersions returns every version of the controls of a card, newest first.
+func (s *CardService) ControlsVersions(ctx context.Context, cardID string) ([]models.CardControlsVersion, error) {
+	card, err := s.getCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := s.store.ControlsVersions.Find(ctx, bson.M{"cardId": cardID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list card controls versions: %w", err)
+	}
+	versions := []models.CardControlsVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, fmt.Errorf("failed to decode card controls versions: %w", err)
+	}
+	if len(versions) == 0 {
+		versions = append(versions, linkedControls(card))
+	}
+	return versions, nil
+}
+
+// ControlsAt returns the version of the controls of a card that was in force
+// at the given time, such as when an authorization was decided.
+func (s *CardService) ControlsAt(ctx context.Context, cardID string, at time.Time) (*models.CardControlsVersion, error) {
+	card, err := s.getCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if at.Before(card.CreatedAt) {
+		return nil, ErrNoControlsInForce
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "effectiveFrom", Value: -1}, {Key: "version", Value: -1}})
+	var version models.CardControlsVersion
+	err = s.store.ControlsVersions.FindOne(ctx, bson.M{"cardId": cardID, "effectiveFrom": bson.M{"$lte": at}}, opts).Decode(&version)
+	if err == mongo.ErrNoDocuments {
+		if card.ControlsVersion != 0 {
+			return nil, ErrNoControlsInForce
+		}
+		linked := linkedControls(card)
+		return &linked, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch card controls version: %w", err)
+	}
+	return &version, nil
+}
+
+// linkedControls is version 0 of the controls of a card, the controls it was
+// linked with.
+func linkedControls(card *models.Card) models.CardControlsVersion {
+	return models.CardControlsVersion{
+		CardID:        card.CardID,
+		Version:       0,
+		Controls:      card.Controls,
+		EffectiveFrom: card.CreatedAt,
+		CreatedAt:     card.CreatedAt,
+	}
+}
+
+// mergeControls returns current with the fields set in update replaced.
+func mergeControls(current, update api.CardControls) api.CardControls {
+	merged := current
+	if update.AllowedChannels != nil {
+		merged.AllowedChannels = update.AllowedChannels
+	}
+	if update.BlockedChannels != nil {
+		merged.BlockedChannels = update.BlockedChannels
+	}
+	if update.AllowedMerchants != nil {
+		merged.AllowedMerchants = update.AllowedMerchants
+	}
+	if update.BlockedMerchants != nil {
+		merged.BlockedMerchants = update.BlockedMerchants
+	}
+	if update.AllowedCategories != nil {
+		merged.AllowedCategories = update.AllowedCategories
+	}
+	if update.BlockedCategories != nil {
+		merged.BlockedCategories = update.BlockedCategories
+	}
+	if update.SpendingLimits != nil {
+		merged.SpendingLimits = update.SpendingLimits
+	}
+	if update.AllowedCountries != nil {
+		merged.AllowedCountries = update.AllowedCountries
+	}
+	if update.BlockedCountries != nil {
+		merged.BlockedCountries = update.BlockedCountries
+	}
+	if update.Schedule != nil {
+		merged.Schedule = update.Schedule
+		// An empty schedule restricts nothing, so it removes the schedule
+		if update.Schedule.Timezone == "" && len(update.Schedule.Days) == 0 && len(update.Schedule.Hours) == 0 {
+			merged.Schedule = nil
+		}
+	}
+	if update.TemporaryBlock != nil {
+		merged.TemporaryBlock = update.TemporaryBlock
+	}
+	if update.Unlocks != nil {
+		merged.Unlocks = update.Unlocks
+	}
+	return merged
+}
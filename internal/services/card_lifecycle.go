@@ -0,0 +1,239 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidCardTransition is returned when a card cannot move to the requested status.
+	ErrInvalidCardTransition = errors.New("invalid card status transition")
+	// ErrInvalidReplacement is returned when a card replacement request is incomplete.
+	ErrInvalidReplacement = errors.New("invalid card replacement")
+	// ErrCardReplaced is returned when a card was already replaced.
+	ErrCardReplaced = errors.New("card already replaced")
+)
+
+// Card lifecycle actions.
+const (
+	cardActivate  = "activate"
+	cardFreeze    = "freeze"
+	cardUnfreeze  = "unfreeze"
+	cardTerminate = "terminate"
+)
+
+// cardStateChange is an edge of the card state machine: the statuses an
+// action moves a card from, and the status it moves it to.
+type cardStateChange struct {
+	from []string
+	to   string
+}
+
+// cardStates is the card state machine: inactive → active → frozen → active,
+// and any linked card → terminated. Terminated is final.
+var cardStates = map[string]cardStateChange{
+	cardActivate:  {from: []string{models.CardInactive}, to: models.CardActive},
+	cardFreeze:    {from: []string{models.CardActive}, to: models.CardFrozen},
+	cardUnfreeze:  {from: []string{models.CardFrozen}, to: models.CardActive},
+	cardTerminate: {from: []string{models.CardInactive, models.CardActive, models.CardFrozen}, to: models.CardTerminated},
+}
+
+// cardTransition returns the status an action moves a card to, or an error
+// wrapping ErrInvalidCardTransition when the card's status does not allow it.
+func cardTransition(card *models.Card, action string) (string, error) {
+	change, ok := cardStates[action]
+	if !ok || !slices.Contains(change.from, card.Status) {
+		return "", fmt.Errorf("%w: cannot %s a card that is %s", ErrInvalidCardTransition, action, card.Status)
+	}
+	return change.to, nil
+}
+
+// cardStatusPayload is the payload of an update-card-status operation.
+type cardStatusPayload struct {
+	CardID  string                      `bson:"cardId"`
+	From    []string                    `bson:"from"` // Statuses the change applies to
+	Request api.UpdateCardStatusRequest `bson:"request"`
+}
+
+// FreezeCard temporarily blocks an active card.
+func (s *CardService) FreezeCard(ctx context.Context, cardID, reason string) (*models.Card, error) {
+	return s.changeStatus(ctx, cardID, cardFreeze, reason)
+}
+
+// UnfreezeCard lifts the block of a frozen card.
+func (s *CardService) UnfreezeCard(ctx context.Context, cardID string) (*models.Card, error) {
+	return s.changeStatus(ctx, cardID, cardUnfreeze, "")
+}
+
+// TerminateCard permanently blocks a card.
+func (s *CardService) TerminateCard(ctx context.Context, cardID, reason string) (*models.Card, error) {
+	return s.changeStatus(ctx, cardID, cardTerminate, reason)
+}
+
+// changeStatus applies a lifecycle action to a card with the issuer, through
+// the outbox, and returns the card once its new status is stored. An
+// *OperationPendingError is returned when the issuer call did not complete.
+func (s *CardService) changeStatus(ctx context.Context, cardID, action, reason string) (*models.Card, error) {
+	card, err := s.getCard(ctx, cardID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := cardTransition(card, action)
+	if err != nil {
+		s.logger.Warn("Card status not changed", zap.String("cardID", cardID), zap.String("action", action), zap.String("status", card.Status))
+		return nil, err
+	}
+
+	payload := cardStatusPayload{
+		CardID:  cardID,
+		From:    cardStates[action].from,
+		Request: api.UpdateCardStatusRequest{Status: status, Reason: reason},
+	}
+	// A retry against the same card state is the same operation
+	key := fmt.Sprintf("card-status:%s:%s:%d", cardID, status, card.UpdatedAt.UnixNano())
+	op, err := s.outbox.Submit(ctx, models.OpUpdateCardStatus, key, cardID, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.outbox.Dispatch(ctx, op.ID); err != nil {
+		s.logger.Error("Failed to change card status via API", zap.String("cardID", cardID), zap.String("action", action), zap.Error(err))
+		return nil, err
+	}
+	s.logger.Info("Card status changed",
+		zap.String("cardID", cardID),
+		zap.String("from", card.Status),
+		zap.String("to", status),
+		zap.String("reason", reason),
+	)
+	return s.getCard(ctx, cardID)
+}
+
+// ReplaceCard replaces a lost or stolen card: it terminates the card, unless
+// it already is, and links a new PAN for the same customer and funding
+// source with the controls, metadata and employee of the old card.
+func (s *CardService) ReplaceCard(ctx context.Context, cardID, pan, reason string) (*models.Card, error) {
+	if reason != models.CardLost && reason != models.CardStolen {
+		return nil, fmt.Errorf("%w: reason must be %s or %s", ErrInvalidReplacement, models.CardLost, models.CardStolen)
+	}
+	if _, err := s.getCard(ctx, cardID); err != nil {
+		return nil, err
+	}
+	// Concurrent replacements race for the claim; only one links a card
+	replacement := primitive.NewObjectID()
+	var old models.Card
+	err := s.store.Cards.FindOneAndUpdate(ctx,
+		bson.M{"cardId": cardID, "replacement": bson.M{"$exists": false}, "replacedBy": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"replacement": replacement.Hex()}},
+	).Decode(&old)
+	if err == mongo.ErrNoDocuments {
+		s.logger.Warn("Card not replaced", zap.String("cardID", cardID), zap.Error(ErrCardReplaced))
+		return nil, ErrCardReplaced
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim card replacement: %w", err)
+	}
+
+	if old.Status != models.CardTerminated {
+		if _, err := s.changeStatus(ctx, cardID, cardTerminate, reason); err != nil {
+			// Replacing again resumes the termination
+			s.releaseReplacement(ctx, cardID, replacement.Hex())
+			return nil, err
+		}
+	}
+
+	controls, metadata := old.Controls, old.Metadata
+	req := api.LinkCardRequest{
+		Pan:           pan,
+		Customer:      old.CustomerID,
+		FundingSource: old.FundingSource,
+		Controls:      &controls,
+		Metadata:      &metadata,
+	}
+	resp, err := s.link(ctx, req, models.Card{ID: replacement, Employee: old.Employee, Replaces: cardID})
+	if err != nil {
+		// Once the card is stored, the outbox links it or releases the claim when it gives up
+		if count, cerr := s.store.Cards.CountDocuments(ctx, bson.M{"_id": replacement}); cerr == nil && count == 0 {
+			s.releaseReplacement(ctx, cardID, replacement.Hex())
+		}
+		return nil, err
+	}
+	s.logger.Info("Replaced card", zap.String("cardID", cardID), zap.String("replacement", resp.Data.ID), zap.String("reason", reason))
+	return s.getCard(ctx, resp.Data.ID)
+}
+
+// releaseReplacement lets a card be replaced again after the card claimed to
+// replace it was not linked.
+func (s *CardService) releaseReplacement(ctx context.Context, cardID, replacement string) {
+	_, err := s.store.Cards.UpdateOne(ctx,
+		bson.M{"cardId": cardID, "replacement": replacement},
+		bson.M{"$unset": bson.M{"replacement": ""}},
+	)
+	if err != nil {
+		s.logger.Error("Failed to release card replacement", zap.String("cardID", cardID), zap.String("replacement", replacement), zap.Error(err))
+	}
+}
+
+// executeCardStatus changes the status of the card of an update-card-status
+// operation with the issuer.
+func (s *CardService) executeCardStatus(ctx context.Context, op models.OutboxOperation) (interface{}, error) {
+	var payload cardStatusPayload
+	if err := bson.Unmarshal(op.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode card status request: %w", err)
+	}
+	return s.client.UpdateCardStatus(ctx, payload.CardID, payload.Request)
+}
+
+// completeCardStatus stores the status the issuer moved a card to.
+func (s *CardService) completeCardStatus(ctx context.Context, op models.OutboxOperation, _ bson.Raw) error {
+	var payload cardStatusPayload
+	if err := bson.Unmarshal(op.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode card status request: %w", err)
+	}
+	return s.setCardStatus(ctx, payload.CardID, payload.From, payload.Request.Status, payload.Request.Reason)
+}
+
+// setCardStatus stores the status of a card and records the change. It
+// returns an error wrapping ErrInvalidCardTransition when the card left the
+// statuses the change applies to in the meantime: a freeze completing after a
+// termination must not revive the card.
+func (s *CardService) setCardStatus(ctx context.Context, cardID string, from []string, status, reason string) error {
+	now := time.Now()
+	result, err := s.store.Cards.UpdateOne(ctx, bson.M{"cardId": cardID, "status": bson.M{"$in": from}}, bson.M{
+		"$set":  bson.M{"status": status, "updatedAt": now},
+		"$push": bson.M{"history": models.CardStatusChange{Status: status, Reason: reason, At: now.UTC()}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update card status: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		s.logger.Warn("Card status change superseded", zap.String("cardID", cardID), zap.Strings("from", from), zap.String("to", status))
+		return fmt.Errorf("%w: %w: card %s is no longer %s", ErrOperationConflict, ErrInvalidCardTransition, cardID, strings.Join(from, " or "))
+	}
+	return nil
+}
+
+// getCard fetches a card by its issuer ID.
+func (s *CardService) getCard(ctx context.Context, cardID string) (*models.Card, error) {
+	var card models.Card
+	err := s.store.Cards.FindOne(ctx, bson.M{"cardId": cardID}).Decode(&card)
+	if err == mongo.ErrNoDocuments {
+		s.logger.Error("Card not found", zap.String("cardID", cardID))
+		return nil, ErrCardNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to fetch card", zap.String("cardID", cardID), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch card: %w", err)
+	}
+	return &card, nil
+}
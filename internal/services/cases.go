@@ -0,0 +1,201 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCaseNotFound is returned when a case does not exist.
+	ErrCaseNotFound = errors.New("case not found")
+	// ErrInvalidCaseTransition is returned when a case cannot move to the requested status.
+	ErrInvalidCaseTransition = errors.New("invalid case status transition")
+	// ErrInvalidCaseUpdate is returned when a status change or note is incomplete.
+	ErrInvalidCaseUpdate = errors.New("invalid case update")
+)
+
+// caseTransitions lists the statuses each case status can move to. Closed
+// cases can be reopened for investigation.
+var caseTransitions = map[string][]string{
+	models.CaseOpen:          {models.CaseInvestigating, models.CaseEscalated, models.CaseClosed},
+	models.CaseInvestigating: {models.CaseEscalated, models.CaseClosed},
+	models.CaseEscalated:     {models.CaseInvestigating, models.CaseClosed},
+	models.CaseClosed:        {models.CaseInvestigating},
+}
+
+// CaseService manages the compliance cases opened by transaction monitoring.
+type CaseService struct {
+	store  *store.Store
+	logger *zap.Logger
+}
+
+// NewCaseService creates a case service.
+func NewCaseService(store *store.Store, logger *zap.Logger) *CaseService {
+	return &CaseService{store: store, logger: logger}
+}
+
+// Open opens a case for a monitoring alert. It reports false without opening
+// anything when the account already has an active case of the same type or
+// the alert already opened a case.
+func (s *CaseService) Open(ctx context.Context, c models.Case) (bool, error) {
+	active, err := s.store.HasActiveCase(ctx, c.AccountID, c.Type)
+	if err != nil {
+		return false, fmt.Errorf("failed to check active cases: %w", err)
+	}
+	if active {
+		return false, nil
+	}
+
+	var customer models.Customer
+	err = s.store.Customers.FindOne(ctx, bson.M{"accountId": c.AccountID}).Decode(&customer)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, fmt.Errorf("failed to fetch customer: %w", err)
+	}
+	now := time.Now().UTC()
+	c.CustomerID = customer.CustomerID
+	c.Status = models.CaseOpen
+	c.Notes = []models.CaseNote{}
+	c.History = []models.CaseStatusChange{{To: models.CaseOpen, Actor: "monitoring", At: now}}
+	c.CreatedAt = now
+	c.UpdatedAt = now
+
+	id, err := s.store.InsertCase(ctx, c)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to store case: %w", err)
+	}
+	s.logger.Info("Opened compliance case",
+		zap.String("caseID", id.Hex()),
+		zap.String("type", c.Type),
+		zap.String("accountID", c.AccountID),
+	)
+	return true, nil
+}
+
+// ListCases returns a page of cases, newest first, optionally filtered by
+// status, type and sub-account.
+func (s *CaseService) ListCases(ctx context.Context, status, caseType, accountID string, page, limit int64) ([]models.Case, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	if caseType != "" {
+		filter["type"] = caseType
+	}
+	if accountID != "" {
+		filter["accountId"] = accountID
+	}
+	cases, err := s.store.ListCases(ctx, filter, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list cases", zap.Error(err))
+		return nil, fmt.Errorf("failed to list cases: %w", err)
+	}
+	return cases, nil
+}
+
+// GetCase returns a single case.
+func (s *CaseService) GetCase(ctx context.Context, id string) (*models.Case, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrCaseNotFound
+	}
+	c, err := s.store.GetCase(ctx, objectID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCaseNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to fetch case", zap.String("caseID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch case: %w", err)
+	}
+	return c, nil
+}
+
+// UpdateStatus moves a case through the status workflow. Closing a case
+// requires a reason. The change only applies if the case still has the status
+// it was validated against.
+func (s *CaseService) UpdateStatus(ctx context.Context, id, status, actor, reason string) (*models.Case, error) {
+	if actor == "" {
+		return nil, fmt.Errorf("%w: actor is required", ErrInvalidCaseUpdate)
+	}
+	if _, ok := caseTransitions[status]; !ok {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidCaseUpdate, status)
+	}
+	if status == models.CaseClosed && reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required to close a case", ErrInvalidCaseUpdate)
+	}
+	current, err := s.GetCase(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(caseTransitions[current.Status], status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidCaseTransition, current.Status, status)
+	}
+
+	now := time.Now().UTC()
+	updated, err := s.store.UpdateCase(ctx,
+		bson.M{"_id": current.ID, "status": current.Status},
+		bson.M{
+			"$set": bson.M{"status": status, "updatedAt": now},
+			"$push": bson.M{"history": models.CaseStatusChange{
+				From:   current.Status,
+				To:     status,
+				Actor:  actor,
+				Reason: reason,
+				At:     now,
+			}},
+		},
+	)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%w: case status changed concurrently", ErrInvalidCaseTransition)
+	}
+	if err != nil {
+		s.logger.Error("Failed to update case status", zap.String("caseID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to update case status: %w", err)
+	}
+	s.logger.Info("Updated case status",
+		zap.String("caseID", id),
+		zap.String("from", current.Status),
+		zap.String("to", status),
+		zap.String("actor", actor),
+	)
+	return updated, nil
+}
+
+// AddNote appends a note to a case.
+func (s *CaseService) AddNote(ctx context.Context, id, author, text string) (*models.Case, error) {
+	if author == "" || text == "" {
+		return nil, fmt.Errorf("%w: author and text are required", ErrInvalidCaseUpdate)
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrCaseNotFound
+	}
+	now := time.Now().UTC()
+	updated, err := s.store.UpdateCase(ctx,
+		bson.M{"_id": objectID},
+		bson.M{
+			"$set":  bson.M{"updatedAt": now},
+			"$push": bson.M{"notes": models.CaseNote{Author: author, Text: text, At: now}},
+		},
+	)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCaseNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to add case note", zap.String("caseID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to add case note: %w", err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// allowThenBlock applies allow/block lists: an entry matching the allow list
+// permits the value, then an entry matching the block list denies it, and a
+// value matching neither is permitted only when the allow list is empty.
+func allowThenBlock(allowed, blocked []string, matches func(entry string) bool) bool {
+	for _, entry := range allowed {
+		if matches(entry) {
+			return true
+		}
+	}
+	for _, entry := range blocked {
+		if matches(entry) {
+			return false
+		}
+	}
+	return len(allowed) == 0
+}
+
+// isMerchantAllowed checks the merchant controls of a card. Entries match the
+// merchant ID exactly or the merchant name case-insensitively.
+func isMerchantAllowed(controls api.CardControls, merchantID, merchantName string) bool {
+	return allowThenBlock(controls.AllowedMerchants, controls.BlockedMerchants, func(entry string) bool {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return false
+		}
+		return entry == merchantID || (merchantName != "" && strings.EqualFold(entry, merchantName))
+	})
+}
+
+// isCategoryAllowed checks the merchant category controls of a card. Entries
+// are MCCs such as "5411" or inclusive MCC ranges such as "4000-4799".
+func isCategoryAllowed(controls api.CardControls, mcc string) bool {
+	return allowThenBlock(controls.AllowedCategories, controls.BlockedCategories, func(entry string) bool {
+		return mccMatches(entry, mcc)
+	})
+}
+
+// mccMatches reports whether an MCC falls under a category entry.
+func mccMatches(entry, mcc string) bool {
+	entry = strings.TrimSpace(entry)
+	if mcc == "" || entry == "" {
+		return false
+	}
+	from, to, isRange := strings.Cut(entry, "-")
+	if !isRange {
+		return entry == mcc
+	}
+	code, err := strconv.Atoi(mcc)
+	if err != nil {
+		return false
+	}
+	low, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return false
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return false
+	}
+	return code >= low && code <= high
+}
+
+// Channels authorizations arrive on, as named in the authorization events.
+const (
+	ChannelPOS    = "POS"
+	ChannelATM    = "ATM"
+	ChannelOnline = "Online"
+)
+
+var knownChannels = []string{ChannelPOS, ChannelATM, ChannelOnline}
+
+// validateControls checks card controls before they are stored: channels
+// must be known, spending limits non-negative with a supported interval, no
+// merchant or country may be both allowed and blocked, countries must be
+// ISO 3166 alpha-2 codes, and schedules must parse.
+func validateControls(controls api.CardControls) error {
+	for _, channel := range append(slices.Clone(controls.AllowedChannels), controls.BlockedChannels...) {
+		if !slices.Contains(knownChannels, channel) {
+			return fmt.Errorf("%w: unknown channel %q, expected one of %s", ErrInvalidControls, channel, strings.Join(knownChannels, ", "))
+		}
+	}
+	for _, limit := range controls.SpendingLimits {
+		if limit.Amount < 0 {
+			return fmt.Errorf("%w: %s spending limit is negative", ErrInvalidControls, limit.Interval)
+		}
+		switch limit.Interval {
+		case IntervalDaily, IntervalWeekly, IntervalMonthly:
+		default:
+			return fmt.Errorf("%w: unknown spending limit interval %q", ErrInvalidControls, limit.Interval)
+		}
+	}
+	// Merchant entries match names case-insensitively, so compare them that way
+	for _, allowed := range controls.AllowedMerchants {
+		for _, blocked := range controls.BlockedMerchants {
+			if strings.EqualFold(strings.TrimSpace(allowed), strings.TrimSpace(blocked)) {
+				return fmt.Errorf("%w: merchant %q is both allowed and blocked", ErrInvalidControls, allowed)
+			}
+		}
+	}
+	for _, country := range append(slices.Clone(controls.AllowedCountries), controls.BlockedCountries...) {
+		if !countryCode.MatchString(country) {
+			return fmt.Errorf("%w: country %q is not an ISO 3166 alpha-2 code such as NG", ErrInvalidControls, country)
+		}
+	}
+	for _, country := range controls.AllowedCountries {
+		if slices.Contains(controls.BlockedCountries, country) {
+			return fmt.Errorf("%w: country %q is both allowed and blocked", ErrInvalidControls, country)
+		}
+	}
+	return validateSchedule(controls)
+}
+
+// scheduleDays maps the day names of a control schedule to weekdays.
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// isCountryBlocked reports whether the country of a transaction is on the
+// blocked countries of a card.
+func isCountryBlocked(controls api.CardControls, country string) bool {
+	return country != "" && slices.ContainsFunc(controls.BlockedCountries, func(entry string) bool {
+		return strings.EqualFold(strings.TrimSpace(entry), country)
+	})
+}
+
+// isCountryAllowed reports whether the country of a transaction is on the
+// allowed countries of a card, or the card allows every country. A
+// transaction whose country is unknown cannot be shown to be on the list and
+// is not allowed when the card has one.
+func isCountryAllowed(controls api.CardControls, country string) bool {
+	return len(controls.AllowedCountries) == 0 || country != "" && slices.ContainsFunc(controls.AllowedCountries, func(entry string) bool {
+		return strings.EqualFold(strings.TrimSpace(entry), country)
+	})
+}
+
+// isScheduleAllowed checks the schedule of a card at the time of an
+// authorization, in the schedule's timezone or else in loc.
+func isScheduleAllowed(controls api.CardControls, at time.Time, loc *time.Location) bool {
+	schedule := controls.Schedule
+	if schedule == nil {
+		return true
+	}
+	if schedule.Timezone != "" {
+		if tz, err := time.LoadLocation(schedule.Timezone); err == nil {
+			loc = tz
+		}
+	}
+	local := at.In(loc)
+	if len(schedule.Days) > 0 && !slices.ContainsFunc(schedule.Days, func(day string) bool {
+		weekday, ok := scheduleDays[strings.ToLower(day)]
+		return ok && weekday == local.Weekday()
+	}) {
+		return false
+	}
+	if len(schedule.Hours) == 0 {
+		return true
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, hours := range schedule.Hours {
+		from, errFrom := minuteOfDay(hours.From)
+		to, errTo := minuteOfDay(hours.To)
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		if from <= to && minute >= from && minute < to {
+			return true
+		}
+		// The range runs past midnight
+		if from > to && (minute >= from || minute < to) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTemporarilyBlocked reports whether a temporary block is in force at the given time.
+func isTemporarilyBlocked(controls api.CardControls, at time.Time) bool {
+	return controls.TemporaryBlock != nil && at.Before(controls.TemporaryBlock.Until)
+}
+
+// isChannelUnlocked reports whether a channel unlock is in force at the given time.
+func isChannelUnlocked(controls api.CardControls, channel string, at time.Time) bool {
+	return slices.ContainsFunc(controls.Unlocks, func(unlock api.ChannelUnlock) bool {
+		return unlock.Channel == channel && at.Before(unlock.Until)
+	})
+}
+
+// withoutExpiredOverrides returns the controls without the temporary block
+// and channel unlocks that ended by now, and whether any did.
+func withoutExpiredOverrides(controls api.CardControls, now time.Time) (api.CardControls, bool) {
+	expired := false
+	if controls.TemporaryBlock != nil && !now.Before(controls.TemporaryBlock.Until) {
+		controls.TemporaryBlock = nil
+		expired = true
+	}
+	var unlocks []api.ChannelUnlock
+	for _, unlock := range controls.Unlocks {
+		if now.Before(unlock.Until) {
+			unlocks = append(unlocks, unlock)
+			continue
+		}
+		expired = true
+	}
+	controls.Unlocks = unlocks
+	return controls, expired
+}
+
+// minuteOfDay parses an HH:MM time of day into minutes since midnight.
+func minuteOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateSchedule checks the schedule and temporary overrides of card controls.
+func validateSchedule(controls api.CardControls) error {
+	if schedule := controls.Schedule; schedule != nil {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidControls, schedule.Timezone)
+		}
+		for _, day := range schedule.Days {
+			if _, ok := scheduleDays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("%w: unknown day %q, expected sun to sat", ErrInvalidControls, day)
+			}
+		}
+		for _, hours := range schedule.Hours {
+			from, err := minuteOfDay(hours.From)
+			if err != nil {
+				return fmt.Errorf("%w: hour range start %q is not HH:MM", ErrInvalidControls, hours.From)
+			}
+			to, err := minuteOfDay(hours.To)
+			if err != nil {
+				return fmt.Errorf("%w: hour range end %q is not HH:MM", ErrInvalidControls, hours.To)
+			}
+			if from == to {
+				return fmt.Errorf("%w: hour range %s-%s is empty", ErrInvalidControls, hours.From, hours.To)
+			}
+		}
+	}
+	if controls.TemporaryBlock != nil && controls.TemporaryBlock.Until.IsZero() {
+		return fmt.Errorf("%w: temporary block needs an end time", ErrInvalidControls)
+	}
+	for _, unlock := range controls.Unlocks {
+		if !slices.Contains(knownChannels, unlock.Channel) {
+			return fmt.Errorf("%w: unknown channel %q, expected one of %s", ErrInvalidControls, unlock.Channel, strings.Join(knownChannels, ", "))
+		}
+		if unlock.Until.IsZero() {
+			return fmt.Errorf("%w: %s unlock needs an end time", ErrInvalidControls, unlock.Channel)
+		}
+	}
+	return nil
+}
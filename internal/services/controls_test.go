@@ -0,0 +1,129 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/simulator"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestCountryControls(t *testing.T) {
+	allowList := api.CardControls{AllowedCountries: []string{"NG", " gh"}}
+	blockList := api.CardControls{BlockedCountries: []string{"US"}}
+
+	tests := []struct {
+		name     string
+		controls api.CardControls
+		country  string
+		allowed  bool
+		blocked  bool
+	}{
+		{name: "no lists", country: "US", allowed: true},
+		{name: "no lists, unknown country", allowed: true},
+		{name: "on the allow list", controls: allowList, country: "NG", allowed: true},
+		{name: "on the allow list, trimmed and folded", controls: allowList, country: "GH", allowed: true},
+		{name: "off the allow list", controls: allowList, country: "US"},
+		{name: "allow list, unknown country", controls: allowList},
+		{name: "on the block list", controls: blockList, country: "US", allowed: true, blocked: true},
+		{name: "block list, unknown country", controls: blockList, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCountryAllowed(tt.controls, tt.country); got != tt.allowed {
+				t.Errorf("isCountryAllowed(%q) = %v; want %v", tt.country, got, tt.allowed)
+			}
+			if got := isCountryBlocked(tt.controls, tt.country); got != tt.blocked {
+				t.Errorf("isCountryBlocked(%q) = %v; want %v", tt.country, got, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestLinkCardValidatesControls(t *testing.T) {
+	// Controls are rejected before the issuer or the store is reached
+	cards := &CardService{logger: zap.NewNop()}
+	invalid := []api.CardControls{
+		{AllowedChannels: []string{"TELEPATHY"}},
+		{SpendingLimits: []api.SpendingLimit{{Amount: -1, Interval: "daily"}}},
+		{AllowedCountries: []string{"NGA"}},
+	}
+	for _, controls := range invalid {
+		_, _, _, _, _, _, _, _, err := cards.LinkCard(context.Background(), "5399000011112222", "cus_1", "acc_1", "", &controls, nil)
+		if !errors.Is(err, ErrInvalidControls) {
+			t.Errorf("LinkCard(%+v) error = %v; want %v", controls, err, ErrInvalidControls)
+		}
+	}
+}
+
+func TestMergeControlsSchedule(t *testing.T) {
+	weekdays := &api.ControlSchedule{Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+	nights := &api.ControlSchedule{Hours: []api.HourRange{{From: "22:00", To: "06:00"}}}
+	current := api.CardControls{Schedule: weekdays}
+
+	tests := []struct {
+		name   string
+		update *api.ControlSchedule
+		want   *api.ControlSchedule
+	}{
+		{name: "omitted keeps the schedule", want: weekdays},
+		{name: "replaced", update: nights, want: nights},
+		{name: "empty removes the schedule", update: &api.ControlSchedule{}},
+		{name: "empty lists remove the schedule", update: &api.ControlSchedule{Days: []string{}, Hours: []api.HourRange{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeControls(current, api.CardControls{Schedule: tt.update})
+			if !reflect.DeepEqual(got.Schedule, tt.want) {
+				t.Errorf("schedule = %+v; want %+v", got.Schedule, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpireOverridesSkipsValidation(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{})
+	outbox := NewOutboxService(db, OutboxConfig{MaxAttempts: 3, Backoff: time.Second, Secret: "secret"}, zap.NewNop())
+	cards := NewCardService(db, sim, nil, NewKYCService(db, nil, zap.NewNop()), outbox, zap.NewNop())
+
+	linked, err := sim.LinkCard(ctx, api.LinkCardRequest{Pan: "5399000011112222", Customer: customerID, FundingSource: accountID})
+	if err != nil {
+		t.Fatalf("LinkCard: %v", err)
+	}
+	// The country list predates country validation and no longer passes it
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	controls := api.CardControls{
+		BlockedCountries: []string{"NGA"},
+		TemporaryBlock:   &api.TemporaryBlock{Until: now.Add(-time.Minute)},
+		Unlocks: []api.ChannelUnlock{
+			{Channel: ChannelOnline, Until: now.Add(-time.Minute)},
+			{Channel: ChannelATM, Until: now.Add(time.Hour)},
+		},
+	}
+	if validateControls(controls) == nil {
+		t.Fatal("legacy controls pass validation")
+	}
+	card := models.Card{CardID: linked.Data.ID, CustomerID: customerID, Status: models.CardActive, Controls: controls, CreatedAt: now}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+
+	if expired, err := cards.ExpireOverrides(ctx, now); err != nil || expired != 1 {
+		t.Fatalf("ExpireOverrides = %d, %v; want 1", expired, err)
+	}
+	stored, err := cards.getCard(ctx, card.CardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := api.CardControls{BlockedCountries: []string{"NGA"}, Unlocks: []api.ChannelUnlock{controls.Unlocks[1]}}
+	if stored.ControlsVersion != 1 || stored.Controls.TemporaryBlock != nil || !reflect.DeepEqual(stored.Controls.Unlocks, want.Unlocks) || !reflect.DeepEqual(stored.Controls.BlockedCountries, want.BlockedCountries) {
+		t.Errorf("controls = %+v at version %d; want %+v at version 1", stored.Controls, stored.ControlsVersion, want)
+	}
+}
@@ -5,136 +5,198 @@ import (
 	"card-service/internal/models"
 	"card-service/internal/store"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.uber.org/zap"
 )
 
+var (
+	// ErrOnboardingBlocked is returned when screening matched a watchlist entry above the block threshold.
+	ErrOnboardingBlocked = errors.New("onboarding blocked by sanctions screening")
+	// ErrOnboardingInReview is returned when screening queued the onboarding for a manual review.
+	ErrOnboardingInReview = errors.New("onboarding held for sanctions screening review")
+)
+
+// ScreeningHoldError is returned when screening stops an onboarding. It
+// wraps ErrOnboardingBlocked or ErrOnboardingInReview.
+type ScreeningHoldError struct {
+	ScreeningID string
+	Result      string
+}
+
+func (e *ScreeningHoldError) Error() string {
+	return e.Unwrap().Error()
+}
+
+func (e *ScreeningHoldError) Unwrap() error {
+	if e.Result == models.ScreeningBlocked {
+		return ErrOnboardingBlocked
+	}
+	return ErrOnboardingInReview
+}
+
 //logic to create a customer and a sub account
 
 //customer service handles customer and sub accounts operations
 
 type CustomerService struct {
-	store     *store.Store //MongoDB store
-	apiClient *api.Client  //API client for external services
-	logger    *zap.Logger  //Logger for logging
+	store     *store.Store      //MongoDB store
+	apiClient api.Issuer        //Card issuer customers and sub accounts are created with
+	ledger    *LedgerService    //Internal ledger backing sub-accounts
+	screening *ScreeningService //Sanctions screening of new customers
+	kyc       *KYCService       //KYC tier of new customers
+	outbox    *OutboxService    //Outbox running the issuer calls of onboarding
+	logger    *zap.Logger       //Logger for logging
 }
 
-// NewCustomerService initializes a new CustomerService instance with the provided store, API client, and logger.
-func NewCustomerService(store *store.Store, apiClient *api.Client, logger *zap.Logger) *CustomerService {
-	return &CustomerService{store: store, apiClient: apiClient, logger: logger}
+// NewCustomerService initializes a new CustomerService instance with the provided store, API client, ledger, screening service, KYC service, outbox and logger.
+func NewCustomerService(store *store.Store, apiClient api.Issuer, ledger *LedgerService, screening *ScreeningService, kyc *KYCService, outbox *OutboxService, logger *zap.Logger) *CustomerService {
+	s := &CustomerService{store: store, apiClient: apiClient, ledger: ledger, screening: screening, kyc: kyc, outbox: outbox, logger: logger}
+	outbox.Register(models.OpCreateCustomer, OutboxHandler{Execute: s.executeCreateCustomer, Complete: s.completeIssuerStep})
+	outbox.Register(models.OpCreateSubAccount, OutboxHandler{Execute: s.executeCreateSubAccount, Complete: s.completeIssuerStep})
+	return s
 }
 
 //CreateCustomer creatres a customer and a sub account and stores them in the mongoDB
+//once the customer has passed sanctions screening. A screening match returns a
+//*ScreeningHoldError and the onboarding is blocked or waits for a review. Retrying
+//with the same ref resumes an onboarding that failed part way.
 
-func (s *CustomerService) CreateCustomer(name, firstName, lastName, middleName, email, phoneNumber, title, gender, dob, nationalityCode, idType, idNumber, issuingCountry string,
+func (s *CustomerService) CreateCustomer(ctx context.Context, name, firstName, lastName, middleName, email, phoneNumber, title, gender, dob, nationalityCode, idType, idNumber, issuingCountry string,
 	userID int, ref string) (string, string, []api.DepositChannel, error) {
 	s.logger.Info("Starting CreateCustomer",
 		zap.String("email", email),
 		zap.String("phoneNumber", phoneNumber),
 		zap.String("gender", gender),
 	)
+	application := models.CustomerApplication{
+		Name:            name,
+		FirstName:       firstName,
+		LastName:        lastName,
+		MiddleName:      middleName,
+		Email:           email,
+		PhoneNumber:     phoneNumber,
+		Title:           title,
+		Gender:          gender,
+		DateOfBirth:     dob,
+		NationalityCode: nationalityCode,
+		IDType:          idType,
+		IDNumber:        idNumber,
+		IssuingCountry:  issuingCountry,
+		UserID:          userID,
+		Ref:             ref,
+	}
 
-	//start mongoDB transaction to ensure consistency
-	session, err := s.store.Client.StartSession()
-	if err != nil {
-		s.logger.Error("Failed to start MongoDB session", zap.Error(err))
-		return "", "", nil, err
+	onboarding := models.Onboarding{Ref: ref, Type: models.CustomerIndividual, Application: &application}
+	return s.startOnboarding(ctx, onboarding, func() models.Screening {
+		return s.screening.Screen(models.ScreeningOnboarding, applicationSubject(application))
+	})
+}
+
+// applicationSubject is the person screened for an onboarding request.
+func applicationSubject(application models.CustomerApplication) models.ScreeningSubject {
+	var names []string
+	for _, name := range []string{application.FirstName, application.MiddleName, application.LastName} {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
 	}
-	defer session.EndSession(context.Background())
+	return models.ScreeningSubject{
+		Name:        strings.Join(names, " "),
+		DateOfBirth: application.DateOfBirth,
+		Nationality: application.NationalityCode,
+	}
+}
+
+// individualCustomer is the issuer request and the stored customer of an
+// individual onboarding, at the tier granted on its identity.
+func (s *CustomerService) individualCustomer(application models.CustomerApplication) (api.CreateCustomerRequest, models.Customer) {
+	name, email := application.Name, application.Email
+	tier := s.kyc.InitialTier(application.IDType)
 
 	req := api.CreateCustomerRequest{
 		Name: name,
 		Type: "individual",
 		Claims: api.CustomerClaims{
-			IndividualInformation: api.IndividualInformation{
-				FirstName:       firstName,
-				LastName:        lastName,
-				MiddleName:      middleName,
+			IndividualInformation: &api.IndividualInformation{
+				FirstName:       application.FirstName,
+				LastName:        application.LastName,
+				MiddleName:      application.MiddleName,
 				Email:           email,
-				PhoneNumber:     phoneNumber,
-				Title:           title,
-				Gender:          gender,
-				DateOfBirth:     dob,
-				NationalityCode: nationalityCode,
+				PhoneNumber:     application.PhoneNumber,
+				Title:           application.Title,
+				Gender:          application.Gender,
+				DateOfBirth:     application.DateOfBirth,
+				NationalityCode: application.NationalityCode,
 			},
-			IndividualIdentity: api.IndividualIdentity{
-				Type:           idType,
-				ID:             idNumber,
-				IssuingCountry: issuingCountry,
+			IndividualIdentity: &api.IndividualIdentity{
+				Type:           application.IDType,
+				ID:             application.IDNumber,
+				IssuingCountry: application.IssuingCountry,
 			},
 		},
 		Verifications: []api.CustomerVerification{
-			{Type: "tier-2", Status: "verified"},
+			{Type: tier, Status: models.VerificationVerified},
 		},
 		Metadata: api.CustomerMetadata{
-			UserID: userID,
-			Ref:    ref,
+			UserID: application.UserID,
+			Ref:    application.Ref,
 		},
 	}
-	customerID, err := s.apiClient.CreateCustomer(req)
-	if err != nil {
-		s.logger.Error("Failed to create customer in Allawee API", zap.Error(err))
-		return "", "", nil, err
-	}
-	s.logger.Info("Created customer", zap.String("customerID", customerID))
-
-	//Create sub account
-	vaReq := api.CreateSubAccountRequest{
+	customer := models.Customer{
+		Type:            models.CustomerIndividual,
 		Name:            name,
-		Type:            "sub",
-		Currency:        "NGN",
-		Customer:        customerID,
-		DepositChannels: []string{"bank-account"},
-		// SettlementAccount: "",
-	}
-	accountID, depositChannels, err := s.apiClient.CreateSubAccount(vaReq)
-	if err != nil {
-		s.logger.Error("Failed to create sub account in Allawee API", zap.Error(err))
-		return "", "", nil, err
+		Email:           email,
+		DateOfBirth:     application.DateOfBirth,
+		NationalityCode: application.NationalityCode,
+		Tier:            tier,
+		Verifications:   []models.KYCVerification{s.kyc.InitialVerification(tier, application.IDType, time.Now().UTC())},
 	}
-	s.logger.Info("Created sub account", zap.String("subAccountID", accountID))
+	return req, customer
+}
 
-	//store customer in MongoDB
-	customer := models.Customer{
-		CustomerID: customerID,
-		Name:       name,
-		Email:      email,
-		// Balance:      0, //initial balance is 0
-		AccountID: accountID,
-		CreatedAt: time.Now(),
+// ReviewScreening records the review of a screening pending review. Clearing
+// an onboarding screening resumes the onboarding, and can be repeated if the
+// onboarding failed. Confirming a rescreening match freezes the account of
+// the customer.
+func (s *CustomerService) ReviewScreening(ctx context.Context, id, decision, reviewer, note string) (*models.Screening, error) {
+	screening, err := s.screening.review(ctx, id, decision, reviewer, note)
+	if errors.Is(err, ErrScreeningReviewed) && decision == models.ReviewCleared {
+		screening, err = s.screening.GetScreening(ctx, id)
+		if err == nil && (screening.ReviewStatus != models.ReviewCleared || (screening.Application == nil && screening.Business == nil) || screening.CustomerID != "") {
+			err = ErrScreeningReviewed
+		}
 	}
-	_, err = s.store.Customers.InsertOne(context.Background(), customer)
 	if err != nil {
-		s.logger.Error("Failed to store customer in MongoDB", zap.Error(err))
-		return "", "", nil, err
+		return nil, err
 	}
 
-	var depositChannelModels []models.DepositChannel
-	for _, dc := range depositChannels {
-		depositChannelModels = append(depositChannelModels, models.DepositChannel{
-			AccountName:   dc.AccountName,
-			AccountNumber: dc.AccountNumber,
-			BankName:      dc.BankName,
-			BankCode:      dc.BankCode,
-			Type:          dc.Type,
-		})
-	}
-	//store account in Mongo DB
-	account := models.Account{
-		AccountID:       accountID,
-		CustomerID:      customerID,
-		Name:            name,
-		DepositChannels: depositChannelModels,
-		Status:          "active", //default status is active
-		CreatedAt:       time.Now(),
+	switch {
+	case screening.Trigger == models.ScreeningOnboarding && (screening.Application != nil || screening.Business != nil) && decision == models.ReviewCleared:
+		customerID, accountID, err := s.resumeOnboarding(ctx, screening)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume onboarding: %w", err)
+		}
+		screening.CustomerID, screening.AccountID, screening.Application, screening.Business = customerID, accountID, nil, nil
+	case screening.Trigger == models.ScreeningOnboarding && decision == models.ReviewConfirmed:
+		if _, err := s.store.Screenings.UpdateOne(ctx, bson.M{"_id": screening.ID}, bson.M{"$unset": bson.M{"application": "", "business": ""}}); err != nil {
+			s.logger.Error("Failed to drop rejected application", zap.String("screeningID", id), zap.Error(err))
+		}
+		s.rejectOnboarding(ctx, screening.Ref)
+		screening.Application, screening.Business = nil, nil
+	case screening.Trigger == models.ScreeningRescreen && decision == models.ReviewConfirmed:
+		if _, err := s.store.Accounts.UpdateOne(ctx, bson.M{"accountId": screening.AccountID}, bson.M{"$set": bson.M{"status": "frozen"}}); err != nil {
+			s.logger.Error("Failed to freeze account", zap.String("accountID", screening.AccountID), zap.Error(err))
+			return nil, fmt.Errorf("failed to freeze account: %w", err)
+		}
+		s.logger.Warn("Froze account after confirmed watchlist match",
+			zap.String("customerID", screening.CustomerID),
+			zap.String("accountID", screening.AccountID),
+		)
 	}
-
-	_, err = s.store.Accounts.InsertOne(context.Background(), account)
-	if err != nil {
-		s.logger.Error("failed to store account in MongoDb", zap.Error(err))
-		return "", "", nil, err
-	}
-
-	return customerID, accountID, depositChannels, nil
+	return screening, nil
 }
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// decisionAuditTimeout bounds writing a decision to the audit log in the
+// background once the decision has been made.
+const decisionAuditTimeout = 5 * time.Second
+
+// decisionAudit collects the audit record of an authorization decision while
+// the decision is made.
+type decisionAudit struct {
+	record models.AuthorizationDecision
+	repeat bool // The authorization was decided before: a duplicate or a redelivered or replayed event
+}
+
+func newDecisionAudit(ctx context.Context, event api.AuthorizationRequestEvent) *decisionAudit {
+	return &decisionAudit{
+		record: models.AuthorizationDecision{
+			AuthorizationID: event.ID,
+			CardID:          event.CardID,
+			Event:           event,
+			Checks:          []models.DecisionCheck{},
+		},
+		repeat: isRepeatedEvent(ctx),
+	}
+}
+
+// check records the outcome of a check.
+func (a *decisionAudit) check(name string, passed bool, detail string) {
+	result := models.CheckPass
+	if !passed {
+		result = models.CheckFail
+	}
+	a.record.Checks = append(a.record.Checks, models.DecisionCheck{Name: name, Result: result, Detail: detail})
+}
+
+// skip records a check that does not apply to the authorization.
+func (a *decisionAudit) skip(name, detail string) {
+	a.record.Checks = append(a.record.Checks, models.DecisionCheck{Name: name, Result: models.CheckSkip, Detail: detail})
+}
+
+// rules records the evaluation of each rule as a check.
+func (a *decisionAudit) rules(result RuleResult) {
+	for _, evaluation := range result.Evaluations {
+		check := models.DecisionCheck{
+			Name:   evaluation.RuleID,
+			Result: models.CheckPass,
+			Detail: fmt.Sprintf("%s v%d", evaluation.Name, evaluation.Version),
+		}
+		switch {
+		case evaluation.Error != "":
+			check.Result = models.CheckSkip
+			check.Detail += ": " + evaluation.Error
+		case evaluation.Matched && evaluation.Outcome == models.RuleDecline:
+			check.Result = models.CheckFail
+		case evaluation.Matched && evaluation.Outcome == models.RuleFlag:
+			check.Result = models.CheckFlag
+		case evaluation.Matched:
+			check.Detail += ": approved"
+		}
+		a.record.Checks = append(a.record.Checks, check)
+	}
+}
+
+// recordDecision writes the audit record of a decision and adds the decision
+// to the recent authorizations of the card, unless the authorization was
+// decided before. It runs after the response is sent, so both are written even
+// when the decision budget has run out.
+func (s *WebhookService) recordDecision(ctx context.Context, audit *decisionAudit, response api.AuthorizationResponse, decisionErr error, timing *models.DecisionTiming) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), decisionAuditTimeout)
+	defer cancel()
+
+	record := audit.record
+	record.Response = response
+	if decisionErr != nil {
+		record.Error = decisionErr.Error()
+	}
+	record.Timing = timing
+	record.DecidedAt = time.Now().UTC()
+	if err := s.store.InsertDecision(ctx, record); err != nil {
+		s.logger.Error("Failed to record authorization decision",
+			zap.String("authorizationID", record.AuthorizationID),
+			zap.Error(err),
+		)
+	}
+	if record.Card == nil || audit.repeat {
+		return
+	}
+	event := velocityEvent(record.Event, response.Action == "approve", record.DecidedAt)
+	if err := s.velocity.Record(ctx, record.CardID, event); err != nil {
+		s.logger.Error("Failed to record card velocity",
+			zap.String("authorizationID", record.AuthorizationID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrDepositNotFound is returned when a deposit does not exist.
+	ErrDepositNotFound = errors.New("deposit not found")
+	// ErrDepositNotQuarantined is returned when a review targets a deposit without an open quarantine.
+	ErrDepositNotQuarantined = errors.New("deposit is not quarantined")
+	// ErrInvalidDepositReview is returned when a quarantine review cannot be applied.
+	ErrInvalidDepositReview = errors.New("invalid deposit review")
+)
+
+// depositTransitions lists the statuses each deposit status can move to.
+// Completed and failed payments are final.
+var depositTransitions = map[string][]string{
+	models.DepositPending: {models.DepositCompleted, models.DepositFailed},
+}
+
+// DepositService records the payments into the virtual accounts of sub
+// accounts and credits them to the ledger. Payments to unknown or inactive
+// accounts are quarantined until a review releases or rejects them.
+type DepositService struct {
+	store  *store.Store
+	ledger *LedgerService
+	logger *zap.Logger
+}
+
+// NewDepositService creates a deposit service crediting deposits to the given ledger.
+func NewDepositService(store *store.Store, ledger *LedgerService, logger *zap.Logger) *DepositService {
+	return &DepositService{store: store, ledger: ledger, logger: logger}
+}
+
+// Process records a payment event. The first event of a payment creates its
+// deposit; later ones move it along its statuses, and redelivered or stale
+// ones leave it as is. A completed deposit is credited once.
+func (s *DepositService) Process(ctx context.Context, payment models.PaymentData) (*models.Deposit, error) {
+	switch payment.Status {
+	case models.DepositPending, models.DepositCompleted, models.DepositFailed:
+	default:
+		return nil, fmt.Errorf("%w: unknown payment status %q", ErrInvalidEventPayload, payment.Status)
+	}
+	if payment.ID == "" || payment.VirtualAccountID == "" {
+		return nil, fmt.Errorf("%w: payment and virtual account are required", ErrInvalidEventPayload)
+	}
+
+	deposit, inserted, err := s.insert(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	if !inserted {
+		if deposit, err = s.transition(ctx, deposit, payment.Status); err != nil {
+			return nil, err
+		}
+	}
+	if creditable(deposit) {
+		if err := s.credit(ctx, deposit); err != nil {
+			return nil, err
+		}
+	}
+	return deposit, nil
+}
+
+// insert creates the deposit of a payment unless it exists, and returns it
+// with whether it was created. A deposit to an unknown or inactive account
+// is created quarantined.
+func (s *DepositService) insert(ctx context.Context, payment models.PaymentData) (*models.Deposit, bool, error) {
+	quarantine, err := s.quarantine(ctx, payment.VirtualAccountID)
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now().UTC()
+	deposit := models.Deposit{
+		ID:         primitive.NewObjectID(),
+		PaymentID:  payment.ID,
+		AccountID:  payment.VirtualAccountID,
+		CustomerID: payment.CustomerID,
+		Amount:     payment.Amount,
+		Currency:   payment.Currency,
+		Status:     payment.Status,
+		History:    []models.DepositStatusChange{{Status: payment.Status, At: now}},
+		Quarantine: quarantine,
+		PaidAt:     payment.CreatedAt,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if deposit.PaidAt.IsZero() {
+		deposit.PaidAt = now
+	}
+
+	var existing models.Deposit
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	err = s.store.Deposits.FindOneAndUpdate(ctx, bson.M{"paymentId": payment.ID}, bson.M{"$setOnInsert": deposit}, opts).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		if quarantine != nil {
+			s.logger.Warn("Quarantined deposit",
+				zap.String("paymentID", payment.ID),
+				zap.String("accountID", payment.VirtualAccountID),
+				zap.String("reason", quarantine.Reason),
+				zap.Int64("amount", payment.Amount),
+			)
+		} else {
+			s.logger.Info("Recorded deposit", zap.String("paymentID", payment.ID), zap.String("status", payment.Status))
+		}
+		return &deposit, true, nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to record deposit", zap.String("paymentID", payment.ID), zap.Error(err))
+		return nil, false, fmt.Errorf("failed to record deposit: %w", err)
+	}
+	return &existing, false, nil
+}
+
+// quarantine returns the quarantine of a deposit to an account that is
+// unknown or not active, or nil when the account may be credited.
+func (s *DepositService) quarantine(ctx context.Context, accountID string) (*models.DepositQuarantine, error) {
+	var account models.Account
+	err := s.store.Accounts.FindOne(ctx, bson.M{"accountId": accountID}).Decode(&account)
+	quarantine := &models.DepositQuarantine{Status: models.QuarantineOpen, At: time.Now().UTC()}
+	switch {
+	case err == mongo.ErrNoDocuments:
+		quarantine.Reason = models.QuarantineUnknownAccount
+		return quarantine, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to fetch account: %w", err)
+	case account.Status != "active":
+		quarantine.Reason, quarantine.AccountStatus = models.QuarantineInactiveAccount, account.Status
+		return quarantine, nil
+	}
+	return nil, nil
+}
+
+// transition moves a deposit to the status of a later event of its payment.
+// A redelivered event or one the deposit has moved past is ignored.
+func (s *DepositService) transition(ctx context.Context, deposit *models.Deposit, status string) (*models.Deposit, error) {
+	if deposit.Status == status {
+		s.logger.Info("Ignoring redelivered payment event", zap.String("paymentID", deposit.PaymentID), zap.String("status", status))
+		return deposit, nil
+	}
+	if !slices.Contains(depositTransitions[deposit.Status], status) {
+		s.logger.Warn("Ignoring out of order payment event",
+			zap.String("paymentID", deposit.PaymentID),
+			zap.String("from", deposit.Status),
+			zap.String("to", status),
+		)
+		return deposit, nil
+	}
+
+	now := time.Now().UTC()
+	var updated models.Deposit
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.store.Deposits.FindOneAndUpdate(ctx,
+		bson.M{"_id": deposit.ID, "status": deposit.Status},
+		bson.M{
+			"$set":  bson.M{"status": status, "updatedAt": now},
+			"$push": bson.M{"history": models.DepositStatusChange{Status: status, At: now}},
+		},
+		opts,
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		// A concurrent event moved the deposit first
+		return s.get(ctx, deposit.ID)
+	}
+	if err != nil {
+		s.logger.Error("Failed to update deposit", zap.String("paymentID", deposit.PaymentID), zap.Error(err))
+		return nil, fmt.Errorf("failed to update deposit: %w", err)
+	}
+	s.logger.Info("Deposit status changed",
+		zap.String("paymentID", deposit.PaymentID),
+		zap.String("from", deposit.Status),
+		zap.String("to", status),
+	)
+	return &updated, nil
+}
+
+// creditable reports whether a deposit is completed, not credited yet and
+// not held back by a quarantine.
+func creditable(deposit *models.Deposit) bool {
+	if deposit.Status != models.DepositCompleted || deposit.CreditedAt != nil {
+		return false
+	}
+	return deposit.Quarantine == nil || deposit.Quarantine.Status == models.QuarantineReleased
+}
+
+// credit posts a deposit to the available funds of its account. The journal
+// entry is keyed by the payment, so crediting again after a failure between
+// the two writes is a no-op.
+func (s *DepositService) credit(ctx context.Context, deposit *models.Deposit) error {
+	payment := models.PaymentData{
+		ID:               deposit.PaymentID,
+		CustomerID:       deposit.CustomerID,
+		VirtualAccountID: deposit.AccountID,
+		Amount:           deposit.Amount,
+		Currency:         deposit.Currency,
+		Status:           deposit.Status,
+		CreatedAt:        deposit.PaidAt,
+	}
+	if err := s.ledger.RecordDeposit(ctx, deposit.AccountID, payment); err != nil {
+		s.logger.Error("Failed to credit deposit", zap.String("paymentID", deposit.PaymentID), zap.Error(err))
+		return err
+	}
+	now := time.Now().UTC()
+	_, err := s.store.Deposits.UpdateOne(ctx,
+		bson.M{"_id": deposit.ID, "creditedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"creditedAt": now, "updatedAt": now}},
+	)
+	if err != nil {
+		s.logger.Error("Failed to mark deposit credited", zap.String("paymentID", deposit.PaymentID), zap.Error(err))
+		return fmt.Errorf("failed to mark deposit credited: %w", err)
+	}
+	deposit.CreditedAt = &now
+	s.logger.Info("Credited deposit",
+		zap.String("paymentID", deposit.PaymentID),
+		zap.String("accountID", deposit.AccountID),
+		zap.Int64("amount", deposit.Amount),
+	)
+	return nil
+}
+
+// Review releases or rejects a quarantined deposit. Releasing credits it once
+// its payment completes, and requires its account to exist and be active by
+// then; rejecting leaves it uncredited for the payment to be returned.
+func (s *DepositService) Review(ctx context.Context, id, decision, reviewer, note string) (*models.Deposit, error) {
+	if decision != models.QuarantineReleased && decision != models.QuarantineRejected {
+		return nil, fmt.Errorf("%w: decision must be %s or %s", ErrInvalidDepositReview, models.QuarantineReleased, models.QuarantineRejected)
+	}
+	if reviewer == "" {
+		return nil, fmt.Errorf("%w: reviewer is required", ErrInvalidDepositReview)
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrDepositNotFound
+	}
+	deposit, err := s.get(ctx, objectID)
+	if err != nil {
+		return nil, err
+	}
+	if deposit.Quarantine == nil || deposit.Quarantine.Status != models.QuarantineOpen {
+		return nil, ErrDepositNotQuarantined
+	}
+	if decision == models.QuarantineReleased {
+		quarantine, err := s.quarantine(ctx, deposit.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if quarantine != nil {
+			return nil, fmt.Errorf("%w: account %s is still %s", ErrInvalidDepositReview, deposit.AccountID, quarantine.Reason)
+		}
+	}
+
+	now := time.Now().UTC()
+	var updated models.Deposit
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = s.store.Deposits.FindOneAndUpdate(ctx,
+		bson.M{"_id": deposit.ID, "quarantine.status": models.QuarantineOpen},
+		bson.M{"$set": bson.M{
+			"quarantine.status":     decision,
+			"quarantine.reviewer":   reviewer,
+			"quarantine.note":       note,
+			"quarantine.reviewedAt": now,
+			"updatedAt":             now,
+		}},
+		opts,
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDepositNotQuarantined
+	}
+	if err != nil {
+		s.logger.Error("Failed to review deposit", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to review deposit: %w", err)
+	}
+	s.logger.Info("Reviewed quarantined deposit",
+		zap.String("paymentID", updated.PaymentID),
+		zap.String("decision", decision),
+		zap.String("reviewer", reviewer),
+	)
+	if creditable(&updated) {
+		if err := s.credit(ctx, &updated); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
+
+// ListAccountDeposits returns a page of the deposits into a sub account, newest first.
+func (s *DepositService) ListAccountDeposits(ctx context.Context, accountID, status string, page, limit int64) ([]models.Deposit, error) {
+	filter := bson.M{"accountId": accountID}
+	if status != "" {
+		filter["status"] = status
+	}
+	deposits, err := s.store.ListDeposits(ctx, filter, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list deposits", zap.String("accountID", accountID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list deposits: %w", err)
+	}
+	return deposits, nil
+}
+
+// ListQuarantined returns a page of quarantined deposits in the given
+// quarantine status, open ones by default.
+func (s *DepositService) ListQuarantined(ctx context.Context, status string, page, limit int64) ([]models.Deposit, error) {
+	if status == "" {
+		status = models.QuarantineOpen
+	}
+	deposits, err := s.store.ListDeposits(ctx, bson.M{"quarantine.status": status}, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list quarantined deposits", zap.Error(err))
+		return nil, fmt.Errorf("failed to list quarantined deposits: %w", err)
+	}
+	return deposits, nil
+}
+
+func (s *DepositService) get(ctx context.Context, id primitive.ObjectID) (*models.Deposit, error) {
+	var deposit models.Deposit
+	err := s.store.Deposits.FindOne(ctx, bson.M{"_id": id}).Decode(&deposit)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDepositNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deposit: %w", err)
+	}
+	return &deposit, nil
+}
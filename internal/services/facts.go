@@ -0,0 +1,201 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/rules"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// authorizationFacts builds the facts authorization rules are evaluated
+// against. Aggregates over the transactions of the card are only queried when
+// a rule reads them.
+func (s *WebhookService) authorizationFacts(ctx context.Context, event api.AuthorizationRequestEvent, card models.Card, customer models.Customer, balance models.DecisionBalance, at time.Time) rules.Env {
+	acceptor := api.ParseCardAcceptorNameLocation(event.NetworkData.CardAcceptorNameLocation)
+	var ledger interface{}
+	if balance.Ledger != nil {
+		ledger = *balance.Ledger
+	}
+	tier := s.kyc.Tier(customer)
+	tierLimits := s.kyc.Limits(tier)
+	authorizedAt := authorizationTime(event, at)
+	country := api.TransactionCountry(event.NetworkData)
+	return rules.Env{
+		"event": rules.Env{
+			"id":              event.ID,
+			"amount":          event.Amount,
+			"fees":            event.Fees,
+			"total":           event.Amount + event.Fees,
+			"currency":        event.Currency,
+			"type":            event.Type,
+			"channel":         event.Channel,
+			"status":          event.Status,
+			"mcc":             event.NetworkData.MCC,
+			"merchantId":      event.NetworkData.MerchantID,
+			"merchantName":    acceptor.Name,
+			"merchantCity":    acceptor.City,
+			"merchantCountry": country,
+		},
+		"card": rules.Env{
+			"id":            card.CardID,
+			"status":        card.Status,
+			"type":          card.Type,
+			"program":       card.Program,
+			"fundingSource": card.FundingSource,
+			"controls": rules.Env{
+				"allowedChannels":   card.Controls.AllowedChannels,
+				"blockedChannels":   card.Controls.BlockedChannels,
+				"allowedMerchants":  card.Controls.AllowedMerchants,
+				"blockedMerchants":  card.Controls.BlockedMerchants,
+				"allowedCategories": card.Controls.AllowedCategories,
+				"blockedCategories": card.Controls.BlockedCategories,
+				"allowedCountries":  card.Controls.AllowedCountries,
+				"blockedCountries":  card.Controls.BlockedCountries,
+			},
+		},
+		"customer": rules.Env{
+			"id":    customer.CustomerID,
+			"name":  customer.Name,
+			"email": customer.Email,
+		},
+		"kyc": rules.Env{
+			"tier":       tier,
+			"maxBalance": tierLimits.MaxBalance,
+			"dailyLimit": tierLimits.DailySpend,
+			"dailySpend": rules.Lazy(func() (interface{}, error) {
+				return s.limits.FundingSpend(ctx, card.FundingSource, IntervalDaily, at)
+			}),
+		},
+		"balance": rules.Env{
+			"available": balance.Available,
+			"issuer":    balance.Issuer,
+			"holds":     balance.Holds,
+			"ledger":    ledger,
+			"standIn":   balance.StandIn,
+		},
+		"controls": rules.Env{
+			"channelAllowed":     s.isChannelAllowed(card.Controls, event.Channel) || isChannelUnlocked(card.Controls, event.Channel, authorizedAt),
+			"channelUnlocked":    isChannelUnlocked(card.Controls, event.Channel, authorizedAt),
+			"merchantAllowed":    isMerchantAllowed(card.Controls, event.NetworkData.MerchantID, acceptor.Name),
+			"categoryAllowed":    isCategoryAllowed(card.Controls, event.NetworkData.MCC),
+			"scheduleAllowed":    isScheduleAllowed(card.Controls, authorizedAt, s.limits.location),
+			"temporarilyBlocked": isTemporarilyBlocked(card.Controls, authorizedAt),
+			"countryBlocked":     isCountryBlocked(card.Controls, country),
+			"countryAllowed":     isCountryAllowed(card.Controls, country),
+			"travelNotice": rules.Lazy(func() (interface{}, error) {
+				if country == "" {
+					return false, nil
+				}
+				return s.store.HasTravelNotice(ctx, card.CustomerID, country, authorizedAt)
+			}),
+		},
+		"limits": rules.Lazy(func() (interface{}, error) {
+			exceeded, err := s.limits.Exceeded(ctx, card.CardID, card.Controls.SpendingLimits, event.Amount, at)
+			if err != nil {
+				return nil, err
+			}
+			if exceeded == nil {
+				return rules.Env{"exceeded": false}, nil
+			}
+			return rules.Env{
+				"exceeded": true,
+				"interval": exceeded.Interval,
+				"limit":    exceeded.Limit,
+				"spent":    exceeded.Spent,
+			}, nil
+		}),
+		"spend": rules.Env{
+			"daily":   s.spendFact(ctx, card.CardID, IntervalDaily, at),
+			"weekly":  s.spendFact(ctx, card.CardID, IntervalWeekly, at),
+			"monthly": s.spendFact(ctx, card.CardID, IntervalMonthly, at),
+		},
+		"risk": rules.Lazy(func() (interface{}, error) {
+			assessment, err := s.velocity.Assess(ctx, card.CardID, velocityEvent(event, true, at))
+			if err != nil {
+				return nil, err
+			}
+			return rules.Env{
+				"score":   assessment.Score,
+				"signals": assessment.Signals,
+				"action":  assessment.Action,
+			}, nil
+		}),
+		"recent": rules.Env{
+			"lastHour": s.countFact(ctx, card.CardID, at.Add(-time.Hour), at),
+			"lastDay":  s.countFact(ctx, card.CardID, at.Add(-24*time.Hour), at),
+		},
+	}
+}
+
+// authorizationTime is when an authorization was made, as stamped by the
+// issuer, or the given time when the event carries no valid timestamp.
+func authorizationTime(event api.AuthorizationRequestEvent, at time.Time) time.Time {
+	createdAt, err := time.Parse(time.RFC3339, event.CreatedAt)
+	if err != nil {
+		return at
+	}
+	return createdAt
+}
+
+// spendFact loads the spend of a card in the current interval window.
+func (s *WebhookService) spendFact(ctx context.Context, cardID, interval string, at time.Time) rules.Lazy {
+	return func() (interface{}, error) {
+		return s.limits.Spend(ctx, cardID, interval, at)
+	}
+}
+
+// countFact loads the number of transactions of a card created in [from, to).
+func (s *WebhookService) countFact(ctx context.Context, cardID string, from, to time.Time) rules.Lazy {
+	return func() (interface{}, error) {
+		return s.store.CountCardTransactions(ctx, cardID, from, to)
+	}
+}
+
+// EvaluateRules evaluates the authorization rules against a sample
+// authorization without recording anything. Candidate rules replace the
+// current versions of the same rules. Without a balance the last known balance
+// of the funding account is used, so the issuer is not called.
+func (s *WebhookService) EvaluateRules(ctx context.Context, event api.AuthorizationRequestEvent, balance *int64, candidates []models.Rule) (RuleResult, error) {
+	var card models.Card
+	err := s.store.Cards.FindOne(ctx, bson.M{"cardId": event.CardID}).Decode(&card)
+	if err == mongo.ErrNoDocuments {
+		return RuleResult{}, ErrCardNotFound
+	}
+	if err != nil {
+		return RuleResult{}, fmt.Errorf("failed to fetch card: %w", err)
+	}
+	var customer models.Customer
+	err = s.store.Customers.FindOne(ctx, bson.M{"accountId": card.FundingSource}).Decode(&customer)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return RuleResult{}, fmt.Errorf("failed to fetch customer: %w", err)
+	}
+
+	var seen models.DecisionBalance
+	if balance != nil {
+		seen.Issuer = *balance
+	} else {
+		snapshot, err := s.balances.LastKnown(ctx, card.FundingSource)
+		if err != nil {
+			return RuleResult{}, err
+		}
+		if snapshot != nil {
+			seen.Issuer = snapshot.Available
+		}
+	}
+	seen.Available, err = s.holds.EffectiveAvailable(ctx, card.FundingSource, seen.Issuer)
+	if err != nil {
+		return RuleResult{}, fmt.Errorf("failed to compute available balance: %w", err)
+	}
+	seen.Holds = seen.Issuer - seen.Available
+
+	facts := s.authorizationFacts(ctx, event, card, customer, seen, time.Now())
+	if len(candidates) == 0 {
+		return s.rules.Evaluate(card, facts)
+	}
+	return s.rules.EvaluateCandidates(card, facts, candidates)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// HoldLedger tracks the funds reserved by approved authorizations that the
+// issuer has not settled yet, so they can be deducted from the issuer balance.
+type HoldLedger struct {
+	store  *store.Store
+	ledger *LedgerService
+	expiry time.Duration // How long a hold stays open without being closed
+	logger *zap.Logger
+}
+
+// NewHoldLedger creates a hold ledger whose holds expire after the given duration.
+func NewHoldLedger(store *store.Store, ledger *LedgerService, expiry time.Duration, logger *zap.Logger) *HoldLedger {
+	return &HoldLedger{store: store, ledger: ledger, expiry: expiry, logger: logger}
+}
+
+// Reserve opens a hold of amount plus fees for an authorization. Reserving an
+// authorization that already has a hold is a no-op.
+func (l *HoldLedger) Reserve(ctx context.Context, authorizationID, cardID, accountID string, amount, fees int64) error {
+	now := time.Now().UTC()
+	hold := models.Hold{
+		AuthorizationID: authorizationID,
+		CardID:          cardID,
+		AccountID:       accountID,
+		Amount:          amount,
+		Fees:            fees,
+		Status:          models.HoldOpen,
+		ExpiresAt:       now.Add(l.expiry),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	_, err := l.store.Holds.UpdateOne(ctx,
+		bson.M{"authorizationId": authorizationID},
+		bson.M{"$setOnInsert": hold},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		l.logger.Error("Failed to reserve hold", zap.String("authorizationID", authorizationID), zap.Error(err))
+		return fmt.Errorf("failed to reserve hold: %w", err)
+	}
+	l.logger.Info("Reserved hold",
+		zap.String("authorizationID", authorizationID),
+		zap.String("accountID", accountID),
+		zap.Int64("total", amount+fees),
+	)
+	return nil
+}
+
+// Adjust changes the amount reserved by an open hold.
+func (l *HoldLedger) Adjust(ctx context.Context, authorizationID string, amount, fees int64) error {
+	_, err := l.store.Holds.UpdateOne(ctx,
+		bson.M{"authorizationId": authorizationID, "status": models.HoldOpen},
+		bson.M{"$set": bson.M{"amount": amount, "fees": fees, "updatedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		l.logger.Error("Failed to adjust hold", zap.String("authorizationID", authorizationID), zap.Error(err))
+		return fmt.Errorf("failed to adjust hold: %w", err)
+	}
+	return nil
+}
+
+// Release closes the open hold of an authorization with the given status.
+func (l *HoldLedger) Release(ctx context.Context, authorizationID, status, reason string) error {
+	_, err := l.store.Holds.UpdateOne(ctx,
+		bson.M{"authorizationId": authorizationID, "status": models.HoldOpen},
+		bson.M{"$set": bson.M{"status": status, "releaseReason": reason, "updatedAt": time.Now().UTC()}},
+	)
+	if err != nil {
+		l.logger.Error("Failed to release hold", zap.String("authorizationID", authorizationID), zap.Error(err))
+		return fmt.Errorf("failed to release hold: %w", err)
+	}
+	l.logger.Info("Released hold",
+		zap.String("authorizationID", authorizationID),
+		zap.String("status", status),
+		zap.String("reason", reason),
+	)
+	return nil
+}
+
+// EffectiveAvailable returns the issuer balance of an account minus its open holds.
+func (l *HoldLedger) EffectiveAvailable(ctx context.Context, accountID string, issuerAvailable int64) (int64, error) {
+	held, err := l.store.SumOpenHolds(ctx, accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum open holds: %w", err)
+	}
+	return issuerAvailable - held, nil
+}
+
+// ExpireStale expires the open holds whose expiry time has passed and returns how many were expired.
+func (l *HoldLedger) ExpireStale(ctx context.Context, now time.Time) (int64, error) {
+	cursor, err := l.store.Holds.Find(ctx, bson.M{"status": models.HoldOpen, "expiresAt": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list stale holds: %w", err)
+	}
+	var stale []models.Hold
+	if err := cursor.All(ctx, &stale); err != nil {
+		return 0, fmt.Errorf("failed to decode stale holds: %w", err)
+	}
+	var expired int64
+	var failed error
+	for _, hold := range stale {
+		ok, err := l.expire(ctx, hold, now)
+		if err != nil {
+			l.logger.Error("Failed to expire hold", zap.String("authorizationID", hold.AuthorizationID), zap.Error(err))
+			failed = fmt.Errorf("failed to expire hold %s: %w", hold.AuthorizationID, err)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, failed
+}
+
+// expire expires an open hold, returns its funds to the available balance in
+// the ledger and marks its pending authorization expired, so the funds and the
+// spending limits it used are freed together. It reports false when the hold
+// was closed in the meantime.
+func (l *HoldLedger) expire(ctx context.Context, hold models.Hold, now time.Time) (bool, error) {
+	expired := false
+	err := l.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		expired = false
+		result, err := l.store.Holds.UpdateOne(sc,
+			bson.M{"_id": hold.ID, "status": models.HoldOpen},
+			bson.M{"$set": bson.M{"status": models.HoldExpired, "releaseReason": "expired", "updatedAt": now}},
+		)
+		if err != nil || result.ModifiedCount == 0 {
+			return err
+		}
+		expired = true
+
+		transaction, err := l.store.GetTransaction(sc, hold.AuthorizationID)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch transaction: %w", err)
+		}
+		_, err = l.store.Transactions.UpdateOne(sc,
+			bson.M{"authorizationId": hold.AuthorizationID, "status": "pending"},
+			bson.M{"$set": bson.M{"status": "expired", "updatedAt": now.Format(time.RFC3339)}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to expire transaction: %w", err)
+		}
+		if transaction.AccountID == "" {
+			return nil
+		}
+		return l.ledger.RecordRelease(sc, transaction.AccountID, hold.AuthorizationID, models.EntryExpiry, hold.Amount+hold.Fees, transaction.Currency)
+	})
+	return expired, err
+}
+
+// RunExpiry expires stale holds every interval until the context is cancelled.
+func (l *HoldLedger) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := l.ExpireStale(ctx, now.UTC())
+			if err != nil {
+				l.logger.Error("Failed to expire stale holds", zap.Error(err))
+				continue
+			}
+			if expired > 0 {
+				l.logger.Info("Expired stale holds", zap.Int64("count", expired))
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/simulator"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpireStaleFreesFundsAndLimits(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 100_000})
+	service := testWebhookService(t, db, sim)
+	if err := service.ledger.OpenAccount(ctx, accountID, customerID, "NGN"); err != nil {
+		t.Fatalf("OpenAccount: %v", err)
+	}
+	if err := service.ledger.RecordDeposit(ctx, accountID, models.PaymentData{ID: "pay_1", Amount: 100_000, Currency: "NGN"}); err != nil {
+		t.Fatalf("RecordDeposit: %v", err)
+	}
+	card := models.Card{CardID: "card_1", CustomerID: customerID, FundingSource: accountID, Status: models.CardActive}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	request := testAuthorization(card.CardID, 40_000)
+	if response, err := service.HandleAuthorizationRequest(ctx, request); response.Action != "approve" {
+		t.Fatalf("authorization = %s %q, %v; want approve", response.Action, response.Code, err)
+	}
+
+	// Nothing is due before the expiry period has passed
+	if expired, err := service.holds.ExpireStale(ctx, time.Now().UTC()); err != nil || expired != 0 {
+		t.Fatalf("ExpireStale before expiry = %d, %v; want 0", expired, err)
+	}
+	later := time.Now().UTC().Add(8 * 24 * time.Hour)
+	if expired, err := service.holds.ExpireStale(ctx, later); err != nil || expired != 1 {
+		t.Fatalf("ExpireStale = %d, %v; want 1", expired, err)
+	}
+	if expired, err := service.holds.ExpireStale(ctx, later); err != nil || expired != 0 {
+		t.Errorf("second ExpireStale = %d, %v; want 0", expired, err)
+	}
+
+	transaction, err := db.GetTransaction(ctx, request.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Status != "expired" {
+		t.Errorf("transaction status = %s; want expired", transaction.Status)
+	}
+	holds, err := db.SumOpenHolds(ctx, accountID)
+	if err != nil || holds != 0 {
+		t.Errorf("open holds = %d (%v); want 0", holds, err)
+	}
+	balances, _, err := service.ledger.Balances(ctx, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances.Held != 0 || balances.Available != 100_000 {
+		t.Errorf("ledger held %d and available %d; want 0 and 100000", balances.Held, balances.Available)
+	}
+	spent, err := db.SumCardSpend(ctx, card.CardID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil || spent != 0 {
+		t.Errorf("spend = %d (%v); want 0", spent, err)
+	}
+}
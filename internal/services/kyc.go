@@ -0,0 +1,268 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCustomerNotFound is returned when no customer exists with the given ID.
+	ErrCustomerNotFound = errors.New("customer not found")
+	// ErrInvalidUpgrade is returned when a tier upgrade request is incomplete or not an upgrade.
+	ErrInvalidUpgrade = errors.New("invalid tier upgrade")
+	// ErrUpgradePending is returned when the customer already has an upgrade waiting for review.
+	ErrUpgradePending = errors.New("customer already has a pending tier upgrade")
+	// ErrVerificationNotFound is returned when a customer has no pending verification with the given ID.
+	ErrVerificationNotFound = errors.New("pending verification not found")
+	// ErrCardLimitReached is returned when linking a card would exceed the card count of the customer's tier.
+	ErrCardLimitReached = errors.New("card limit of the customer's KYC tier reached")
+)
+
+// legacyTier is the tier of customers onboarded before tiering, who were all
+// reported to the issuer as tier-2 verified.
+const legacyTier = models.KYCTier2
+
+// initialTiers is the tier a customer starts at for each identity type.
+var initialTiers = map[string]string{
+	"nin": models.KYCTier1,
+	"bvn": models.KYCTier2,
+	"cac": models.KYCTier3, // Business registered with the Corporate Affairs Commission, officers identified
+}
+
+// TierLimits are the limits of a KYC tier, in minor units. Zero means no limit.
+type TierLimits struct {
+	MaxBalance int64 `json:"maxBalance"` // Highest issuer balance the funding account may hold
+	DailySpend int64 `json:"dailySpend"` // Spend allowed per day across all cards of the customer
+	MaxCards   int64 `json:"maxCards"`   // Cards the customer may have linked
+}
+
+// KYCStatus is the KYC tier of a customer, its limits and its verifications.
+type KYCStatus struct {
+	CustomerID    string                   `json:"customerId"`
+	Tier          string                   `json:"tier"`
+	Limits        TierLimits               `json:"limits"`
+	Verifications []models.KYCVerification `json:"verifications"`
+}
+
+// KYCService assigns KYC tiers, handles tier upgrades and enforces the limits
+// of each tier.
+type KYCService struct {
+	store  *store.Store
+	limits map[string]TierLimits
+	logger *zap.Logger
+}
+
+// NewKYCService creates a KYC service with the limits of each tier.
+func NewKYCService(store *store.Store, limits map[string]TierLimits, logger *zap.Logger) *KYCService {
+	return &KYCService{store: store, limits: limits, logger: logger}
+}
+
+// InitialTier returns the tier a customer onboarded on the given identity
+// type starts at. Unknown identity types start at the lowest tier.
+func (s *KYCService) InitialTier(idType string) string {
+	if tier, ok := initialTiers[idType]; ok {
+		return tier
+	}
+	return models.KYCTier1
+}
+
+// InitialVerification is the verification recorded for the tier granted at onboarding.
+func (s *KYCService) InitialVerification(tier, idType string, at time.Time) models.KYCVerification {
+	return models.KYCVerification{
+		ID:          primitive.NewObjectID().Hex(),
+		Tier:        tier,
+		Status:      models.VerificationVerified,
+		IDType:      idType,
+		RequestedAt: at,
+		History: []models.VerificationStatusChange{
+			{To: models.VerificationVerified, Actor: "onboarding", At: at},
+		},
+	}
+}
+
+// Tier returns the verified tier of a customer.
+func (s *KYCService) Tier(customer models.Customer) string {
+	if customer.Tier == "" {
+		return legacyTier
+	}
+	return customer.Tier
+}
+
+// Limits returns the limits of a tier.
+func (s *KYCService) Limits(tier string) TierLimits {
+	return s.limits[tier]
+}
+
+// GetKYC returns the KYC status of a customer.
+func (s *KYCService) GetKYC(ctx context.Context, customerID string) (KYCStatus, error) {
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return KYCStatus{}, err
+	}
+	return s.status(*customer), nil
+}
+
+func (s *KYCService) status(customer models.Customer) KYCStatus {
+	tier := s.Tier(customer)
+	verifications := customer.Verifications
+	if verifications == nil {
+		verifications = []models.KYCVerification{}
+	}
+	return KYCStatus{
+		CustomerID:    customer.CustomerID,
+		Tier:          tier,
+		Limits:        s.Limits(tier),
+		Verifications: verifications,
+	}
+}
+
+func (s *KYCService) customer(ctx context.Context, customerID string) (*models.Customer, error) {
+	var customer models.Customer
+	err := s.store.Customers.FindOne(ctx, bson.M{"customerId": customerID}).Decode(&customer)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch customer: %w", err)
+	}
+	return &customer, nil
+}
+
+// RequestUpgrade records a request to verify a customer at a higher tier. It
+// stays pending until reviewed.
+func (s *KYCService) RequestUpgrade(ctx context.Context, customerID, tier, actor string, documents []models.KYCDocument) (models.KYCVerification, error) {
+	requested := slices.Index(models.KYCTiers, tier)
+	if requested < 0 {
+		return models.KYCVerification{}, fmt.Errorf("%w: unknown tier %q", ErrInvalidUpgrade, tier)
+	}
+	if len(documents) == 0 {
+		return models.KYCVerification{}, fmt.Errorf("%w: at least one document is required", ErrInvalidUpgrade)
+	}
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return models.KYCVerification{}, err
+	}
+	current := s.Tier(*customer)
+	if requested <= slices.Index(models.KYCTiers, current) {
+		return models.KYCVerification{}, fmt.Errorf("%w: customer is already %s", ErrInvalidUpgrade, current)
+	}
+
+	now := time.Now().UTC()
+	verification := models.KYCVerification{
+		ID:          primitive.NewObjectID().Hex(),
+		Tier:        tier,
+		Status:      models.VerificationPending,
+		Documents:   documents,
+		RequestedAt: now,
+		History: []models.VerificationStatusChange{
+			{To: models.VerificationPending, Actor: actor, At: now},
+		},
+	}
+	result, err := s.store.Customers.UpdateOne(ctx,
+		bson.M{
+			"customerId":    customerID,
+			"verifications": bson.M{"$not": bson.M{"$elemMatch": bson.M{"status": models.VerificationPending}}},
+		},
+		bson.M{"$push": bson.M{"verifications": verification}},
+	)
+	if err != nil {
+		s.logger.Error("Failed to store tier upgrade", zap.String("customerID", customerID), zap.Error(err))
+		return models.KYCVerification{}, fmt.Errorf("failed to store tier upgrade: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return models.KYCVerification{}, ErrUpgradePending
+	}
+	s.logger.Info("Requested tier upgrade", zap.String("customerID", customerID), zap.String("tier", tier))
+	return verification, nil
+}
+
+// ReviewUpgrade verifies or rejects a pending tier upgrade. A verified
+// upgrade becomes the tier of the customer.
+func (s *KYCService) ReviewUpgrade(ctx context.Context, customerID, verificationID, decision, reviewer, reason string) (KYCStatus, error) {
+	if decision != models.VerificationVerified && decision != models.VerificationRejected {
+		return KYCStatus{}, fmt.Errorf("%w: decision must be %s or %s", ErrInvalidUpgrade, models.VerificationVerified, models.VerificationRejected)
+	}
+	if decision == models.VerificationRejected && reason == "" {
+		return KYCStatus{}, fmt.Errorf("%w: a reason is required to reject an upgrade", ErrInvalidUpgrade)
+	}
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return KYCStatus{}, err
+	}
+	index := slices.IndexFunc(customer.Verifications, func(v models.KYCVerification) bool {
+		return v.ID == verificationID && v.Status == models.VerificationPending
+	})
+	if index < 0 {
+		return KYCStatus{}, ErrVerificationNotFound
+	}
+
+	now := time.Now().UTC()
+	var updated models.Customer
+	set := bson.M{"verifications.$.status": decision}
+	if decision == models.VerificationVerified {
+		set["tier"] = customer.Verifications[index].Tier
+	}
+	err = s.store.Customers.FindOneAndUpdate(ctx,
+		bson.M{
+			"customerId":    customerID,
+			"verifications": bson.M{"$elemMatch": bson.M{"id": verificationID, "status": models.VerificationPending}},
+		},
+		bson.M{
+			"$set": set,
+			"$push": bson.M{"verifications.$.history": models.VerificationStatusChange{
+				From:   models.VerificationPending,
+				To:     decision,
+				Actor:  reviewer,
+				Reason: reason,
+				At:     now,
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return KYCStatus{}, ErrVerificationNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to review tier upgrade", zap.String("customerID", customerID), zap.Error(err))
+		return KYCStatus{}, fmt.Errorf("failed to review tier upgrade: %w", err)
+	}
+	s.logger.Info("Reviewed tier upgrade",
+		zap.String("customerID", customerID),
+		zap.String("verificationID", verificationID),
+		zap.String("decision", decision),
+		zap.String("reviewer", reviewer),
+	)
+	return s.status(updated), nil
+}
+
+// CheckCardCount returns ErrCardLimitReached when the customer may not link another card.
+func (s *KYCService) CheckCardCount(ctx context.Context, customerID string) error {
+	customer, err := s.customer(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	tier := s.Tier(*customer)
+	limit := s.Limits(tier).MaxCards
+	if limit <= 0 {
+		return nil
+	}
+	count, err := s.store.CountCustomerCards(ctx, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to count cards: %w", err)
+	}
+	if count >= limit {
+		return fmt.Errorf("%w: %s allows %d", ErrCardLimitReached, tier, limit)
+	}
+	return nil
+}
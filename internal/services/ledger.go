@@ -0,0 +1,242 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// System ledger accounts shared by every sub-account.
+const (
+	settlementAccountCode = "system:settlement"   // Funds held for us at the issuer
+	cardNetworkCode       = "system:card-network" // Captured card spend owed to the network
+	feeRevenueCode        = "system:fee-revenue"  // Card fees earned
+)
+
+// ErrUnbalancedEntry is returned when the debits of a journal entry do not equal its credits.
+var ErrUnbalancedEntry = errors.New("journal entry is not balanced")
+
+// LedgerBalances are the balances of the ledger accounts backing a sub-account.
+type LedgerBalances struct {
+	AccountID string `json:"accountId"`
+	Available int64  `json:"available"`
+	Held      int64  `json:"held"`
+}
+
+// LedgerService posts business events to the internal double-entry ledger and
+// derives sub-account balances from the postings.
+type LedgerService struct {
+	store  *store.Store
+	logger *zap.Logger
+}
+
+// NewLedgerService creates a ledger service.
+func NewLedgerService(store *store.Store, logger *zap.Logger) *LedgerService {
+	return &LedgerService{store: store, logger: logger}
+}
+
+// availableCode is the ledger account holding the spendable funds of a sub-account.
+func availableCode(accountID string) string {
+	return "customer:" + accountID + ":available"
+}
+
+// heldCode is the ledger account holding the funds a sub-account has reserved for authorizations.
+func heldCode(accountID string) string {
+	return "customer:" + accountID + ":held"
+}
+
+// OpenAccount creates the ledger accounts backing a sub-account. Opening an
+// account twice is a no-op.
+func (l *LedgerService) OpenAccount(ctx context.Context, accountID, customerID, currency string) error {
+	accounts := []models.LedgerAccount{
+		{Code: availableCode(accountID), Name: "Available funds", Type: models.LedgerLiability, AccountID: accountID, CustomerID: customerID, Currency: currency},
+		{Code: heldCode(accountID), Name: "Held funds", Type: models.LedgerLiability, AccountID: accountID, CustomerID: customerID, Currency: currency},
+	}
+	for _, account := range accounts {
+		if err := l.ensureAccount(ctx, account); err != nil {
+			l.logger.Error("Failed to open ledger account", zap.String("code", account.Code), zap.Error(err))
+			return fmt.Errorf("failed to open ledger account: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureAccount inserts a ledger account unless one with the same code exists.
+func (l *LedgerService) ensureAccount(ctx context.Context, account models.LedgerAccount) error {
+	account.CreatedAt = time.Now().UTC()
+	_, err := l.store.LedgerAccounts.UpdateOne(ctx,
+		bson.M{"code": account.Code},
+		bson.M{"$setOnInsert": account},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// ensureSystemAccounts creates the system ledger accounts used by an entry.
+func (l *LedgerService) ensureSystemAccounts(ctx context.Context, currency string) error {
+	system := []models.LedgerAccount{
+		{Code: settlementAccountCode, Name: "Issuer settlement", Type: models.LedgerAsset, Currency: currency},
+		{Code: cardNetworkCode, Name: "Card network clearing", Type: models.LedgerLiability, Currency: currency},
+		{Code: feeRevenueCode, Name: "Card fee revenue", Type: models.LedgerRevenue, Currency: currency},
+	}
+	for _, account := range system {
+		if err := l.ensureAccount(ctx, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Post writes a journal entry. Entries must balance; posting an entry whose
+// reference was already posted is a no-op, which makes redelivered events safe.
+func (l *LedgerService) Post(ctx context.Context, entry models.JournalEntry) error {
+	var debits, credits int64
+	for _, posting := range entry.Postings {
+		if posting.Amount < 0 {
+			return fmt.Errorf("%w: negative posting on %s", ErrUnbalancedEntry, posting.AccountCode)
+		}
+		switch posting.Direction {
+		case models.Debit:
+			debits += posting.Amount
+		case models.Credit:
+			credits += posting.Amount
+		default:
+			return fmt.Errorf("invalid posting direction: %s", posting.Direction)
+		}
+	}
+	if debits != credits {
+		return fmt.Errorf("%w: debits %d, credits %d", ErrUnbalancedEntry, debits, credits)
+	}
+	if debits == 0 {
+		return nil
+	}
+
+	entry.CreatedAt = time.Now().UTC()
+	_, err := l.store.JournalEntries.InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		l.logger.Info("Journal entry already posted", zap.String("reference", entry.Reference))
+		return nil
+	}
+	if err != nil {
+		l.logger.Error("Failed to post journal entry", zap.String("reference", entry.Reference), zap.Error(err))
+		return fmt.Errorf("failed to post journal entry: %w", err)
+	}
+	return nil
+}
+
+// transfer posts an entry moving an amount from one ledger account to another.
+func (l *LedgerService) transfer(ctx context.Context, reference, entryType, description, from, fromAccountID, to, toAccountID string, amount int64, currency string) error {
+	return l.Post(ctx, models.JournalEntry{
+		Reference:   reference,
+		Type:        entryType,
+		Description: description,
+		Postings: []models.Posting{
+			{AccountCode: from, AccountID: fromAccountID, Direction: models.Debit, Amount: amount, Currency: currency},
+			{AccountCode: to, AccountID: toAccountID, Direction: models.Credit, Amount: amount, Currency: currency},
+		},
+	})
+}
+
+// RecordDeposit credits a deposit to the available funds of a sub-account.
+func (l *LedgerService) RecordDeposit(ctx context.Context, accountID string, payment models.PaymentData) error {
+	if err := l.ensureSystemAccounts(ctx, payment.Currency); err != nil {
+		return fmt.Errorf("failed to open system ledger accounts: %w", err)
+	}
+	return l.transfer(ctx, "deposit:"+payment.ID, models.EntryDeposit, "Deposit "+payment.ID,
+		settlementAccountCode, "", availableCode(accountID), accountID, payment.Amount, payment.Currency)
+}
+
+// RecordAuthorization moves the amount and fees of an approved authorization
+// from available to held funds.
+func (l *LedgerService) RecordAuthorization(ctx context.Context, accountID, authorizationID string, total int64, currency string) error {
+	return l.transfer(ctx, "authorization:"+authorizationID, models.EntryAuthorization, "Authorization "+authorizationID,
+		availableCode(accountID), accountID, heldCode(accountID), accountID, total, currency)
+}
+
+// RecordAdjustment moves funds between available and held when an
+// authorization amount changes. Sequence distinguishes successive adjustments.
+func (l *LedgerService) RecordAdjustment(ctx context.Context, accountID, authorizationID string, sequence int, delta int64, currency string) error {
+	reference := fmt.Sprintf("adjustment:%s:%d", authorizationID, sequence)
+	description := "Adjustment of authorization " + authorizationID
+	if delta >= 0 {
+		return l.transfer(ctx, reference, models.EntryAdjustment, description,
+			availableCode(accountID), accountID, heldCode(accountID), accountID, delta, currency)
+	}
+	return l.transfer(ctx, reference, models.EntryAdjustment, description,
+		heldCode(accountID), accountID, availableCode(accountID), accountID, -delta, currency)
+}
+
+// RecordRelease returns the held funds of a reversed or declined authorization to available funds.
+func (l *LedgerService) RecordRelease(ctx context.Context, accountID, authorizationID, entryType string, held int64, currency string) error {
+	return l.transfer(ctx, entryType+":"+authorizationID, entryType, "Release of authorization "+authorizationID,
+		heldCode(accountID), accountID, availableCode(accountID), accountID, held, currency)
+}
+
+// RecordCapture settles a closed authorization: the held funds are released,
+// then the captured amount is paid to the card network and the fees to revenue.
+func (l *LedgerService) RecordCapture(ctx context.Context, accountID, authorizationID string, held, amount, fees int64, currency string) error {
+	if err := l.ensureSystemAccounts(ctx, currency); err != nil {
+		return fmt.Errorf("failed to open system ledger accounts: %w", err)
+	}
+	postings := []models.Posting{
+		{AccountCode: heldCode(accountID), AccountID: accountID, Direction: models.Debit, Amount: held, Currency: currency},
+		{AccountCode: availableCode(accountID), AccountID: accountID, Direction: models.Credit, Amount: held, Currency: currency},
+		{AccountCode: availableCode(accountID), AccountID: accountID, Direction: models.Debit, Amount: amount, Currency: currency},
+		{AccountCode: cardNetworkCode, Direction: models.Credit, Amount: amount, Currency: currency},
+	}
+	if err := l.Post(ctx, models.JournalEntry{
+		Reference:   "capture:" + authorizationID,
+		Type:        models.EntryCapture,
+		Description: "Capture of authorization " + authorizationID,
+		Postings:    postings,
+	}); err != nil {
+		return err
+	}
+	if fees == 0 {
+		return nil
+	}
+	return l.transfer(ctx, "fee:"+authorizationID, models.EntryFee, "Fees of authorization "+authorizationID,
+		availableCode(accountID), accountID, feeRevenueCode, "", fees, currency)
+}
+
+// Balances derives the available and held balances of a sub-account from its
+// postings. It reports false when the sub-account has no ledger accounts yet.
+func (l *LedgerService) Balances(ctx context.Context, accountID string) (LedgerBalances, bool, error) {
+	balances := LedgerBalances{AccountID: accountID}
+	count, err := l.store.LedgerAccounts.CountDocuments(ctx, bson.M{"accountId": accountID})
+	if err != nil {
+		return balances, false, fmt.Errorf("failed to fetch ledger accounts: %w", err)
+	}
+	if count == 0 {
+		return balances, false, nil
+	}
+
+	sums, err := l.store.SumPostings(ctx, []string{availableCode(accountID), heldCode(accountID)})
+	if err != nil {
+		return balances, false, fmt.Errorf("failed to sum postings: %w", err)
+	}
+	// Customer accounts are liabilities, so credits increase them.
+	available := sums[availableCode(accountID)]
+	held := sums[heldCode(accountID)]
+	balances.Available = available[1] - available[0]
+	balances.Held = held[1] - held[0]
+	return balances, true, nil
+}
+
+// ListPostings returns a page of postings made to the ledger accounts of a sub-account.
+func (l *LedgerService) ListPostings(ctx context.Context, accountID string, page, limit int64) ([]models.PostingLine, error) {
+	lines, err := l.store.ListPostings(ctx, accountID, (page-1)*limit, limit)
+	if err != nil {
+		l.logger.Error("Failed to list postings", zap.String("accountID", accountID), zap.Error(err))
+		return nil, fmt.Errorf("failed to list postings: %w", err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/store"
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Spending limit intervals supported on card controls.
+const (
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+)
+
+// LimitUsage is the state of one spending limit of a card.
+type LimitUsage struct {
+	Interval    string    `json:"interval"`
+	Limit       int64     `json:"limit"`
+	Spent       int64     `json:"spent"`
+	Remaining   int64     `json:"remaining"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+}
+
+// LimitEvaluator evaluates card spending limits against the approved and
+// pending transactions of the current interval window.
+type LimitEvaluator struct {
+	store    *store.Store
+	location *time.Location // Timezone in which interval windows start
+	logger   *zap.Logger
+}
+
+// NewLimitEvaluator creates a limit evaluator whose windows start in the given location.
+func NewLimitEvaluator(store *store.Store, location *time.Location, logger *zap.Logger) *LimitEvaluator {
+	return &LimitEvaluator{store: store, location: location, logger: logger}
+}
+
+// Usage returns the usage of every spending limit of a card at the given time.
+func (e *LimitEvaluator) Usage(ctx context.Context, cardID string, limits []api.SpendingLimit, at time.Time) ([]LimitUsage, error) {
+	usages := make([]LimitUsage, 0, len(limits))
+	for _, limit := range limits {
+		start, end, ok := limitWindow(limit.Interval, at, e.location)
+		if !ok {
+			e.logger.Warn("Unknown spending limit interval",
+				zap.String("cardID", cardID),
+				zap.String("interval", limit.Interval),
+			)
+			continue
+		}
+		spent, err := e.store.SumCardSpend(ctx, cardID, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum card spend: %w", err)
+		}
+		remaining := int64(limit.Amount) - spent
+		if remaining < 0 {
+			remaining = 0
+		}
+		usages = append(usages, LimitUsage{
+			Interval:    limit.Interval,
+			Limit:       int64(limit.Amount),
+			Spent:       spent,
+			Remaining:   remaining,
+			WindowStart: start,
+			WindowEnd:   end,
+		})
+	}
+	return usages, nil
+}
+
+// Exceeded returns the first limit that an additional amount would exceed, if any.
+func (e *LimitEvaluator) Exceeded(ctx context.Context, cardID string, limits []api.SpendingLimit, amount int64, at time.Time) (*LimitUsage, error) {
+	usages, err := e.Usage(ctx, cardID, limits, at)
+	if err != nil {
+		return nil, err
+	}
+	for i := range usages {
+		if usages[i].Spent+amount > usages[i].Limit {
+			return &usages[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Spend returns the approved and pending spend of a card in the interval
+// window containing at.
+func (e *LimitEvaluator) Spend(ctx context.Context, cardID, interval string, at time.Time) (int64, error) {
+	start, end, ok := limitWindow(interval, at, e.location)
+	if !ok {
+		return 0, fmt.Errorf("unknown interval %q", interval)
+	}
+	return e.store.SumCardSpend(ctx, cardID, start, end)
+}
+
+// FundingSpend returns the approved and pending spend of all cards funded by
+// a sub-account in the interval window containing at.
+func (e *LimitEvaluator) FundingSpend(ctx context.Context, accountID, interval string, at time.Time) (int64, error) {
+	start, end, ok := limitWindow(interval, at, e.location)
+	if !ok {
+		return 0, fmt.Errorf("unknown interval %q", interval)
+	}
+	return e.store.SumFundingSpend(ctx, accountID, start, end)
+}
+
+// limitWindow returns the [start, end) window of an interval containing at,
+// with boundaries at midnight in loc. Weeks start on Monday.
+func limitWindow(interval string, at time.Time, loc *time.Location) (time.Time, time.Time, bool) {
+	local := at.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	switch interval {
+	case IntervalDaily:
+		return midnight, midnight.AddDate(0, 0, 1), true
+	case IntervalWeekly:
+		offset := (int(midnight.Weekday()) + 6) % 7
+		start := midnight.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7), true
+	case IntervalMonthly:
+		start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0), true
+	}
+	return time.Time{}, time.Time{}, false
+}
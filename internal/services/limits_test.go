@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitWindowBoundaries(t *testing.T) {
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		interval string
+		at       string
+		start    string
+		end      string
+	}{
+		{name: "daily at midnight", interval: IntervalDaily, at: "2024-05-14T00:00:00+01:00", start: "2024-05-14T00:00:00+01:00", end: "2024-05-15T00:00:00+01:00"},
+		{name: "daily just before midnight", interval: IntervalDaily, at: "2024-05-14T23:59:59+01:00", start: "2024-05-14T00:00:00+01:00", end: "2024-05-15T00:00:00+01:00"},
+		{name: "daily in UTC before local midnight", interval: IntervalDaily, at: "2024-05-14T22:59:59Z", start: "2024-05-14T00:00:00+01:00", end: "2024-05-15T00:00:00+01:00"},
+		{name: "daily in UTC after local midnight", interval: IntervalDaily, at: "2024-05-14T23:00:00Z", start: "2024-05-15T00:00:00+01:00", end: "2024-05-16T00:00:00+01:00"},
+		{name: "weekly on Sunday night", interval: IntervalWeekly, at: "2024-05-19T23:59:59+01:00", start: "2024-05-13T00:00:00+01:00", end: "2024-05-20T00:00:00+01:00"},
+		{name: "weekly on Monday", interval: IntervalWeekly, at: "2024-05-20T00:00:00+01:00", start: "2024-05-20T00:00:00+01:00", end: "2024-05-27T00:00:00+01:00"},
+		{name: "monthly at month end", interval: IntervalMonthly, at: "2024-02-29T23:59:59+01:00", start: "2024-02-01T00:00:00+01:00", end: "2024-03-01T00:00:00+01:00"},
+		{name: "monthly on the first", interval: IntervalMonthly, at: "2024-03-01T00:00:00+01:00", start: "2024-03-01T00:00:00+01:00", end: "2024-04-01T00:00:00+01:00"},
+		{name: "monthly at year end", interval: IntervalMonthly, at: "2024-12-31T23:00:00Z", start: "2025-01-01T00:00:00+01:00", end: "2025-02-01T00:00:00+01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := limitWindow(tt.interval, at(tt.at), lagos)
+			if !ok {
+				t.Fatalf("limitWindow(%s) not ok", tt.interval)
+			}
+			if !start.Equal(at(tt.start)) || !end.Equal(at(tt.end)) {
+				t.Errorf("window = [%s, %s); want [%s, %s)", start, end, tt.start, tt.end)
+			}
+			// The window is half open: its end belongs to the next window
+			if next, _, _ := limitWindow(tt.interval, end, lagos); !next.Equal(end) {
+				t.Errorf("window after %s starts at %s", end, next)
+			}
+		})
+	}
+
+	if _, _, ok := limitWindow("yearly", at("2024-05-14T00:00:00Z"), lagos); ok {
+		t.Error("limitWindow accepted an unknown interval")
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"card-service/internal/store"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// lockLease is how long a lock is held when the context of its holder has no
+// earlier deadline. The lock of a holder that died is free once it ran out.
+const lockLease = 30 * time.Second
+
+// lockRetry is how often a lock held by someone else is tried again.
+const lockRetry = 10 * time.Millisecond
+
+// leaseLocks serializes work per key across every instance, such as all
+// authorizations of one account. A lock is a lease in the store that is
+// removed when it is released.
+type leaseLocks struct {
+	store  *store.Store
+	prefix string // Namespace of the keys in the store
+	logger *zap.Logger
+}
+
+func newLeaseLocks(store *store.Store, prefix string, logger *zap.Logger) *leaseLocks {
+	return &leaseLocks{store: store, prefix: prefix, logger: logger}
+}
+
+// Lock acquires the lock of a key and returns the function that releases it.
+// It gives up when the context is done before the lock is free. The lease
+// ends with the context's deadline, so a lock never outlives the work it guards.
+func (l *leaseLocks) Lock(ctx context.Context, key string) (func(), error) {
+	id := l.prefix + key
+	holder := primitive.NewObjectID().Hex()
+	for {
+		now := time.Now().UTC()
+		until := now.Add(lockLease)
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+			until = deadline
+		}
+		acquired, err := l.store.AcquireLock(ctx, id, holder, now, until)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return func() { l.release(id, holder) }, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+}
+
+// release ends a lease, even when the context of the work it guarded is done.
+func (l *leaseLocks) release(id, holder string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.store.ReleaseLock(ctx, id, holder); err != nil {
+		// The lease runs out on its own
+		l.logger.Error("Failed to release lock", zap.String("key", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+func TestLeaseLocksExclude(t *testing.T) {
+	db := testStore(t)
+	// Two lock sets share the store like two instances of the service
+	instances := []*leaseLocks{newLeaseLocks(db, "account:", zap.NewNop()), newLeaseLocks(db, "account:", zap.NewNop())}
+
+	var held, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(locks *leaseLocks) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			unlock, err := locks.Lock(ctx, "acc_1")
+			if err != nil {
+				t.Errorf("Lock: %v", err)
+				return
+			}
+			if atomic.AddInt32(&held, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&held, -1)
+			unlock()
+		}(instances[i%2])
+	}
+	wg.Wait()
+	if overlaps > 0 {
+		t.Errorf("lock held by %d holders at once", overlaps+1)
+	}
+
+	// Released locks leave no key behind
+	count, err := db.Locks.CountDocuments(context.Background(), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d locks left after release; want 0", count)
+	}
+}
+
+func TestLeaseLocksExpire(t *testing.T) {
+	db := testStore(t)
+	locks := newLeaseLocks(db, "account:", zap.NewNop())
+
+	// A holder that dies keeps the lock only until its deadline
+	dead, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := locks.Lock(dead, "acc_1"); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := locks.Lock(short, "acc_1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock under a running lease error = %v; want context.DeadlineExceeded", err)
+	}
+
+	ctx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelWait()
+	unlock, err := locks.Lock(ctx, "acc_1")
+	if err != nil {
+		t.Fatalf("Lock after the lease ran out: %v", err)
+	}
+	unlock()
+
+	// Other keys are never held up
+	other, cancelOther := context.WithTimeout(context.Background(), time.Second)
+	defer cancelOther()
+	unlock, err = locks.Lock(other, "acc_2")
+	if err != nil {
+		t.Fatalf("Lock of another key: %v", err)
+	}
+	unlock()
+}
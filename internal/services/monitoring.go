@@ -0,0 +1,282 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxEvidenceReferences caps the references kept as evidence on a case.
+const maxEvidenceReferences = 50
+
+// MonitoringConfig configures the transaction monitoring heuristics. Amounts
+// are in minor units.
+type MonitoringConfig struct {
+	StructuringThreshold int64         // Reporting threshold deposits are kept under
+	StructuringMargin    int64         // Percentage under the threshold that counts as just under it
+	StructuringCount     int           // Deposits just under the threshold in the window that open a case
+	StructuringWindow    time.Duration // Window of the structuring heuristic
+	RapidWindow          time.Duration // Window in which deposits and card spend are compared
+	RapidMinAmount       int64         // Deposits in the window below which rapid movement is ignored
+	RapidOutflowPercent  int64         // Share of the deposits spent in the window that opens a case
+	VolumeWindow         time.Duration // Window whose volume is compared with the customer's history
+	VolumeBaselineDays   int           // Days of history before the window forming the baseline
+	VolumeMultiplier     int64         // Multiple of the baseline that opens a case
+	VolumeMinAmount      int64         // Volume in the window below which unusual volume is ignored
+}
+
+// MonitoringService periodically scans deposits and card spend for
+// structuring, rapid movement of funds and unusual volumes, and opens a case
+// for every hit.
+type MonitoringService struct {
+	store  *store.Store
+	cases  *CaseService
+	config MonitoringConfig
+	logger *zap.Logger
+}
+
+// NewMonitoringService creates a monitoring service.
+func NewMonitoringService(store *store.Store, cases *CaseService, config MonitoringConfig, logger *zap.Logger) *MonitoringService {
+	return &MonitoringService{store: store, cases: cases, config: config, logger: logger}
+}
+
+// RunMonitoring scans the activity at the given interval until ctx is cancelled.
+func (m *MonitoringService) RunMonitoring(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			opened, err := m.Scan(ctx, now.UTC())
+			if err != nil {
+				m.logger.Error("Failed to scan activity", zap.Error(err))
+			}
+			if opened > 0 {
+				m.logger.Info("Opened compliance cases", zap.Int("count", opened))
+			}
+		}
+	}
+}
+
+// Scan runs every heuristic over the windows ending at and opens a case for
+// every hit. A failing heuristic does not stop the others.
+func (m *MonitoringService) Scan(ctx context.Context, at time.Time) (int, error) {
+	detectors := []struct {
+		name   string
+		detect func(context.Context, time.Time) ([]models.Case, error)
+	}{
+		{models.AlertStructuring, m.detectStructuring},
+		{models.AlertRapidMovement, m.detectRapidMovement},
+		{models.AlertUnusualVolume, m.detectUnusualVolume},
+	}
+	opened := 0
+	var failed error
+	for _, detector := range detectors {
+		hits, err := detector.detect(ctx, at)
+		if err != nil {
+			m.logger.Error("Monitoring heuristic failed", zap.String("alert", detector.name), zap.Error(err))
+			failed = fmt.Errorf("%s: %w", detector.name, err)
+			continue
+		}
+		for _, hit := range hits {
+			ok, err := m.cases.Open(ctx, hit)
+			if err != nil {
+				m.logger.Error("Failed to open case", zap.String("alert", hit.Type), zap.String("accountID", hit.AccountID), zap.Error(err))
+				failed = err
+				continue
+			}
+			if ok {
+				opened++
+			}
+		}
+	}
+	return opened, failed
+}
+
+// detectStructuring finds sub-accounts with many deposits just under the
+// reporting threshold in the window.
+func (m *MonitoringService) detectStructuring(ctx context.Context, at time.Time) ([]models.Case, error) {
+	if m.config.StructuringThreshold <= 0 || m.config.StructuringCount <= 0 {
+		return nil, nil
+	}
+	from := at.Add(-m.config.StructuringWindow)
+	floor := m.config.StructuringThreshold - m.config.StructuringThreshold*m.config.StructuringMargin/100
+	deposits, err := m.store.SumAccountDeposits(ctx, nil, from, at, floor, m.config.StructuringThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum deposits: %w", err)
+	}
+	var hits []models.Case
+	for _, activity := range deposits {
+		if activity.Count < int64(m.config.StructuringCount) {
+			continue
+		}
+		hits = append(hits, newCase(models.AlertStructuring, activity.AccountID, at,
+			fmt.Sprintf("%d deposits between %d and %d", activity.Count, floor, m.config.StructuringThreshold),
+			models.CaseEvidence{
+				From:       from,
+				To:         at,
+				Deposits:   activity.Count,
+				Inflow:     activity.Amount,
+				References: capReferences(activity.References),
+			}))
+	}
+	return hits, nil
+}
+
+// detectRapidMovement finds sub-accounts that spent most of their deposits on
+// their cards within the window.
+func (m *MonitoringService) detectRapidMovement(ctx context.Context, at time.Time) ([]models.Case, error) {
+	if m.config.RapidOutflowPercent <= 0 {
+		return nil, nil
+	}
+	from := at.Add(-m.config.RapidWindow)
+	deposits, err := m.store.SumAccountDeposits(ctx, nil, from, at, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum deposits: %w", err)
+	}
+	var accountIDs []string
+	inflows := map[string]models.AccountActivity{}
+	for _, activity := range deposits {
+		if activity.Amount >= m.config.RapidMinAmount {
+			accountIDs = append(accountIDs, activity.AccountID)
+			inflows[activity.AccountID] = activity
+		}
+	}
+	if len(accountIDs) == 0 {
+		return nil, nil
+	}
+	spend, err := m.store.SumAccountSpend(ctx, accountIDs, from, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum card spend: %w", err)
+	}
+
+	var hits []models.Case
+	for _, outflow := range spend {
+		inflow := inflows[outflow.AccountID]
+		if outflow.Amount*100 < inflow.Amount*m.config.RapidOutflowPercent {
+			continue
+		}
+		hits = append(hits, newCase(models.AlertRapidMovement, outflow.AccountID, at,
+			fmt.Sprintf("%d of %d deposited spent within %s", outflow.Amount, inflow.Amount, m.config.RapidWindow),
+			models.CaseEvidence{
+				From:       from,
+				To:         at,
+				Deposits:   inflow.Count,
+				Inflow:     inflow.Amount,
+				Outflow:    outflow.Amount,
+				References: capReferences(slices.Concat(inflow.References, outflow.References)),
+			}))
+	}
+	return hits, nil
+}
+
+// detectUnusualVolume finds sub-accounts whose deposits and card spend in the
+// window far exceed what their history before the window predicts. Accounts
+// without history have no baseline and are not compared.
+func (m *MonitoringService) detectUnusualVolume(ctx context.Context, at time.Time) ([]models.Case, error) {
+	if m.config.VolumeMultiplier <= 0 || m.config.VolumeBaselineDays <= 0 {
+		return nil, nil
+	}
+	from := at.Add(-m.config.VolumeWindow)
+	current, err := m.accountVolumes(ctx, nil, from, at)
+	if err != nil {
+		return nil, err
+	}
+	var accountIDs []string
+	for accountID, volume := range current {
+		if volume.inflow.Amount+volume.outflow.Amount >= m.config.VolumeMinAmount {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	if len(accountIDs) == 0 {
+		return nil, nil
+	}
+	baselineFrom := from.AddDate(0, 0, -m.config.VolumeBaselineDays)
+	history, err := m.accountVolumes(ctx, accountIDs, baselineFrom, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []models.Case
+	for _, accountID := range accountIDs {
+		volume := current[accountID]
+		total := volume.inflow.Amount + volume.outflow.Amount
+		past := history[accountID]
+		// Scale the history to the length of the window
+		baseline := int64(float64(past.inflow.Amount+past.outflow.Amount) * m.config.VolumeWindow.Hours() / (24 * float64(m.config.VolumeBaselineDays)))
+		if baseline <= 0 || total <= baseline*m.config.VolumeMultiplier {
+			continue
+		}
+		hits = append(hits, newCase(models.AlertUnusualVolume, accountID, at,
+			fmt.Sprintf("Volume of %d against %d expected from the last %d days", total, baseline, m.config.VolumeBaselineDays),
+			models.CaseEvidence{
+				From:       from,
+				To:         at,
+				Deposits:   volume.inflow.Count,
+				Inflow:     volume.inflow.Amount,
+				Outflow:    volume.outflow.Amount,
+				Baseline:   baseline,
+				References: capReferences(slices.Concat(volume.inflow.References, volume.outflow.References)),
+			}))
+	}
+	return hits, nil
+}
+
+// accountVolume is the deposits and card spend of a sub-account in a window.
+type accountVolume struct {
+	inflow  models.AccountActivity
+	outflow models.AccountActivity
+}
+
+// accountVolumes totals the deposits and card spend of sub-accounts in [from, to).
+func (m *MonitoringService) accountVolumes(ctx context.Context, accountIDs []string, from, to time.Time) (map[string]accountVolume, error) {
+	deposits, err := m.store.SumAccountDeposits(ctx, accountIDs, from, to, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum deposits: %w", err)
+	}
+	spend, err := m.store.SumAccountSpend(ctx, accountIDs, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum card spend: %w", err)
+	}
+	volumes := map[string]accountVolume{}
+	for _, activity := range deposits {
+		volume := volumes[activity.AccountID]
+		volume.inflow = activity
+		volumes[activity.AccountID] = volume
+	}
+	for _, activity := range spend {
+		volume := volumes[activity.AccountID]
+		volume.outflow = activity
+		volumes[activity.AccountID] = volume
+	}
+	return volumes, nil
+}
+
+// newCase builds the case for an alert. The key makes an alert open at most
+// one case per account and day.
+func newCase(alert, accountID string, at time.Time, summary string, evidence models.CaseEvidence) models.Case {
+	return models.Case{
+		Key:       fmt.Sprintf("%s:%s:%s", alert, accountID, at.UTC().Format("2006-01-02")),
+		Type:      alert,
+		AccountID: accountID,
+		Summary:   summary,
+		Evidence:  evidence,
+	}
+}
+
+func capReferences(references []string) []string {
+	if len(references) > maxEvidenceReferences {
+		return references[:maxEvidenceReferences]
+	}
+	if references == nil {
+		return []string{}
+	}
+	return references
+}
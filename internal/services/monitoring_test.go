@@ -0,0 +1,164 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// activityStream writes synthetic deposits and card spend of sub-accounts.
+type activityStream struct {
+	t  *testing.T
+	db *store.Store
+	n  int
+}
+
+func (s *activityStream) deposit(accountID string, amount int64, at time.Time) {
+	s.t.Helper()
+	s.n++
+	_, err := s.db.JournalEntries.InsertOne(context.Background(), models.JournalEntry{
+		Reference: fmt.Sprintf("pay_%d", s.n),
+		Type:      models.EntryDeposit,
+		Postings: []models.Posting{
+			{AccountCode: "settlement", Direction: models.Debit, Amount: amount, Currency: "NGN"},
+			{AccountCode: "customer:" + accountID, AccountID: accountID, Direction: models.Credit, Amount: amount, Currency: "NGN"},
+		},
+		CreatedAt: at,
+	})
+	if err != nil {
+		s.t.Fatalf("failed to store deposit: %v", err)
+	}
+}
+
+func (s *activityStream) spend(accountID string, amount int64, at time.Time) {
+	s.t.Helper()
+	s.n++
+	id := fmt.Sprintf("auth_%d", s.n)
+	_, err := s.db.InsertTransaction(context.Background(), models.Transaction{
+		ID:            id,
+		Authorization: id,
+		CardID:        "card_" + accountID,
+		AccountID:     accountID,
+		Amount:        amount,
+		Currency:      "NGN",
+		Type:          "purchase",
+		Status:        "approved",
+		CreatedAt:     at.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		s.t.Fatalf("failed to store transaction: %v", err)
+	}
+}
+
+func TestMonitoringScenarios(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	monitoring := NewMonitoringService(db, NewCaseService(db, zap.NewNop()), MonitoringConfig{
+		StructuringThreshold: 1_000_000,
+		StructuringMargin:    10,
+		StructuringCount:     3,
+		StructuringWindow:    7 * 24 * time.Hour,
+		RapidWindow:          24 * time.Hour,
+		RapidMinAmount:       1_000_000,
+		RapidOutflowPercent:  80,
+		VolumeWindow:         24 * time.Hour,
+		VolumeBaselineDays:   30,
+		VolumeMultiplier:     5,
+		VolumeMinAmount:      100_000,
+	}, zap.NewNop())
+
+	at := time.Date(2024, 5, 14, 12, 0, 0, 0, time.UTC)
+	stream := &activityStream{t: t, db: db}
+	// Deposits just under the threshold spread over the week
+	for day := 1; day <= 4; day++ {
+		stream.deposit("acc_structuring", 950_000, at.Add(-time.Duration(day)*24*time.Hour-time.Hour))
+	}
+	// Deposits under the floor of the margin, at the threshold or before the
+	// window do not count
+	stream.deposit("acc_split", 850_000, at.Add(-74*time.Hour))
+	stream.deposit("acc_split", 1_000_000, at.Add(-26*time.Hour))
+	stream.deposit("acc_split", 950_000, at.Add(-50*time.Hour))
+	stream.deposit("acc_split", 950_000, at.Add(-8*24*time.Hour))
+	// Most of a deposit spent on the card within the day
+	stream.deposit("acc_rapid", 2_000_000, at.Add(-6*time.Hour))
+	stream.spend("acc_rapid", 900_000, at.Add(-5*time.Hour))
+	stream.spend("acc_rapid", 800_000, at.Add(-4*time.Hour))
+	// Spending half of a deposit within the day is not rapid
+	stream.deposit("acc_slow", 2_000_000, at.Add(-6*time.Hour))
+	stream.spend("acc_slow", 1_000_000, at.Add(-2*time.Hour))
+	// Steady history, then a day far above it
+	for day := 2; day <= 30; day++ {
+		stream.deposit("acc_volume", 20_000, at.Add(-time.Duration(day)*24*time.Hour))
+		stream.deposit("acc_steady", 50_000, at.Add(-time.Duration(day)*24*time.Hour))
+	}
+	stream.deposit("acc_volume", 600_000, at.Add(-3*time.Hour))
+	stream.spend("acc_volume", 100_000, at.Add(-2*time.Hour))
+	stream.deposit("acc_steady", 60_000, at.Add(-3*time.Hour))
+	stream.spend("acc_steady", 50_000, at.Add(-2*time.Hour))
+	// A large first day has no history to compare with
+	stream.deposit("acc_new", 600_000, at.Add(-3*time.Hour))
+
+	opened, err := monitoring.Scan(ctx, at)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	want := map[string]string{
+		"acc_structuring": models.AlertStructuring,
+		"acc_rapid":       models.AlertRapidMovement,
+		"acc_volume":      models.AlertUnusualVolume,
+	}
+	if opened != len(want) {
+		t.Errorf("Scan opened %d cases; want %d", opened, len(want))
+	}
+
+	cursor, err := db.Cases.Find(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []models.Case
+	if err := cursor.All(ctx, &cases); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, c := range cases {
+		if previous, ok := got[c.AccountID]; ok {
+			t.Errorf("%s has %s and %s cases; want one", c.AccountID, previous, c.Type)
+		}
+		got[c.AccountID] = c.Type
+		if c.Status != models.CaseOpen || c.Evidence.From.IsZero() || !c.Evidence.To.Equal(at) || len(c.Evidence.References) == 0 {
+			t.Errorf("%s case of %s = %+v; want an open case with evidence", c.Type, c.AccountID, c)
+		}
+	}
+	for accountID, alert := range want {
+		if got[accountID] != alert {
+			t.Errorf("%s case = %q; want %q", accountID, got[accountID], alert)
+		}
+	}
+	for accountID, alert := range got {
+		if _, ok := want[accountID]; !ok {
+			t.Errorf("unexpected %s case for %s", alert, accountID)
+		}
+	}
+
+	var structuring models.Case
+	for _, c := range cases {
+		if c.AccountID == "acc_structuring" {
+			structuring = c
+		}
+	}
+	if structuring.Evidence.Deposits != 4 || structuring.Evidence.Inflow != 3_800_000 {
+		t.Errorf("structuring evidence = %d deposits of %d; want 4 of 3800000", structuring.Evidence.Deposits, structuring.Evidence.Inflow)
+	}
+
+	// Scanning again while the cases are open opens nothing
+	opened, err = monitoring.Scan(ctx, at.Add(time.Hour))
+	if err != nil || opened != 0 {
+		t.Errorf("second Scan = %d, %v; want 0", opened, err)
+	}
+}
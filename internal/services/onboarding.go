@@ -0,0 +1,446 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrOnboardingInProgress is returned when another attempt is running the onboarding of a reference.
+	ErrOnboardingInProgress = errors.New("onboarding is already in progress")
+	// ErrOnboardingNotFound is returned when no onboarding exists with the given reference.
+	ErrOnboardingNotFound = errors.New("onboarding not found")
+)
+
+// onboardingLease is how long an attempt holds an onboarding. A retry after
+// an attempt died resumes once its lease ran out.
+const onboardingLease = 2 * time.Minute
+
+// startOnboarding runs the onboarding saga of a request, or resumes it after
+// its last completed step when the reference was seen before. screen screens
+// the parties of the request the first time.
+func (s *CustomerService) startOnboarding(ctx context.Context, onboarding models.Onboarding, screen func() models.Screening) (string, string, []api.DepositChannel, error) {
+	claimed, err := s.claimOnboarding(ctx, onboarding)
+	if err != nil {
+		return "", "", nil, err
+	}
+	switch claimed.Status {
+	case models.OnboardingCompleted:
+		s.releaseOnboarding(ctx, claimed.Ref)
+		return claimed.CustomerID, claimed.AccountID, apiDepositChannels(claimed.DepositChannels), nil
+	case models.OnboardingInReview:
+		s.releaseOnboarding(ctx, claimed.Ref)
+		return "", "", nil, &ScreeningHoldError{ScreeningID: claimed.ScreeningID, Result: models.ScreeningReview}
+	case models.OnboardingRejected:
+		s.releaseOnboarding(ctx, claimed.Ref)
+		return "", "", nil, &ScreeningHoldError{ScreeningID: claimed.ScreeningID, Result: models.ScreeningBlocked}
+	}
+
+	if !stepDone(claimed, models.StepScreened) {
+		screening := screen()
+		screening.Ref = claimed.Ref
+		if screening.Result == models.ScreeningReview {
+			screening.Application, screening.Business = claimed.Application, claimed.Business
+		}
+		screening, err := s.screening.Record(ctx, screening)
+		if err != nil {
+			return "", "", nil, s.failOnboarding(ctx, claimed, models.StepScreened, err)
+		}
+		if screening.Result != models.ScreeningClear {
+			return "", "", nil, s.holdOnboarding(ctx, claimed, screening)
+		}
+		err = s.completeStep(ctx, claimed, models.StepScreened, bson.M{
+			"screeningId": screening.ID.Hex(),
+			"screenedAt":  screening.ListsImportedAt,
+		})
+		if err != nil {
+			return "", "", nil, s.failOnboarding(ctx, claimed, models.StepScreened, err)
+		}
+	}
+	return s.runOnboarding(ctx, claimed)
+}
+
+// resumeOnboarding runs the onboarding saga of a screening cleared by a review.
+func (s *CustomerService) resumeOnboarding(ctx context.Context, screening *models.Screening) (string, string, error) {
+	onboarding := models.Onboarding{
+		Ref:         screening.Ref,
+		Type:        models.CustomerIndividual,
+		Application: screening.Application,
+		Business:    screening.Business,
+	}
+	if screening.Business != nil {
+		onboarding.Type = models.CustomerBusiness
+	}
+	claimed, err := s.claimOnboarding(ctx, onboarding)
+	if err != nil {
+		return "", "", err
+	}
+	if claimed.Status == models.OnboardingCompleted {
+		s.releaseOnboarding(ctx, claimed.Ref)
+		return claimed.CustomerID, claimed.AccountID, nil
+	}
+	if !stepDone(claimed, models.StepScreened) {
+		err := s.completeStep(ctx, claimed, models.StepScreened, bson.M{
+			"status":      models.OnboardingInProgress,
+			"screeningId": screening.ID.Hex(),
+			"screenedAt":  screening.ListsImportedAt,
+		})
+		if err != nil {
+			return "", "", s.failOnboarding(ctx, claimed, models.StepScreened, err)
+		}
+	}
+	customerID, accountID, _, err := s.runOnboarding(ctx, claimed)
+	return customerID, accountID, err
+}
+
+// rejectOnboarding closes the onboarding of a reference after screening
+// confirmed a match.
+func (s *CustomerService) rejectOnboarding(ctx context.Context, ref string) {
+	err := s.store.UpdateOnboarding(ctx, ref, bson.M{
+		"$set":   bson.M{"status": models.OnboardingRejected, "updatedAt": time.Now().UTC()},
+		"$unset": bson.M{"application": "", "business": ""},
+	})
+	if err != nil {
+		s.logger.Error("Failed to reject onboarding", zap.String("ref", ref), zap.Error(err))
+	}
+}
+
+// runOnboarding runs the steps of a screened onboarding that have not
+// completed yet. Each issuer call runs as an outbox operation keyed by the
+// reference and step, whose result records the step, so a retry continues
+// with the same issuer customer instead of creating another.
+func (s *CustomerService) runOnboarding(ctx context.Context, onboarding *models.Onboarding) (string, string, []api.DepositChannel, error) {
+	req, customer, err := s.onboardingCustomer(onboarding)
+	if err != nil {
+		return "", "", nil, s.failOnboarding(ctx, onboarding, models.StepIssuerCustomer, err)
+	}
+
+	if !stepDone(onboarding, models.StepIssuerCustomer) {
+		var result issuerCustomerResult
+		if err := s.runIssuerStep(ctx, onboarding, models.OpCreateCustomer, models.StepIssuerCustomer, req, &result); err != nil {
+			s.logger.Error("Failed to create customer in Allawee API", zap.String("ref", onboarding.Ref), zap.Error(err))
+			return "", "", nil, s.failOnboarding(ctx, onboarding, models.StepIssuerCustomer, err)
+		}
+		s.logger.Info("Created customer", zap.String("ref", onboarding.Ref), zap.String("customerID", result.CustomerID))
+		onboarding.CustomerID = result.CustomerID
+	}
+
+	if !stepDone(onboarding, models.StepSubAccount) {
+		var result subAccountResult
+		err := s.runIssuerStep(ctx, onboarding, models.OpCreateSubAccount, models.StepSubAccount, subAccountRequest(onboarding.CustomerID, customer.Name), &result)
+		if err != nil {
+			s.logger.Error("Failed to create sub account in Allawee API", zap.String("ref", onboarding.Ref), zap.Error(err))
+			return "", "", nil, s.failOnboarding(ctx, onboarding, models.StepSubAccount, err)
+		}
+		s.logger.Info("Created sub account", zap.String("ref", onboarding.Ref), zap.String("subAccountID", result.AccountID))
+		onboarding.AccountID, onboarding.DepositChannels = result.AccountID, result.DepositChannels
+	}
+
+	if err := s.storeOnboarding(ctx, onboarding, customer); err != nil {
+		s.logger.Error("Failed to store onboarded customer", zap.String("ref", onboarding.Ref), zap.Error(err))
+		return "", "", nil, s.failOnboarding(ctx, onboarding, models.StepStored, err)
+	}
+	s.logger.Info("Completed onboarding",
+		zap.String("ref", onboarding.Ref),
+		zap.String("customerID", onboarding.CustomerID),
+		zap.String("subAccountID", onboarding.AccountID),
+	)
+	return onboarding.CustomerID, onboarding.AccountID, apiDepositChannels(onboarding.DepositChannels), nil
+}
+
+// issuerCustomerResult is the result of a create-customer operation.
+type issuerCustomerResult struct {
+	CustomerID string `bson:"customerId"`
+}
+
+// subAccountResult is the result of a create-sub-account operation.
+type subAccountResult struct {
+	AccountID       string                  `bson:"accountId"`
+	DepositChannels []models.DepositChannel `bson:"depositChannels"`
+}
+
+// runIssuerStep submits the issuer call of an onboarding step, or finds the
+// one a previous attempt submitted, runs it and decodes its result.
+func (s *CustomerService) runIssuerStep(ctx context.Context, onboarding *models.Onboarding, opType, step string, req interface{}, result interface{}) error {
+	op, err := s.outbox.Submit(ctx, opType, "onboarding:"+onboarding.Ref+":"+step, onboarding.Ref, req, nil)
+	if err != nil {
+		return err
+	}
+	op, err = s.outbox.Dispatch(ctx, op.ID)
+	if err != nil {
+		return err
+	}
+	if err := bson.Unmarshal(op.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", opType, err)
+	}
+	onboarding.Steps = append(onboarding.Steps, models.OnboardingStep{Name: step, At: *op.CompletedAt})
+	return nil
+}
+
+// executeCreateCustomer creates the customer of an onboarding with the issuer.
+func (s *CustomerService) executeCreateCustomer(ctx context.Context, op models.OutboxOperation) (interface{}, error) {
+	var req api.CreateCustomerRequest
+	if err := bson.Unmarshal(op.Payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode create customer request: %w", err)
+	}
+	customerID, err := s.apiClient.CreateCustomer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return issuerCustomerResult{CustomerID: customerID}, nil
+}
+
+// executeCreateSubAccount creates the sub account of an onboarding with the issuer.
+func (s *CustomerService) executeCreateSubAccount(ctx context.Context, op models.OutboxOperation) (interface{}, error) {
+	var req api.CreateSubAccountRequest
+	if err := bson.Unmarshal(op.Payload, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode create sub account request: %w", err)
+	}
+	accountID, depositChannels, err := s.apiClient.CreateSubAccount(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return subAccountResult{AccountID: accountID, DepositChannels: modelDepositChannels(depositChannels)}, nil
+}
+
+// completeIssuerStep records the onboarding step of a completed issuer
+// operation with the fields of its result.
+func (s *CustomerService) completeIssuerStep(ctx context.Context, op models.OutboxOperation, result bson.Raw) error {
+	step, set := models.StepIssuerCustomer, bson.M{}
+	if op.Type == models.OpCreateSubAccount {
+		step = models.StepSubAccount
+	}
+	if err := bson.Unmarshal(result, &set); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", op.Type, err)
+	}
+	now := time.Now().UTC()
+	set["updatedAt"] = now
+	_, err := s.store.Onboarding.UpdateOne(ctx, bson.M{"ref": op.Subject, "steps.name": bson.M{"$ne": step}}, bson.M{
+		"$set":  set,
+		"$push": bson.M{"steps": models.OnboardingStep{Name: step, At: now}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record onboarding step %s: %w", step, err)
+	}
+	return nil
+}
+
+// onboardingCustomer is the issuer request and the stored customer of an onboarding.
+func (s *CustomerService) onboardingCustomer(onboarding *models.Onboarding) (api.CreateCustomerRequest, models.Customer, error) {
+	switch {
+	case onboarding.Type == models.CustomerBusiness && onboarding.Business != nil:
+		req, customer := s.businessCustomer(*onboarding.Business)
+		return req, customer, nil
+	case onboarding.Type == models.CustomerIndividual && onboarding.Application != nil:
+		req, customer := s.individualCustomer(*onboarding.Application)
+		return req, customer, nil
+	}
+	return api.CreateCustomerRequest{}, models.Customer{}, fmt.Errorf("onboarding %s has no %s application", onboarding.Ref, onboarding.Type)
+}
+
+// subAccountRequest is the issuer request for the sub account of a customer.
+func subAccountRequest(customerID, name string) api.CreateSubAccountRequest {
+	return api.CreateSubAccountRequest{
+		Name:            name,
+		Type:            "sub",
+		Currency:        "NGN",
+		Customer:        customerID,
+		DepositChannels: []string{"bank-account"},
+		// SettlementAccount: "",
+	}
+}
+
+// storeOnboarding stores the customer and the sub account, opens its ledger
+// accounts, links the screening and completes the onboarding in one
+// transaction.
+func (s *CustomerService) storeOnboarding(ctx context.Context, onboarding *models.Onboarding, customer models.Customer) error {
+	now := time.Now()
+	customer.CustomerID = onboarding.CustomerID
+	customer.AccountID = onboarding.AccountID
+	customer.CreatedAt = now
+	customer.ScreenedAt = onboarding.ScreenedAt
+	account := models.Account{
+		AccountID:       onboarding.AccountID,
+		CustomerID:      onboarding.CustomerID,
+		Name:            customer.Name,
+		DepositChannels: onboarding.DepositChannels,
+		Status:          "active", //default status is active
+		CreatedAt:       now,
+	}
+	screeningID, _ := primitive.ObjectIDFromHex(onboarding.ScreeningID)
+
+	return s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := s.store.Customers.InsertOne(sc, customer); err != nil {
+			return fmt.Errorf("failed to store customer: %w", err)
+		}
+		if _, err := s.store.Accounts.InsertOne(sc, account); err != nil {
+			return fmt.Errorf("failed to store account: %w", err)
+		}
+		if err := s.ledger.OpenAccount(sc, account.AccountID, account.CustomerID, "NGN"); err != nil {
+			return err
+		}
+		_, err := s.store.Screenings.UpdateOne(sc, bson.M{"_id": screeningID}, bson.M{
+			"$set":   bson.M{"customerId": account.CustomerID, "accountId": account.AccountID},
+			"$unset": bson.M{"application": "", "business": ""},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to link screening: %w", err)
+		}
+		err = s.store.UpdateOnboarding(sc, onboarding.Ref, bson.M{
+			"$set":   bson.M{"status": models.OnboardingCompleted, "updatedAt": now.UTC()},
+			"$push":  bson.M{"steps": models.OnboardingStep{Name: models.StepStored, At: now.UTC()}},
+			"$unset": bson.M{"lockedUntil": "", "lastError": "", "application": "", "business": ""},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to complete onboarding: %w", err)
+		}
+		return nil
+	})
+}
+
+// claimOnboarding locks the onboarding of a reference for this attempt,
+// creating it on the first attempt.
+func (s *CustomerService) claimOnboarding(ctx context.Context, onboarding models.Onboarding) (*models.Onboarding, error) {
+	now := time.Now().UTC()
+	claimed, err := s.store.ClaimOnboarding(ctx, onboarding, now, now.Add(onboardingLease))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrOnboardingInProgress
+	}
+	if err != nil {
+		s.logger.Error("Failed to claim onboarding", zap.String("ref", onboarding.Ref), zap.Error(err))
+		return nil, fmt.Errorf("failed to claim onboarding: %w", err)
+	}
+	if claimed.Status == models.OnboardingFailed {
+		claimed.Status = models.OnboardingInProgress
+		if err := s.store.UpdateOnboarding(ctx, claimed.Ref, bson.M{"$set": bson.M{"status": claimed.Status}}); err != nil {
+			return nil, fmt.Errorf("failed to resume onboarding: %w", err)
+		}
+		s.logger.Info("Resuming onboarding", zap.String("ref", claimed.Ref), zap.Int("attempt", claimed.Attempts))
+	}
+	return claimed, nil
+}
+
+// releaseOnboarding unlocks an onboarding the attempt did not change.
+func (s *CustomerService) releaseOnboarding(ctx context.Context, ref string) {
+	if err := s.store.UpdateOnboarding(ctx, ref, bson.M{"$unset": bson.M{"lockedUntil": ""}}); err != nil {
+		s.logger.Error("Failed to release onboarding", zap.String("ref", ref), zap.Error(err))
+	}
+}
+
+// completeStep records a completed step of an onboarding with the fields it produced.
+func (s *CustomerService) completeStep(ctx context.Context, onboarding *models.Onboarding, step string, set bson.M) error {
+	now := time.Now().UTC()
+	set["updatedAt"] = now
+	err := s.store.UpdateOnboarding(ctx, onboarding.Ref, bson.M{
+		"$set":  set,
+		"$push": bson.M{"steps": models.OnboardingStep{Name: step, At: now}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record onboarding step %s: %w", step, err)
+	}
+	onboarding.Steps = append(onboarding.Steps, models.OnboardingStep{Name: step, At: now})
+	if id, ok := set["screeningId"].(string); ok {
+		onboarding.ScreeningID = id
+	}
+	if screenedAt, ok := set["screenedAt"].(time.Time); ok {
+		onboarding.ScreenedAt = screenedAt
+	}
+	return nil
+}
+
+// holdOnboarding stops an onboarding that screening blocked or queued for a review.
+func (s *CustomerService) holdOnboarding(ctx context.Context, onboarding *models.Onboarding, screening models.Screening) error {
+	s.logger.Warn("Onboarding stopped by sanctions screening",
+		zap.String("ref", onboarding.Ref),
+		zap.String("screeningID", screening.ID.Hex()),
+		zap.String("result", screening.Result),
+	)
+	status, unset := models.OnboardingInReview, bson.M{"lockedUntil": ""}
+	if screening.Result == models.ScreeningBlocked {
+		status, unset["application"], unset["business"] = models.OnboardingRejected, "", ""
+	}
+	update := bson.M{
+		"$set":   bson.M{"status": status, "screeningId": screening.ID.Hex(), "updatedAt": time.Now().UTC()},
+		"$unset": unset,
+	}
+	if err := s.store.UpdateOnboarding(ctx, onboarding.Ref, update); err != nil {
+		s.logger.Error("Failed to hold onboarding", zap.String("ref", onboarding.Ref), zap.Error(err))
+	}
+	return &ScreeningHoldError{ScreeningID: screening.ID.Hex(), Result: screening.Result}
+}
+
+// failOnboarding records the failure of a step and unlocks the onboarding so
+// a retry resumes it.
+func (s *CustomerService) failOnboarding(ctx context.Context, onboarding *models.Onboarding, step string, cause error) error {
+	err := s.store.UpdateOnboarding(ctx, onboarding.Ref, bson.M{
+		"$set": bson.M{
+			"status":    models.OnboardingFailed,
+			"lastError": fmt.Sprintf("%s: %v", step, cause),
+			"updatedAt": time.Now().UTC(),
+		},
+		"$unset": bson.M{"lockedUntil": ""},
+	})
+	if err != nil {
+		s.logger.Error("Failed to record onboarding failure", zap.String("ref", onboarding.Ref), zap.Error(err))
+	}
+	return fmt.Errorf("onboarding failed at step %s: %w", step, cause)
+}
+
+// GetOnboarding returns the progress of the onboarding of a reference.
+func (s *CustomerService) GetOnboarding(ctx context.Context, ref string) (*models.Onboarding, error) {
+	onboarding, err := s.store.GetOnboarding(ctx, ref)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOnboardingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch onboarding: %w", err)
+	}
+	if onboarding.Steps == nil {
+		onboarding.Steps = []models.OnboardingStep{}
+	}
+	return onboarding, nil
+}
+
+func stepDone(onboarding *models.Onboarding, step string) bool {
+	return slices.ContainsFunc(onboarding.Steps, func(done models.OnboardingStep) bool {
+		return done.Name == step
+	})
+}
+
+func modelDepositChannels(channels []api.DepositChannel) []models.DepositChannel {
+	var result []models.DepositChannel
+	for _, dc := range channels {
+		result = append(result, models.DepositChannel{
+			AccountName:   dc.AccountName,
+			AccountNumber: dc.AccountNumber,
+			BankName:      dc.BankName,
+			BankCode:      dc.BankCode,
+			Type:          dc.Type,
+		})
+	}
+	return result
+}
+
+func apiDepositChannels(channels []models.DepositChannel) []api.DepositChannel {
+	var result []api.DepositChannel
+	for _, dc := range channels {
+		result = append(result, api.DepositChannel{
+			AccountName:   dc.AccountName,
+			AccountNumber: dc.AccountNumber,
+			BankName:      dc.BankName,
+			BankCode:      dc.BankCode,
+			Type:          dc.Type,
+		})
+	}
+	return result
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrOperationPending is returned when an issuer operation has not completed yet; the dispatcher retries it.
+	ErrOperationPending = errors.New("issuer operation pending")
+	// ErrOperationFailed is returned when an issuer operation failed after its last attempt.
+	ErrOperationFailed = errors.New("issuer operation failed")
+	// ErrOperationNotFound is returned when no outbox operation exists with the given ID.
+	ErrOperationNotFound = errors.New("outbox operation not found")
+	// ErrOperationConflict is wrapped by Complete errors when the local state no
+	// longer admits the result; the operation fails instead of being retried.
+	ErrOperationConflict = errors.New("issuer operation conflicts with local state")
+)
+
+// OperationPendingError is returned when the issuer call of an operation did
+// not complete in the request that submitted it. It wraps ErrOperationPending.
+type OperationPendingError struct {
+	OperationID string
+	Cause       string
+}
+
+func (e *OperationPendingError) Error() string {
+	if e.Cause == "" {
+		return ErrOperationPending.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrOperationPending, e.Cause)
+}
+
+func (e *OperationPendingError) Unwrap() error {
+	return ErrOperationPending
+}
+
+// outboxLease is how long an attempt holds an operation. An operation whose
+// attempt died is picked up again once its lease ran out.
+const outboxLease = time.Minute
+
+// outboxBatch is how many due operations the dispatcher runs per tick.
+const outboxBatch = 50
+
+// OutboxHandler executes the operations of one type and writes their results back.
+type OutboxHandler struct {
+	// Execute makes the issuer call. Its result must marshal to a BSON document.
+	Execute func(ctx context.Context, op models.OutboxOperation) (interface{}, error)
+	// Complete writes the result back, in the transaction that completes the
+	// operation. An error wrapping ErrOperationConflict fails the operation.
+	Complete func(ctx context.Context, op models.OutboxOperation, result bson.Raw) error
+	// Fail, if set, undoes the local change once the operation gave up, in the
+	// transaction that fails it.
+	Fail func(ctx context.Context, op models.OutboxOperation, cause string) error
+}
+
+// OutboxConfig tunes the retries of the outbox dispatcher.
+type OutboxConfig struct {
+	MaxAttempts int           // Attempts before an operation is failed
+	Backoff     time.Duration // Delay before the first retry, doubled on each one after
+	Secret      string        // Secret the issuer requests are encrypted with at rest
+}
+
+// OutboxService records issuer calls in the transaction of the local change
+// that needs them and executes them until they complete, so a crash between
+// the two never loses or repeats a call.
+type OutboxService struct {
+	store    *store.Store
+	config   OutboxConfig
+	aead     cipher.AEAD // Seals the payloads, which may hold a PAN, CVV or PIN
+	mu       sync.RWMutex
+	handlers map[string]OutboxHandler
+	logger   *zap.Logger
+}
+
+// NewOutboxService initializes an OutboxService with the provided store, retry config and logger.
+func NewOutboxService(store *store.Store, config OutboxConfig, logger *zap.Logger) *OutboxService {
+	key := sha256.Sum256([]byte(config.Secret))
+	// Neither call fails for a 256-bit AES key
+	block, _ := aes.NewCipher(key[:])
+	aead, _ := cipher.NewGCM(block)
+	return &OutboxService{store: store, config: config, aead: aead, handlers: map[string]OutboxHandler{}, logger: logger}
+}
+
+// seal encrypts a payload and authenticates it together with the key of its
+// operation, so a sealed payload cannot be moved to another operation.
+func (s *OutboxService) seal(key string, payload []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(payload)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, payload, []byte(key)), nil
+}
+
+// open decrypts the payload of an operation sealed by seal.
+func (s *OutboxService) open(key string, sealed []byte) ([]byte, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("sealed payload too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	return s.aead.Open(nil, nonce, ciphertext, []byte(key))
+}
+
+// Register sets the handler of an operation type.
+func (s *OutboxService) Register(opType string, handler OutboxHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[opType] = handler
+}
+
+func (s *OutboxService) handler(opType string) (OutboxHandler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	handler, ok := s.handlers[opType]
+	return handler, ok
+}
+
+// Submit records an operation and runs local, the change that needs it, in
+// one transaction. The key makes submitting idempotent: when an operation
+// with the key exists it is returned and local does not run. A failed
+// operation is queued again, since submitting it anew is a retry.
+func (s *OutboxService) Submit(ctx context.Context, opType, key, subject string, payload interface{}, local func(sc mongo.SessionContext) error) (*models.OutboxOperation, error) {
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", opType, err)
+	}
+	sealed, err := s.seal(key, raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s payload: %w", opType, err)
+	}
+	now := time.Now().UTC()
+	op := models.OutboxOperation{
+		ID:            primitive.NewObjectID(),
+		Type:          opType,
+		Key:           key,
+		Subject:       subject,
+		Status:        models.OutboxPending,
+		Payload:       sealed,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	var existing *models.OutboxOperation
+	err = s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		existing, err = s.store.InsertOutboxOperation(sc, op)
+		if err != nil {
+			return fmt.Errorf("failed to store outbox operation: %w", err)
+		}
+		if existing != nil || local == nil {
+			return nil
+		}
+		return local(sc)
+	})
+	if err != nil {
+		s.logger.Error("Failed to submit outbox operation", zap.String("type", opType), zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+	if existing == nil {
+		s.logger.Info("Submitted outbox operation", zap.String("type", opType), zap.String("id", op.ID.Hex()), zap.String("subject", subject))
+		return &op, nil
+	}
+
+	if existing.Status == models.OutboxFailed {
+		err := s.store.UpdateOutboxOperation(ctx, existing.ID, bson.M{
+			"$set": bson.M{
+				"status":        models.OutboxPending,
+				"payload":       sealed,
+				"attempts":      0,
+				"nextAttemptAt": now,
+				"updatedAt":     now,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to requeue outbox operation: %w", err)
+		}
+		existing.Status, existing.Payload, existing.Attempts, existing.NextAttemptAt = models.OutboxPending, sealed, 0, now
+		s.logger.Info("Requeued failed outbox operation", zap.String("type", opType), zap.String("id", existing.ID.Hex()))
+	}
+	return existing, nil
+}
+
+// Dispatch runs an attempt of an operation now, whatever its backoff, and
+// returns it once settled. It returns an *OperationPendingError when the
+// attempt failed or another attempt holds the operation, an error wrapping
+// ErrOperationFailed once it gave up, and the error of Complete when that
+// wraps ErrOperationConflict.
+func (s *OutboxService) Dispatch(ctx context.Context, id primitive.ObjectID) (*models.OutboxOperation, error) {
+	now := time.Now().UTC()
+	op, err := s.store.ClaimOutboxOperation(ctx, id, now, now.Add(outboxLease))
+	if err == mongo.ErrNoDocuments {
+		return s.settled(ctx, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox operation: %w", err)
+	}
+
+	handler, ok := s.handler(op.Type)
+	if !ok {
+		return s.fail(ctx, op, OutboxHandler{}, fmt.Sprintf("no handler for operation type %s", op.Type))
+	}
+	// Handlers see the issuer request in the clear; only this copy holds it
+	if op.Payload, err = s.open(op.Key, op.Payload); err != nil {
+		return s.fail(ctx, op, handler, fmt.Sprintf("failed to decrypt payload: %v", err))
+	}
+	result, err := handler.Execute(api.WithIdempotencyKey(ctx, op.Key), *op)
+	if err != nil {
+		s.logger.Warn("Outbox operation attempt failed",
+			zap.String("type", op.Type),
+			zap.String("id", op.ID.Hex()),
+			zap.Int("attempt", op.Attempts),
+			zap.Error(err),
+		)
+		return s.retry(ctx, op, handler, err.Error())
+	}
+	raw, err := bson.Marshal(result)
+	if err != nil {
+		return s.fail(ctx, op, handler, fmt.Sprintf("failed to encode result: %v", err))
+	}
+
+	completedAt := time.Now().UTC()
+	err = s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := handler.Complete(sc, *op, raw); err != nil {
+			return err
+		}
+		// The payload may hold card secrets; it is not kept once the operation settles
+		return s.store.UpdateOutboxOperation(sc, op.ID, bson.M{
+			"$set": bson.M{
+				"status":      models.OutboxCompleted,
+				"result":      raw,
+				"completedAt": completedAt,
+				"updatedAt":   completedAt,
+			},
+			"$unset": bson.M{"payload": "", "lockedUntil": "", "lastError": ""},
+		})
+	})
+	if errors.Is(err, ErrOperationConflict) {
+		// Retrying cannot apply a result the local state no longer admits
+		if _, ferr := s.fail(ctx, op, handler, err.Error()); !errors.Is(ferr, ErrOperationFailed) {
+			return op, ferr
+		}
+		return op, err
+	}
+	if err != nil {
+		// The issuer applies the retried call once, by its idempotency key
+		s.logger.Error("Failed to record outbox result", zap.String("type", op.Type), zap.String("id", op.ID.Hex()), zap.Error(err))
+		return s.retry(ctx, op, handler, fmt.Sprintf("failed to record result: %v", err))
+	}
+	s.logger.Info("Completed outbox operation", zap.String("type", op.Type), zap.String("id", op.ID.Hex()), zap.Int("attempts", op.Attempts))
+	op.Status, op.Result, op.Payload, op.CompletedAt, op.LockedUntil, op.LastError = models.OutboxCompleted, raw, nil, &completedAt, nil, ""
+	return op, nil
+}
+
+// settled returns an operation this attempt could not claim.
+func (s *OutboxService) settled(ctx context.Context, id primitive.ObjectID) (*models.OutboxOperation, error) {
+	op, err := s.store.GetOutboxOperation(ctx, id)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOperationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch outbox operation: %w", err)
+	}
+	switch op.Status {
+	case models.OutboxCompleted:
+		return op, nil
+	case models.OutboxFailed:
+		return op, fmt.Errorf("%w: %s", ErrOperationFailed, op.LastError)
+	}
+	return op, &OperationPendingError{OperationID: op.ID.Hex(), Cause: op.LastError}
+}
+
+// retry schedules the next attempt of an operation, doubling the backoff on
+// each one, or fails it after its last attempt.
+func (s *OutboxService) retry(ctx context.Context, op *models.OutboxOperation, handler OutboxHandler, cause string) (*models.OutboxOperation, error) {
+	if op.Attempts >= s.config.MaxAttempts {
+		return s.fail(ctx, op, handler, cause)
+	}
+	now := time.Now().UTC()
+	next := now.Add(s.config.Backoff << (op.Attempts - 1))
+	err := s.store.UpdateOutboxOperation(ctx, op.ID, bson.M{
+		"$set":   bson.M{"status": models.OutboxPending, "lastError": cause, "nextAttemptAt": next, "updatedAt": now},
+		"$unset": bson.M{"lockedUntil": ""},
+	})
+	if err != nil {
+		// The lease runs out and the dispatcher picks the operation up again
+		s.logger.Error("Failed to schedule outbox retry", zap.String("id", op.ID.Hex()), zap.Error(err))
+	}
+	op.Status, op.LastError, op.NextAttemptAt, op.LockedUntil = models.OutboxPending, cause, next, nil
+	return op, &OperationPendingError{OperationID: op.ID.Hex(), Cause: cause}
+}
+
+// fail gives up on an operation and undoes its local change in one transaction.
+func (s *OutboxService) fail(ctx context.Context, op *models.OutboxOperation, handler OutboxHandler, cause string) (*models.OutboxOperation, error) {
+	now := time.Now().UTC()
+	err := s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if handler.Fail != nil {
+			if err := handler.Fail(sc, *op, cause); err != nil {
+				return err
+			}
+		}
+		// A retry submits the payload anew, so the secrets it may hold are not kept
+		return s.store.UpdateOutboxOperation(sc, op.ID, bson.M{
+			"$set":   bson.M{"status": models.OutboxFailed, "lastError": cause, "updatedAt": now},
+			"$unset": bson.M{"payload": "", "lockedUntil": ""},
+		})
+	})
+	if err != nil {
+		s.logger.Error("Failed to record outbox failure", zap.String("id", op.ID.Hex()), zap.Error(err))
+		return op, fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+	s.logger.Error("Outbox operation failed",
+		zap.String("type", op.Type),
+		zap.String("id", op.ID.Hex()),
+		zap.String("subject", op.Subject),
+		zap.Int("attempts", op.Attempts),
+		zap.String("cause", cause),
+	)
+	op.Status, op.LastError, op.Payload, op.LockedUntil = models.OutboxFailed, cause, nil, nil
+	return op, fmt.Errorf("%w: %s", ErrOperationFailed, cause)
+}
+
+// RunDispatcher dispatches the due operations at the given interval until
+// ctx is cancelled. It also resumes the operations of attempts that died
+// with the process, once their lease ran out.
+func (s *OutboxService) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			completed, err := s.DispatchDue(ctx, now.UTC())
+			if err != nil {
+				s.logger.Error("Failed to dispatch outbox operations", zap.Error(err))
+				continue
+			}
+			if completed > 0 {
+				s.logger.Info("Dispatched outbox operations", zap.Int("completed", completed))
+			}
+		}
+	}
+}
+
+// DispatchDue runs an attempt of each operation due at now and returns how many completed.
+func (s *OutboxService) DispatchDue(ctx context.Context, now time.Time) (int, error) {
+	ids, err := s.store.ListDueOutboxOperations(ctx, now, outboxBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due outbox operations: %w", err)
+	}
+	completed := 0
+	for _, id := range ids {
+		op, err := s.Dispatch(ctx, id)
+		if err != nil && !errors.Is(err, ErrOperationPending) && !errors.Is(err, ErrOperationFailed) {
+			s.logger.Error("Failed to dispatch outbox operation", zap.String("id", id.Hex()), zap.Error(err))
+			continue
+		}
+		if err == nil && op.Status == models.OutboxCompleted {
+			completed++
+		}
+	}
+	return completed, nil
+}
+
+// OutboxFilter narrows the operations listed.
+type OutboxFilter struct {
+	Type    string
+	Status  string
+	Subject string
+}
+
+// ListOperations returns a page of outbox operations, newest first.
+func (s *OutboxService) ListOperations(ctx context.Context, filter OutboxFilter, page, limit int64) ([]models.OutboxOperation, error) {
+	query := bson.M{}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Subject != "" {
+		query["subject"] = filter.Subject
+	}
+	ops, err := s.store.ListOutboxOperations(ctx, query, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list outbox operations", zap.Error(err))
+		return nil, fmt.Errorf("failed to list outbox operations: %w", err)
+	}
+	return ops, nil
+}
+
+// GetOperation returns a single outbox operation.
+func (s *OutboxService) GetOperation(ctx context.Context, id string) (*models.OutboxOperation, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrOperationNotFound
+	}
+	op, err := s.store.GetOutboxOperation(ctx, objectID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOperationNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to fetch outbox operation", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch outbox operation: %w", err)
+	}
+	return op, nil
+}
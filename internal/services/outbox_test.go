@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bytes"
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/simulator"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// testStore connects to the MongoDB replica set in MONGODB_TEST_URI, which
+// transactions need, and returns a store on a database of its own that is
+// dropped when the test ends. The test is skipped when the variable is unset.
+func testStore(t *testing.T) *store.Store {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	s, err := store.NewStore(uri, fmt.Sprintf("card_service_test_%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Db.Drop(context.Background())
+		s.Close()
+	})
+	return s
+}
+
+// testIssuer returns a simulator holding a customer with a sub account, and
+// stores the customer.
+func testIssuer(t *testing.T, db *store.Store, config simulator.Config) (*simulator.Simulator, string, string) {
+	t.Helper()
+	ctx := context.Background()
+	sim := simulator.New(config, zap.NewNop())
+	customerID, err := sim.CreateCustomer(ctx, api.CreateCustomerRequest{
+		Name:   "Ada Obi",
+		Claims: api.CustomerClaims{IndividualInformation: &api.IndividualInformation{}},
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	accountID, _, err := sim.CreateSubAccount(ctx, api.CreateSubAccountRequest{Customer: customerID, Name: "Ada Obi"})
+	if err != nil {
+		t.Fatalf("CreateSubAccount: %v", err)
+	}
+	_, err = db.Customers.InsertOne(ctx, models.Customer{
+		CustomerID: customerID,
+		Name:       "Ada Obi",
+		Email:      "ada@example.com",
+		AccountID:  accountID,
+		Tier:       "tier-3",
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("failed to store customer: %v", err)
+	}
+	return sim, customerID, accountID
+}
+
+func TestOutboxSealsPayloads(t *testing.T) {
+	outbox := NewOutboxService(nil, OutboxConfig{Secret: "secret"}, zap.NewNop())
+	payload, err := bson.Marshal(activateCardPayload{CardID: "card_1", Request: api.ActivateCardRequest{Cvv: "123", Pin: "4321"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := outbox.seal("activate-card:card_1", payload)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("4321")) || bytes.Contains(sealed, []byte("pin")) {
+		t.Fatalf("sealed payload holds the request in the clear: %q", sealed)
+	}
+	opened, err := outbox.open("activate-card:card_1", sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !bytes.Equal(opened, payload) {
+		t.Fatalf("opened payload differs from the sealed one")
+	}
+
+	if _, err := outbox.open("activate-card:card_2", sealed); err == nil {
+		t.Error("payload opened under the key of another operation")
+	}
+	other := NewOutboxService(nil, OutboxConfig{Secret: "other"}, zap.NewNop())
+	if _, err := other.open("activate-card:card_1", sealed); err == nil {
+		t.Error("payload opened with another secret")
+	}
+}
+
+func TestOutboxRecoversAfterCrash(t *testing.T) {
+	tests := []struct {
+		name        string
+		issuerCalls bool // Whether the issuer applied the call before the crash
+	}{
+		{name: "crash before the issuer call"},
+		{name: "crash after the issuer call", issuerCalls: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testStore(t)
+			sim, customerID, accountID := testIssuer(t, db, simulator.Config{})
+			outbox := NewOutboxService(db, OutboxConfig{MaxAttempts: 3, Backoff: time.Second, Secret: "secret"}, zap.NewNop())
+			cards := NewCardService(db, sim, nil, NewKYCService(db, nil, zap.NewNop()), outbox, zap.NewNop())
+
+			// The card and its operation are stored together; the process
+			// dies before the dispatcher settles the operation
+			req := api.LinkCardRequest{Pan: "5399000011112222", Customer: customerID, FundingSource: accountID}
+			card := models.Card{ID: primitive.NewObjectID(), CustomerID: customerID, Status: models.CardLinking}
+			op, err := outbox.Submit(ctx, models.OpLinkCard, "link-card:"+card.ID.Hex(), card.ID.Hex(), req, func(sc mongo.SessionContext) error {
+				_, err := db.Cards.InsertOne(sc, card)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Submit: %v", err)
+			}
+			var stored models.OutboxOperation
+			if err := db.Outbox.FindOne(ctx, bson.M{"_id": op.ID}).Decode(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(stored.Payload, []byte(req.Pan)) {
+				t.Fatal("stored payload holds the PAN in the clear")
+			}
+
+			// The dead attempt started a lease ago, so its lease runs out now
+			now := time.Now().UTC()
+			claimed, err := db.ClaimOutboxOperation(ctx, op.ID, now.Add(-outboxLease), now)
+			if err != nil {
+				t.Fatalf("ClaimOutboxOperation: %v", err)
+			}
+			var linkedID string
+			if tt.issuerCalls {
+				linked, err := sim.LinkCard(api.WithIdempotencyKey(ctx, claimed.Key), req)
+				if err != nil {
+					t.Fatalf("LinkCard: %v", err)
+				}
+				linkedID = linked.Data.ID
+			}
+
+			// Nothing is due while the dead attempt holds its lease
+			if completed, err := outbox.DispatchDue(ctx, now.Add(-time.Second)); err != nil || completed != 0 {
+				t.Fatalf("DispatchDue under lease = %d, %v; want 0", completed, err)
+			}
+			completed, err := outbox.DispatchDue(ctx, time.Now().UTC())
+			if err != nil || completed != 1 {
+				t.Fatalf("DispatchDue after lease = %d, %v; want 1", completed, err)
+			}
+
+			if err := db.Outbox.FindOne(ctx, bson.M{"_id": op.ID}).Decode(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != models.OutboxCompleted || stored.Attempts != 2 || stored.Payload != nil {
+				t.Errorf("operation = %s after %d attempts with payload %v; want completed after 2 without payload", stored.Status, stored.Attempts, stored.Payload != nil)
+			}
+			var linked models.Card
+			if err := db.Cards.FindOne(ctx, bson.M{"_id": card.ID}).Decode(&linked); err != nil {
+				t.Fatal(err)
+			}
+			if linked.CardID == "" || linked.Status != models.CardInactive {
+				t.Errorf("card = %q %s; want a linked inactive card", linked.CardID, linked.Status)
+			}
+			if tt.issuerCalls && linked.CardID != linkedID {
+				t.Errorf("card linked again as %s; want the issuer's %s", linked.CardID, linkedID)
+			}
+			if _, err := cards.getCard(ctx, linked.CardID); err != nil {
+				t.Errorf("getCard: %v", err)
+			}
+		})
+	}
+}
+
+func TestOutboxFailsSupersededCardStatus(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{})
+	outbox := NewOutboxService(db, OutboxConfig{MaxAttempts: 3, Backoff: time.Second, Secret: "secret"}, zap.NewNop())
+	NewCardService(db, sim, nil, NewKYCService(db, nil, zap.NewNop()), outbox, zap.NewNop())
+
+	linked, err := sim.LinkCard(ctx, api.LinkCardRequest{Pan: "5399000011112222", Customer: customerID, FundingSource: accountID})
+	if err != nil {
+		t.Fatalf("LinkCard: %v", err)
+	}
+	cardID := linked.Data.ID
+	if _, err := db.Cards.InsertOne(ctx, models.Card{CardID: cardID, CustomerID: customerID, Status: models.CardActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A termination completes between the freeze's submission and its completion
+	payload := cardStatusPayload{
+		CardID:  cardID,
+		From:    cardStates[cardFreeze].from,
+		Request: api.UpdateCardStatusRequest{Status: models.CardFrozen},
+	}
+	op, err := outbox.Submit(ctx, models.OpUpdateCardStatus, "card-status:"+cardID+":frozen", cardID, payload, nil)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if _, err := db.Cards.UpdateOne(ctx, bson.M{"cardId": cardID}, bson.M{"$set": bson.M{"status": models.CardTerminated}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := outbox.Dispatch(ctx, op.ID); !errors.Is(err, ErrInvalidCardTransition) {
+		t.Fatalf("Dispatch error = %v; want %v", err, ErrInvalidCardTransition)
+	}
+	var stored models.OutboxOperation
+	if err := db.Outbox.FindOne(ctx, bson.M{"_id": op.ID}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.OutboxFailed || stored.Attempts != 1 {
+		t.Errorf("operation = %s after %d attempts; want failed after 1", stored.Status, stored.Attempts)
+	}
+	var card models.Card
+	if err := db.Cards.FindOne(ctx, bson.M{"cardId": cardID}).Decode(&card); err != nil {
+		t.Fatal(err)
+	}
+	if card.Status != models.CardTerminated || len(card.History) != 0 {
+		t.Errorf("card = %s with %d status changes; want terminated with none", card.Status, len(card.History))
+	}
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/rules"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrRuleNotFound is returned when no rule exists with the given ID.
+	ErrRuleNotFound = errors.New("rule not found")
+	// ErrInvalidRule is returned when a rule fails validation.
+	ErrInvalidRule = errors.New("invalid rule")
+	// ErrRuleConflict is returned when a rule was changed by someone else.
+	ErrRuleConflict = errors.New("rule was changed concurrently")
+)
+
+// defaultRules are the authorization checks every program starts with. They
+// are stored like any other rule so they can be changed without a release.
+var defaultRules = []models.Rule{
+	{RuleID: "card-status", Name: "Card is not active", Priority: 100, Expression: `card.status != "active"`, Outcome: models.RuleDecline, Code: "account-inactive"},
+	{RuleID: "temporary-block", Name: "Card temporarily blocked", Priority: 150, Expression: `controls.temporarilyBlocked`, Outcome: models.RuleDecline, Code: "card-blocked"},
+	{RuleID: "balance", Name: "Insufficient funds", Priority: 200, Expression: `event.total > 0 && event.total > balance.available`, Outcome: models.RuleDecline, Code: "insufficient-funds"},
+	{RuleID: "kyc-balance", Name: "Balance above KYC tier maximum", Priority: 250, Expression: `kyc.maxBalance > 0 && balance.issuer > kyc.maxBalance`, Outcome: models.RuleDecline, Code: "kyc-balance-limit"},
+	{RuleID: "channel", Name: "Channel not allowed", Priority: 300, Expression: `!controls.channelAllowed`, Outcome: models.RuleDecline, Code: "spending-control"},
+	{RuleID: "schedule", Name: "Outside allowed schedule", Priority: 350, Expression: `!controls.scheduleAllowed`, Outcome: models.RuleDecline, Code: "schedule-control"},
+	{RuleID: "country", Name: "Country blocked", Priority: 360, Expression: `controls.countryBlocked`, Outcome: models.RuleDecline, Code: "country-control"},
+	{RuleID: "travel-notice", Name: "Country not allowed without a travel notice", Priority: 370, Expression: `!controls.countryAllowed && !controls.travelNotice`, Outcome: models.RuleDecline, Code: "no-travel-notice"},
+	{RuleID: "merchant", Name: "Merchant not allowed", Priority: 400, Expression: `!controls.merchantAllowed`, Outcome: models.RuleDecline, Code: "merchant-control"},
+	{RuleID: "category", Name: "Merchant category not allowed", Priority: 500, Expression: `!controls.categoryAllowed`, Outcome: models.RuleDecline, Code: "category-control"},
+	{RuleID: "spending-limits", Name: "Spending limit exceeded", Priority: 600, Expression: `limits.exceeded`, Outcome: models.RuleDecline, Code: "spending-limit"},
+	{RuleID: "kyc-daily-spend", Name: "KYC tier daily spend exceeded", Priority: 650, Expression: `kyc.dailyLimit > 0 && kyc.dailySpend + event.amount > kyc.dailyLimit`, Outcome: models.RuleDecline, Code: "kyc-spend-limit"},
+	{RuleID: "fraud-risk", Name: "Fraud risk too high", Priority: 700, Expression: `risk.action == "decline"`, Outcome: models.RuleDecline, Code: "fraud-risk"},
+	{RuleID: "fraud-review", Name: "Fraud risk needs review", Priority: 710, Expression: `risk.action == "flag"`, Outcome: models.RuleFlag},
+}
+
+// supersededDefaults are earlier expressions of default rules. A default rule
+// still on one of them is moved to the current default when seeding; rules
+// changed since they were seeded are left alone.
+var supersededDefaults = map[string][]string{
+	// Only captures were checked, so purchases could overdraw the account
+	"balance": {`event.type == "capture" && event.total > balance.available`},
+}
+
+// compiledRule is a rule with its parsed expression.
+type compiledRule struct {
+	models.Rule
+	expression *rules.Expression
+	invalid    error // Why a stored rule no longer compiles; it then fails closed
+}
+
+// appliesTo reports whether the rule is in scope for a card.
+func (r *compiledRule) appliesTo(card models.Card) bool {
+	switch r.Scope {
+	case models.RuleScopeProgram:
+		return r.ScopeID == card.Program
+	case models.RuleScopeCard:
+		return r.ScopeID == card.CardID
+	}
+	return true
+}
+
+// RuleEvaluation is the outcome of evaluating one rule.
+type RuleEvaluation struct {
+	RuleID  string `json:"ruleId"`
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RuleResult is the decision of a rule set for an authorization.
+type RuleResult struct {
+	Outcome     string           `json:"outcome"`          // approve or decline
+	Code        string           `json:"code,omitempty"`   // Decline code of the deciding rule
+	RuleID      string           `json:"ruleId,omitempty"` // Rule that decided, empty when no rule matched
+	Flags       []string         `json:"flags,omitempty"`  // Flag rules that matched
+	Evaluations []RuleEvaluation `json:"evaluations"`
+}
+
+// RuleService stores authorization rules and evaluates the current ones.
+// Rules are kept in memory and reloaded periodically so changes made on any
+// instance take effect without a restart.
+type RuleService struct {
+	store  *store.Store
+	logger *zap.Logger
+
+	mu     sync.RWMutex
+	rules  []*compiledRule // Enabled current live rules in evaluation order
+	shadow []*compiledRule // Candidate policy: the live rules with the shadow versions in their place; nil without shadow versions
+}
+
+// NewRuleService creates a new rule service.
+func NewRuleService(store *store.Store, logger *zap.Logger) *RuleService {
+	return &RuleService{store: store, logger: logger}
+}
+
+// SeedDefaults stores the default rules that do not exist yet and upgrades
+// the default rules still on a superseded expression.
+func (s *RuleService) SeedDefaults(ctx context.Context) error {
+	for _, rule := range defaultRules {
+		rule.Version = 1
+		rule.Scope = models.RuleScopeGlobal
+		rule.Enabled = true
+		rule.CreatedBy = "system"
+		rule.CreatedAt = time.Now().UTC()
+		err := s.store.InsertRuleVersion(ctx, rule)
+		if mongo.IsDuplicateKeyError(err) {
+			err = s.upgradeDefault(ctx, rule)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to seed rule %s: %w", rule.RuleID, err)
+		}
+	}
+	return s.Reload(ctx)
+}
+
+// upgradeDefault stores the default version of a rule whose current live
+// version is a superseded default expression.
+func (s *RuleService) upgradeDefault(ctx context.Context, rule models.Rule) error {
+	superseded := supersededDefaults[rule.RuleID]
+	if len(superseded) == 0 {
+		return nil
+	}
+	versions, err := s.store.ListRuleVersions(ctx, rule.RuleID)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if !version.Current || version.Shadow {
+			continue
+		}
+		if version.CreatedBy != "system" || !slices.Contains(superseded, version.Expression) {
+			return nil
+		}
+		rule.Version = versions[0].Version + 1
+		rule.Enabled = version.Enabled
+		err := s.store.InsertRuleVersion(ctx, rule)
+		if mongo.IsDuplicateKeyError(err) {
+			// Another instance upgraded it first
+			return nil
+		}
+		if err == nil {
+			s.logger.Info("Upgraded default rule", zap.String("ruleID", rule.RuleID), zap.Int("version", rule.Version))
+		}
+		return err
+	}
+	return nil
+}
+
+// Reload replaces the in-memory rules with the current rules in the store.
+// Rules that no longer compile are kept and fail closed when evaluated.
+func (s *RuleService) Reload(ctx context.Context) error {
+	stored, err := s.store.ListCurrentRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+	live := make([]*compiledRule, 0, len(stored))
+	var candidates []*compiledRule
+	for _, rule := range stored {
+		if !rule.Enabled && !rule.Shadow {
+			continue
+		}
+		c, err := compileRule(rule)
+		if err != nil {
+			s.logger.Error("Rule does not compile and fails closed", zap.String("ruleID", rule.RuleID), zap.Int("version", rule.Version), zap.Error(err))
+			c = &compiledRule{Rule: rule, invalid: err}
+		}
+		if rule.Shadow {
+			candidates = append(candidates, c)
+		} else {
+			live = append(live, c)
+		}
+	}
+	sortRules(live)
+	var shadow []*compiledRule
+	if len(candidates) > 0 {
+		shadow = overlayRules(live, candidates)
+	}
+
+	s.mu.Lock()
+	s.rules = live
+	s.shadow = shadow
+	s.mu.Unlock()
+	return nil
+}
+
+// RunReload reloads the rules at the given interval until ctx is cancelled.
+func (s *RuleService) RunReload(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				s.logger.Error("Failed to reload rules", zap.Error(err))
+			}
+		}
+	}
+}
+
+// current returns the rules currently in force.
+func (s *RuleService) current() []*compiledRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules
+}
+
+// candidate returns the candidate policy, or nil when no rule has a shadow version.
+func (s *RuleService) candidate() []*compiledRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shadow
+}
+
+// ListRules returns the current version of every rule.
+func (s *RuleService) ListRules(ctx context.Context) ([]models.Rule, error) {
+	return s.store.ListCurrentRules(ctx)
+}
+
+// RuleVersions returns every version of a rule, newest first.
+func (s *RuleService) RuleVersions(ctx context.Context, ruleID string) ([]models.Rule, error) {
+	versions, err := s.store.ListRuleVersions(ctx, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rule versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, ErrRuleNotFound
+	}
+	return versions, nil
+}
+
+// CreateRule stores the first version of a new rule.
+func (s *RuleService) CreateRule(ctx context.Context, rule models.Rule) (models.Rule, error) {
+	rule.Version = 1
+	return s.saveRule(ctx, rule)
+}
+
+// UpdateRule stores a new live or shadow version of a rule. expectedVersion
+// must be the latest version of the rule when the change was made.
+func (s *RuleService) UpdateRule(ctx context.Context, ruleID string, expectedVersion int, rule models.Rule) (models.Rule, error) {
+	versions, err := s.RuleVersions(ctx, ruleID)
+	if err != nil {
+		return models.Rule{}, err
+	}
+	if versions[0].Version != expectedVersion {
+		return models.Rule{}, ErrRuleConflict
+	}
+	rule.RuleID = ruleID
+	rule.Version = expectedVersion + 1
+	return s.saveRule(ctx, rule)
+}
+
+func (s *RuleService) saveRule(ctx context.Context, rule models.Rule) (models.Rule, error) {
+	if _, err := compileRule(rule); err != nil {
+		return models.Rule{}, err
+	}
+	rule.ID = primitive.NilObjectID
+	rule.Current = true
+	rule.CreatedAt = time.Now().UTC()
+	err := s.store.InsertRuleVersion(ctx, rule)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Rule{}, ErrRuleConflict
+	}
+	if err != nil {
+		s.logger.Error("Failed to store rule", zap.String("ruleID", rule.RuleID), zap.Error(err))
+		return models.Rule{}, fmt.Errorf("failed to store rule: %w", err)
+	}
+	s.logger.Info("Stored rule",
+		zap.String("ruleID", rule.RuleID),
+		zap.Int("version", rule.Version),
+		zap.Bool("enabled", rule.Enabled),
+	)
+	if err := s.Reload(ctx); err != nil {
+		s.logger.Error("Failed to reload rules", zap.Error(err))
+	}
+	return rule, nil
+}
+
+// compileRule validates a rule and parses its expression.
+func compileRule(rule models.Rule) (*compiledRule, error) {
+	if rule.RuleID == "" || rule.Name == "" {
+		return nil, fmt.Errorf("%w: ruleId and name are required", ErrInvalidRule)
+	}
+	switch rule.Scope {
+	case models.RuleScopeGlobal:
+	case models.RuleScopeProgram, models.RuleScopeCard:
+		if rule.ScopeID == "" {
+			return nil, fmt.Errorf("%w: scopeId is required for %s rules", ErrInvalidRule, rule.Scope)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidRule, rule.Scope)
+	}
+	switch rule.Outcome {
+	case models.RuleApprove, models.RuleFlag:
+	case models.RuleDecline:
+		if rule.Code == "" {
+			return nil, fmt.Errorf("%w: decline rules need a code", ErrInvalidRule)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown outcome %q", ErrInvalidRule, rule.Outcome)
+	}
+	expression, err := rules.Compile(rule.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return &compiledRule{Rule: rule, expression: expression}, nil
+}
+
+// sortRules orders rules by priority, then by ID so the order is stable.
+func sortRules(set []*compiledRule) {
+	sort.SliceStable(set, func(i, j int) bool {
+		if set[i].Priority != set[j].Priority {
+			return set[i].Priority < set[j].Priority
+		}
+		return set[i].RuleID < set[j].RuleID
+	})
+}
+
+// Evaluate evaluates the rules in force against the facts of an authorization.
+func (s *RuleService) Evaluate(card models.Card, facts rules.Env) (RuleResult, error) {
+	return evaluateRules(s.current(), card, facts)
+}
+
+// EvaluateCandidates evaluates the rules in force with candidate rules in
+// place of the current versions of the same rules. Nothing is stored.
+func (s *RuleService) EvaluateCandidates(card models.Card, facts rules.Env, candidates []models.Rule) (RuleResult, error) {
+	compiled := make([]*compiledRule, 0, len(candidates))
+	for _, candidate := range candidates {
+		c, err := compileRule(candidate)
+		if err != nil {
+			return RuleResult{}, fmt.Errorf("rule %s: %w", candidate.RuleID, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return evaluateRules(overlayRules(s.current(), compiled), card, facts)
+}
+
+// EvaluateShadow evaluates the candidate policy. It reports false when no
+// rule has a shadow version.
+func (s *RuleService) EvaluateShadow(card models.Card, facts rules.Env) (RuleResult, bool, error) {
+	set := s.candidate()
+	if set == nil {
+		return RuleResult{}, false, nil
+	}
+	result, err := evaluateRules(set, card, facts)
+	return result, true, err
+}
+
+// overlayRules returns the live rules with candidates in place of the rules
+// with the same ID. Disabled candidates remove the rule.
+func overlayRules(live, candidates []*compiledRule) []*compiledRule {
+	byID := make(map[string]*compiledRule, len(live)+len(candidates))
+	for _, rule := range live {
+		byID[rule.RuleID] = rule
+	}
+	for _, candidate := range candidates {
+		if !candidate.Enabled {
+			delete(byID, candidate.RuleID)
+			continue
+		}
+		byID[candidate.RuleID] = candidate
+	}
+	set := make([]*compiledRule, 0, len(byID))
+	for _, rule := range byID {
+		set = append(set, rule)
+	}
+	sortRules(set)
+	return set
+}
+
+// evaluateRules evaluates rules in order. The first approve or decline rule
+// that matches decides; flag rules are collected along the way. An
+// authorization no rule decides is approved. Rules whose expression fails
+// fail closed: decline and flag rules count as matched, approve rules do not.
+// Facts that fail to load fail the evaluation.
+func evaluateRules(set []*compiledRule, card models.Card, facts rules.Env) (RuleResult, error) {
+	result := RuleResult{Outcome: models.RuleApprove, Evaluations: []RuleEvaluation{}}
+	for _, rule := range set {
+		if !rule.appliesTo(card) {
+			continue
+		}
+		evaluation := RuleEvaluation{
+			RuleID:  rule.RuleID,
+			Version: rule.Version,
+			Name:    rule.Name,
+			Outcome: rule.Outcome,
+		}
+		matched, err := false, rule.invalid
+		if err == nil {
+			matched, err = rule.expression.Match(facts)
+		}
+		if rules.IsFactError(err) {
+			return result, err
+		}
+		if err != nil {
+			evaluation.Error = err.Error()
+			matched = rule.Outcome != models.RuleApprove
+		}
+		evaluation.Matched = matched
+		result.Evaluations = append(result.Evaluations, evaluation)
+		if !matched {
+			continue
+		}
+		if rule.Outcome == models.RuleFlag {
+			result.Flags = append(result.Flags, rule.RuleID)
+			continue
+		}
+		result.Outcome = rule.Outcome
+		result.Code = rule.Code
+		result.RuleID = rule.RuleID
+		return result, nil
+	}
+	return result, nil
+}
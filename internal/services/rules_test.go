@@ -0,0 +1,160 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/rules"
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestEvaluateRulesFailsClosed(t *testing.T) {
+	facts := rules.Env{"event": rules.Env{"amount": float64(5000), "channel": "POS"}}
+	compile := func(t *testing.T, rule models.Rule) *compiledRule {
+		t.Helper()
+		rule.Name, rule.Scope = rule.RuleID, models.RuleScopeGlobal
+		c, err := compileRule(rule)
+		if err != nil {
+			t.Fatalf("compileRule(%s): %v", rule.RuleID, err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		set     func(t *testing.T) []*compiledRule
+		outcome string
+		ruleID  string
+	}{
+		{
+			name: "decline rule that errors declines",
+			set: func(t *testing.T) []*compiledRule {
+				return []*compiledRule{compile(t, models.Rule{RuleID: "broken", Expression: `event.amount > "limit"`, Outcome: models.RuleDecline, Code: "broken"})}
+			},
+			outcome: models.RuleDecline,
+			ruleID:  "broken",
+		},
+		{
+			name: "approve rule that errors does not approve",
+			set: func(t *testing.T) []*compiledRule {
+				return []*compiledRule{
+					compile(t, models.Rule{RuleID: "allow", Priority: 1, Expression: `event.amount > "limit"`, Outcome: models.RuleApprove}),
+					compile(t, models.Rule{RuleID: "pos", Priority: 2, Expression: `event.channel == "POS"`, Outcome: models.RuleDecline, Code: "pos"}),
+				}
+			},
+			outcome: models.RuleDecline,
+			ruleID:  "pos",
+		},
+		{
+			name: "stored rule that no longer compiles declines",
+			set: func(t *testing.T) []*compiledRule {
+				rule := models.Rule{RuleID: "stale", Expression: `event.amount >`, Outcome: models.RuleDecline, Code: "stale"}
+				return []*compiledRule{{Rule: rule, invalid: errors.New("unexpected end of expression")}}
+			},
+			outcome: models.RuleDecline,
+			ruleID:  "stale",
+		},
+		{
+			name: "rules that pass approve",
+			set: func(t *testing.T) []*compiledRule {
+				return []*compiledRule{compile(t, models.Rule{RuleID: "atm", Expression: `event.channel == "ATM"`, Outcome: models.RuleDecline, Code: "atm"})}
+			},
+			outcome: models.RuleApprove,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := evaluateRules(tt.set(t), models.Card{}, facts)
+			if err != nil {
+				t.Fatalf("evaluateRules: %v", err)
+			}
+			if result.Outcome != tt.outcome || result.RuleID != tt.ruleID {
+				t.Errorf("evaluateRules = %s by %q; want %s by %q", result.Outcome, result.RuleID, tt.outcome, tt.ruleID)
+			}
+		})
+	}
+}
+
+func TestDefaultBalanceRuleGatesDebits(t *testing.T) {
+	var balance []*compiledRule
+	for _, rule := range defaultRules {
+		if rule.RuleID == "balance" {
+			rule.Scope = models.RuleScopeGlobal
+			c, err := compileRule(rule)
+			if err != nil {
+				t.Fatalf("compileRule: %v", err)
+			}
+			balance = append(balance, c)
+		}
+	}
+
+	tests := []struct {
+		eventType string
+		total     int64
+		outcome   string
+	}{
+		{eventType: "purchase", total: 5_001, outcome: models.RuleDecline},
+		{eventType: "capture", total: 5_001, outcome: models.RuleDecline},
+		{eventType: "withdrawal", total: 5_001, outcome: models.RuleDecline},
+		{eventType: "purchase", total: 5_000, outcome: models.RuleApprove},
+		{eventType: "check", total: 0, outcome: models.RuleApprove},
+	}
+	for _, tt := range tests {
+		facts := rules.Env{
+			"event":   rules.Env{"type": tt.eventType, "total": tt.total},
+			"balance": rules.Env{"available": int64(5_000)},
+		}
+		result, err := evaluateRules(balance, models.Card{}, facts)
+		if err != nil {
+			t.Fatalf("evaluateRules: %v", err)
+		}
+		if result.Outcome != tt.outcome {
+			t.Errorf("%s of %d against 5000 = %s; want %s", tt.eventType, tt.total, result.Outcome, tt.outcome)
+		}
+	}
+}
+
+func TestSeedDefaultsUpgradesSupersededRules(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	stored := []models.Rule{
+		{RuleID: "balance", Name: "Insufficient funds", Priority: 200, Expression: supersededDefaults["balance"][0], Outcome: models.RuleDecline, Code: "insufficient-funds", CreatedBy: "system"},
+		// Changed by an operator, so it is kept
+		{RuleID: "card-status", Name: "Card is not active", Priority: 100, Expression: `card.status == "terminated"`, Outcome: models.RuleDecline, Code: "account-inactive", CreatedBy: "ops@example.com"},
+	}
+	for _, rule := range stored {
+		rule.Version, rule.Scope, rule.Enabled = 1, models.RuleScopeGlobal, true
+		if err := db.InsertRuleVersion(ctx, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service := NewRuleService(db, zap.NewNop())
+	for i := 0; i < 2; i++ {
+		if err := service.SeedDefaults(ctx); err != nil {
+			t.Fatalf("SeedDefaults: %v", err)
+		}
+	}
+	current, err := db.ListCurrentRules(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{}
+	for _, rule := range defaultRules {
+		want[rule.RuleID] = rule.Expression
+	}
+	want["card-status"] = stored[1].Expression
+	for _, rule := range current {
+		if rule.Expression != want[rule.RuleID] {
+			t.Errorf("%s v%d = %s; want %s", rule.RuleID, rule.Version, rule.Expression, want[rule.RuleID])
+		}
+		if rule.RuleID == "balance" && rule.Version != 2 {
+			t.Errorf("balance at version %d; want 2", rule.Version)
+		}
+	}
+	if len(current) != len(defaultRules) {
+		t.Errorf("%d current rules; want %d", len(current), len(defaultRules))
+	}
+}
@@ -0,0 +1,401 @@
+package services
+
+import (
+	"bytes"
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"card-service/internal/watchlist"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidWatchlist is returned when an imported list cannot be parsed.
+	ErrInvalidWatchlist = errors.New("invalid watchlist")
+	// ErrScreeningNotFound is returned when a screening does not exist.
+	ErrScreeningNotFound = errors.New("screening not found")
+	// ErrScreeningReviewed is returned when reviewing a screening that is not waiting for a review.
+	ErrScreeningReviewed = errors.New("screening is not pending review")
+	// ErrInvalidReview is returned when a review decision is incomplete.
+	ErrInvalidReview = errors.New("invalid review")
+)
+
+// rescreenBatchSize is the number of customers rescreened per query.
+const rescreenBatchSize = 500
+
+// ScreeningConfig holds the match thresholds, as scores from 0 to 1.
+type ScreeningConfig struct {
+	ReviewScore float64 // Best match score from which a screening needs a manual review
+	BlockScore  float64 // Best match score from which onboarding is blocked outright
+}
+
+// ScreeningService screens people against the locally loaded watchlists. The
+// lists are indexed in memory and reloaded when a newer import is found.
+type ScreeningService struct {
+	store  *store.Store
+	config ScreeningConfig
+	logger *zap.Logger
+
+	mu       sync.RWMutex
+	index    *watchlist.Index
+	loadedAt time.Time // Import time of the newest list in the index
+}
+
+// NewScreeningService creates a screening service.
+func NewScreeningService(store *store.Store, config ScreeningConfig, logger *zap.Logger) *ScreeningService {
+	return &ScreeningService{store: store, config: config, logger: logger, index: watchlist.NewIndex(nil)}
+}
+
+// Reload rebuilds the index when a list was imported since the last load.
+func (s *ScreeningService) Reload(ctx context.Context) error {
+	lists, err := s.store.ListWatchlists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list watchlists: %w", err)
+	}
+	newest := listsImportedAt(lists)
+	s.mu.RLock()
+	loaded := s.loadedAt
+	s.mu.RUnlock()
+	if !newest.After(loaded) {
+		return nil
+	}
+
+	var entries []watchlist.Entry
+	for _, list := range lists {
+		stored, err := s.store.ListWatchlistEntries(ctx, list.Source, list.ImportedAt)
+		if err != nil {
+			return fmt.Errorf("failed to load watchlist %s: %w", list.Source, err)
+		}
+		for _, entry := range stored {
+			entries = append(entries, watchlist.Entry{
+				List:          entry.Source,
+				ID:            entry.EntryID,
+				Name:          entry.Name,
+				Aliases:       entry.Aliases,
+				DatesOfBirth:  entry.DatesOfBirth,
+				Nationalities: entry.Nationalities,
+			})
+		}
+	}
+	index := watchlist.NewIndex(entries)
+	s.mu.Lock()
+	s.index = index
+	s.loadedAt = newest
+	s.mu.Unlock()
+	s.logger.Info("Loaded watchlists", zap.Int("lists", len(lists)), zap.Int("entries", index.Len()))
+	return nil
+}
+
+func listsImportedAt(lists []models.Watchlist) time.Time {
+	var newest time.Time
+	for _, list := range lists {
+		if list.ImportedAt.After(newest) {
+			newest = list.ImportedAt
+		}
+	}
+	return newest
+}
+
+// Import parses a csv or xml list and replaces the previous import of the
+// same source. Customers are rescreened against it by the rescreening job.
+func (s *ScreeningService) Import(ctx context.Context, source, format string, r io.Reader) (models.Watchlist, error) {
+	if source == "" {
+		return models.Watchlist{}, fmt.Errorf("%w: source is required", ErrInvalidWatchlist)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return models.Watchlist{}, fmt.Errorf("failed to read watchlist: %w", err)
+	}
+	var parsed []watchlist.Entry
+	switch format {
+	case "csv":
+		parsed, err = watchlist.ParseCSV(bytes.NewReader(data))
+	case "xml":
+		parsed, err = watchlist.ParseXML(bytes.NewReader(data))
+	default:
+		return models.Watchlist{}, fmt.Errorf("%w: unknown format %q", ErrInvalidWatchlist, format)
+	}
+	if err != nil {
+		return models.Watchlist{}, fmt.Errorf("%w: %v", ErrInvalidWatchlist, err)
+	}
+
+	checksum := sha256.Sum256(data)
+	list := models.Watchlist{
+		Source:     source,
+		Format:     format,
+		Entries:    len(parsed),
+		Checksum:   hex.EncodeToString(checksum[:]),
+		ImportedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	entries := make([]models.WatchlistEntry, 0, len(parsed))
+	for _, entry := range parsed {
+		entries = append(entries, models.WatchlistEntry{
+			Source:        source,
+			EntryID:       entry.ID,
+			Name:          entry.Name,
+			Aliases:       entry.Aliases,
+			DatesOfBirth:  entry.DatesOfBirth,
+			Nationalities: entry.Nationalities,
+			ImportedAt:    list.ImportedAt,
+		})
+	}
+	if err := s.store.ReplaceWatchlist(ctx, list, entries); err != nil {
+		s.logger.Error("Failed to store watchlist", zap.String("source", source), zap.Error(err))
+		return models.Watchlist{}, fmt.Errorf("failed to store watchlist: %w", err)
+	}
+	s.logger.Info("Imported watchlist", zap.String("source", source), zap.Int("entries", list.Entries))
+	if err := s.Reload(ctx); err != nil {
+		s.logger.Error("Failed to reload watchlists", zap.Error(err))
+	}
+	return list, nil
+}
+
+// ListWatchlists returns the latest import of every list.
+func (s *ScreeningService) ListWatchlists(ctx context.Context) ([]models.Watchlist, error) {
+	lists, err := s.store.ListWatchlists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list watchlists: %w", err)
+	}
+	return lists, nil
+}
+
+// Screen matches a person against the loaded lists. The result is not stored.
+func (s *ScreeningService) Screen(trigger string, subject models.ScreeningSubject) models.Screening {
+	s.mu.RLock()
+	index, loadedAt := s.index, s.loadedAt
+	s.mu.RUnlock()
+
+	screening := models.Screening{
+		Trigger:         trigger,
+		Subject:         subject,
+		Result:          models.ScreeningClear,
+		Matches:         []models.ScreeningMatch{},
+		ListsImportedAt: loadedAt,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if index.Len() == 0 {
+		s.logger.Warn("No watchlists loaded; screening passes", zap.String("trigger", trigger))
+		return screening
+	}
+	matches := index.Screen(watchlist.Subject{
+		Name:        subject.Name,
+		DateOfBirth: subject.DateOfBirth,
+		Nationality: subject.Nationality,
+	}, s.config.ReviewScore)
+	for _, match := range matches {
+		screening.Matches = append(screening.Matches, models.ScreeningMatch{
+			Source:      match.Entry.List,
+			EntryID:     match.Entry.ID,
+			Name:        match.Entry.Name,
+			MatchedName: match.MatchedName,
+			NameScore:   match.NameScore,
+			Score:       match.Score,
+			DateOfBirth: match.DateOfBirth,
+			Nationality: match.Nationality,
+		})
+	}
+	if len(matches) > 0 {
+		screening.Result = models.ScreeningReview
+		screening.ReviewStatus = models.ReviewPending
+		if matches[0].Score >= s.config.BlockScore {
+			screening.Result = models.ScreeningBlocked
+			screening.ReviewStatus = ""
+		}
+	}
+	return screening
+}
+
+// ScreenParties screens a subject together with the parties related to it,
+// such as the officers of a business, as one screening. The result is the
+// most severe result of any party.
+func (s *ScreeningService) ScreenParties(trigger string, subject models.ScreeningSubject, related []models.ScreeningSubject) models.Screening {
+	severity := []string{models.ScreeningClear, models.ScreeningReview, models.ScreeningBlocked}
+	screening := s.Screen(trigger, subject)
+	for i := range screening.Matches {
+		screening.Matches[i].Party = subject.Name
+	}
+	for _, party := range related {
+		screened := s.Screen(trigger, party)
+		for _, match := range screened.Matches {
+			match.Party = party.Name
+			screening.Matches = append(screening.Matches, match)
+		}
+		if slices.Index(severity, screened.Result) > slices.Index(severity, screening.Result) {
+			screening.Result, screening.ReviewStatus = screened.Result, screened.ReviewStatus
+		}
+	}
+	sort.SliceStable(screening.Matches, func(i, j int) bool {
+		return screening.Matches[i].Score > screening.Matches[j].Score
+	})
+	return screening
+}
+
+// Record stores a screening and returns it with its ID.
+func (s *ScreeningService) Record(ctx context.Context, screening models.Screening) (models.Screening, error) {
+	id, err := s.store.InsertScreening(ctx, screening)
+	if err != nil {
+		s.logger.Error("Failed to store screening", zap.String("trigger", screening.Trigger), zap.Error(err))
+		return models.Screening{}, fmt.Errorf("failed to store screening: %w", err)
+	}
+	screening.ID = id
+	return screening, nil
+}
+
+// RunRescreening reloads the lists at the given interval and rescreens the
+// customers not yet screened against the newest import, until ctx is cancelled.
+func (s *ScreeningService) RunRescreening(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				s.logger.Error("Failed to reload watchlists", zap.Error(err))
+				continue
+			}
+			screened, hits, err := s.Rescreen(ctx)
+			if err != nil {
+				s.logger.Error("Failed to rescreen customers", zap.Error(err))
+			}
+			if screened > 0 {
+				s.logger.Info("Rescreened customers", zap.Int("screened", screened), zap.Int("hits", hits))
+			}
+		}
+	}
+}
+
+// Rescreen screens the customers last screened before the newest list import.
+// Matches are queued for review whatever their score: an existing customer is
+// never blocked without a reviewer.
+func (s *ScreeningService) Rescreen(ctx context.Context) (int, int, error) {
+	s.mu.RLock()
+	loadedAt := s.loadedAt
+	s.mu.RUnlock()
+	if loadedAt.IsZero() {
+		return 0, 0, nil
+	}
+
+	screened, hits := 0, 0
+	for {
+		customers, err := s.store.ListCustomersToScreen(ctx, loadedAt, rescreenBatchSize)
+		if err != nil {
+			return screened, hits, fmt.Errorf("failed to list customers: %w", err)
+		}
+		if len(customers) == 0 {
+			return screened, hits, nil
+		}
+		for _, customer := range customers {
+			screening := s.Screen(models.ScreeningRescreen, models.ScreeningSubject{
+				Name:        customer.Name,
+				DateOfBirth: customer.DateOfBirth,
+				Nationality: customer.NationalityCode,
+			})
+			if screening.Result != models.ScreeningClear {
+				screening.CustomerID = customer.CustomerID
+				screening.AccountID = customer.AccountID
+				screening.ReviewStatus = models.ReviewPending
+				if _, err := s.Record(ctx, screening); err != nil {
+					return screened, hits, err
+				}
+				hits++
+				s.logger.Warn("Rescreening matched a watchlist entry",
+					zap.String("customerID", customer.CustomerID),
+					zap.String("result", screening.Result),
+				)
+			}
+			if err := s.store.MarkCustomerScreened(ctx, customer.CustomerID, loadedAt); err != nil {
+				return screened, hits, fmt.Errorf("failed to mark customer screened: %w", err)
+			}
+			screened++
+		}
+	}
+}
+
+// ListScreenings returns a page of screenings, newest first, optionally
+// filtered by review status and result.
+func (s *ScreeningService) ListScreenings(ctx context.Context, reviewStatus, result string, page, limit int64) ([]models.Screening, error) {
+	filter := bson.M{}
+	if reviewStatus != "" {
+		filter["reviewStatus"] = reviewStatus
+	}
+	if result != "" {
+		filter["result"] = result
+	}
+	screenings, err := s.store.ListScreenings(ctx, filter, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list screenings", zap.Error(err))
+		return nil, fmt.Errorf("failed to list screenings: %w", err)
+	}
+	return screenings, nil
+}
+
+// GetScreening returns a single screening.
+func (s *ScreeningService) GetScreening(ctx context.Context, id string) (*models.Screening, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrScreeningNotFound
+	}
+	screening, err := s.store.GetScreening(ctx, objectID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrScreeningNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch screening: %w", err)
+	}
+	return screening, nil
+}
+
+// review records the decision on a screening pending review.
+func (s *ScreeningService) review(ctx context.Context, id, decision, reviewer, note string) (*models.Screening, error) {
+	if decision != models.ReviewCleared && decision != models.ReviewConfirmed {
+		return nil, fmt.Errorf("%w: decision must be %s or %s", ErrInvalidReview, models.ReviewCleared, models.ReviewConfirmed)
+	}
+	if reviewer == "" {
+		return nil, fmt.Errorf("%w: reviewer is required", ErrInvalidReview)
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrScreeningNotFound
+	}
+	now := time.Now().UTC()
+	screening, err := s.store.UpdateScreening(ctx,
+		bson.M{"_id": objectID, "reviewStatus": models.ReviewPending},
+		bson.M{"$set": bson.M{
+			"reviewStatus": decision,
+			"reviewedBy":   reviewer,
+			"reviewNote":   note,
+			"reviewedAt":   now,
+		}},
+	)
+	if err == mongo.ErrNoDocuments {
+		if _, err := s.GetScreening(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrScreeningReviewed
+	}
+	if err != nil {
+		s.logger.Error("Failed to record screening review", zap.String("screeningID", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to record screening review: %w", err)
+	}
+	s.logger.Info("Reviewed screening",
+		zap.String("screeningID", id),
+		zap.String("decision", decision),
+		zap.String("reviewer", reviewer),
+	)
+	return screening, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/rules"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoShadowVersion is returned when a rule has no current shadow version.
+var ErrNoShadowVersion = errors.New("rule has no shadow version")
+
+// ShadowReport summarizes how the candidate policy would have changed the
+// decisions made in a time window.
+type ShadowReport struct {
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	Evaluated     int64              `json:"evaluated"`     // Decisions also evaluated by the candidate policy
+	Disagreements int64              `json:"disagreements"` // Decisions with a different outcome or decline code
+	Rules         []ShadowRuleReport `json:"rules"`
+}
+
+// ShadowRuleReport is the number of decisions attributed to a rule that the
+// candidate policy would flip.
+type ShadowRuleReport struct {
+	RuleID           string `json:"ruleId"`
+	ApproveToDecline int64  `json:"approveToDecline"`
+	DeclineToApprove int64  `json:"declineToApprove"`
+}
+
+// evaluateShadow evaluates the candidate policy against the facts of an
+// authorization and compares it with the live result. It returns nil when no
+// rule has a shadow version. Failures are recorded, never returned: the
+// candidate policy must not affect the live decision.
+func (s *WebhookService) evaluateShadow(card models.Card, facts rules.Env, live RuleResult) *models.ShadowDecision {
+	result, ok, err := s.rules.EvaluateShadow(card, facts)
+	if !ok {
+		return nil
+	}
+	shadow := &models.ShadowDecision{LiveOutcome: live.Outcome, LiveCode: live.Code}
+	if err != nil {
+		s.logger.Warn("Failed to evaluate shadow rules", zap.String("cardID", card.CardID), zap.Error(err))
+		shadow.Error = err.Error()
+		return shadow
+	}
+	shadow.Outcome = result.Outcome
+	shadow.Code = result.Code
+	shadow.RuleID = result.RuleID
+	shadow.Flags = result.Flags
+	shadow.Disagrees = live.Outcome != result.Outcome || live.Code != result.Code
+	switch {
+	case live.Outcome == models.RuleApprove && result.Outcome == models.RuleDecline:
+		shadow.Flip = models.FlipApproveToDecline
+		shadow.FlipRuleID = result.RuleID
+	case live.Outcome == models.RuleDecline && result.Outcome == models.RuleApprove:
+		// Either a candidate approve rule took over or the declining rule changed
+		shadow.Flip = models.FlipDeclineToApprove
+		shadow.FlipRuleID = result.RuleID
+		if shadow.FlipRuleID == "" {
+			shadow.FlipRuleID = live.RuleID
+		}
+	}
+	if shadow.Disagrees {
+		s.logger.Info("Shadow rules disagree with live decision",
+			zap.String("cardID", card.CardID),
+			zap.String("liveOutcome", live.Outcome),
+			zap.String("liveCode", live.Code),
+			zap.String("shadowOutcome", result.Outcome),
+			zap.String("shadowCode", result.Code),
+		)
+	}
+	return shadow
+}
+
+// PromoteShadow makes the shadow version of a rule its live version.
+func (s *RuleService) PromoteShadow(ctx context.Context, ruleID string) (models.Rule, error) {
+	versions, err := s.RuleVersions(ctx, ruleID)
+	if err != nil {
+		return models.Rule{}, err
+	}
+	shadow, ok := currentShadow(versions)
+	if !ok {
+		return models.Rule{}, ErrNoShadowVersion
+	}
+	promoted := shadow
+	promoted.Shadow = false
+	promoted.Version = versions[0].Version + 1
+	rule, err := s.saveRule(ctx, promoted)
+	if err != nil {
+		return models.Rule{}, err
+	}
+	if err := s.retireShadow(ctx, ruleID); err != nil {
+		return models.Rule{}, err
+	}
+	return rule, nil
+}
+
+// DiscardShadow removes the shadow version of a rule from the candidate policy.
+func (s *RuleService) DiscardShadow(ctx context.Context, ruleID string) error {
+	versions, err := s.RuleVersions(ctx, ruleID)
+	if err != nil {
+		return err
+	}
+	if _, ok := currentShadow(versions); !ok {
+		return ErrNoShadowVersion
+	}
+	return s.retireShadow(ctx, ruleID)
+}
+
+func (s *RuleService) retireShadow(ctx context.Context, ruleID string) error {
+	if err := s.store.RetireRule(ctx, ruleID, true); err != nil {
+		s.logger.Error("Failed to retire shadow rule", zap.String("ruleID", ruleID), zap.Error(err))
+		return fmt.Errorf("failed to retire shadow rule: %w", err)
+	}
+	s.logger.Info("Retired shadow rule", zap.String("ruleID", ruleID))
+	if err := s.Reload(ctx); err != nil {
+		s.logger.Error("Failed to reload rules", zap.Error(err))
+	}
+	return nil
+}
+
+func currentShadow(versions []models.Rule) (models.Rule, bool) {
+	for _, version := range versions {
+		if version.Shadow && version.Current {
+			return version, true
+		}
+	}
+	return models.Rule{}, false
+}
+
+// ShadowReport summarizes the shadow decisions made in [from, to).
+func (s *RuleService) ShadowReport(ctx context.Context, from, to time.Time) (ShadowReport, error) {
+	evaluated, disagreements, err := s.store.CountShadowDecisions(ctx, from, to)
+	if err != nil {
+		return ShadowReport{}, fmt.Errorf("failed to count shadow decisions: %w", err)
+	}
+	flips, err := s.store.CountShadowFlips(ctx, from, to)
+	if err != nil {
+		return ShadowReport{}, fmt.Errorf("failed to count shadow flips: %w", err)
+	}
+
+	report := ShadowReport{
+		From:          from,
+		To:            to,
+		Evaluated:     evaluated,
+		Disagreements: disagreements,
+		Rules:         []ShadowRuleReport{},
+	}
+	index := map[string]int{}
+	for _, flip := range flips {
+		i, ok := index[flip.RuleID]
+		if !ok {
+			i = len(report.Rules)
+			index[flip.RuleID] = i
+			report.Rules = append(report.Rules, ShadowRuleReport{RuleID: flip.RuleID})
+		}
+		switch flip.Flip {
+		case models.FlipApproveToDecline:
+			report.Rules[i].ApproveToDecline += flip.Count
+		case models.FlipDeclineToApprove:
+			report.Rules[i].DeclineToApprove += flip.Count
+		}
+	}
+	return report, nil
+}
+
+// ListShadowDisagreements returns a page of the decisions made in [from, to)
+// the candidate policy disagreed with, newest first.
+func (s *RuleService) ListShadowDisagreements(ctx context.Context, from, to time.Time, page, limit int64) ([]models.AuthorizationDecision, error) {
+	decisions, err := s.store.ListShadowDisagreements(ctx, from, to, (page-1)*limit, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shadow disagreements: %w", err)
+	}
+	return decisions, nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTravelNoticeNotFound is returned when no travel notice exists with the given ID.
+	ErrTravelNoticeNotFound = errors.New("travel notice not found")
+	// ErrInvalidTravelNotice is returned when a travel notice fails validation.
+	ErrInvalidTravelNotice = errors.New("invalid travel notice")
+)
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// TravelNoticeService stores the travel notices of customers. Authorizations
+// in a country outside the allowed countries of a card are declined unless a
+// travel notice covers the country at the time of the authorization.
+type TravelNoticeService struct {
+	store    *store.Store
+	location *time.Location // Timezone in which travel days start
+	logger   *zap.Logger
+}
+
+// NewTravelNoticeService creates a travel notice service whose days start in the given location.
+func NewTravelNoticeService(store *store.Store, location *time.Location, logger *zap.Logger) *TravelNoticeService {
+	return &TravelNoticeService{store: store, location: location, logger: logger}
+}
+
+// CreateNotice pre-authorizes countries for the cards of a customer from the
+// start of the day from to the end of the day to, both YYYY-MM-DD.
+func (s *TravelNoticeService) CreateNotice(ctx context.Context, customerID string, countries []string, from, to, note string) (*models.TravelNotice, error) {
+	count, err := s.store.Customers.CountDocuments(ctx, bson.M{"customerId": customerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch customer: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrCustomerNotFound
+	}
+
+	codes, err := normalizeCountries(countries)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTravelNotice, err)
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("%w: at least one country is required", ErrInvalidTravelNotice)
+	}
+	first, err := time.ParseInLocation(time.DateOnly, from, s.location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: from must be YYYY-MM-DD", ErrInvalidTravelNotice)
+	}
+	last, err := time.ParseInLocation(time.DateOnly, to, s.location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: to must be YYYY-MM-DD", ErrInvalidTravelNotice)
+	}
+	if last.Before(first) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidTravelNotice)
+	}
+	now := time.Now().UTC()
+	endsAt := last.AddDate(0, 0, 1).UTC()
+	if !endsAt.After(now) {
+		return nil, fmt.Errorf("%w: travel has already ended", ErrInvalidTravelNotice)
+	}
+
+	notice := models.TravelNotice{
+		ID:         primitive.NewObjectID(),
+		CustomerID: customerID,
+		Countries:  codes,
+		From:       from,
+		To:         to,
+		StartsAt:   first.UTC(),
+		EndsAt:     endsAt,
+		Note:       note,
+		CreatedAt:  now,
+	}
+	if _, err := s.store.TravelNotices.InsertOne(ctx, notice); err != nil {
+		s.logger.Error("Failed to store travel notice", zap.String("customerID", customerID), zap.Error(err))
+		return nil, fmt.Errorf("failed to store travel notice: %w", err)
+	}
+	s.logger.Info("Created travel notice",
+		zap.String("customerID", customerID),
+		zap.String("noticeID", notice.ID.Hex()),
+		zap.Strings("countries", codes),
+		zap.String("from", from),
+		zap.String("to", to),
+	)
+	return &notice, nil
+}
+
+// ListNotices returns the travel notices of a customer, latest travel first.
+func (s *TravelNoticeService) ListNotices(ctx context.Context, customerID string) ([]models.TravelNotice, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "startsAt", Value: -1}})
+	cursor, err := s.store.TravelNotices.Find(ctx, bson.M{"customerId": customerID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list travel notices: %w", err)
+	}
+	notices := []models.TravelNotice{}
+	if err := cursor.All(ctx, &notices); err != nil {
+		return nil, fmt.Errorf("failed to decode travel notices: %w", err)
+	}
+	return notices, nil
+}
+
+// CancelNotice withdraws a travel notice of a customer. Cancelling it again
+// returns it unchanged.
+func (s *TravelNoticeService) CancelNotice(ctx context.Context, customerID, noticeID string) (*models.TravelNotice, error) {
+	id, err := primitive.ObjectIDFromHex(noticeID)
+	if err != nil {
+		return nil, ErrTravelNoticeNotFound
+	}
+	filter := bson.M{"_id": id, "customerId": customerID}
+	var notice models.TravelNotice
+	err = s.store.TravelNotices.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "customerId": customerID, "cancelledAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"cancelledAt": time.Now().UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&notice)
+	if err == mongo.ErrNoDocuments {
+		err = s.store.TravelNotices.FindOne(ctx, filter).Decode(&notice)
+	}
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTravelNoticeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel travel notice: %w", err)
+	}
+	s.logger.Info("Cancelled travel notice", zap.String("customerID", customerID), zap.String("noticeID", noticeID))
+	return &notice, nil
+}
+
+// normalizeCountries upper-cases country codes, drops duplicates and checks
+// they are ISO 3166 alpha-2 codes.
+func normalizeCountries(countries []string) ([]string, error) {
+	var codes []string
+	for _, country := range countries {
+		code := strings.ToUpper(strings.TrimSpace(country))
+		if !countryCode.MatchString(code) {
+			return nil, fmt.Errorf("country %q is not an ISO 3166 alpha-2 code", country)
+		}
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
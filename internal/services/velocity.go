@@ -0,0 +1,185 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/store"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Velocity signals raised by the fraud heuristics.
+const (
+	SignalFrequency      = "frequency"       // Too many authorizations in the velocity window
+	SignalCardTesting    = "card-testing"    // Small authorization after repeated declines
+	SignalMerchantSpread = "merchant-spread" // Too many distinct merchants in the velocity window
+	SignalGeoJump        = "geo-jump"        // Country changed faster than travel allows
+)
+
+// Risk actions for an assessed authorization.
+const (
+	RiskDecline = "decline"
+	RiskFlag    = "flag"
+)
+
+// velocityHistorySize is the number of recent authorizations kept per card.
+const velocityHistorySize = 50
+
+// defaultRiskWeights is the score each signal adds to the risk score.
+var defaultRiskWeights = map[string]int64{
+	SignalFrequency:      40,
+	SignalCardTesting:    60,
+	SignalMerchantSpread: 30,
+	SignalGeoJump:        50,
+}
+
+// VelocityConfig configures the fraud heuristics.
+type VelocityConfig struct {
+	Window        time.Duration    // Window of the frequency, card testing and merchant spread heuristics
+	MaxCount      int              // Authorizations allowed in the window
+	MaxDeclines   int              // Declines in the window after which a small amount looks like card testing
+	SmallAmount   int64            // Amount at or below which an authorization counts as small
+	MaxMerchants  int              // Distinct merchants allowed in the window
+	GeoJumpWindow time.Duration    // Shortest plausible time between authorizations in different countries
+	Weights       map[string]int64 // Score per signal; missing signals use the defaults
+	FlagScore     int64            // Score from which authorizations are flagged for review
+	DeclineScore  int64            // Score from which authorizations are declined
+}
+
+// RiskAssessment is the fraud risk of an authorization.
+type RiskAssessment struct {
+	Score   int64    `json:"score"`
+	Signals []string `json:"signals"`
+	Action  string   `json:"action,omitempty"` // decline, flag, or empty when the score is below both thresholds
+}
+
+// VelocityChecker scores authorizations against the recent authorizations of
+// their card. The recent authorizations are kept per card in a bounded list,
+// so a check reads one document.
+type VelocityChecker struct {
+	store  *store.Store
+	config VelocityConfig
+	logger *zap.Logger
+}
+
+// NewVelocityChecker creates a velocity checker.
+func NewVelocityChecker(store *store.Store, config VelocityConfig, logger *zap.Logger) *VelocityChecker {
+	weights := make(map[string]int64, len(defaultRiskWeights))
+	for signal, weight := range defaultRiskWeights {
+		weights[signal] = weight
+	}
+	for signal, weight := range config.Weights {
+		weights[signal] = weight
+	}
+	config.Weights = weights
+	return &VelocityChecker{store: store, config: config, logger: logger}
+}
+
+// Assess scores an authorization against the recent authorizations of its card.
+func (v *VelocityChecker) Assess(ctx context.Context, cardID string, current models.VelocityEvent) (RiskAssessment, error) {
+	velocity, err := v.store.GetCardVelocity(ctx, cardID)
+	if err != nil {
+		return RiskAssessment{}, fmt.Errorf("failed to fetch card velocity: %w", err)
+	}
+	var history []models.VelocityEvent
+	if velocity != nil {
+		history = velocity.Events
+	}
+	return assessRisk(v.config, history, current), nil
+}
+
+// Record adds a decided authorization to the recent authorizations of its card.
+func (v *VelocityChecker) Record(ctx context.Context, cardID string, event models.VelocityEvent) error {
+	if err := v.store.PushVelocityEvent(ctx, cardID, event, velocityHistorySize); err != nil {
+		return fmt.Errorf("failed to record card velocity: %w", err)
+	}
+	return nil
+}
+
+// assessRisk runs the heuristics over the history of a card, oldest first,
+// and the authorization being decided.
+func assessRisk(config VelocityConfig, history []models.VelocityEvent, current models.VelocityEvent) RiskAssessment {
+	since := current.At.Add(-config.Window)
+	count, declines := 1, 0
+	merchants := map[string]bool{}
+	if current.Merchant != "" {
+		merchants[current.Merchant] = true
+	}
+	for _, event := range history {
+		if event.At.Before(since) || event.AuthorizationID == current.AuthorizationID {
+			continue
+		}
+		count++
+		if !event.Approved {
+			declines++
+		}
+		if event.Merchant != "" {
+			merchants[event.Merchant] = true
+		}
+	}
+
+	var signals []string
+	if config.MaxCount > 0 && count > config.MaxCount {
+		signals = append(signals, SignalFrequency)
+	}
+	if config.MaxDeclines > 0 && declines >= config.MaxDeclines && current.Amount <= config.SmallAmount {
+		signals = append(signals, SignalCardTesting)
+	}
+	if config.MaxMerchants > 0 && len(merchants) > config.MaxMerchants {
+		signals = append(signals, SignalMerchantSpread)
+	}
+	if geoJump(config.GeoJumpWindow, history, current) {
+		signals = append(signals, SignalGeoJump)
+	}
+
+	assessment := RiskAssessment{Signals: []string{}}
+	for _, signal := range signals {
+		assessment.Score += config.Weights[signal]
+		assessment.Signals = append(assessment.Signals, signal)
+	}
+	switch {
+	case config.DeclineScore > 0 && assessment.Score >= config.DeclineScore:
+		assessment.Action = RiskDecline
+	case config.FlagScore > 0 && assessment.Score >= config.FlagScore:
+		assessment.Action = RiskFlag
+	}
+	return assessment
+}
+
+// geoJump reports whether the latest authorization with a known country was in
+// another country too recently to have travelled since.
+func geoJump(window time.Duration, history []models.VelocityEvent, current models.VelocityEvent) bool {
+	if window <= 0 || current.Country == "" {
+		return false
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		previous := history[i]
+		if previous.Country == "" || previous.AuthorizationID == current.AuthorizationID {
+			continue
+		}
+		return previous.Country != current.Country && current.At.Sub(previous.At) < window
+	}
+	return false
+}
+
+// velocityEvent describes an authorization request for the velocity checks.
+func velocityEvent(event api.AuthorizationRequestEvent, approved bool, at time.Time) models.VelocityEvent {
+	acceptor := api.ParseCardAcceptorNameLocation(event.NetworkData.CardAcceptorNameLocation)
+	merchant := event.NetworkData.MerchantID
+	if merchant == "" {
+		merchant = strings.ToUpper(acceptor.Name)
+	}
+	return models.VelocityEvent{
+		AuthorizationID: event.ID,
+		At:              at.UTC(),
+		Amount:          event.Amount + event.Fees,
+		Approved:        approved,
+		Merchant:        merchant,
+		City:            acceptor.City,
+		Country:         api.TransactionCountry(event.NetworkData),
+	}
+}
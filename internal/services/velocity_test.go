@@ -0,0 +1,161 @@
+package services
+
+import (
+	"card-service/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAssessRisk(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	config := VelocityConfig{
+		Window:        10 * time.Minute,
+		MaxCount:      3,
+		MaxDeclines:   2,
+		SmallAmount:   100,
+		MaxMerchants:  2,
+		GeoJumpWindow: 2 * time.Hour,
+		Weights:       defaultRiskWeights,
+		FlagScore:     40,
+		DeclineScore:  90,
+	}
+	// event is a past authorization; the options are a merchant, a country
+	// and "declined".
+	event := func(id string, ago time.Duration, options ...string) models.VelocityEvent {
+		e := models.VelocityEvent{AuthorizationID: id, At: now.Add(-ago), Amount: 5000, Approved: true, Merchant: "shop-1"}
+		for _, option := range options {
+			switch {
+			case option == "declined":
+				e.Approved = false
+			case len(option) == 2:
+				e.Country = option
+			default:
+				e.Merchant = option
+			}
+		}
+		return e
+	}
+	current := models.VelocityEvent{AuthorizationID: "current", At: now, Amount: 5000, Approved: true, Merchant: "shop-1"}
+	small := current
+	small.Amount = 100
+	smallPlusOne := current
+	smallPlusOne.Amount = 101
+	inGhana := current
+	inGhana.Country = "GH"
+	inNigeria := current
+	inNigeria.Country = "NG"
+	smallInGhana := small
+	smallInGhana.Country = "GH"
+
+	tests := []struct {
+		name    string
+		history []models.VelocityEvent
+		current models.VelocityEvent
+		signals []string
+		score   int64
+		action  string
+	}{
+		{name: "no history", current: current},
+
+		// Frequency: the current authorization plus those in the window
+		{name: "frequency at the limit", current: current,
+			history: []models.VelocityEvent{event("a1", 5*time.Minute), event("a2", time.Minute)}},
+		{name: "frequency over the limit", current: current,
+			history: []models.VelocityEvent{event("a1", 5*time.Minute), event("a2", 2*time.Minute), event("a3", time.Minute)},
+			signals: []string{SignalFrequency}, score: 40, action: RiskFlag},
+		{name: "frequency counts the window start", current: current,
+			history: []models.VelocityEvent{event("a1", 10*time.Minute), event("a2", 2*time.Minute), event("a3", time.Minute)},
+			signals: []string{SignalFrequency}, score: 40, action: RiskFlag},
+		{name: "frequency ignores events before the window", current: current,
+			history: []models.VelocityEvent{event("a1", 10*time.Minute+time.Second), event("a2", 2*time.Minute), event("a3", time.Minute)}},
+		{name: "frequency ignores the same authorization", current: current,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute), event("current", time.Minute), event("current", 30*time.Second)}},
+
+		// Card testing: a small amount after repeated declines
+		{name: "card testing", current: small,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute, "declined"), event("a2", time.Minute, "declined")},
+			signals: []string{SignalCardTesting}, score: 60, action: RiskFlag},
+		{name: "card testing below the decline limit", current: small,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute), event("a2", time.Minute, "declined")}},
+		{name: "card testing above the small amount", current: smallPlusOne,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute, "declined"), event("a2", time.Minute, "declined")}},
+		{name: "card testing ignores declines before the window", current: small,
+			history: []models.VelocityEvent{event("a1", 11*time.Minute, "declined"), event("a2", time.Minute, "declined")}},
+
+		// Merchant spread: distinct merchants including the current one
+		{name: "merchant spread at the limit", current: current,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute, "shop-2"), event("a2", time.Minute, "shop-1")}},
+		{name: "merchant spread over the limit", current: current,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute, "shop-2"), event("a2", time.Minute, "shop-3")},
+			signals: []string{SignalMerchantSpread}, score: 30},
+		{name: "merchant spread ignores merchants before the window", current: current,
+			history: []models.VelocityEvent{event("a1", 11*time.Minute, "shop-2"), event("a2", time.Minute, "shop-3")}},
+		{name: "merchant spread ignores unknown merchants", current: current,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute, "shop-2"), event("a2", time.Minute, "")}},
+
+		// Geo jump: the latest authorization with a country was elsewhere
+		{name: "geo jump", current: inGhana,
+			history: []models.VelocityEvent{event("a1", time.Hour, "NG")},
+			signals: []string{SignalGeoJump}, score: 50, action: RiskFlag},
+		{name: "geo jump just inside the window", current: inGhana,
+			history: []models.VelocityEvent{event("a1", 2*time.Hour-time.Second, "NG")},
+			signals: []string{SignalGeoJump}, score: 50, action: RiskFlag},
+		{name: "geo jump at the window", current: inGhana,
+			history: []models.VelocityEvent{event("a1", 2*time.Hour, "NG")}},
+		{name: "geo jump in the same country", current: inNigeria,
+			history: []models.VelocityEvent{event("a1", time.Hour, "NG")}},
+		{name: "geo jump skips events without a country", current: inGhana,
+			history: []models.VelocityEvent{event("a1", time.Hour, "NG"), event("a2", time.Minute)},
+			signals: []string{SignalGeoJump}, score: 50, action: RiskFlag},
+		{name: "geo jump compares the latest country only", current: inGhana,
+			history: []models.VelocityEvent{event("a1", 90*time.Minute, "NG"), event("a2", time.Hour, "GH")}},
+		{name: "geo jump without a current country", current: current,
+			history: []models.VelocityEvent{event("a1", time.Hour, "NG")}},
+
+		// Combined signals reach the decline score
+		{name: "card testing across countries", current: smallInGhana,
+			history: []models.VelocityEvent{event("a1", 2*time.Minute, "declined", "NG"), event("a2", time.Minute, "declined")},
+			signals: []string{SignalCardTesting, SignalGeoJump}, score: 110, action: RiskDecline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := assessRisk(config, tt.history, tt.current)
+			signals := tt.signals
+			if signals == nil {
+				signals = []string{}
+			}
+			want := RiskAssessment{Score: tt.score, Signals: signals, Action: tt.action}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("assessRisk() = %+v; want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGeoJump(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	previous := models.VelocityEvent{AuthorizationID: "a1", At: now.Add(-30 * time.Minute), Country: "NG"}
+	current := models.VelocityEvent{AuthorizationID: "a2", At: now, Country: "KE"}
+
+	tests := []struct {
+		name    string
+		window  time.Duration
+		history []models.VelocityEvent
+		current models.VelocityEvent
+		want    bool
+	}{
+		{name: "within the window", window: time.Hour, history: []models.VelocityEvent{previous}, current: current, want: true},
+		{name: "at the window", window: 30 * time.Minute, history: []models.VelocityEvent{previous}, current: current},
+		{name: "disabled", history: []models.VelocityEvent{previous}, current: current},
+		{name: "no history", window: time.Hour, current: current},
+		{name: "replayed authorization", window: time.Hour, history: []models.VelocityEvent{previous}, current: models.VelocityEvent{AuthorizationID: "a1", At: now, Country: "KE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := geoJump(tt.window, tt.history, tt.current); got != tt.want {
+				t.Errorf("geoJump() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
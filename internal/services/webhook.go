@@ -9,56 +9,62 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
 )
 
 type WebhookService struct {
-	store     *store.Store
-	apiClient *api.Client
-	logger    *zap.Logger
+	store    *store.Store
+	balances *BalanceProvider
+	standIn  *StandInPolicy
+	logger   *zap.Logger
+	registry *EventRegistry
+	limits   *LimitEvaluator
+	holds    *HoldLedger
+	ledger   *LedgerService
+	rules    *RuleService
+	velocity *VelocityChecker
+	kyc      *KYCService
+	deposits *DepositService
+	budget   DecisionBudget
+	accounts *leaseLocks // Serializes authorizations per funding account
 }
 
-func NewWebhookService(store *store.Store, apiClient *api.Client, logger *zap.Logger) *WebhookService {
-	return &WebhookService{
-		store:     store,
-		apiClient: apiClient,
-		logger:    logger,
+func NewWebhookService(store *store.Store, balances *BalanceProvider, standIn *StandInPolicy, limits *LimitEvaluator, holds *HoldLedger, ledger *LedgerService, rules *RuleService, velocity *VelocityChecker, kyc *KYCService, deposits *DepositService, budget DecisionBudget, logger *zap.Logger) *WebhookService {
+	s := &WebhookService{
+		store:    store,
+		balances: balances,
+		standIn:  standIn,
+		logger:   logger,
+		registry: NewEventRegistry(),
+		limits:   limits,
+		holds:    holds,
+		ledger:   ledger,
+		rules:    rules,
+		velocity: velocity,
+		kyc:      kyc,
+		deposits: deposits,
+		budget:   budget,
+		accounts: newLeaseLocks(store, "account:", logger),
 	}
+	Register(s.registry, "card.transaction.created", s.handleTransactionEvent)
+	Register(s.registry, "card.authorization.request", s.HandleAuthorizationRequest)
+	Register(s.registry, "card.authorization.updated", s.HandleAuthorizationUpdated)
+	Register(s.registry, "card.authorization.closed", s.HandleAuthorizationClosed)
+	Register(s.registry, "payment.created", s.handlePaymentEvent)
+	Register(s.registry, "payment.updated", s.handlePaymentEvent)
+	return s
 }
 
+// HandleWebhook dispatches an event to the handler registered for it. Events
+// without a handler return errUnhandledEvent so the inbox can keep them aside.
 func (s *WebhookService) HandleWebhook(ctx context.Context, event api.WebhookEvent) (api.AuthorizationResponse, error) {
-	var response api.AuthorizationResponse
-
-	switch event.Event {
-	case "card.transaction.created":
-		transaction, ok := event.Data.(api.TransactionEvent)
-		if !ok {
-			s.logger.Error("Invalid transaction event data", zap.Any("data", event.Data))
-			return response, fmt.Errorf("invalid transaction event data")
-		}
-		return s.handleTransactionEvent(ctx, transaction)
-
-	case "card.authorization.request":
-		authRequest, ok := event.Data.(api.AuthorizationRequestEvent)
-		if !ok {
-			s.logger.Error("Invalid authorization request event data", zap.Any("data", event.Data))
-			return response, fmt.Errorf("invalid authorization request event data")
-		}
-		return s.HandleAuthorizationRequest(ctx, authRequest)
-
-	case "card.authorization.closed":
-		authClosed, ok := event.Data.(api.AuthorizationClosedEvent)
-		if !ok {
-			s.logger.Error("Invalid authorization closed event data", zap.Any("data", event.Data))
-			return response, fmt.Errorf("invalid authorization closed event data")
-		}
-		return s.HandleAuthorizationClosed(ctx, authClosed)
-
-	default:
-		s.logger.Error("Unknown event type", zap.String("event", event.Event))
-		return response, fmt.Errorf("unknown event type: %s", event.Event)
+	handler, ok := s.registry.lookup(event.Event)
+	if !ok {
+		s.logger.Warn("No handler registered for event", zap.String("event", event.Event))
+		return api.AuthorizationResponse{}, fmt.Errorf("%w: %s", errUnhandledEvent, event.Event)
 	}
-
+	return handler(ctx, event.Data)
 }
 
 // handleTransactionEvent processes a transaction event and returns an authorization request event.
@@ -68,15 +74,39 @@ func (s *WebhookService) handleTransactionEvent(ctx context.Context, event api.T
 		return api.AuthorizationResponse{Code: "success"}, nil
 	}
 
-	if event.Type == "capture" {
-		//validate positive amount
-		if event.Amount < 0 || event.Fees < 0 {
-			s.logger.Error("invalid amount or fees",
-				zap.Int64("amount", event.Amount),
-				zap.Int64("fees", event.Fees),
+	//validate positive amount
+	if event.Amount < 0 || event.Fees < 0 {
+		s.logger.Error("invalid amount or fees",
+			zap.Int64("amount", event.Amount),
+			zap.Int64("fees", event.Fees),
+		)
+		return api.AuthorizationResponse{Code: "error"}, fmt.Errorf("invalid ammount or fees")
+	}
+
+	//settle the recorded authorization instead of storing the spend twice
+	if event.Authorization != "" {
+		result, err := s.store.UpdateTransaction(ctx, event.Authorization, bson.M{
+			"$set": bson.M{
+				"status":    "approved",
+				"amount":    event.Amount,
+				"fees":      event.Fees,
+				"updatedAt": time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			s.logger.Error("Failed to settle authorization",
+				zap.String("authorizationID", event.Authorization),
+				zap.Error(err),
 			)
+			return api.AuthorizationResponse{Code: "error"}, fmt.Errorf("failed to settle authorization: %w", err)
+		}
+		if result.MatchedCount > 0 {
+			s.logger.Info("Settled authorization",
+				zap.String("transactionID", event.ID),
+				zap.String("authorizationID", event.Authorization),
+			)
+			return api.AuthorizationResponse{Code: "success"}, nil
 		}
-		return api.AuthorizationResponse{Code: "error"}, fmt.Errorf("invalid ammount or fees")
 	}
 
 	//create transaction and store it in the database
@@ -91,14 +121,8 @@ func (s *WebhookService) handleTransactionEvent(ctx context.Context, event api.T
 		Fees:          event.Fees,
 		Channel:       event.Channel,
 		Status:        "approved", // Assuming the transaction is approved
-		NetworkData: models.NetworkData{
-			CardAcceptorNameLocation: event.NetworkData.CardAcceptorNameLocation,
-			Network:                  event.NetworkData.Network,
-			Reference:                event.NetworkData.Reference,
-			RRN:                      event.NetworkData.RRN,
-			STAN:                     event.NetworkData.STAN,
-		},
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		NetworkData:   toNetworkDataModel(event.NetworkData),
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
 	}
 	//store in the database
 	_, err := s.store.InsertTransaction(ctx, transaction)
@@ -117,107 +141,242 @@ func (s *WebhookService) handleTransactionEvent(ctx context.Context, event api.T
 }
 
 // handle AuthorizationRequestEvent processes an authorization request event and returns an authorization response.
+// The decision must be made within the decision budget; when it runs out the
+// configured fallback decision is returned instead.
 func (s *WebhookService) HandleAuthorizationRequest(ctx context.Context, event api.AuthorizationRequestEvent) (api.AuthorizationResponse, error) {
-	if event.Type != "pending" {
+	ctx, cancel := context.WithTimeout(ctx, s.budget.Timeout)
+	defer cancel()
+	timer := newDecisionTimer()
+	audit := newDecisionAudit(ctx, event)
+
+	response, err := s.authorize(ctx, event, timer, audit)
+	exceeded := err != nil && ctx.Err() == context.DeadlineExceeded
+	timing := timer.timing(s.budget.Timeout, exceeded)
+	if exceeded {
+		response, err = s.fallbackDecision(event, audit, timing, err)
+	} else {
+		s.logger.Info("Authorization decided",
+			zap.String("authorizationID", event.ID),
+			zap.String("action", response.Action),
+			zap.String("code", response.Code),
+			zap.Float64("elapsedMs", timing.ElapsedMs),
+			zap.Any("steps", timing.Steps),
+		)
+	}
+	//the audit is written after the response so it never eats into the budget
+	go s.recordDecision(ctx, audit, response, err, timing)
+	return response, err
+}
+
+// authorize evaluates an authorization request. Every step honours the
+// context so the decision stops as soon as the budget runs out.
+func (s *WebhookService) authorize(ctx context.Context, event api.AuthorizationRequestEvent, timer *decisionTimer, audit *decisionAudit) (api.AuthorizationResponse, error) {
+	audit.check("status", event.Status == "pending", "status "+event.Status)
+	if event.Status != "pending" {
 		s.logger.Error("Invalid authorization request",
-			zap.String("stauts", event.Status),
+			zap.String("status", event.Status),
 			zap.String("cardID", event.CardID),
 		)
-		return api.AuthorizationResponse{Action: "declined", Code: "invalid transaction"}, fmt.Errorf("invalid status:%s", event.Status)
+		return api.AuthorizationResponse{Action: "decline", Code: "invalid-transaction"}, fmt.Errorf("invalid status:%s", event.Status)
 	}
 
 	//fetch the card from the database
 	var card models.Card
-	err := s.store.Cards.FindOne(ctx, bson.M{"cardID": event.CardID}).Decode(&card)
+	done := timer.step("card-lookup")
+	err := s.store.Cards.FindOne(ctx, bson.M{"cardId": event.CardID}).Decode(&card)
+	done()
+	audit.check("card", err == nil, "")
 	if err != nil {
 		s.logger.Error("failed to fetch card",
 			zap.String("CardID", event.CardID),
 			zap.Error(err))
-		return api.AuthorizationResponse{Action: "declined", Code: "account-not-found"}, fmt.Errorf("failed to fetch card: %w", err)
-	}
-
-	//validate card status
-	if card.Status != "active" {
-		s.logger.Warn("Card is not active",
-			zap.String("CardID", event.CardID),
-			zap.String("status", card.Status),
-		)
-		return api.AuthorizationResponse{Action: "declined", Code: "account-inactive"}, fmt.Errorf("card is not active")
+		return api.AuthorizationResponse{Action: "decline", Code: "account-not-found"}, fmt.Errorf("failed to fetch card: %w", err)
 	}
+	audit.record.Card = &card
 
 	//fetch the customer from the db
 	var customer models.Customer
+	done = timer.step("customer-lookup")
 	err = s.store.Customers.FindOne(ctx, bson.M{"accountId": card.FundingSource}).Decode(&customer)
+	done()
+	audit.check("customer", err == nil, "")
 	if err != nil {
 		s.logger.Error("Failed to fetch customer", zap.String("accountID", card.FundingSource), zap.Error(err))
 		return api.AuthorizationResponse{Action: "decline", Code: "account-not-found"}, fmt.Errorf("failed to fetch customer: %w", err)
 	}
-	// fetch balance
-	balance, err := s.apiClient.GetAccountBalance(card.FundingSource)
+	audit.record.Customer = &customer
+
+	//hold the account until this authorization is recorded so concurrent
+	//requests see its hold and its spend
+	done = timer.step("account-lock")
+	unlock, err := s.accounts.Lock(ctx, card.FundingSource)
+	done()
 	if err != nil {
-		s.logger.Error("Failed to fetch balance", zap.String("accountID", card.FundingSource), zap.Error(err))
-		return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to fetch balance: %w", err)
+		return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to lock account: %w", err)
 	}
+	defer unlock()
 
-	//validate balance(amount + fees)
+	// fetch balance, standing in on the last known balance when the issuer is unavailable
 	totalAmount := event.Amount + event.Fees
-	if event.Type == "capture" && totalAmount > balance.Data.Available {
-		s.logger.Warn("Insufficient balance",
+	standIn := false
+	var issuerAvailable int64
+	done = timer.step("balance")
+	balance, err := s.balances.Fetch(ctx, card.FundingSource)
+	done()
+	if err == nil {
+		issuerAvailable = balance.Data.Available
+	} else {
+		s.logger.Error("Failed to fetch balance", zap.String("accountID", card.FundingSource), zap.Error(err))
+		done = timer.step("cached-balance")
+		snapshot, cacheErr := s.balances.LastKnown(ctx, card.FundingSource)
+		done()
+		if cacheErr != nil {
+			s.logger.Error("Failed to fetch cached balance", zap.String("accountID", card.FundingSource), zap.Error(cacheErr))
+		}
+		allowed, reason := s.standIn.Allows(snapshot, event.Channel, totalAmount, time.Now())
+		audit.check("stand-in", allowed, reason)
+		if !allowed {
+			s.logger.Warn("Stand-in not allowed",
+				zap.String("cardID", event.CardID),
+				zap.String("channel", event.Channel),
+				zap.String("reason", reason),
+			)
+			return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to fetch balance: %w", err)
+		}
+		s.logger.Warn("Standing in for issuer balance",
 			zap.String("cardID", event.CardID),
-			zap.Int64("totalAmount", totalAmount),
-			zap.Int64("availableBalance", balance.Data.Available),
+			zap.Int64("cachedAvailable", snapshot.Available),
+			zap.Time("fetchedAt", snapshot.FetchedAt),
 		)
-		return api.AuthorizationResponse{Action: "decline", Code: "insufficient-funds"}, fmt.Errorf("insufficient balance")
+		standIn = true
+		issuerAvailable = snapshot.Available
 	}
-
-	//validate controls
-	if !s.isChannelAllowed(card.Controls, event.Channel) {
-		s.logger.Warn("Channel not allowed",
-			zap.String("cardID", event.CardID),
-			zap.String("channel", event.Channel),
+	done = timer.step("holds")
+	available, err := s.holds.EffectiveAvailable(ctx, card.FundingSource, issuerAvailable)
+	done()
+	if err != nil {
+		s.logger.Error("Failed to compute available balance", zap.String("accountID", card.FundingSource), zap.Error(err))
+		return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to compute available balance: %w", err)
+	}
+	audit.record.Balance = &models.DecisionBalance{
+		Issuer:    issuerAvailable,
+		Holds:     issuerAvailable - available,
+		Available: available,
+		StandIn:   standIn,
+	}
+	//accounts backed by the ledger cannot spend more than their ledger balance
+	done = timer.step("ledger-balance")
+	ledgerBalances, ledgerBacked, err := s.ledger.Balances(ctx, card.FundingSource)
+	done()
+	if err != nil {
+		s.logger.Error("Failed to fetch ledger balance", zap.String("accountID", card.FundingSource), zap.Error(err))
+		return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to fetch ledger balance: %w", err)
+	}
+	if ledgerBacked {
+		audit.record.Balance.Ledger = &ledgerBalances.Available
+	}
+	if ledgerBacked && ledgerBalances.Available != available {
+		s.logger.Warn("Ledger balance differs from issuer balance",
+			zap.String("accountID", card.FundingSource),
+			zap.Int64("ledgerAvailable", ledgerBalances.Available),
+			zap.Int64("issuerAvailable", available),
 		)
-		return api.AuthorizationResponse{Action: "decline", Code: "spending-control"}, fmt.Errorf("channel not allowed")
+		if ledgerBalances.Available < available {
+			available = ledgerBalances.Available
+			audit.record.Balance.Available = available
+		}
 	}
 
 	//check for duplicate transaction
+	done = timer.step("duplicate-check")
 	existing, err := s.store.GetTransaction(ctx, event.ID)
-	if err == nil && existing != nil {
+	done()
+	duplicate := err == nil && existing != nil
+	audit.check("duplicate", !duplicate, "")
+	if duplicate {
+		audit.repeat = true
 		s.logger.Warn("Duplicate transaction",
 			zap.String("transactionID", event.ID),
 			zap.String("cardID", event.CardID),
 		)
 		return api.AuthorizationResponse{Action: "approve", Code: "duplicate-transaction"}, fmt.Errorf("duplicate transaction")
 	}
+
+	//evaluate the authorization rules in force for the card
+	facts := s.authorizationFacts(ctx, event, card, customer, *audit.record.Balance, time.Now())
+	done = timer.step("rules")
+	result, err := s.rules.Evaluate(card, facts)
+	done()
+	if err != nil {
+		s.logger.Error("Failed to evaluate rules", zap.String("cardID", event.CardID), zap.Error(err))
+		return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to evaluate rules: %w", err)
+	}
+	audit.rules(result)
+	//evaluate the candidate policy alongside; it never changes the decision
+	done = timer.step("shadow-rules")
+	audit.record.Shadow = s.evaluateShadow(card, facts, result)
+	done()
+	if result.Outcome == models.RuleDecline {
+		s.logger.Warn("Authorization declined by rule",
+			zap.String("cardID", event.CardID),
+			zap.String("ruleID", result.RuleID),
+			zap.String("code", result.Code),
+			zap.Int64("totalAmount", totalAmount),
+			zap.Int64("availableBalance", available),
+		)
+		return api.AuthorizationResponse{Action: "decline", Code: result.Code}, fmt.Errorf("declined by rule %s", result.RuleID)
+	}
+
+	//record the approved authorization so updates and closure can be applied to it
+	transaction := newAuthorizationTransaction(event, card)
+	if standIn {
+		transaction.StandIn = true
+		transaction.ReviewStatus = "pending-review"
+	}
+	if len(result.Flags) > 0 {
+		transaction.Flags = result.Flags
+		transaction.ReviewStatus = "pending-review"
+	}
+	transaction.Timing = timer.timing(s.budget.Timeout, false)
+	done = timer.step("record")
+	err = s.approveAuthorization(ctx, transaction)
+	done()
+	if err != nil {
+		s.logger.Error("Failed to record authorization",
+			zap.String("authorizationID", event.ID),
+			zap.Error(err),
+		)
+		return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to record authorization: %w", err)
+	}
 	s.logger.Info("Authorization approved",
 		zap.String("cardID", event.CardID),
 		zap.String("type", event.Type),
 		zap.Int64("totalAmount", totalAmount),
+		zap.Bool("standIn", standIn),
+		zap.Strings("flags", result.Flags),
 	)
 
-	//handle closed authorization
-
-	return api.AuthorizationResponse{
+	response := api.AuthorizationResponse{
 		Action:         "approve",
-		CardBalance:    balance.Data.Available,
+		CardBalance:    available,
 		CardHolderName: customer.Name,
-	}, nil
+	}
+	if standIn {
+		response.Metadata = map[string]interface{}{"standIn": true}
+	}
+	return response, nil
 }
 
 // isChannelAllowed checks if a channel is allowed for the card controls.
 func (s *WebhookService) isChannelAllowed(controls api.CardControls, channel string) bool {
-	for _, allowed := range controls.AllowedChannels {
-		if allowed == channel {
-			return true
-		}
-	}
-	for _, blocked := range controls.BlockedChannels {
-		if blocked == channel {
-			return false
-		}
-	}
-	return len(controls.AllowedChannels) == 0
+	return allowThenBlock(controls.AllowedChannels, controls.BlockedChannels, func(entry string) bool {
+		return entry == channel
+	})
 }
 
+// closableStatuses are the transaction statuses an authorization can be closed from.
+var closableStatuses = map[string]bool{"pending": true, "expired": true, "reversed": true}
+
 // HandleAuthorizationClosed processes an authorization closed event and returns an authorization response.
 func (s *WebhookService) HandleAuthorizationClosed(ctx context.Context, event api.AuthorizationClosedEvent) (api.AuthorizationResponse, error) {
 	// fetch original transaction
@@ -230,23 +389,98 @@ func (s *WebhookService) HandleAuthorizationClosed(ctx context.Context, event ap
 		return api.AuthorizationResponse{Action: "decline", Code: "invalid-transaction"}, fmt.Errorf("failed to fetch original transaction: %w", err)
 	}
 
-	if event.Status == "approved" {
-		update := bson.M{
-			"$set": bson.M{
-				"status":    event.Status,
-				"amount":    event.Amount,
-				"fees":      event.Fees,
-				"updatedAt": time.Now().UTC().Format(time.RFC3339),
-			},
+	//a closed authorization keeps the outcome of its first closure
+	if !closableStatuses[original.Status] {
+		s.logger.Info("Authorization already closed",
+			zap.String("authorizationID", event.ID),
+			zap.String("status", original.Status),
+		)
+		if original.Status == "approved" {
+			return api.AuthorizationResponse{Action: "approve"}, nil
 		}
-		_, err = s.store.Transactions.UpdateOne(ctx, bson.M{"id": event.ID}, update)
+		return api.AuthorizationResponse{Action: "decline"}, nil
+	}
+
+	//reconcile the closing amount against the amount history of the authorization
+	reconciliation := reconcileAuthorization(*original, event)
+	if !reconciliation.Matched {
+		s.logger.Warn("Closed authorization does not match its amount history",
+			zap.String("authorizationID", event.ID),
+			zap.Int64("expectedAmount", reconciliation.ExpectedAmount),
+			zap.Int64("closedAmount", reconciliation.ClosedAmount),
+		)
+	}
+	now := time.Now().UTC()
+	set := bson.M{
+		"status":         event.Status,
+		"reconciliation": reconciliation,
+		"updatedAt":      now.Format(time.RFC3339),
+	}
+	if event.Status == "approved" {
+		set["amount"] = event.Amount
+		set["fees"] = event.Fees
+	}
+	update := bson.M{
+		"$set": set,
+		"$push": bson.M{"history": models.AmountChange{
+			Type:           models.AmountChangeClosed,
+			EventStatus:    event.Status,
+			PreviousAmount: original.Amount,
+			Amount:         event.Amount,
+			Delta:          event.Amount - original.Amount,
+			Fees:           event.Fees,
+			At:             now,
+		}},
+	}
+
+	//the issuer has settled or dropped the authorization, so its hold is no longer needed
+	holdStatus := models.HoldReleased
+	if event.Status == "approved" {
+		holdStatus = models.HoldCaptured
+	}
+	//an expired authorization has had its funds released already
+	held := original.Amount + original.Fees
+	if original.Status == "expired" {
+		held = 0
+	}
+
+	// The transaction, its hold and its ledger entries change together, so a
+	// failure part way leaves none of them changed and the redelivered event
+	// closes the authorization again.
+	err = s.store.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		// Match on the status we read so a concurrent closure cannot be applied twice.
+		result, err := s.store.Transactions.UpdateOne(sc, bson.M{
+			"authorizationId": event.ID,
+			"status":          original.Status,
+		}, update)
 		if err != nil {
-			s.logger.Error("Failed to update transaction for approval",
+			s.logger.Error("Failed to update transaction for closure",
 				zap.String("authorizationID", event.ID),
 				zap.Error(err),
 			)
-			return api.AuthorizationResponse{Action: "decline", Code: "error"}, fmt.Errorf("failed to update transaction: %w", err)
+			return fmt.Errorf("failed to update transaction: %w", err)
+		}
+		if result.MatchedCount == 0 {
+			s.logger.Warn("Transaction changed while closing", zap.String("authorizationID", event.ID))
+			return fmt.Errorf("transaction %s changed concurrently", event.ID)
+		}
+
+		if err := s.holds.Release(sc, event.ID, holdStatus, "closed-"+event.Status); err != nil {
+			return err
 		}
+		if original.AccountID == "" {
+			return nil
+		}
+		if event.Status == "approved" {
+			return s.ledger.RecordCapture(sc, original.AccountID, event.ID, held, event.Amount, event.Fees, original.Currency)
+		}
+		return s.ledger.RecordRelease(sc, original.AccountID, event.ID, models.EntryRelease, held, original.Currency)
+	})
+	if err != nil {
+		return api.AuthorizationResponse{Action: "decline", Code: "error"}, err
+	}
+
+	if event.Status == "approved" {
 		s.logger.Info("Authorization closed approved",
 			zap.String("cardID", event.CardID),
 			zap.Int64("totalAmount", event.Amount+event.Fees),
@@ -255,3 +489,35 @@ func (s *WebhookService) HandleAuthorizationClosed(ctx context.Context, event ap
 	}
 	return api.AuthorizationResponse{Action: "decline"}, nil
 }
+
+// toNetworkDataModel converts network data from an event into its stored form.
+func toNetworkDataModel(data api.NetworkData) models.NetworkData {
+	acceptor := api.ParseCardAcceptorNameLocation(data.CardAcceptorNameLocation)
+	return models.NetworkData{
+		CardAcceptorNameLocation: data.CardAcceptorNameLocation,
+		TerminalID:               data.TerminalID,
+		Network:                  data.Network,
+		Reference:                data.Reference,
+		RRN:                      data.RRN,
+		STAN:                     data.STAN,
+		MCC:                      data.MCC,
+		MerchantID:               data.MerchantID,
+		MerchantName:             acceptor.Name,
+		MerchantCity:             acceptor.City,
+		MerchantCountry:          api.TransactionCountry(data),
+	}
+}
+
+// handlePaymentEvent processes a payment event on a customer sub-account.
+func (s *WebhookService) handlePaymentEvent(ctx context.Context, payment models.PaymentData) (api.AuthorizationResponse, error) {
+	s.logger.Info("Received payment event",
+		zap.String("paymentID", payment.ID),
+		zap.String("virtualAccountID", payment.VirtualAccountID),
+		zap.String("status", payment.Status),
+		zap.Int64("amount", payment.Amount),
+	)
+	if _, err := s.deposits.Process(ctx, payment); err != nil {
+		return api.AuthorizationResponse{}, err
+	}
+	return api.AuthorizationResponse{}, nil
+}
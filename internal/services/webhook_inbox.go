@@ -0,0 +1,298 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidSignature is returned when a webhook signature does not match the body.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrEventInProgress is returned when a redelivery arrives while the first delivery is still processing.
+	ErrEventInProgress = errors.New("event is still being processed")
+	// ErrWebhookEventNotFound is returned when an inbox event does not exist.
+	ErrWebhookEventNotFound = errors.New("webhook event not found")
+	// ErrReplayNotAllowed is returned when replaying an event that was rejected on receipt.
+	ErrReplayNotAllowed = errors.New("rejected events cannot be replayed")
+
+	errUnhandledEvent = errors.New("no handler registered for event")
+)
+
+// inProgressTimeout is how long a received event may stay unprocessed before a
+// redelivery is allowed to process it again.
+const inProgressTimeout = time.Minute
+
+// Triggers recorded on inbox processing attempts.
+const (
+	triggerDelivery   = "delivery"
+	triggerRedelivery = "redelivery"
+	triggerReplay     = "replay"
+)
+
+type repeatedEventContext struct{}
+
+// withRepeatedEvent marks ctx as processing an event that was processed
+// before, by a redelivery or a replay.
+func withRepeatedEvent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, repeatedEventContext{}, true)
+}
+
+// isRepeatedEvent reports whether ctx processes an event that was processed before.
+func isRepeatedEvent(ctx context.Context) bool {
+	repeated, _ := ctx.Value(repeatedEventContext{}).(bool)
+	return repeated
+}
+
+// WebhookEventFilter narrows a listing of inbox events.
+type WebhookEventFilter struct {
+	Event  string
+	Status string
+}
+
+// ReceiveWebhook stores a raw inbound webhook in the inbox and runs it through
+// the pipeline. Redeliveries of an already processed event are answered with
+// the decision recorded for the first delivery.
+func (s *WebhookService) ReceiveWebhook(ctx context.Context, headers http.Header, body []byte, signatureValid bool) (api.AuthorizationResponse, error) {
+	now := time.Now().UTC()
+	record := models.WebhookEventRecord{
+		Headers:         flattenHeaders(headers),
+		Body:            string(body),
+		SignatureValid:  signatureValid,
+		Status:          models.WebhookEventReceived,
+		Deliveries:      1,
+		Attempts:        []models.WebhookEventAttempt{},
+		ReceivedAt:      now,
+		LastDeliveredAt: now,
+	}
+
+	var event api.WebhookEvent
+	decodeErr := json.Unmarshal(body, &event)
+	if decodeErr == nil {
+		record.Event = event.Event
+		record.Key = webhookEventKey(event, body)
+	}
+
+	switch {
+	case !signatureValid:
+		record.Status = models.WebhookEventRejected
+		record.Error = ErrInvalidSignature.Error()
+	case decodeErr != nil:
+		record.Status = models.WebhookEventRejected
+		record.Error = decodeErr.Error()
+	}
+	if record.Status == models.WebhookEventRejected {
+		if _, err := s.store.InsertWebhookEvent(ctx, record); err != nil {
+			s.logger.Error("Failed to store rejected webhook event", zap.Error(err))
+		}
+		if !signatureValid {
+			return api.AuthorizationResponse{}, ErrInvalidSignature
+		}
+		return api.AuthorizationResponse{}, fmt.Errorf("%w: %v", ErrInvalidEventPayload, decodeErr)
+	}
+
+	id, err := s.store.InsertWebhookEvent(ctx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		existing, err := s.store.GetWebhookEventByKey(ctx, record.Key)
+		if err != nil {
+			s.logger.Error("Failed to fetch original webhook event", zap.String("key", record.Key), zap.Error(err))
+			return api.AuthorizationResponse{}, fmt.Errorf("failed to fetch original webhook event: %w", err)
+		}
+		return s.redeliver(ctx, existing)
+	}
+	if err != nil {
+		s.logger.Error("Failed to store webhook event", zap.String("event", record.Event), zap.Error(err))
+		return api.AuthorizationResponse{}, fmt.Errorf("failed to store webhook event: %w", err)
+	}
+	record.ID = id
+	return s.processInboxEvent(ctx, &record, triggerDelivery)
+}
+
+// redeliver answers a redelivered event from the inbox, processing it again
+// only when no decision was recorded for it.
+func (s *WebhookService) redeliver(ctx context.Context, record *models.WebhookEventRecord) (api.AuthorizationResponse, error) {
+	s.logger.Info("Received redelivery of webhook event",
+		zap.String("key", record.Key),
+		zap.String("status", record.Status),
+		zap.Int("deliveries", record.Deliveries+1),
+	)
+	err := s.store.UpdateWebhookEvent(ctx, record.ID, bson.M{
+		"$inc": bson.M{"deliveries": 1},
+		"$set": bson.M{"lastDeliveredAt": time.Now().UTC()},
+	})
+	if err != nil {
+		s.logger.Error("Failed to record redelivery", zap.String("key", record.Key), zap.Error(err))
+	}
+
+	switch record.Status {
+	case models.WebhookEventProcessed:
+		if record.Response == nil {
+			return api.AuthorizationResponse{}, nil
+		}
+		return *record.Response, nil
+	case models.WebhookEventUnhandled:
+		return api.AuthorizationResponse{}, nil
+	case models.WebhookEventReceived:
+		if time.Since(record.LastDeliveredAt) < inProgressTimeout {
+			return api.AuthorizationResponse{}, ErrEventInProgress
+		}
+	}
+	return s.processInboxEvent(ctx, record, triggerRedelivery)
+}
+
+// processInboxEvent runs a stored event through HandleWebhook and records the
+// outcome. The first decision recorded for an event is never overwritten.
+func (s *WebhookService) processInboxEvent(ctx context.Context, record *models.WebhookEventRecord, trigger string) (api.AuthorizationResponse, error) {
+	var event api.WebhookEvent
+	if err := json.Unmarshal([]byte(record.Body), &event); err != nil {
+		return api.AuthorizationResponse{}, fmt.Errorf("%w: %v", ErrInvalidEventPayload, err)
+	}
+
+	if trigger != triggerDelivery {
+		ctx = withRepeatedEvent(ctx)
+	}
+	response, err := s.HandleWebhook(ctx, event)
+	status := models.WebhookEventProcessed
+	switch {
+	case errors.Is(err, errUnhandledEvent):
+		status = models.WebhookEventUnhandled
+	case errors.Is(err, ErrInvalidEventPayload):
+		status = models.WebhookEventRejected
+	case err != nil && response.Action == "":
+		status = models.WebhookEventFailed
+	}
+
+	now := time.Now().UTC()
+	attempt := models.WebhookEventAttempt{
+		Trigger: trigger,
+		Status:  status,
+		At:      now,
+	}
+	if status == models.WebhookEventProcessed {
+		attempt.Response = &response
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	update := bson.M{"$push": bson.M{"attempts": attempt}}
+	if record.Status != models.WebhookEventProcessed {
+		set := bson.M{"status": status, "error": attempt.Error}
+		if status != models.WebhookEventFailed {
+			set["response"] = attempt.Response
+			set["processedAt"] = now
+		}
+		update["$set"] = set
+	}
+	if err := s.store.UpdateWebhookEvent(ctx, record.ID, update); err != nil {
+		s.logger.Error("Failed to record webhook event outcome",
+			zap.String("id", record.ID.Hex()),
+			zap.String("status", status),
+			zap.Error(err),
+		)
+	}
+
+	if status == models.WebhookEventUnhandled {
+		return response, nil
+	}
+	return response, err
+}
+
+// ListWebhookEvents returns a page of inbox events, newest first.
+func (s *WebhookService) ListWebhookEvents(ctx context.Context, filter WebhookEventFilter, page, limit int64) ([]models.WebhookEventRecord, error) {
+	query := bson.M{}
+	if filter.Event != "" {
+		query["event"] = filter.Event
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	records, err := s.store.ListWebhookEvents(ctx, query, (page-1)*limit, limit)
+	if err != nil {
+		s.logger.Error("Failed to list webhook events", zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook events: %w", err)
+	}
+	return records, nil
+}
+
+// GetWebhookEvent returns a single inbox event.
+func (s *WebhookService) GetWebhookEvent(ctx context.Context, id string) (*models.WebhookEventRecord, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrWebhookEventNotFound
+	}
+	record, err := s.store.GetWebhookEvent(ctx, objectID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrWebhookEventNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to fetch webhook event", zap.String("id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to fetch webhook event: %w", err)
+	}
+	return record, nil
+}
+
+// ReplayWebhookEvent runs a stored event through the pipeline again. The
+// attempt is recorded on the event; an existing decision is kept as is.
+func (s *WebhookService) ReplayWebhookEvent(ctx context.Context, id string) (api.AuthorizationResponse, error) {
+	record, err := s.GetWebhookEvent(ctx, id)
+	if err != nil {
+		return api.AuthorizationResponse{}, err
+	}
+	if !record.SignatureValid || record.Key == "" {
+		return api.AuthorizationResponse{}, ErrReplayNotAllowed
+	}
+	s.logger.Info("Replaying webhook event", zap.String("id", id), zap.String("event", record.Event))
+	return s.processInboxEvent(ctx, record, triggerReplay)
+}
+
+// updateEvents are the events the issuer sends more than once for the same
+// data ID, once for every change of the authorization or payment.
+var updateEvents = map[string]bool{
+	"card.authorization.updated": true,
+	"payment.updated":            true,
+}
+
+// webhookEventKey identifies an event across redeliveries: the event name and
+// the ID of its data, or a hash of the body when the data carries no ID.
+// Updates also carry the status, amount and time of the change, so distinct
+// updates of one authorization or payment get distinct keys.
+func webhookEventKey(event api.WebhookEvent, body []byte) string {
+	var data struct {
+		ID        string `json:"id"`
+		Status    string `json:"status"`
+		Amount    int64  `json:"amount"`
+		CreatedAt string `json:"createdAt"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err == nil && data.ID != "" {
+		if updateEvents[event.Event] {
+			return fmt.Sprintf("%s:%s:%s:%d:%s", event.Event, data.ID, data.Status, data.Amount, data.CreatedAt)
+		}
+		return event.Event + ":" + data.ID
+	}
+	sum := sha256.Sum256(body)
+	return event.Event + ":sha256:" + hex.EncodeToString(sum[:])
+}
+
+// flattenHeaders keeps the first value of each request header.
+func flattenHeaders(headers http.Header) map[string]string {
+	flat := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if len(values) > 0 {
+			flat[name] = values[0]
+		}
+	}
+	return flat
+}
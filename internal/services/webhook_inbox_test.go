@@ -0,0 +1,112 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/simulator"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// testWebhookBody encodes a webhook as the issuer sends it.
+func testWebhookBody(t *testing.T, event string, data interface{}) []byte {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := api.WebhookEvent{Event: event, Data: raw}
+	envelope.Metadata.Event = event
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestWebhookEventKeySeparatesUpdates(t *testing.T) {
+	key := func(event string, data interface{}) string {
+		body := testWebhookBody(t, event, data)
+		var envelope api.WebhookEvent
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			t.Fatal(err)
+		}
+		return webhookEventKey(envelope, body)
+	}
+	increment := api.AuthorizationUpdateEvent{ID: "auth_1", Amount: 60_000, Status: "pending", CreatedAt: "2024-05-14T11:00:00Z"}
+	reversal := api.AuthorizationUpdateEvent{ID: "auth_1", Amount: 20_000, Status: "pending", CreatedAt: "2024-05-14T11:05:00Z"}
+	pending := models.PaymentData{ID: "pay_1", Amount: 5_000, Status: "pending"}
+	completed := models.PaymentData{ID: "pay_1", Amount: 5_000, Status: "completed"}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{name: "redelivered update", a: key("card.authorization.updated", increment), b: key("card.authorization.updated", increment), equal: true},
+		{name: "two updates of an authorization", a: key("card.authorization.updated", increment), b: key("card.authorization.updated", reversal)},
+		{name: "two updates of a payment", a: key("payment.updated", pending), b: key("payment.updated", completed)},
+		{name: "request and closure of an authorization", a: key("card.authorization.request", increment), b: key("card.authorization.closed", increment)},
+		{name: "redelivered request", a: key("card.authorization.request", increment), b: key("card.authorization.request", reversal), equal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.equal {
+				t.Errorf("keys %q and %q equal = %v; want %v", tt.a, tt.b, tt.a == tt.b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestWebhookInboxAppliesEveryUpdate(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 100_000})
+	service := testWebhookService(t, db, sim)
+	card := models.Card{CardID: "card_1", CustomerID: customerID, FundingSource: accountID, Status: models.CardActive}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	request := testAuthorization(card.CardID, 40_000)
+	response, err := service.ReceiveWebhook(ctx, nil, testWebhookBody(t, "card.authorization.request", request), true)
+	if response.Action != "approve" {
+		t.Fatalf("authorization = %s %q, %v; want approve", response.Action, response.Code, err)
+	}
+
+	updates := []struct {
+		name   string
+		event  api.AuthorizationUpdateEvent
+		amount int64 // Amount of the authorization after the update
+	}{
+		{name: "incremental authorization", event: api.AuthorizationUpdateEvent{ID: request.ID, CardID: card.CardID, Amount: 60_000, Currency: "NGN", Status: "pending", CreatedAt: "2024-05-14T11:00:00Z"}, amount: 60_000},
+		{name: "partial reversal", event: api.AuthorizationUpdateEvent{ID: request.ID, CardID: card.CardID, Amount: 20_000, Currency: "NGN", Status: "pending", CreatedAt: "2024-05-14T11:05:00Z"}, amount: 20_000},
+		{name: "redelivered partial reversal", event: api.AuthorizationUpdateEvent{ID: request.ID, CardID: card.CardID, Amount: 20_000, Currency: "NGN", Status: "pending", CreatedAt: "2024-05-14T11:05:00Z"}, amount: 20_000},
+	}
+	for _, tt := range updates {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.ReceiveWebhook(ctx, nil, testWebhookBody(t, "card.authorization.updated", tt.event), true); err != nil {
+				t.Fatalf("ReceiveWebhook: %v", err)
+			}
+			transaction, err := db.GetTransaction(ctx, request.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transaction.Amount != tt.amount {
+				t.Errorf("amount = %d; want %d", transaction.Amount, tt.amount)
+			}
+			holds, err := db.SumOpenHolds(ctx, accountID)
+			if err != nil || holds != tt.amount {
+				t.Errorf("holds = %d (%v); want %d", holds, err, tt.amount)
+			}
+		})
+	}
+
+	transaction, err := db.GetTransaction(ctx, request.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transaction.History) != 3 {
+		t.Errorf("%d amount changes; want the authorization and two updates", len(transaction.History))
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrInvalidEventPayload is returned when the data of a known event cannot be
+// decoded into the payload type registered for it.
+var ErrInvalidEventPayload = errors.New("invalid event payload")
+
+// eventHandler decodes the raw data of a webhook event and processes it.
+type eventHandler func(ctx context.Context, data json.RawMessage) (api.AuthorizationResponse, error)
+
+// EventRegistry maps webhook event names to typed decoders and handlers.
+type EventRegistry struct {
+	handlers map[string]eventHandler
+}
+
+// NewEventRegistry creates an empty event registry.
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{handlers: make(map[string]eventHandler)}
+}
+
+// Register binds an event name to a handler. The event data is decoded into T
+// before the handler is called, so handlers never see untyped payloads.
+func Register[T any](r *EventRegistry, event string, handle func(context.Context, T) (api.AuthorizationResponse, error)) {
+	r.handlers[event] = func(ctx context.Context, data json.RawMessage) (api.AuthorizationResponse, error) {
+		var payload T
+		if len(data) == 0 {
+			return api.AuthorizationResponse{}, fmt.Errorf("%w: %s: missing data", ErrInvalidEventPayload, event)
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return api.AuthorizationResponse{}, fmt.Errorf("%w: %s: %v", ErrInvalidEventPayload, event, err)
+		}
+		return handle(ctx, payload)
+	}
+}
+
+// lookup returns the handler registered for an event, if any.
+func (r *EventRegistry) lookup(event string) (eventHandler, bool) {
+	handler, ok := r.handlers[event]
+	return handler, ok
+}
+
+// Events returns the registered event names in alphabetical order.
+func (r *EventRegistry) Events() []string {
+	events := make([]string, 0, len(r.handlers))
+	for event := range r.handlers {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bytes"
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// goldenSigningKey is the key the golden webhooks in testdata/webhooks are signed with.
+const goldenSigningKey = "whsec_golden_test"
+
+// goldenRegistry registers the payload types WebhookService registers, with
+// handlers that keep the decoded payload instead of processing it.
+func goldenRegistry(decoded *interface{}) *EventRegistry {
+	r := NewEventRegistry()
+	keep := func(ctx context.Context, payload interface{}) (api.AuthorizationResponse, error) {
+		*decoded = payload
+		return api.AuthorizationResponse{}, nil
+	}
+	Register(r, "card.transaction.created", func(ctx context.Context, e api.TransactionEvent) (api.AuthorizationResponse, error) {
+		return keep(ctx, e)
+	})
+	Register(r, "card.authorization.request", func(ctx context.Context, e api.AuthorizationRequestEvent) (api.AuthorizationResponse, error) {
+		return keep(ctx, e)
+	})
+	Register(r, "card.authorization.updated", func(ctx context.Context, e api.AuthorizationUpdateEvent) (api.AuthorizationResponse, error) {
+		return keep(ctx, e)
+	})
+	Register(r, "card.authorization.closed", func(ctx context.Context, e api.AuthorizationClosedEvent) (api.AuthorizationResponse, error) {
+		return keep(ctx, e)
+	})
+	Register(r, "payment.created", func(ctx context.Context, e models.PaymentData) (api.AuthorizationResponse, error) {
+		return keep(ctx, e)
+	})
+	Register(r, "payment.updated", func(ctx context.Context, e models.PaymentData) (api.AuthorizationResponse, error) {
+		return keep(ctx, e)
+	})
+	return r
+}
+
+func TestWebhookRegistryMatchesGoldenEvents(t *testing.T) {
+	var decoded interface{}
+	service := NewWebhookService(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, DecisionBudget{}, zap.NewNop())
+	if got, want := service.registry.Events(), goldenRegistry(&decoded).Events(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("registered events = %v; want %v", got, want)
+	}
+	// Unknown events are kept in the inbox as unhandled rather than rejected
+	if _, err := service.HandleWebhook(context.Background(), api.WebhookEvent{Event: "card.dispute.created"}); !errors.Is(err, errUnhandledEvent) {
+		t.Errorf("HandleWebhook(card.dispute.created) error = %v; want errUnhandledEvent", err)
+	}
+}
+
+func TestWebhookGoldenPayloads(t *testing.T) {
+	network := api.NetworkData{
+		CardAcceptorNameLocation: "SHOPRITE LEKKI          LAGOS        NG",
+		TerminalID:               "2058LK01",
+		Network:                  "Verve",
+		Reference:                "401512345678",
+		RRN:                      "401512345678",
+		STAN:                     "123456",
+		MCC:                      "5411",
+		MerchantID:               "2058LA000012345",
+	}
+	withCountry := network
+	withCountry.MerchantCountry = "NG"
+
+	tests := []struct {
+		file      string
+		signature string
+		event     string
+		want      interface{} // Decoded payload; nil for events without a handler
+	}{
+		{
+			file:      "transaction_created.json",
+			signature: "260e1af01c1ea8c8463d70f648294b1a59ec1d2e0814fada9ca1389946fec0e6a4adcc7d995d8e0d24d09b012b885510bc297f7316e221132d76ab4218bc9582",
+			event:     "card.transaction.created",
+			want: api.TransactionEvent{
+				ID: "txn_01HV6Z8C", Authorization: "auth_01HV6Z7K", CardID: "card_01HV5M2Q", CustomerID: "cus_01HV5KX9",
+				Amount: 250000, Currency: "NGN", Type: "capture", Fees: 1500, Channel: "POS",
+				NetworkData: network, CreatedAt: "2024-05-14T10:15:02Z",
+			},
+		},
+		{
+			file:      "authorization_request.json",
+			signature: "b1343852e8770dd4b54cf294eebff14a91cf21bd5542e7587bcbe033469e58fa0ec970575cafae691b11384720f3f2af25ba00c108c5546e2ffd1c276227038a",
+			event:     "card.authorization.request",
+			want: api.AuthorizationRequestEvent{
+				ID: "auth_01HV6Z7K", CardID: "card_01HV5M2Q", CustomerID: "cus_01HV5KX9",
+				Amount: 250000, Currency: "NGN", Type: "purchase", Fees: 1500, Channel: "POS", Status: "pending",
+				NetworkData: withCountry, CreatedAt: "2024-05-14T10:14:58Z",
+			},
+		},
+		{
+			file:      "authorization_updated.json",
+			signature: "95c8b0a626d1d9cf4b7956209e0cfef1bd7404fdace63d7c12f2f7af104f199e12f8bfd3be10fed00104531efdeeaf6aac6a17ae7548d6ef5d45c9f116a4a0ab",
+			event:     "card.authorization.updated",
+			want: api.AuthorizationUpdateEvent{
+				ID: "auth_01HV6Z7K", CardID: "card_01HV5M2Q", Amount: 100000, Currency: "NGN", Type: "purchase",
+				Fees: 1500, Channel: "POS", NetworkData: network, CreatedAt: "2024-05-14T11:02:40Z",
+				DecisionType: "partial-reversal", Status: "reversed",
+			},
+		},
+		{
+			file:      "authorization_closed.json",
+			signature: "bae22b8db4bb90db5276ad26ffd9d36405c3fafd66724833a3b2fe30481e67e12ace3fc4d88d35fdee3160fbb5841be19d3e47f33aea5c98ddeff26fad965367",
+			event:     "card.authorization.closed",
+			want: api.AuthorizationClosedEvent{
+				ID: "auth_01HV6Z7K", CardID: "card_01HV5M2Q", Amount: 150000, Currency: "NGN", Type: "purchase",
+				Fees: 1500, Channel: "POS", NetworkData: network, CreatedAt: "2024-05-15T00:00:12Z",
+				DecisionType: "capture", Status: "approved",
+			},
+		},
+		{
+			file:      "payment_created.json",
+			signature: "bb440029cd899450e3babf2d8e1c0c819604bc8dae22e16ecf95e62a4b95f0574b3ed9537c6b7cd76a6ab3c415b4cb497299976cacc943cc46b86c3cf5e92054",
+			event:     "payment.created",
+			want: models.PaymentData{
+				ID: "pay_01HV70AB", CustomerID: "cus_01HV5KX9", VirtualAccountID: "va_01HV5KZ2",
+				Amount: 5000000, Currency: "NGN", Status: "completed", CreatedAt: time.Date(2024, 5, 14, 12, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			file:      "unknown_event.json",
+			signature: "f09dbaf9e469f993f9f34eaa80a761a88b7006a09deff2201e142449a3f11d42701af745467fc60dfef03c05f62f58d64c9130b5f8034705c6d174e4b45f1ece",
+			event:     "card.dispute.created",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "webhooks", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("valid", func(t *testing.T) {
+				if !api.VerifyWebhookSignature(goldenSigningKey, body, tt.signature) {
+					t.Error("golden signature rejected")
+				}
+			})
+			t.Run("tampered", func(t *testing.T) {
+				for name, tampered := range map[string][]byte{
+					"value":      bytes.Replace(body, []byte(`_01HV`), []byte(`_02HV`), 1),
+					"whitespace": bytes.Replace(body, []byte(`{`), []byte(`{ `), 1),
+					"truncated":  body[:len(body)-1],
+				} {
+					if api.VerifyWebhookSignature(goldenSigningKey, tampered, tt.signature) {
+						t.Errorf("signature accepted for a body with a tampered %s", name)
+					}
+				}
+			})
+			t.Run("wrong secret", func(t *testing.T) {
+				if api.VerifyWebhookSignature("whsec_other", body, tt.signature) {
+					t.Error("signature accepted under another signing key")
+				}
+				if api.VerifyWebhookSignature(goldenSigningKey, body, api.WebhookSignature("whsec_other", body)) {
+					t.Error("signature made with another signing key accepted")
+				}
+			})
+			t.Run("decode", func(t *testing.T) {
+				var event api.WebhookEvent
+				if err := json.Unmarshal(body, &event); err != nil {
+					t.Fatalf("failed to decode envelope: %v", err)
+				}
+				if event.Event != tt.event || event.Metadata.Event != tt.event {
+					t.Fatalf("event = %q (metadata %q); want %q", event.Event, event.Metadata.Event, tt.event)
+				}
+				var decoded interface{}
+				handler, ok := goldenRegistry(&decoded).lookup(event.Event)
+				if ok != (tt.want != nil) {
+					t.Fatalf("handler registered = %v; want %v", ok, tt.want != nil)
+				}
+				if !ok {
+					return
+				}
+				if _, err := handler(context.Background(), event.Data); err != nil {
+					t.Fatalf("handler: %v", err)
+				}
+				if !reflect.DeepEqual(decoded, tt.want) {
+					t.Errorf("decoded %#v\nwant %#v", decoded, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestWebhookRegistryRejectsInvalidPayloads(t *testing.T) {
+	var decoded interface{}
+	handler, _ := goldenRegistry(&decoded).lookup("card.authorization.request")
+	for name, data := range map[string]json.RawMessage{
+		"missing data": nil,
+		"wrong type":   json.RawMessage(`{"id":"auth_01HV6Z7K","amount":"250000"}`),
+		"not an event": json.RawMessage(`[1,2,3]`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := handler(context.Background(), data); !errors.Is(err, ErrInvalidEventPayload) {
+				t.Errorf("error = %v; want ErrInvalidEventPayload", err)
+			}
+		})
+	}
+}
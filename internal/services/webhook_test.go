@@ -0,0 +1,353 @@
+package services
+
+import (
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"card-service/internal/simulator"
+	"card-service/internal/store"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// testWebhookService wires a webhook service to the store and the issuer the
+// way the server does, with the default rules.
+func testWebhookService(t *testing.T, db *store.Store, issuer api.Issuer) *WebhookService {
+	t.Helper()
+	logger := zap.NewNop()
+	ledger := NewLedgerService(db, logger)
+	rules := NewRuleService(db, logger)
+	if err := rules.SeedDefaults(context.Background()); err != nil {
+		t.Fatalf("SeedDefaults: %v", err)
+	}
+	return NewWebhookService(db,
+		NewBalanceProvider(issuer, api.NewCircuitBreaker(5, time.Minute), db, logger),
+		NewStandInPolicy(nil, 0, time.Minute),
+		NewLimitEvaluator(db, time.UTC, logger),
+		NewHoldLedger(db, ledger, 7*24*time.Hour, logger),
+		ledger,
+		rules,
+		NewVelocityChecker(db, VelocityConfig{}, logger),
+		NewKYCService(db, nil, logger),
+		NewDepositService(db, ledger, logger),
+		DecisionBudget{Timeout: 10 * time.Second, Fallback: "decline"},
+		logger,
+	)
+}
+
+// testWebhookEndpoint serves the webhooks of the issuer like the webhook
+// handler does, so the simulator can drive the service through them.
+func testWebhookEndpoint(t *testing.T, signingKey string, service **WebhookService) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		valid := api.VerifyWebhookSignature(signingKey, body, r.Header.Get("Allawee-Signature"))
+		response, err := (*service).ReceiveWebhook(r.Context(), r.Header, body, valid)
+		if err != nil && response.Action == "" {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testAuthorization returns a pending POS purchase on a card.
+func testAuthorization(cardID string, amount int64) api.AuthorizationRequestEvent {
+	return api.AuthorizationRequestEvent{
+		ID:        fmt.Sprintf("auth_%d", time.Now().UnixNano()),
+		CardID:    cardID,
+		Amount:    amount,
+		Currency:  "NGN",
+		Type:      "purchase",
+		Channel:   "POS",
+		Status:    "pending",
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func TestConcurrentAuthorizationsShareSpendingLimit(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 1_000_000})
+	card := models.Card{
+		CardID:        "card_1",
+		CustomerID:    customerID,
+		FundingSource: accountID,
+		Status:        models.CardActive,
+		Controls: api.CardControls{
+			SpendingLimits: []api.SpendingLimit{{Amount: 50_000, Interval: IntervalDaily}},
+		},
+	}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	// Two instances decide the authorizations of the same account at once
+	instances := []*WebhookService{testWebhookService(t, db, sim), testWebhookService(t, db, sim)}
+
+	var wg sync.WaitGroup
+	responses := make([]api.AuthorizationResponse, 10)
+	for i := range responses {
+		event := testAuthorization(card.CardID, 10_000)
+		event.ID = fmt.Sprintf("auth_%d", i)
+		wg.Add(1)
+		go func(i int, service *WebhookService) {
+			defer wg.Done()
+			responses[i], _ = service.HandleAuthorizationRequest(ctx, event)
+		}(i, instances[i%2])
+	}
+	wg.Wait()
+
+	approved := 0
+	for _, response := range responses {
+		switch {
+		case response.Action == "approve":
+			approved++
+		case response.Code != "spending-limit":
+			t.Errorf("declined with %q; want spending-limit", response.Code)
+		}
+	}
+	if approved != 5 {
+		t.Errorf("%d authorizations approved; want the 5 that fit the limit", approved)
+	}
+	spent, err := db.SumCardSpend(ctx, card.CardID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 50_000 {
+		t.Errorf("spend = %d; want 50000", spent)
+	}
+	if count, err := db.Locks.CountDocuments(ctx, bson.M{}); err != nil || count != 0 {
+		t.Errorf("%d locks left after the authorizations (%v); want 0", count, err)
+	}
+}
+
+func TestAuthorizationsThroughSimulator(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	var service *WebhookService
+	endpoint := testWebhookEndpoint(t, "whsec_sandbox", &service)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{
+		WebhookURL:     endpoint.URL,
+		SigningKey:     "whsec_sandbox",
+		OpeningBalance: 100_000,
+	})
+	service = testWebhookService(t, db, sim)
+
+	linked, err := sim.LinkCard(ctx, api.LinkCardRequest{Pan: "5399000011112222", Customer: customerID, FundingSource: accountID})
+	if err != nil {
+		t.Fatalf("LinkCard: %v", err)
+	}
+	card := models.Card{
+		CardID:        linked.Data.ID,
+		CustomerID:    customerID,
+		FundingSource: accountID,
+		Status:        models.CardActive,
+		Controls: api.CardControls{
+			SpendingLimits:   []api.SpendingLimit{{Amount: 60_000, Interval: IntervalDaily}},
+			BlockedMerchants: []string{"BETKING"},
+		},
+	}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		merchant string
+		amount   int64
+		action   string
+		code     string
+		balance  int64 // Issuer balance after the authorization
+	}{
+		{name: "approved", merchant: "SHOPRITE LEKKI          LAGOS        NG", amount: 40_000, action: "approve", balance: 60_000},
+		{name: "over the balance", merchant: "SHOPRITE LEKKI          LAGOS        NG", amount: 70_000, action: "decline", code: "insufficient-funds", balance: 60_000},
+		{name: "over the spending limit", merchant: "SHOPRITE LEKKI          LAGOS        NG", amount: 30_000, action: "decline", code: "spending-limit", balance: 60_000},
+		{name: "blocked merchant", merchant: "BETKING                 LAGOS        NG", amount: 1_000, action: "decline", code: "merchant-control", balance: 60_000},
+		{name: "within the limit", merchant: "SHOPRITE LEKKI          LAGOS        NG", amount: 20_000, action: "approve", balance: 40_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := sim.Authorize(ctx, simulator.Authorization{
+				CardID:   card.CardID,
+				Amount:   tt.amount,
+				Channel:  "POS",
+				MCC:      "5411",
+				Merchant: tt.merchant,
+			})
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			if response.Action != tt.action || response.Code != tt.code {
+				t.Errorf("response = %s %q; want %s %q", response.Action, response.Code, tt.action, tt.code)
+			}
+			balance, err := sim.GetAccountBalance(ctx, accountID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance.Data.Available != tt.balance {
+				t.Errorf("issuer balance = %d; want %d", balance.Data.Available, tt.balance)
+			}
+		})
+	}
+
+	// The approved authorizations were closed and captured
+	spent, err := db.SumCardSpend(ctx, card.CardID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 60_000 {
+		t.Errorf("spend = %d; want 60000", spent)
+	}
+	count, err := db.Transactions.CountDocuments(ctx, bson.M{"cardId": card.CardID, "status": "approved"})
+	if err != nil || count != 2 {
+		t.Errorf("%d approved transactions (%v); want 2", count, err)
+	}
+}
+
+func TestRepeatedAuthorizationsSkipVelocity(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 100_000})
+	service := testWebhookService(t, db, sim)
+	card := models.Card{CardID: "card_1", CustomerID: customerID, FundingSource: accountID, Status: models.CardActive}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	approved := testAuthorization(card.CardID, 10_000)
+	approved.ID = "auth_approved"
+	declined := testAuthorization(card.CardID, 500_000)
+	declined.ID = "auth_declined"
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		event  api.AuthorizationRequestEvent
+		action string
+		events int // Velocity events of the card after the decision
+	}{
+		{name: "approval", ctx: ctx, event: approved, action: "approve", events: 1},
+		{name: "duplicate of the approval", ctx: ctx, event: approved, action: "approve", events: 1},
+		{name: "decline", ctx: ctx, event: declined, action: "decline", events: 2},
+		{name: "replayed decline", ctx: withRepeatedEvent(ctx), event: declined, action: "decline", events: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Decide and record in the foreground, as HandleAuthorizationRequest
+			// does in the background
+			audit := newDecisionAudit(tt.ctx, tt.event)
+			response, err := service.authorize(tt.ctx, tt.event, newDecisionTimer(), audit)
+			if response.Action != tt.action {
+				t.Fatalf("response = %s %q, %v; want %s", response.Action, response.Code, err, tt.action)
+			}
+			service.recordDecision(tt.ctx, audit, response, err, nil)
+
+			velocity, err := db.GetCardVelocity(ctx, card.CardID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if velocity == nil || len(velocity.Events) != tt.events {
+				t.Errorf("velocity = %+v; want %d events", velocity, tt.events)
+			}
+		})
+	}
+}
+
+func TestClosingAuthorizationsSettlesOnce(t *testing.T) {
+	ctx := context.Background()
+	db := testStore(t)
+	sim, customerID, accountID := testIssuer(t, db, simulator.Config{OpeningBalance: 100_000})
+	service := testWebhookService(t, db, sim)
+	if err := service.ledger.OpenAccount(ctx, accountID, customerID, "NGN"); err != nil {
+		t.Fatalf("OpenAccount: %v", err)
+	}
+	if err := service.ledger.RecordDeposit(ctx, accountID, models.PaymentData{ID: "pay_1", Amount: 100_000, Currency: "NGN"}); err != nil {
+		t.Fatalf("RecordDeposit: %v", err)
+	}
+	card := models.Card{CardID: "card_1", CustomerID: customerID, FundingSource: accountID, Status: models.CardActive}
+	if _, err := db.Cards.InsertOne(ctx, card); err != nil {
+		t.Fatal(err)
+	}
+	authorize := func(amount int64) api.AuthorizationRequestEvent {
+		t.Helper()
+		request := testAuthorization(card.CardID, amount)
+		if response, err := service.HandleAuthorizationRequest(ctx, request); response.Action != "approve" {
+			t.Fatalf("authorization = %s %q, %v; want approve", response.Action, response.Code, err)
+		}
+		return request
+	}
+	// check closes an authorization and verifies the transaction and the
+	// account balances after the closure.
+	check := func(request api.AuthorizationRequestEvent, amount int64, status, want string, available int64) {
+		t.Helper()
+		closed := api.AuthorizationClosedEvent{ID: request.ID, CardID: card.CardID, Amount: amount, Currency: "NGN", Status: status}
+		response, err := service.HandleAuthorizationClosed(ctx, closed)
+		if err != nil {
+			t.Fatalf("HandleAuthorizationClosed(%s) error = %v", status, err)
+		}
+		action := "decline"
+		if want == "approved" {
+			action = "approve"
+		}
+		if response.Action != action {
+			t.Errorf("HandleAuthorizationClosed(%s) = %s; want %s", status, response.Action, action)
+		}
+		transaction, err := db.GetTransaction(ctx, request.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		closures := 0
+		for _, change := range transaction.History {
+			if change.Type == models.AmountChangeClosed {
+				closures++
+			}
+		}
+		if transaction.Status != want || closures != 1 {
+			t.Errorf("transaction status = %s with %d closures; want %s with 1", transaction.Status, closures, want)
+		}
+		holds, err := db.SumOpenHolds(ctx, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		balances, _, err := service.ledger.Balances(ctx, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if holds != 0 || balances.Held != 0 || balances.Available != available {
+			t.Errorf("holds = %d, ledger held %d and available %d; want 0 held and %d available", holds, balances.Held, balances.Available, available)
+		}
+	}
+
+	settled := authorize(40_000)
+	check(settled, 35_000, "approved", "approved", 65_000)
+	// A repeated or conflicting closure leaves the first one in place
+	check(settled, 35_000, "approved", "approved", 65_000)
+	check(settled, 0, "declined", "approved", 65_000)
+
+	// A late capture of an expired authorization only pays out the capture
+	expired := authorize(20_000)
+	if n, err := service.holds.ExpireStale(ctx, time.Now().UTC().Add(8*24*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("ExpireStale = %d, %v; want 1", n, err)
+	}
+	check(expired, 20_000, "approved", "approved", 45_000)
+	check(expired, 20_000, "approved", "approved", 45_000)
+
+	declined := authorize(10_000)
+	check(declined, 0, "declined", "declined", 45_000)
+	check(declined, 10_000, "approved", "declined", 45_000)
+}
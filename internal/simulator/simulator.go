@@ -0,0 +1,587 @@
+package simulator
+
+import (
+	"bytes"
+	"card-service/internal/api"
+	"card-service/internal/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInjected is returned by a call failed by an injected fault.
+	ErrInjected = errors.New("simulated issuer failure")
+	// ErrNotFound is returned when a call names a customer, account or card the simulator does not hold.
+	ErrNotFound = errors.New("not found in simulator")
+	// ErrInvalidRequest is returned when a call is rejected the way the issuer would reject it.
+	ErrInvalidRequest = errors.New("invalid issuer request")
+	// ErrWebhooksDisabled is returned when an event is emitted without a webhook URL.
+	ErrWebhooksDisabled = errors.New("simulator webhooks are disabled")
+)
+
+// Simulated operations, as named when injecting faults.
+const (
+	OpCreateCustomer     = "create-customer"
+	OpCreateSubAccount   = "create-sub-account"
+	OpLinkCard           = "link-card"
+	OpActivateCard       = "activate-card"
+	OpUpdateCardStatus   = "update-card-status"
+	OpUpdateCardControls = "update-card-controls"
+	OpGetAccountBalance  = "get-account-balance"
+)
+
+// Config configures the simulator.
+type Config struct {
+	WebhookURL     string        // Endpoint emitted webhooks are posted to; empty disables them
+	SigningKey     string        // Key webhooks are signed with in Allawee-Signature
+	Latency        time.Duration // Added to every call
+	FailurePercent int           // Share of calls failed with ErrInjected
+	OpeningBalance int64         // Available balance of a new sub account
+	Currency       string
+}
+
+// Fault makes the next Count calls of an operation fail with Err, after Delay.
+type Fault struct {
+	Operation string
+	Count     int
+	Delay     time.Duration
+	Err       error // ErrInjected when nil
+}
+
+// Authorization is a card payment run through the authorization webhooks.
+type Authorization struct {
+	CardID   string
+	Amount   int64
+	Channel  string
+	MCC      string
+	Merchant string // Card acceptor name and location, as sent by the network
+	Country  string // Merchant country sent apart from Merchant; empty to send none
+}
+
+type customer struct {
+	id        string
+	request   api.CreateCustomerRequest
+	createdAt time.Time
+}
+
+type account struct {
+	id              string
+	customerID      string
+	name            string
+	currency        string
+	available       int64
+	depositChannels []api.DepositChannel
+	createdAt       time.Time
+}
+
+type card struct {
+	id     string
+	status string
+	pin    string
+	linked api.LinkCardResponse
+}
+
+// Simulator is an in-memory Allawee issuer. It keeps customers, sub
+// accounts, cards and balances, replays the response of a call retried with
+// the same idempotency key, fails or delays calls on demand and emits signed
+// webhooks, so the service runs without the issuer in sandbox mode.
+type Simulator struct {
+	mu        sync.Mutex
+	config    Config
+	customers map[string]*customer
+	accounts  map[string]*account
+	cards     map[string]*card
+	replies   map[string]interface{} // Responses by idempotency key
+	faults    map[string][]Fault
+	numbers   int // Account numbers handed out
+	client    *http.Client
+	logger    *zap.Logger
+}
+
+// New creates an empty simulator.
+func New(config Config, logger *zap.Logger) *Simulator {
+	if config.Currency == "" {
+		config.Currency = "NGN"
+	}
+	return &Simulator{
+		config:    config,
+		customers: map[string]*customer{},
+		accounts:  map[string]*account{},
+		cards:     map[string]*card{},
+		replies:   map[string]interface{}{},
+		faults:    map[string][]Fault{},
+		client:    &http.Client{Timeout: 30 * time.Second},
+		logger:    logger,
+	}
+}
+
+var _ api.Issuer = (*Simulator)(nil)
+
+// InjectFault queues a fault for the next calls of its operation.
+func (s *Simulator) InjectFault(fault Fault) {
+	if fault.Count < 1 {
+		fault.Count = 1
+	}
+	if fault.Err == nil {
+		fault.Err = ErrInjected
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[fault.Operation] = append(s.faults[fault.Operation], fault)
+	s.logger.Info("Injected simulator fault", zap.String("operation", fault.Operation), zap.Int("count", fault.Count), zap.Duration("delay", fault.Delay))
+}
+
+// ClearFaults drops every queued fault.
+func (s *Simulator) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = map[string][]Fault{}
+}
+
+// begin applies the latency and the faults of a call and returns the reply
+// already given to its idempotency key, if any.
+func (s *Simulator) begin(ctx context.Context, op string) (interface{}, error) {
+	s.mu.Lock()
+	delay, err := s.config.Latency, error(nil)
+	if queued := s.faults[op]; len(queued) > 0 {
+		fault := &queued[0]
+		delay += fault.Delay
+		err = fault.Err
+		if fault.Count--; fault.Count == 0 {
+			s.faults[op] = queued[1:]
+		}
+	} else if s.config.FailurePercent > 0 && mathrand.Intn(100) < s.config.FailurePercent {
+		err = ErrInjected
+	}
+	reply, replayed := s.replies[replyKey(ctx, op)]
+	s.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if err != nil {
+		s.logger.Warn("Simulated issuer call failed", zap.String("operation", op), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if replayed {
+		s.logger.Info("Replayed simulated issuer call", zap.String("operation", op), zap.String("idempotencyKey", api.IdempotencyKey(ctx)))
+		return reply, nil
+	}
+	return nil, nil
+}
+
+// remember keeps the reply of a call for retries with its idempotency key.
+// It must be called with s.mu held.
+func (s *Simulator) remember(ctx context.Context, op string, reply interface{}) {
+	if key := replyKey(ctx, op); key != "" {
+		s.replies[key] = reply
+	}
+}
+
+func replyKey(ctx context.Context, op string) string {
+	key := api.IdempotencyKey(ctx)
+	if key == "" {
+		return ""
+	}
+	return op + ":" + key
+}
+
+type subAccountReply struct {
+	id              string
+	depositChannels []api.DepositChannel
+}
+
+// CreateCustomer creates a customer.
+func (s *Simulator) CreateCustomer(ctx context.Context, req api.CreateCustomerRequest) (string, error) {
+	reply, err := s.begin(ctx, OpCreateCustomer)
+	if err != nil {
+		return "", err
+	}
+	if reply != nil {
+		return reply.(string), nil
+	}
+	if req.Name == "" || (req.Claims.IndividualInformation == nil && req.Claims.BusinessInformation == nil) {
+		return "", fmt.Errorf("%w: customer name and claims are required", ErrInvalidRequest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &customer{id: newID("cus"), request: req, createdAt: time.Now().UTC()}
+	s.customers[c.id] = c
+	s.remember(ctx, OpCreateCustomer, c.id)
+	s.logger.Info("Simulated customer created", zap.String("customerID", c.id), zap.String("type", req.Type))
+	return c.id, nil
+}
+
+// CreateSubAccount creates a sub account of a customer with a bank account
+// deposit channel, opened with the configured balance.
+func (s *Simulator) CreateSubAccount(ctx context.Context, req api.CreateSubAccountRequest) (string, []api.DepositChannel, error) {
+	reply, err := s.begin(ctx, OpCreateSubAccount)
+	if err != nil {
+		return "", nil, err
+	}
+	if reply != nil {
+		r := reply.(subAccountReply)
+		return r.id, r.depositChannels, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.customers[req.Customer]; !ok {
+		return "", nil, fmt.Errorf("customer %s: %w", req.Customer, ErrNotFound)
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = s.config.Currency
+	}
+	s.numbers++
+	a := &account{
+		id:         newID("acc"),
+		customerID: req.Customer,
+		name:       req.Name,
+		currency:   currency,
+		available:  s.config.OpeningBalance,
+		depositChannels: []api.DepositChannel{{
+			AccountName:   req.Name,
+			AccountNumber: fmt.Sprintf("99%08d", s.numbers),
+			BankName:      "Sandbox Bank",
+			BankCode:      "000",
+			Type:          "bank-account",
+		}},
+		createdAt: time.Now().UTC(),
+	}
+	s.accounts[a.id] = a
+	s.remember(ctx, OpCreateSubAccount, subAccountReply{id: a.id, depositChannels: a.depositChannels})
+	s.logger.Info("Simulated sub account created", zap.String("accountID", a.id), zap.String("customerID", a.customerID))
+	return a.id, a.depositChannels, nil
+}
+
+// LinkCard links an inactive card of a customer, funded from one of its sub accounts.
+func (s *Simulator) LinkCard(ctx context.Context, req api.LinkCardRequest) (api.LinkCardResponse, error) {
+	reply, err := s.begin(ctx, OpLinkCard)
+	if err != nil {
+		return api.LinkCardResponse{}, err
+	}
+	if reply != nil {
+		return reply.(api.LinkCardResponse), nil
+	}
+	if len(req.Pan) < 12 {
+		return api.LinkCardResponse{}, fmt.Errorf("%w: invalid pan", ErrInvalidRequest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.customers[req.Customer]
+	if !ok {
+		return api.LinkCardResponse{}, fmt.Errorf("customer %s: %w", req.Customer, ErrNotFound)
+	}
+	if a, ok := s.accounts[req.FundingSource]; req.FundingSource != "" && (!ok || a.customerID != c.id) {
+		return api.LinkCardResponse{}, fmt.Errorf("funding source %s: %w", req.FundingSource, ErrNotFound)
+	}
+
+	now := time.Now().UTC()
+	var resp api.LinkCardResponse
+	resp.Code = "success"
+	resp.Data.ID = newID("card")
+	resp.Data.Customer = c.id
+	resp.Data.Details = api.CardDetails{
+		Last4:          req.Pan[len(req.Pan)-4:],
+		Expiry:         now.AddDate(3, 0, 0).Format("01/06"),
+		CardHolderName: c.request.Name,
+	}
+	resp.Data.Program = "sandbox"
+	resp.Data.Type = "physical"
+	resp.Data.Status = "inactive"
+	resp.Data.Currency = s.config.Currency
+	if req.Controls != nil {
+		resp.Data.Controls = *req.Controls
+	}
+	if req.Metadata != nil {
+		resp.Data.Metadata = *req.Metadata
+	}
+	resp.Data.FundingSource = req.FundingSource
+	resp.Data.Reference = req.Reference
+	resp.Data.CreatedAt = now.Format(time.RFC3339)
+	resp.Data.UpdatedAt = resp.Data.CreatedAt
+
+	s.cards[resp.Data.ID] = &card{id: resp.Data.ID, status: resp.Data.Status, linked: resp}
+	s.remember(ctx, OpLinkCard, resp)
+	s.logger.Info("Simulated card linked", zap.String("cardID", resp.Data.ID), zap.String("customerID", c.id))
+	return resp, nil
+}
+
+// ActivateCard activates an inactive card with its PIN.
+func (s *Simulator) ActivateCard(ctx context.Context, cardID string, req api.ActivateCardRequest) (api.ActivateCardResponse, error) {
+	reply, err := s.begin(ctx, OpActivateCard)
+	if err != nil {
+		return api.ActivateCardResponse{}, err
+	}
+	if reply != nil {
+		return reply.(api.ActivateCardResponse), nil
+	}
+	if len(req.Cvv) != 3 || len(req.Pin) != 4 {
+		return api.ActivateCardResponse{}, fmt.Errorf("%w: cvv and pin are required", ErrInvalidRequest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cards[cardID]
+	if !ok {
+		return api.ActivateCardResponse{}, fmt.Errorf("card %s: %w", cardID, ErrNotFound)
+	}
+	if c.status == "active" {
+		return api.ActivateCardResponse{}, fmt.Errorf("%w: card already active", ErrInvalidRequest)
+	}
+	c.status, c.pin = "active", req.Pin
+	resp := api.ActivateCardResponse{Code: "success", Message: "Card activated"}
+	s.remember(ctx, OpActivateCard, resp)
+	s.logger.Info("Simulated card activated", zap.String("cardID", cardID))
+	return resp, nil
+}
+
+// UpdateCardStatus freezes, unfreezes or terminates a card. A terminated
+// card cannot change status again.
+func (s *Simulator) UpdateCardStatus(ctx context.Context, cardID string, req api.UpdateCardStatusRequest) (api.UpdateCardStatusResponse, error) {
+	reply, err := s.begin(ctx, OpUpdateCardStatus)
+	if err != nil {
+		return api.UpdateCardStatusResponse{}, err
+	}
+	if reply != nil {
+		return reply.(api.UpdateCardStatusResponse), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cards[cardID]
+	if !ok {
+		return api.UpdateCardStatusResponse{}, fmt.Errorf("card %s: %w", cardID, ErrNotFound)
+	}
+	switch {
+	case c.status == "terminated":
+		return api.UpdateCardStatusResponse{}, fmt.Errorf("%w: card is terminated", ErrInvalidRequest)
+	case req.Status != "active" && req.Status != "frozen" && req.Status != "terminated":
+		return api.UpdateCardStatusResponse{}, fmt.Errorf("%w: unknown card status %q", ErrInvalidRequest, req.Status)
+	}
+	c.status = req.Status
+	var resp api.UpdateCardStatusResponse
+	resp.Code = "success"
+	resp.Data.ID, resp.Data.Status = c.id, c.status
+	s.remember(ctx, OpUpdateCardStatus, resp)
+	s.logger.Info("Simulated card status changed", zap.String("cardID", cardID), zap.String("status", c.status))
+	return resp, nil
+}
+
+// UpdateCardControls replaces the controls of a card that is not terminated.
+func (s *Simulator) UpdateCardControls(ctx context.Context, cardID string, req api.UpdateCardControlsRequest) (api.UpdateCardControlsResponse, error) {
+	reply, err := s.begin(ctx, OpUpdateCardControls)
+	if err != nil {
+		return api.UpdateCardControlsResponse{}, err
+	}
+	if reply != nil {
+		return reply.(api.UpdateCardControlsResponse), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cards[cardID]
+	if !ok {
+		return api.UpdateCardControlsResponse{}, fmt.Errorf("card %s: %w", cardID, ErrNotFound)
+	}
+	if c.status == "terminated" {
+		return api.UpdateCardControlsResponse{}, fmt.Errorf("%w: card is terminated", ErrInvalidRequest)
+	}
+	c.linked.Data.Controls = req.Controls
+	var resp api.UpdateCardControlsResponse
+	resp.Code = "success"
+	resp.Data.ID, resp.Data.Controls = c.id, req.Controls
+	s.remember(ctx, OpUpdateCardControls, resp)
+	s.logger.Info("Simulated card controls changed", zap.String("cardID", cardID))
+	return resp, nil
+}
+
+// GetAccountBalance returns the available balance of a sub account.
+func (s *Simulator) GetAccountBalance(ctx context.Context, accountID string) (api.GetAccountBalanceResponse, error) {
+	if _, err := s.begin(ctx, OpGetAccountBalance); err != nil {
+		return api.GetAccountBalanceResponse{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.accounts[accountID]
+	if !ok {
+		return api.GetAccountBalanceResponse{}, fmt.Errorf("account %s: %w", accountID, ErrNotFound)
+	}
+	var resp api.GetAccountBalanceResponse
+	resp.Code = "success"
+	resp.Data.ID = a.id
+	resp.Data.Available = a.available
+	resp.Data.Currency = a.currency
+	resp.Data.Mode = "sandbox"
+	resp.Data.Source = "simulator"
+	resp.Data.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	return resp, nil
+}
+
+// Deposit credits a completed payment to a sub account and emits its
+// payment.created webhook when webhooks are enabled.
+func (s *Simulator) Deposit(ctx context.Context, accountID string, amount int64) (models.PaymentData, error) {
+	if amount <= 0 {
+		return models.PaymentData{}, fmt.Errorf("%w: amount must be positive", ErrInvalidRequest)
+	}
+	s.mu.Lock()
+	a, ok := s.accounts[accountID]
+	if !ok {
+		s.mu.Unlock()
+		return models.PaymentData{}, fmt.Errorf("account %s: %w", accountID, ErrNotFound)
+	}
+	a.available += amount
+	payment := models.PaymentData{
+		ID:               newID("pay"),
+		CustomerID:       a.customerID,
+		VirtualAccountID: a.id,
+		Amount:           amount,
+		Currency:         a.currency,
+		Status:           "completed",
+		CreatedAt:        time.Now().UTC(),
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("Simulated deposit", zap.String("accountID", accountID), zap.Int64("amount", amount))
+	if _, err := s.Emit(ctx, "payment.created", payment); err != nil && !errors.Is(err, ErrWebhooksDisabled) {
+		return payment, err
+	}
+	return payment, nil
+}
+
+// Authorize runs a card payment through the authorization webhooks: it
+// emits the authorization request, debits the funding account when the
+// service approves it, and emits the closed authorization.
+func (s *Simulator) Authorize(ctx context.Context, auth Authorization) (api.AuthorizationResponse, error) {
+	s.mu.Lock()
+	c, ok := s.cards[auth.CardID]
+	if !ok {
+		s.mu.Unlock()
+		return api.AuthorizationResponse{}, fmt.Errorf("card %s: %w", auth.CardID, ErrNotFound)
+	}
+	linked := c.linked
+	s.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	network := api.NetworkData{
+		CardAcceptorNameLocation: auth.Merchant,
+		TerminalID:               "SANDBOX01",
+		Network:                  "Verve",
+		Reference:                newID("ref"),
+		MCC:                      auth.MCC,
+		MerchantCountry:          auth.Country,
+	}
+	request := api.AuthorizationRequestEvent{
+		ID:          newID("auth"),
+		CardID:      auth.CardID,
+		CustomerID:  linked.Data.Customer,
+		Amount:      auth.Amount,
+		Currency:    linked.Data.Currency,
+		Type:        "purchase",
+		Channel:     auth.Channel,
+		Status:      "pending",
+		NetworkData: network,
+		CreatedAt:   now,
+	}
+	body, err := s.Emit(ctx, "card.authorization.request", request)
+	if err != nil {
+		return api.AuthorizationResponse{}, err
+	}
+	var response api.AuthorizationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return api.AuthorizationResponse{}, fmt.Errorf("failed to decode authorization response: %w", err)
+	}
+
+	status := "declined"
+	if response.Action == "approve" {
+		status = "approved"
+		s.mu.Lock()
+		if a, ok := s.accounts[linked.Data.FundingSource]; ok {
+			a.available -= auth.Amount
+		}
+		s.mu.Unlock()
+	}
+	closed := api.AuthorizationClosedEvent{
+		ID:           request.ID,
+		CardID:       request.CardID,
+		Amount:       request.Amount,
+		Currency:     request.Currency,
+		Type:         request.Type,
+		Channel:      request.Channel,
+		NetworkData:  network,
+		CreatedAt:    now,
+		DecisionType: "webhook",
+		Status:       status,
+	}
+	if _, err := s.Emit(ctx, "card.authorization.closed", closed); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// Emit posts a webhook event signed like the issuer signs it, and returns
+// the response body.
+func (s *Simulator) Emit(ctx context.Context, event string, data interface{}) ([]byte, error) {
+	if s.config.WebhookURL == "" {
+		return nil, ErrWebhooksDisabled
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s data: %w", event, err)
+	}
+	envelope := api.WebhookEvent{Event: event, Data: raw}
+	envelope.Metadata.Event = event
+	envelope.Metadata.SentAt = time.Now().UTC().Format(time.RFC3339)
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s webhook: %w", event, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s webhook: %w", event, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Allawee-Signature", api.WebhookSignature(s.config.SigningKey, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s webhook: %w", event, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s webhook response: %w", event, err)
+	}
+	s.logger.Info("Emitted simulated webhook", zap.String("event", event), zap.Int("status", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return respBody, fmt.Errorf("%s webhook returned status %d: %s", event, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// newID returns a random issuer-style ID with the given prefix.
+func newID(prefix string) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return prefix + "_sbx_" + hex.EncodeToString(b)
+}
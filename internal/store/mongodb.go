@@ -3,21 +3,55 @@ package store
 import (
 	"card-service/internal/models"
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 )
 
 type Store struct {
-	Client       *mongo.Client
-	Db           *mongo.Database
-	Customers    *mongo.Collection
-	Accounts     *mongo.Collection
-	Cards        *mongo.Collection
-	Transactions *mongo.Collection
-	logger       *zap.Logger
+	Client           *mongo.Client
+	Db               *mongo.Database
+	Customers        *mongo.Collection
+	Accounts         *mongo.Collection
+	Cards            *mongo.Collection
+	Transactions     *mongo.Collection
+	WebhookEvents    *mongo.Collection
+	Holds            *mongo.Collection
+	BalanceSnapshots *mongo.Collection
+	// LedgerAccounts and JournalEntries hold the internal double-entry ledger.
+	LedgerAccounts *mongo.Collection
+	JournalEntries *mongo.Collection
+	// Decisions is the audit log of authorization decisions.
+	Decisions *mongo.Collection
+	// Rules holds every version of the authorization rules.
+	Rules *mongo.Collection
+	// Velocity holds the recent authorizations of each card.
+	Velocity *mongo.Collection
+	// Cases holds the compliance cases opened by transaction monitoring.
+	Cases *mongo.Collection
+	// Watchlists, WatchlistEntries and Screenings hold the sanctions lists and
+	// the screenings of customers against them.
+	Watchlists       *mongo.Collection
+	WatchlistEntries *mongo.Collection
+	Screenings       *mongo.Collection
+	// Onboarding holds the progress of every customer onboarding.
+	Onboarding *mongo.Collection
+	// Outbox holds the issuer operations waiting to be dispatched.
+	Outbox *mongo.Collection
+	// Deposits holds the payments into the virtual accounts of sub accounts.
+	Deposits *mongo.Collection
+	// ControlsVersions holds every version of the controls of each card.
+	ControlsVersions *mongo.Collection
+	// TravelNotices holds the countries customers pre-authorized for their travels.
+	TravelNotices *mongo.Collection
+	// Locks holds the leases that serialize work per key across instances,
+	// such as the authorizations of a funding account.
+	Locks  *mongo.Collection
+	logger *zap.Logger
 }
 
 // NewStore initializes a new Store instance with the provided MongoDB client and database name.
@@ -37,13 +71,31 @@ func NewStore(dsn, dbName string) (*Store, error) {
 	//initialize database and collection
 	db := client.Database(dbName)
 	store := &Store{
-		Client:       client,
-		Db:           db,
-		Customers:    db.Collection("customers"),
-		Accounts:     db.Collection("accounts"),
-		Cards:        db.Collection("cards"),
-		Transactions: db.Collection("transactions"),
-		logger:       logger,
+		Client:           client,
+		Db:               db,
+		Customers:        db.Collection("customers"),
+		Accounts:         db.Collection("accounts"),
+		Cards:            db.Collection("cards"),
+		Transactions:     db.Collection("transactions"),
+		WebhookEvents:    db.Collection("webhook_events"),
+		Holds:            db.Collection("holds"),
+		BalanceSnapshots: db.Collection("balance_snapshots"),
+		LedgerAccounts:   db.Collection("ledger_accounts"),
+		JournalEntries:   db.Collection("journal_entries"),
+		Decisions:        db.Collection("authorization_decisions"),
+		Rules:            db.Collection("authorization_rules"),
+		Velocity:         db.Collection("card_velocity"),
+		Cases:            db.Collection("cases"),
+		Watchlists:       db.Collection("watchlists"),
+		WatchlistEntries: db.Collection("watchlist_entries"),
+		Screenings:       db.Collection("screenings"),
+		Onboarding:       db.Collection("onboarding"),
+		Outbox:           db.Collection("outbox"),
+		Deposits:         db.Collection("deposits"),
+		ControlsVersions: db.Collection("card_controls_versions"),
+		TravelNotices:    db.Collection("travel_notices"),
+		Locks:            db.Collection("locks"),
+		logger:           logger,
 	}
 
 	//create indexes for efficient queries
@@ -62,6 +114,107 @@ func (s *Store) createIndexes() {
 	})
 	s.Cards.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{Key: "_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "customerId", Value: 1}}},
+	})
+	s.Transactions.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "authorizationId", Value: 1}}},
+		{Keys: bson.D{{Key: "cardId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "standIn", Value: 1}, {Key: "reviewStatus", Value: 1}}},
+		{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}},
+	})
+	// Only events with a valid signature reserve their dedupe key, so forged
+	// deliveries cannot shadow the real event.
+	s.WebhookEvents.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"signatureValid": true}),
+		},
+		{Keys: bson.D{{Key: "event", Value: 1}, {Key: "receivedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "receivedAt", Value: -1}}},
+	})
+	s.Holds.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "authorizationId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+	})
+	s.BalanceSnapshots.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "accountId", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	s.LedgerAccounts.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "accountId", Value: 1}}},
+	})
+	s.JournalEntries.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "reference", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "postings.accountCode", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "postings.accountId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "createdAt", Value: 1}}},
+	})
+	s.Decisions.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "cardId", Value: 1}, {Key: "decidedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "authorizationId", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "decidedAt", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"shadow.disagrees": true}),
+		},
+	})
+	s.Velocity.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "cardId", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	s.Cases.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "type", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	s.Watchlists.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "source", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	s.WatchlistEntries.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "source", Value: 1}, {Key: "importedAt", Value: 1}}},
+	})
+	s.Screenings.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "reviewStatus", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "ref", Value: 1}}},
+	})
+	s.Onboarding.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ref", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	s.Outbox.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lockedUntil", Value: 1}}},
+	})
+	s.Deposits.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "paymentId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "accountId", Value: 1}, {Key: "paidAt", Value: -1}}},
+		{Keys: bson.D{{Key: "quarantine.status", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	s.ControlsVersions.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "cardId", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "cardId", Value: 1}, {Key: "effectiveFrom", Value: -1}}},
+	})
+	s.TravelNotices.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "endsAt", Value: 1}}},
+	})
+	s.Customers.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "screenedAt", Value: 1}}},
+	})
+	// Leases of holders that died are removed once they ran out.
+	s.Locks.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "lockedUntil", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	// Only one live and one shadow version of a rule can be current.
+	s.Rules.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ruleId", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys: bson.D{{Key: "ruleId", Value: 1}, {Key: "shadow", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"current": true}),
+		},
 	})
 }
 
@@ -88,3 +241,865 @@ func (s *Store) GetTransaction(ctx context.Context, authorizationID string) (*mo
 	}
 	return &transaction, nil
 }
+
+// SumCardSpend totals the amount of approved and pending transactions on a
+// card created in [from, to).
+func (s *Store) SumCardSpend(ctx context.Context, cardID string, from, to time.Time) (int64, error) {
+	return s.sumSpend(ctx, bson.M{"cardId": cardID}, from, to)
+}
+
+// SumFundingSpend totals the amount of approved and pending transactions on
+// all cards funded by a sub-account created in [from, to).
+func (s *Store) SumFundingSpend(ctx context.Context, accountID string, from, to time.Time) (int64, error) {
+	return s.sumSpend(ctx, bson.M{"accountId": accountID}, from, to)
+}
+
+func (s *Store) sumSpend(ctx context.Context, match bson.M, from, to time.Time) (int64, error) {
+	match["status"] = bson.M{"$in": []string{"pending", "approved"}}
+	match["createdAt"] = bson.M{
+		"$gte": from.UTC().Format(time.RFC3339),
+		"$lt":  to.UTC().Format(time.RFC3339),
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
+	}
+	cursor, err := s.Transactions.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	var results []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Total, nil
+}
+
+// CountCardTransactions counts the transactions of a card created in [from, to).
+func (s *Store) CountCardTransactions(ctx context.Context, cardID string, from, to time.Time) (int64, error) {
+	return s.Transactions.CountDocuments(ctx, bson.M{
+		"cardId": cardID,
+		"createdAt": bson.M{
+			"$gte": from.UTC().Format(time.RFC3339),
+			"$lt":  to.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// HasTravelNotice reports whether a travel notice of the customer that is not
+// cancelled covers the country at the given time.
+func (s *Store) HasTravelNotice(ctx context.Context, customerID, country string, at time.Time) (bool, error) {
+	count, err := s.TravelNotices.CountDocuments(ctx, bson.M{
+		"customerId":  customerID,
+		"countries":   country,
+		"startsAt":    bson.M{"$lte": at},
+		"endsAt":      bson.M{"$gt": at},
+		"cancelledAt": bson.M{"$exists": false},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SumOpenHolds totals the amount and fees of the open holds on an account.
+func (s *Store) SumOpenHolds(ctx context.Context, accountID string) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"accountId": accountID, "status": models.HoldOpen}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   nil,
+			"total": bson.M{"$sum": bson.M{"$add": bson.A{"$amount", "$fees"}}},
+		}}},
+	}
+	cursor, err := s.Holds.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	var results []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Total, nil
+}
+
+// SumPostings returns the total debits and credits posted to each ledger account code given.
+func (s *Store) SumPostings(ctx context.Context, codes []string) (map[string][2]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"postings.accountCode": bson.M{"$in": codes}}}},
+		{{Key: "$unwind", Value: "$postings"}},
+		{{Key: "$match", Value: bson.M{"postings.accountCode": bson.M{"$in": codes}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$postings.accountCode",
+			"debits": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$postings.direction", models.Debit}}, "$postings.amount", 0,
+			}}},
+			"credits": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$postings.direction", models.Credit}}, "$postings.amount", 0,
+			}}},
+		}}},
+	}
+	cursor, err := s.JournalEntries.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Code    string `bson:"_id"`
+		Debits  int64  `bson:"debits"`
+		Credits int64  `bson:"credits"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	sums := make(map[string][2]int64, len(results))
+	for _, r := range results {
+		sums[r.Code] = [2]int64{r.Debits, r.Credits}
+	}
+	return sums, nil
+}
+
+// ListPostings returns the postings made to the ledger accounts backing a
+// sub-account, newest first.
+func (s *Store) ListPostings(ctx context.Context, accountID string, skip, limit int64) ([]models.PostingLine, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"postings.accountId": accountID}}},
+		{{Key: "$unwind", Value: "$postings"}},
+		{{Key: "$match", Value: bson.M{"postings.accountId": accountID}}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$skip", Value: skip}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"entryId":     "$_id",
+			"reference":   1,
+			"type":        1,
+			"description": 1,
+			"posting":     "$postings",
+			"createdAt":   1,
+		}}},
+	}
+	cursor, err := s.JournalEntries.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	lines := []models.PostingLine{}
+	if err := cursor.All(ctx, &lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// InsertWebhookEvent stores a raw inbound webhook in the inbox.
+func (s *Store) InsertWebhookEvent(ctx context.Context, record models.WebhookEventRecord) (primitive.ObjectID, error) {
+	res, err := s.WebhookEvents.InsertOne(ctx, record)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return res.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetWebhookEvent fetches an inbox event by its ID.
+func (s *Store) GetWebhookEvent(ctx context.Context, id primitive.ObjectID) (*models.WebhookEventRecord, error) {
+	var record models.WebhookEventRecord
+	if err := s.WebhookEvents.FindOne(ctx, bson.M{"_id": id}).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetWebhookEventByKey fetches the signed inbox event with the given dedupe key.
+func (s *Store) GetWebhookEventByKey(ctx context.Context, key string) (*models.WebhookEventRecord, error) {
+	var record models.WebhookEventRecord
+	err := s.WebhookEvents.FindOne(ctx, bson.M{"key": key, "signatureValid": true}).Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// UpdateWebhookEvent applies an update to an inbox event.
+func (s *Store) UpdateWebhookEvent(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	_, err := s.WebhookEvents.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// ListWebhookEvents returns inbox events matching the filter, newest first.
+func (s *Store) ListWebhookEvents(ctx context.Context, filter bson.M, skip, limit int64) ([]models.WebhookEventRecord, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "receivedAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.WebhookEvents.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	records := []models.WebhookEventRecord{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// InsertDecision appends an authorization decision to the audit log.
+func (s *Store) InsertDecision(ctx context.Context, decision models.AuthorizationDecision) error {
+	_, err := s.Decisions.InsertOne(ctx, decision)
+	return err
+}
+
+// ListDecisions returns the authorization decisions of a card, newest first.
+func (s *Store) ListDecisions(ctx context.Context, cardID string, skip, limit int64) ([]models.AuthorizationDecision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "decidedAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.Decisions.Find(ctx, bson.M{"cardId": cardID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	decisions := []models.AuthorizationDecision{}
+	if err := cursor.All(ctx, &decisions); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// ListCurrentRules returns the current live and shadow version of every rule.
+func (s *Store) ListCurrentRules(ctx context.Context) ([]models.Rule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "ruleId", Value: 1}})
+	cursor, err := s.Rules.Find(ctx, bson.M{"current": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	rules := []models.Rule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ListRuleVersions returns every version of a rule, newest first.
+func (s *Store) ListRuleVersions(ctx context.Context, ruleID string) ([]models.Rule, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := s.Rules.Find(ctx, bson.M{"ruleId": ruleID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	rules := []models.Rule{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// InsertRuleVersion stores a new version of a rule and makes it the current
+// version of its mode, live or shadow, in one transaction so the mode never
+// goes without a current version. The insert fails with a duplicate key
+// error when the version already exists.
+func (s *Store) InsertRuleVersion(ctx context.Context, rule models.Rule) error {
+	rule.Current = true
+	return s.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if rule.Version > 1 {
+			if err := s.RetireRule(sc, rule.RuleID, rule.Shadow); err != nil {
+				return err
+			}
+		}
+		_, err := s.Rules.InsertOne(sc, rule)
+		return err
+	})
+}
+
+// RetireRule makes the current live or shadow version of a rule no longer current.
+func (s *Store) RetireRule(ctx context.Context, ruleID string, shadow bool) error {
+	_, err := s.Rules.UpdateOne(ctx,
+		bson.M{"ruleId": ruleID, "shadow": shadow, "current": true},
+		bson.M{"$set": bson.M{"current": false}},
+	)
+	return err
+}
+
+// shadowDecisionsFilter matches the decisions with a shadow decision made in [from, to).
+func shadowDecisionsFilter(from, to time.Time) bson.M {
+	return bson.M{
+		"shadow":    bson.M{"$exists": true},
+		"decidedAt": bson.M{"$gte": from, "$lt": to},
+	}
+}
+
+// CountShadowDecisions counts the decisions made in [from, to) that were also
+// evaluated by the shadow rules, and how many of them the shadow rules disagreed with.
+func (s *Store) CountShadowDecisions(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	filter := shadowDecisionsFilter(from, to)
+	evaluated, err := s.Decisions.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	filter["shadow.disagrees"] = true
+	disagreements, err := s.Decisions.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	return evaluated, disagreements, nil
+}
+
+// CountShadowFlips counts the decisions made in [from, to) the shadow rules
+// would flip, per rule and direction.
+func (s *Store) CountShadowFlips(ctx context.Context, from, to time.Time) ([]models.ShadowFlipCount, error) {
+	match := shadowDecisionsFilter(from, to)
+	match["shadow.flip"] = bson.M{"$exists": true}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"ruleId": "$shadow.flipRuleId", "flip": "$shadow.flip"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":    0,
+			"ruleId": "$_id.ruleId",
+			"flip":   "$_id.flip",
+			"count":  1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "ruleId", Value: 1}, {Key: "flip", Value: 1}}}},
+	}
+	cursor, err := s.Decisions.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	counts := []models.ShadowFlipCount{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// ListShadowDisagreements returns the decisions made in [from, to) the shadow
+// rules disagreed with, newest first.
+func (s *Store) ListShadowDisagreements(ctx context.Context, from, to time.Time, skip, limit int64) ([]models.AuthorizationDecision, error) {
+	filter := shadowDecisionsFilter(from, to)
+	filter["shadow.disagrees"] = true
+	opts := options.Find().
+		SetSort(bson.D{{Key: "decidedAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.Decisions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	decisions := []models.AuthorizationDecision{}
+	if err := cursor.All(ctx, &decisions); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// GetCardVelocity fetches the recent authorizations of a card. It returns nil
+// when the card has none.
+func (s *Store) GetCardVelocity(ctx context.Context, cardID string) (*models.CardVelocity, error) {
+	var velocity models.CardVelocity
+	err := s.Velocity.FindOne(ctx, bson.M{"cardId": cardID}).Decode(&velocity)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &velocity, nil
+}
+
+// PushVelocityEvent appends an authorization to the recent authorizations of a
+// card, keeping only the latest keep events. An authorization already present
+// is not added again.
+func (s *Store) PushVelocityEvent(ctx context.Context, cardID string, event models.VelocityEvent, keep int) error {
+	_, err := s.Velocity.UpdateOne(ctx,
+		bson.M{"cardId": cardID, "events.authorizationId": bson.M{"$ne": event.AuthorizationID}},
+		bson.M{
+			"$push": bson.M{"events": bson.M{"$each": []models.VelocityEvent{event}, "$slice": -keep}},
+			"$set":  bson.M{"updatedAt": event.At},
+		},
+		options.Update().SetUpsert(true),
+	)
+	// The upsert collides with the existing document when it already has the event
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// SumAccountDeposits totals, per sub-account, the deposits credited in
+// [from, to) with an amount in [min, max). A max of 0 leaves the amount
+// unbounded and nil accountIDs covers every sub-account.
+func (s *Store) SumAccountDeposits(ctx context.Context, accountIDs []string, from, to time.Time, min, max int64) ([]models.AccountActivity, error) {
+	amount := bson.M{"$gte": min}
+	if max > 0 {
+		amount["$lt"] = max
+	}
+	posting := bson.M{
+		"postings.direction": models.Credit,
+		"postings.accountId": bson.M{"$exists": true},
+		"postings.amount":    amount,
+	}
+	if accountIDs != nil {
+		posting["postings.accountId"] = bson.M{"$in": accountIDs}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"type":      models.EntryDeposit,
+			"createdAt": bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$unwind", Value: "$postings"}},
+		{{Key: "$match", Value: posting}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        "$postings.accountId",
+			"count":      bson.M{"$sum": 1},
+			"amount":     bson.M{"$sum": "$postings.amount"},
+			"references": bson.M{"$push": "$reference"},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "accountId": "$_id", "count": 1, "amount": 1, "references": 1}}},
+	}
+	return s.aggregateActivity(ctx, s.JournalEntries, pipeline)
+}
+
+// SumAccountSpend totals, per sub-account, the amount and fees of the approved
+// and pending transactions created in [from, to). Nil accountIDs covers every
+// sub-account.
+func (s *Store) SumAccountSpend(ctx context.Context, accountIDs []string, from, to time.Time) ([]models.AccountActivity, error) {
+	match := bson.M{
+		"accountId": bson.M{"$exists": true},
+		"status":    bson.M{"$in": []string{"pending", "approved"}},
+		"createdAt": bson.M{
+			"$gte": from.UTC().Format(time.RFC3339),
+			"$lt":  to.UTC().Format(time.RFC3339),
+		},
+	}
+	if accountIDs != nil {
+		match["accountId"] = bson.M{"$in": accountIDs}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        "$accountId",
+			"count":      bson.M{"$sum": 1},
+			"amount":     bson.M{"$sum": bson.M{"$add": bson.A{"$amount", "$fees"}}},
+			"references": bson.M{"$push": "$authorizationId"},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "accountId": "$_id", "count": 1, "amount": 1, "references": 1}}},
+	}
+	return s.aggregateActivity(ctx, s.Transactions, pipeline)
+}
+
+func (s *Store) aggregateActivity(ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline) ([]models.AccountActivity, error) {
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	activity := []models.AccountActivity{}
+	if err := cursor.All(ctx, &activity); err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// InsertCase stores a new case. The insert fails with a duplicate key error
+// when a case with the same key exists.
+func (s *Store) InsertCase(ctx context.Context, c models.Case) (primitive.ObjectID, error) {
+	result, err := s.Cases.InsertOne(ctx, c)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// HasActiveCase reports whether a sub-account has a case of the given type
+// that is not closed.
+func (s *Store) HasActiveCase(ctx context.Context, accountID, caseType string) (bool, error) {
+	count, err := s.Cases.CountDocuments(ctx, bson.M{
+		"accountId": accountID,
+		"type":      caseType,
+		"status":    bson.M{"$ne": models.CaseClosed},
+	}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// GetCase fetches a case by its ID.
+func (s *Store) GetCase(ctx context.Context, id primitive.ObjectID) (*models.Case, error) {
+	var c models.Case
+	if err := s.Cases.FindOne(ctx, bson.M{"_id": id}).Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListCases returns the cases matching the filter, newest first.
+func (s *Store) ListCases(ctx context.Context, filter bson.M, skip, limit int64) ([]models.Case, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.Cases.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	cases := []models.Case{}
+	if err := cursor.All(ctx, &cases); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// UpdateCase applies an update to the case matching the filter and returns
+// the updated case, or mongo.ErrNoDocuments when no case matches.
+func (s *Store) UpdateCase(ctx context.Context, filter, update bson.M) (*models.Case, error) {
+	var c models.Case
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cases.FindOneAndUpdate(ctx, filter, update, opts).Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ReplaceWatchlist stores a new import of a list. The new entries are
+// inserted before the previous import is removed, and readers only load the
+// entries of the import recorded in the list.
+func (s *Store) ReplaceWatchlist(ctx context.Context, list models.Watchlist, entries []models.WatchlistEntry) error {
+	const batchSize = 1000
+	for start := 0; start < len(entries); start += batchSize {
+		end := min(start+batchSize, len(entries))
+		documents := make([]interface{}, 0, end-start)
+		for _, entry := range entries[start:end] {
+			documents = append(documents, entry)
+		}
+		if _, err := s.WatchlistEntries.InsertMany(ctx, documents); err != nil {
+			return err
+		}
+	}
+	_, err := s.Watchlists.UpdateOne(ctx,
+		bson.M{"source": list.Source},
+		bson.M{"$set": bson.M{
+			"format":     list.Format,
+			"entries":    list.Entries,
+			"checksum":   list.Checksum,
+			"importedAt": list.ImportedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.WatchlistEntries.DeleteMany(ctx, bson.M{
+		"source":     list.Source,
+		"importedAt": bson.M{"$ne": list.ImportedAt},
+	})
+	return err
+}
+
+// ListWatchlists returns the latest import of every list.
+func (s *Store) ListWatchlists(ctx context.Context) ([]models.Watchlist, error) {
+	cursor, err := s.Watchlists.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "source", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	lists := []models.Watchlist{}
+	if err := cursor.All(ctx, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// ListWatchlistEntries returns the entries of one import of a list.
+func (s *Store) ListWatchlistEntries(ctx context.Context, source string, importedAt time.Time) ([]models.WatchlistEntry, error) {
+	cursor, err := s.WatchlistEntries.Find(ctx, bson.M{"source": source, "importedAt": importedAt})
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.WatchlistEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// InsertScreening stores a screening and returns its ID.
+func (s *Store) InsertScreening(ctx context.Context, screening models.Screening) (primitive.ObjectID, error) {
+	result, err := s.Screenings.InsertOne(ctx, screening)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// GetScreening fetches a screening by its ID.
+func (s *Store) GetScreening(ctx context.Context, id primitive.ObjectID) (*models.Screening, error) {
+	var screening models.Screening
+	if err := s.Screenings.FindOne(ctx, bson.M{"_id": id}).Decode(&screening); err != nil {
+		return nil, err
+	}
+	return &screening, nil
+}
+
+// ListScreenings returns the screenings matching the filter, newest first.
+func (s *Store) ListScreenings(ctx context.Context, filter bson.M, skip, limit int64) ([]models.Screening, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.Screenings.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	screenings := []models.Screening{}
+	if err := cursor.All(ctx, &screenings); err != nil {
+		return nil, err
+	}
+	return screenings, nil
+}
+
+// UpdateScreening applies an update to the screening matching the filter and
+// returns the updated screening, or mongo.ErrNoDocuments when none matches.
+func (s *Store) UpdateScreening(ctx context.Context, filter, update bson.M) (*models.Screening, error) {
+	var screening models.Screening
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Screenings.FindOneAndUpdate(ctx, filter, update, opts).Decode(&screening); err != nil {
+		return nil, err
+	}
+	return &screening, nil
+}
+
+// ListCustomersToScreen returns up to limit customers last screened before the
+// given list import time, or never screened.
+func (s *Store) ListCustomersToScreen(ctx context.Context, before time.Time, limit int64) ([]models.Customer, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"screenedAt": bson.M{"$lt": before}},
+		bson.M{"screenedAt": bson.M{"$exists": false}},
+	}}
+	cursor, err := s.Customers.Find(ctx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	customers := []models.Customer{}
+	if err := cursor.All(ctx, &customers); err != nil {
+		return nil, err
+	}
+	return customers, nil
+}
+
+// MarkCustomerScreened records the list import time a customer was screened against.
+func (s *Store) MarkCustomerScreened(ctx context.Context, customerID string, listsImportedAt time.Time) error {
+	_, err := s.Customers.UpdateOne(ctx,
+		bson.M{"customerId": customerID},
+		bson.M{"$set": bson.M{"screenedAt": listsImportedAt}},
+	)
+	return err
+}
+
+// CountCustomerCards counts the cards linked, or being linked, to a customer
+// that have not been terminated.
+func (s *Store) CountCustomerCards(ctx context.Context, customerID string) (int64, error) {
+	return s.Cards.CountDocuments(ctx, bson.M{
+		"customerId": customerID,
+		"status":     bson.M{"$nin": bson.A{models.CardTerminated, models.CardLinkFailed}},
+	})
+}
+
+// ClaimOnboarding locks the onboarding of a reference for an attempt until
+// lockedUntil, creating it from onboarding when it does not exist. Claiming an
+// onboarding locked by another attempt fails with a duplicate key error.
+func (s *Store) ClaimOnboarding(ctx context.Context, onboarding models.Onboarding, now, lockedUntil time.Time) (*models.Onboarding, error) {
+	filter := bson.M{
+		"ref": onboarding.Ref,
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"type":        onboarding.Type,
+			"status":      models.OnboardingInProgress,
+			"steps":       bson.A{},
+			"application": onboarding.Application,
+			"business":    onboarding.Business,
+			"createdAt":   now,
+		},
+		"$set": bson.M{"lockedUntil": lockedUntil, "updatedAt": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	var claimed models.Onboarding
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := s.Onboarding.FindOneAndUpdate(ctx, filter, update, opts).Decode(&claimed); err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+// GetOnboarding fetches the onboarding of a reference.
+func (s *Store) GetOnboarding(ctx context.Context, ref string) (*models.Onboarding, error) {
+	var onboarding models.Onboarding
+	if err := s.Onboarding.FindOne(ctx, bson.M{"ref": ref}).Decode(&onboarding); err != nil {
+		return nil, err
+	}
+	return &onboarding, nil
+}
+
+// UpdateOnboarding applies an update to the onboarding of a reference.
+func (s *Store) UpdateOnboarding(ctx context.Context, ref string, update bson.M) error {
+	_, err := s.Onboarding.UpdateOne(ctx, bson.M{"ref": ref}, update)
+	return err
+}
+
+// WithTransaction runs fn in a transaction. Writes made with the session
+// context fn receives commit or roll back together.
+func (s *Store) WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := s.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// AcquireLock takes the lease of a key for holder until lockedUntil, unless
+// the lease of another holder is still running at now. It reports whether the
+// lease was taken.
+func (s *Store) AcquireLock(ctx context.Context, key, holder string, now, lockedUntil time.Time) (bool, error) {
+	_, err := s.Locks.UpdateOne(ctx,
+		bson.M{"_id": key, "lockedUntil": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"holder": holder, "lockedUntil": lockedUntil}},
+		options.Update().SetUpsert(true),
+	)
+	// The key exists and its lease is running, so the upsert collided with it
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseLock ends the lease of a key if holder still has it. The key is
+// removed, so idle keys take no space.
+func (s *Store) ReleaseLock(ctx context.Context, key, holder string) error {
+	_, err := s.Locks.DeleteOne(ctx, bson.M{"_id": key, "holder": holder})
+	return err
+}
+
+// InsertOutboxOperation stores an operation unless one with the same key
+// exists, and returns the existing operation or nil when it was inserted.
+func (s *Store) InsertOutboxOperation(ctx context.Context, op models.OutboxOperation) (*models.OutboxOperation, error) {
+	var existing models.OutboxOperation
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	err := s.Outbox.FindOneAndUpdate(ctx, bson.M{"key": op.Key}, bson.M{"$setOnInsert": op}, opts).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// ClaimOutboxOperation locks a pending operation, or one whose previous
+// attempt let its lock expire, for an attempt until lockedUntil.
+func (s *Store) ClaimOutboxOperation(ctx context.Context, id primitive.ObjectID, now, lockedUntil time.Time) (*models.OutboxOperation, error) {
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"status": models.OutboxPending},
+			bson.M{"status": models.OutboxProcessing, "lockedUntil": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"status": models.OutboxProcessing, "lockedUntil": lockedUntil, "updatedAt": now},
+		"$inc": bson.M{"attempts": 1},
+	}
+	var op models.OutboxOperation
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Outbox.FindOneAndUpdate(ctx, filter, update, opts).Decode(&op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// ListDueOutboxOperations returns the IDs of the operations due for an
+// attempt at now, oldest first: pending operations whose backoff has passed
+// and operations whose attempt let its lock expire.
+func (s *Store) ListDueOutboxOperations(ctx context.Context, now time.Time, limit int64) ([]primitive.ObjectID, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.OutboxPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"status": models.OutboxProcessing, "lockedUntil": bson.M{"$lte": now}},
+	}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := s.Outbox.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var due []models.OutboxOperation
+	if err := cursor.All(ctx, &due); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(due))
+	for _, op := range due {
+		ids = append(ids, op.ID)
+	}
+	return ids, nil
+}
+
+// GetOutboxOperation fetches an operation by its ID.
+func (s *Store) GetOutboxOperation(ctx context.Context, id primitive.ObjectID) (*models.OutboxOperation, error) {
+	var op models.OutboxOperation
+	if err := s.Outbox.FindOne(ctx, bson.M{"_id": id}).Decode(&op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// ListOutboxOperations returns the operations matching the filter, newest first.
+func (s *Store) ListOutboxOperations(ctx context.Context, filter bson.M, skip, limit int64) ([]models.OutboxOperation, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.Outbox.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	ops := []models.OutboxOperation{}
+	if err := cursor.All(ctx, &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// UpdateOutboxOperation applies an update to an operation.
+func (s *Store) UpdateOutboxOperation(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	_, err := s.Outbox.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// ListDeposits returns the deposits matching the filter, newest payment first.
+func (s *Store) ListDeposits(ctx context.Context, filter bson.M, skip, limit int64) ([]models.Deposit, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "paidAt", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.Deposits.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	deposits := []models.Deposit{}
+	if err := cursor.All(ctx, &deposits); err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
@@ -0,0 +1,257 @@
+package watchlist
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Adjustments applied to the name score when the date of birth or nationality
+// of the subject is compared with the entry.
+const (
+	dateOfBirthBonus   = 0.10
+	dateOfBirthPenalty = 0.15
+	nationalityBonus   = 0.05
+	nationalityPenalty = 0.10
+)
+
+// Subject is a person screened against the lists.
+type Subject struct {
+	Name        string
+	DateOfBirth string // YYYY-MM-DD
+	Nationality string // ISO country code
+}
+
+// Match is an entry that resembles a subject.
+type Match struct {
+	Entry       Entry
+	MatchedName string  // Name or alias of the entry that matched best
+	NameScore   float64 // Similarity of the names, from 0 to 1
+	Score       float64 // Name score adjusted for date of birth and nationality, from 0 to 1
+	DateOfBirth string  // "match", "mismatch", or empty when it could not be compared
+	Nationality string  // "match", "mismatch", or empty when it could not be compared
+}
+
+// Index holds normalized entries for screening.
+type Index struct {
+	entries []indexedEntry
+}
+
+type indexedEntry struct {
+	entry Entry
+	names []indexedName // The name and every alias
+}
+
+type indexedName struct {
+	name   string
+	tokens []string
+}
+
+// NewIndex normalizes entries for screening.
+func NewIndex(entries []Entry) *Index {
+	index := &Index{entries: make([]indexedEntry, 0, len(entries))}
+	for _, entry := range entries {
+		indexed := indexedEntry{entry: entry}
+		for _, name := range append([]string{entry.Name}, entry.Aliases...) {
+			if tokens := tokenize(name); len(tokens) > 0 {
+				indexed.names = append(indexed.names, indexedName{name: name, tokens: tokens})
+			}
+		}
+		if len(indexed.names) > 0 {
+			index.entries = append(index.entries, indexed)
+		}
+	}
+	return index
+}
+
+// Len returns the number of entries in the index.
+func (i *Index) Len() int {
+	return len(i.entries)
+}
+
+// Screen returns the entries scoring at least minScore against the subject,
+// best first.
+func (i *Index) Screen(subject Subject, minScore float64) []Match {
+	tokens := tokenize(subject.Name)
+	if len(tokens) == 0 {
+		return nil
+	}
+	var matches []Match
+	for _, indexed := range i.entries {
+		best, bestName := 0.0, ""
+		for _, name := range indexed.names {
+			if score := nameSimilarity(tokens, name.tokens); score > best {
+				best, bestName = score, name.name
+			}
+		}
+		// Date of birth and nationality can lift a near miss over the threshold
+		if best+dateOfBirthBonus+nationalityBonus < minScore {
+			continue
+		}
+		match := Match{Entry: indexed.entry, MatchedName: bestName, NameScore: best, Score: best}
+		match.DateOfBirth = compareDateOfBirth(subject.DateOfBirth, indexed.entry.DatesOfBirth)
+		switch match.DateOfBirth {
+		case "match":
+			match.Score += dateOfBirthBonus
+		case "mismatch":
+			match.Score -= dateOfBirthPenalty
+		}
+		match.Nationality = compareNationality(subject.Nationality, indexed.entry.Nationalities)
+		switch match.Nationality {
+		case "match":
+			match.Score += nationalityBonus
+		case "mismatch":
+			match.Score -= nationalityPenalty
+		}
+		match.Score = clamp(match.Score)
+		if match.Score >= minScore {
+			matches = append(matches, match)
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].Score > matches[b].Score })
+	return matches
+}
+
+// tokenize lowercases a name, strips accents and punctuation and splits it into words.
+func tokenize(name string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// missingWordPenalty is subtracted from the word by word score for every word
+// the longer name has beyond the shorter one.
+const missingWordPenalty = 0.08
+
+// nameSimilarity compares two tokenized names. Word order is ignored, and a
+// name of several words is also matched word by word against a longer one, so
+// a missing middle name costs little.
+func nameSimilarity(a, b []string) float64 {
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	whole := jaroWinkler(strings.Join(sortedA, " "), strings.Join(sortedB, " "))
+
+	shorter, longer := a, b
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+	// A single word is weak evidence on its own
+	if len(shorter) < 2 {
+		return whole
+	}
+	var total float64
+	for _, word := range shorter {
+		best := 0.0
+		for _, other := range longer {
+			best = max(best, jaroWinkler(word, other))
+		}
+		total += best
+	}
+	perWord := total/float64(len(shorter)) - missingWordPenalty*float64(len(longer)-len(shorter))
+	return max(whole, perWord)
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 to 1.
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	window := max(len(s), len(t))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedS := make([]bool, len(s))
+	matchedT := make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		start, end := max(0, i-window), min(len(t), i+window+1)
+		for j := start; j < end; j++ {
+			if matchedT[j] || s[i] != t[j] {
+				continue
+			}
+			matchedS[i], matchedT[j] = true, true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range s {
+		if !matchedS[i] {
+			continue
+		}
+		for !matchedT[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// compareDateOfBirth compares a YYYY-MM-DD date of birth with the dates of an
+// entry, which may only give the year.
+func compareDateOfBirth(dob string, dates []string) string {
+	if len(dob) < 4 || len(dates) == 0 {
+		return ""
+	}
+	for _, date := range dates {
+		if date == dob || (len(date) == 4 && strings.HasPrefix(dob, date)) {
+			return "match"
+		}
+	}
+	return "mismatch"
+}
+
+// compareNationality compares a country code with the nationalities of an
+// entry. Lists that give country names instead of codes cannot contradict the
+// subject, only confirm it.
+func compareNationality(code string, nationalities []string) string {
+	if code == "" || len(nationalities) == 0 {
+		return ""
+	}
+	comparable := false
+	for _, nationality := range nationalities {
+		if strings.EqualFold(nationality, code) {
+			return "match"
+		}
+		if len(nationality) == 2 {
+			comparable = true
+		}
+	}
+	if comparable {
+		return "mismatch"
+	}
+	return ""
+}
+
+func clamp(score float64) float64 {
+	return min(max(score, 0), 1)
+}
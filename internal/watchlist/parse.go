@@ -0,0 +1,183 @@
+// Package watchlist parses sanctions and watchlist files and fuzzy-matches
+// people against their entries.
+package watchlist
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entry is a listed person.
+type Entry struct {
+	List          string   // List the entry belongs to; not set by the parsers
+	ID            string   // Identifier of the entry in its list
+	Name          string   // Primary name
+	Aliases       []string // Other names the person is known by
+	DatesOfBirth  []string // YYYY-MM-DD, or YYYY when only the year is known
+	Nationalities []string // ISO country codes or country names
+}
+
+// ErrNoEntries is returned when a file contains no usable entries.
+var ErrNoEntries = errors.New("watchlist contains no entries")
+
+// csvColumns maps the accepted CSV header names to entry fields.
+var csvColumns = map[string]string{
+	"id":            "id",
+	"uid":           "id",
+	"reference":     "id",
+	"name":          "name",
+	"full_name":     "name",
+	"aliases":       "aliases",
+	"aka":           "aliases",
+	"dob":           "dob",
+	"date_of_birth": "dob",
+	"nationality":   "nationality",
+	"nationalities": "nationality",
+	"country":       "nationality",
+}
+
+// ParseCSV reads a CSV list with a header row. The id and name columns are
+// required; aliases, dates of birth and nationalities may hold several values
+// separated by semicolons.
+func ParseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[key]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["id"]; !ok {
+		return nil, fmt.Errorf("missing id column")
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("missing name column")
+	}
+
+	value := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	var entries []Entry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entry := Entry{
+			ID:            value(record, "id"),
+			Name:          value(record, "name"),
+			Aliases:       splitValues(value(record, "aliases")),
+			DatesOfBirth:  splitValues(value(record, "dob")),
+			Nationalities: splitValues(value(record, "nationality")),
+		}
+		if entry.ID == "" || entry.Name == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoEntries
+	}
+	return entries, nil
+}
+
+func splitValues(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// consolidatedList is the XML layout of the UN Security Council consolidated list.
+type consolidatedList struct {
+	Individuals []struct {
+		DataID      string `xml:"DATAID"`
+		Reference   string `xml:"REFERENCE_NUMBER"`
+		FirstName   string `xml:"FIRST_NAME"`
+		SecondName  string `xml:"SECOND_NAME"`
+		ThirdName   string `xml:"THIRD_NAME"`
+		FourthName  string `xml:"FOURTH_NAME"`
+		Nationality []struct {
+			Values []string `xml:"VALUE"`
+		} `xml:"NATIONALITY"`
+		Aliases []struct {
+			Name string `xml:"ALIAS_NAME"`
+		} `xml:"INDIVIDUAL_ALIAS"`
+		DatesOfBirth []struct {
+			Date string `xml:"DATE"`
+			Year string `xml:"YEAR"`
+		} `xml:"INDIVIDUAL_DATE_OF_BIRTH"`
+	} `xml:"INDIVIDUALS>INDIVIDUAL"`
+}
+
+// ParseXML reads the individuals of a consolidated list in the UN Security
+// Council XML layout.
+func ParseXML(r io.Reader) ([]Entry, error) {
+	var list consolidatedList
+	if err := xml.NewDecoder(r).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode list: %w", err)
+	}
+	var entries []Entry
+	for _, individual := range list.Individuals {
+		entry := Entry{ID: individual.Reference}
+		if entry.ID == "" {
+			entry.ID = individual.DataID
+		}
+		var names []string
+		for _, name := range []string{individual.FirstName, individual.SecondName, individual.ThirdName, individual.FourthName} {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		entry.Name = strings.Join(names, " ")
+		for _, alias := range individual.Aliases {
+			if name := strings.TrimSpace(alias.Name); name != "" {
+				entry.Aliases = append(entry.Aliases, name)
+			}
+		}
+		for _, dob := range individual.DatesOfBirth {
+			switch {
+			case dob.Date != "":
+				entry.DatesOfBirth = append(entry.DatesOfBirth, strings.TrimSpace(dob.Date))
+			case dob.Year != "":
+				entry.DatesOfBirth = append(entry.DatesOfBirth, strings.TrimSpace(dob.Year))
+			}
+		}
+		for _, nationality := range individual.Nationality {
+			for _, value := range nationality.Values {
+				if value = strings.TrimSpace(value); value != "" {
+					entry.Nationalities = append(entry.Nationalities, value)
+				}
+			}
+		}
+		if entry.ID == "" || entry.Name == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoEntries
+	}
+	return entries, nil
+}
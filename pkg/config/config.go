@@ -4,6 +4,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -17,6 +19,70 @@ type Config struct {
 	SecureAPIBaseURL  string
 	Port              string
 	SettlementAccount string
+	Timezone          string // IANA timezone used for spending limit windows
+	HoldExpiryDays    int    // Days after which an unsettled authorization hold is released
+
+	// Stand-in authorization while the issuer balance API is unavailable
+	StandInCaps          map[string]int64 // Highest amount approved in stand-in per channel
+	StandInDefaultCap    int64            // Cap for channels without their own; 0 disables stand-in for them
+	StandInMaxAgeMinutes int              // Oldest cached balance stand-in may rely on
+	BreakerFailures      int              // Consecutive balance failures that open the circuit breaker
+	BreakerCooldownSecs  int              // Seconds the breaker stays open before a trial call
+
+	IssuerTimeoutMs  int    // Timeout of every HTTP call to the issuer
+	DecisionBudgetMs int    // Time allowed to answer an authorization request
+	DecisionFallback string // Decision returned when the budget is exceeded: approve or decline
+
+	RulesReloadSecs int // Interval at which authorization rules are reloaded from the database
+
+	// Velocity and fraud heuristics
+	VelocityWindowMinutes int              // Window of the frequency, card testing and merchant spread checks
+	VelocityMaxCount      int              // Authorizations allowed per card in the window
+	VelocityMaxDeclines   int              // Declines in the window after which a small amount looks like card testing
+	VelocitySmallAmount   int64            // Amount at or below which an authorization counts as small
+	VelocityMaxMerchants  int              // Distinct merchants allowed per card in the window
+	GeoJumpMinutes        int              // Shortest plausible time between authorizations in different countries
+	RiskWeights           map[string]int64 // Score per velocity signal
+	RiskFlagScore         int64            // Risk score from which authorizations are flagged
+	RiskDeclineScore      int64            // Risk score from which authorizations are declined
+
+	// Transaction monitoring for compliance cases
+	MonitoringMinutes        int   // Interval between monitoring scans
+	StructuringThreshold     int64 // Reporting threshold deposits are kept under
+	StructuringMarginPercent int64 // Percentage under the threshold that counts as just under it
+	StructuringCount         int   // Deposits just under the threshold that open a case
+	StructuringDays          int   // Window of the structuring check
+	RapidMovementHours       int   // Window in which deposits and card spend are compared
+	RapidMovementMinAmount   int64 // Deposits below which rapid movement is ignored
+	RapidOutflowPercent      int64 // Share of the deposits spent in the window that opens a case
+	VolumeBaselineDays       int   // Days of history the daily volume is compared with
+	VolumeMultiplier         int64 // Multiple of the usual daily volume that opens a case
+	VolumeMinAmount          int64 // Daily volume below which unusual volume is ignored
+
+	// Sanctions screening, scores in percent
+	ScreeningReviewScore int // Match score from which onboarding waits for a manual review
+	ScreeningBlockScore  int // Match score from which onboarding is blocked
+	RescreenMinutes      int // Interval at which customers are rescreened against updated lists
+
+	// Limits per KYC tier, keyed by tier; 0 means no limit
+	KYCMaxBalance map[string]int64 // Highest balance of the funding account
+	KYCDailySpend map[string]int64 // Daily spend across the cards of a customer
+	KYCMaxCards   map[string]int64 // Cards a customer may link
+
+	// Issuer call outbox
+	OutboxIntervalSecs int    // Interval at which due outbox operations are dispatched
+	OutboxMaxAttempts  int    // Attempts before an outbox operation is failed
+	OutboxBackoffSecs  int    // Delay before the first retry of an operation, doubled on each one after
+	OutboxSecret       string // Secret the issuer requests of outbox operations are encrypted with
+
+	ControlsExpirySecs int // Interval at which ended temporary blocks and channel unlocks are removed
+
+	// Sandbox mode runs against the in-memory issuer simulator instead of Allawee
+	SandboxMode             bool
+	SimulatorWebhookURL     string // Where simulated webhooks are posted; defaults to this server
+	SimulatorLatencyMs      int    // Latency added to every simulated issuer call
+	SimulatorFailurePercent int    // Share of simulated issuer calls that fail
+	SimulatorOpeningBalance int64  // Balance of a new simulated sub account
 }
 
 // func Load() (*Config, error) {
@@ -50,23 +116,183 @@ func Load(logger *zap.Logger) (*Config, error) {
 		SecureAPIBaseURL:  os.Getenv("SECURE_API_BASE_URL"),
 		Port:              os.Getenv("PORT"),
 		SettlementAccount: settlementAccount,
+		Timezone:          getEnv("TIMEZONE", "Africa/Lagos"),
+		HoldExpiryDays:    getEnvInt(logger, "HOLD_EXPIRY_DAYS", 7),
+
+		StandInCaps:          parseCaps(logger, os.Getenv("STAND_IN_CAPS")),
+		StandInDefaultCap:    int64(getEnvInt(logger, "STAND_IN_DEFAULT_CAP", 0)),
+		StandInMaxAgeMinutes: getEnvInt(logger, "STAND_IN_MAX_AGE_MINUTES", 24*60),
+		BreakerFailures:      getEnvInt(logger, "BALANCE_BREAKER_FAILURES", 5),
+		BreakerCooldownSecs:  getEnvInt(logger, "BALANCE_BREAKER_COOLDOWN_SECONDS", 30),
+
+		IssuerTimeoutMs:  getEnvInt(logger, "ISSUER_HTTP_TIMEOUT_MS", 10000),
+		DecisionBudgetMs: getEnvInt(logger, "DECISION_BUDGET_MS", 2500),
+		DecisionFallback: getEnv("DECISION_FALLBACK", "decline"),
+
+		RulesReloadSecs: getEnvInt(logger, "RULES_RELOAD_SECONDS", 30),
+
+		VelocityWindowMinutes: getEnvInt(logger, "VELOCITY_WINDOW_MINUTES", 10),
+		VelocityMaxCount:      getEnvInt(logger, "VELOCITY_MAX_COUNT", 5),
+		VelocityMaxDeclines:   getEnvInt(logger, "VELOCITY_MAX_DECLINES", 3),
+		VelocitySmallAmount:   int64(getEnvInt(logger, "VELOCITY_SMALL_AMOUNT", 10000)),
+		VelocityMaxMerchants:  getEnvInt(logger, "VELOCITY_MAX_MERCHANTS", 4),
+		GeoJumpMinutes:        getEnvInt(logger, "GEO_JUMP_MINUTES", 120),
+		RiskWeights:           parseCaps(logger, os.Getenv("RISK_WEIGHTS")),
+		RiskFlagScore:         int64(getEnvInt(logger, "RISK_FLAG_SCORE", 40)),
+		RiskDeclineScore:      int64(getEnvInt(logger, "RISK_DECLINE_SCORE", 80)),
+
+		MonitoringMinutes:        getEnvInt(logger, "MONITORING_INTERVAL_MINUTES", 60),
+		StructuringThreshold:     int64(getEnvInt(logger, "STRUCTURING_THRESHOLD", 500000000)),
+		StructuringMarginPercent: int64(getEnvInt(logger, "STRUCTURING_MARGIN_PERCENT", 10)),
+		StructuringCount:         getEnvInt(logger, "STRUCTURING_COUNT", 3),
+		StructuringDays:          getEnvInt(logger, "STRUCTURING_DAYS", 7),
+		RapidMovementHours:       getEnvInt(logger, "RAPID_MOVEMENT_HOURS", 24),
+		RapidMovementMinAmount:   int64(getEnvInt(logger, "RAPID_MOVEMENT_MIN_AMOUNT", 100000000)),
+		RapidOutflowPercent:      int64(getEnvInt(logger, "RAPID_OUTFLOW_PERCENT", 80)),
+		VolumeBaselineDays:       getEnvInt(logger, "VOLUME_BASELINE_DAYS", 30),
+		VolumeMultiplier:         int64(getEnvInt(logger, "VOLUME_MULTIPLIER", 5)),
+		VolumeMinAmount:          int64(getEnvInt(logger, "VOLUME_MIN_AMOUNT", 50000000)),
+
+		ScreeningReviewScore: getEnvInt(logger, "SCREENING_REVIEW_SCORE", 85),
+		ScreeningBlockScore:  getEnvInt(logger, "SCREENING_BLOCK_SCORE", 95),
+		RescreenMinutes:      getEnvInt(logger, "RESCREEN_INTERVAL_MINUTES", 60),
+
+		KYCMaxBalance: tierCaps(logger, "KYC_MAX_BALANCE", map[string]int64{"tier-1": 30000000, "tier-2": 50000000, "tier-3": 0}),
+		KYCDailySpend: tierCaps(logger, "KYC_DAILY_SPEND", map[string]int64{"tier-1": 5000000, "tier-2": 20000000, "tier-3": 500000000}),
+		KYCMaxCards:   tierCaps(logger, "KYC_MAX_CARDS", map[string]int64{"tier-1": 1, "tier-2": 2, "tier-3": 5}),
+
+		OutboxIntervalSecs: getEnvInt(logger, "OUTBOX_INTERVAL_SECONDS", 15),
+		OutboxMaxAttempts:  getEnvInt(logger, "OUTBOX_MAX_ATTEMPTS", 8),
+		OutboxBackoffSecs:  getEnvInt(logger, "OUTBOX_BACKOFF_SECONDS", 30),
+		OutboxSecret:       os.Getenv("OUTBOX_SECRET"),
+
+		ControlsExpirySecs: getEnvInt(logger, "CONTROLS_EXPIRY_SECONDS", 60),
+
+		SandboxMode:             getEnv("SANDBOX_MODE", "false") == "true",
+		SimulatorWebhookURL:     os.Getenv("SIMULATOR_WEBHOOK_URL"),
+		SimulatorLatencyMs:      getEnvInt(logger, "SIMULATOR_LATENCY_MS", 0),
+		SimulatorFailurePercent: getEnvInt(logger, "SIMULATOR_FAILURE_PERCENT", 0),
+		SimulatorOpeningBalance: int64(getEnvInt(logger, "SIMULATOR_OPENING_BALANCE", 0)),
 	}
-	if cfg.CardAPIKey == "" {
+	if cfg.SimulatorWebhookURL == "" {
+		cfg.SimulatorWebhookURL = "http://localhost:" + cfg.Port + "/webhooks"
+	}
+	if cfg.OutboxMaxAttempts < 1 {
+		return nil, fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be at least 1, got %d", cfg.OutboxMaxAttempts)
+	}
+	if cfg.ScreeningReviewScore > cfg.ScreeningBlockScore {
+		return nil, fmt.Errorf("SCREENING_REVIEW_SCORE must not exceed SCREENING_BLOCK_SCORE")
+	}
+	if cfg.DecisionFallback != "approve" && cfg.DecisionFallback != "decline" {
+		return nil, fmt.Errorf("DECISION_FALLBACK must be approve or decline, got %q", cfg.DecisionFallback)
+	}
+	if cfg.SandboxMode {
+		logger.Warn("Sandbox mode: issuer calls are served by the in-memory simulator",
+			zap.String("webhookURL", cfg.SimulatorWebhookURL),
+			zap.Int("latencyMs", cfg.SimulatorLatencyMs),
+			zap.Int("failurePercent", cfg.SimulatorFailurePercent),
+			zap.Int64("openingBalance", cfg.SimulatorOpeningBalance),
+		)
+	} else if cfg.CardAPIKey == "" {
 		logger.Error("CARD_API_KEY is empty")
 		return nil, fmt.Errorf("CARD_API_KEY is required")
 	}
+	if cfg.OutboxSecret == "" {
+		if !cfg.SandboxMode {
+			return nil, fmt.Errorf("OUTBOX_SECRET is required")
+		}
+		// Simulated cards hold no real secrets
+		cfg.OutboxSecret = "sandbox"
+	}
 
 	keyHash := fmt.Sprintf("%x", sha256.Sum256([]byte(cfg.CardAPIKey)))
+	keyPrefix := cfg.CardAPIKey
+	if len(keyPrefix) > 4 {
+		keyPrefix = keyPrefix[:4]
+	}
 	logger.Info("Loaded configuration",
 		zap.String("databaseURL", cfg.DatabaseURL),
 		zap.String("webhookSigningKey", cfg.WebhookSigningKey),
-		zap.String("cardAPIKeyPrefix", cfg.CardAPIKey[:4]),
+		zap.String("cardAPIKeyPrefix", keyPrefix),
 		zap.Int("cardAPIKeyLength", len(cfg.CardAPIKey)), // Debug length
 		zap.String("cardAPIKeyHash", keyHash),
 		zap.String("cardAPIBaseURL", cfg.CardAPIBaseURL),
 		zap.String("secureAPIBaseURL", cfg.SecureAPIBaseURL),
 		zap.String("port", cfg.Port),
 		zap.String("settlementAccount", cfg.SettlementAccount),
+		zap.String("timezone", cfg.Timezone),
+		zap.Int("holdExpiryDays", cfg.HoldExpiryDays),
+		zap.Any("standInCaps", cfg.StandInCaps),
+		zap.Int64("standInDefaultCap", cfg.StandInDefaultCap),
+		zap.Int("issuerTimeoutMs", cfg.IssuerTimeoutMs),
+		zap.Int("decisionBudgetMs", cfg.DecisionBudgetMs),
+		zap.String("decisionFallback", cfg.DecisionFallback),
+		zap.Int("rulesReloadSecs", cfg.RulesReloadSecs),
+		zap.Any("riskWeights", cfg.RiskWeights),
+		zap.Int64("riskFlagScore", cfg.RiskFlagScore),
+		zap.Int64("riskDeclineScore", cfg.RiskDeclineScore),
+		zap.Int("monitoringMinutes", cfg.MonitoringMinutes),
+		zap.Int64("structuringThreshold", cfg.StructuringThreshold),
+		zap.Int("screeningReviewScore", cfg.ScreeningReviewScore),
+		zap.Int("screeningBlockScore", cfg.ScreeningBlockScore),
+		zap.Any("kycMaxBalance", cfg.KYCMaxBalance),
+		zap.Any("kycDailySpend", cfg.KYCDailySpend),
+		zap.Any("kycMaxCards", cfg.KYCMaxCards),
+		zap.Int("outboxIntervalSecs", cfg.OutboxIntervalSecs),
+		zap.Int("outboxMaxAttempts", cfg.OutboxMaxAttempts),
+		zap.Int("outboxBackoffSecs", cfg.OutboxBackoffSecs),
+		zap.Int("controlsExpirySecs", cfg.ControlsExpirySecs),
+		zap.Bool("sandboxMode", cfg.SandboxMode),
 	)
 	return cfg, nil
 }
+
+// getEnv returns the value of an environment variable or a fallback when it is unset.
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt returns an integer environment variable, or the fallback when it is unset or invalid.
+func getEnvInt(logger *zap.Logger, key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warn("Invalid integer in environment, using default", zap.String("key", key), zap.Int("default", fallback))
+		return fallback
+	}
+	return n
+}
+
+// parseCaps parses amounts per key written as "pos:50000,web:20000".
+func parseCaps(logger *zap.Logger, value string) map[string]int64 {
+	caps := make(map[string]int64)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		channel, amount, ok := strings.Cut(pair, ":")
+		n, err := strconv.ParseInt(strings.TrimSpace(amount), 10, 64)
+		if !ok || err != nil {
+			logger.Warn("Ignoring invalid amount", zap.String("value", pair))
+			continue
+		}
+		caps[strings.TrimSpace(channel)] = n
+	}
+	return caps
+}
+
+// tierCaps parses amounts per KYC tier from an environment variable written as
+// "tier-1:5000000,tier-2:20000000" over the defaults of the tiers it omits.
+func tierCaps(logger *zap.Logger, key string, defaults map[string]int64) map[string]int64 {
+	for tier, amount := range parseCaps(logger, os.Getenv(key)) {
+		defaults[tier] = amount
+	}
+	return defaults
+}